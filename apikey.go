@@ -0,0 +1,37 @@
+package gorsk
+
+import (
+	"time"
+)
+
+// APIKey represents long-lived key used for service-to-service access instead of user's JWT.
+// Key owned by a user (UserID is set) acts as that user, limited to Role. Key owned by a company acts
+// on behalf of the company with Role. Only the hash of the key is stored, the key itself is shown once on creation.
+type APIKey struct {
+	Base
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	Key    string `json:"key,omitempty" pg:"-"`
+	Hash   string `json:"-" pg:",unique"`
+
+	UserID     int        `json:"user_id,omitempty"`
+	CompanyID  int        `json:"company_id"`
+	LocationID int        `json:"location_id,omitempty"`
+	Role       AccessRole `json:"role"`
+
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the key can no longer be used. Keys without expiration never expire.
+func (k APIKey) Expired() bool {
+	return !k.ExpiresAt.IsZero() && !time.Now().Before(k.ExpiresAt)
+}
+
+// Redacted returns copy of the key safe for logging, without the key itself
+func (k APIKey) Redacted() APIKey {
+	if k.Key != "" {
+		k.Key = "[REDACTED]"
+	}
+	return k
+}
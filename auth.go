@@ -1,18 +1,150 @@
 package gorsk
 
 import (
+	"time"
+
 	"github.com/labstack/echo"
 )
 
-// AuthToken holds authentication token details with refresh token
+// AuthToken holds authentication token details with refresh token.
+// For users with two-factor authentication, only MFAToken is set until the second step of login is completed.
 type AuthToken struct {
-	Token        string `json:"token"`
-	RefreshToken string `json:"refresh_token"`
+	Token                 string   `json:"token,omitempty"`
+	RefreshToken          string   `json:"refresh_token,omitempty"`
+	MFAToken              string   `json:"mfa_token,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"`
+}
+
+// Redacted returns copy of the token safe for logging, with secrets replaced by placeholder
+func (t AuthToken) Redacted() AuthToken {
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "[REDACTED]"
+	}
+	t.Token = redact(t.Token)
+	t.RefreshToken = redact(t.RefreshToken)
+	t.MFAToken = redact(t.MFAToken)
+	if t.RecoveryCodes != nil {
+		codes := make([]string, len(t.RecoveryCodes))
+		for i, c := range t.RecoveryCodes {
+			codes[i] = redact(c)
+		}
+		t.RecoveryCodes = codes
+	}
+	return t
+}
+
+// JWK represents public JSON Web Key used for verifying access tokens, as defined by RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS represents JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Purposes of one-time tokens
+const (
+	TokenMFAChallenge      = "mfa_challenge"
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+	TokenOIDCLogin         = "oidc_login"
+	TokenMagicLink         = "magic_link"
+)
+
+// OneTimeToken represents a pending single-use token given out to a user, such as second step of login
+// with two-factor authentication or a password reset link. Purpose tells what the token can be used for,
+// and Token holds its hash. Data holds values needed to use the token, such as the PKCE verifier
+// of login through OpenID Connect identity provider, which has no user yet. Attempts holds the number
+// of wrong codes entered for a two-factor authentication challenge.
+type OneTimeToken struct {
+	ID        int               `json:"-"`
+	UserID    int               `json:"-"`
+	Purpose   string            `json:"-"`
+	Token     string            `json:"-" pg:",unique"`
+	Data      map[string]string `json:"-"`
+	Attempts  int               `json:"-" pg:",use_zero"`
+	ExpiresAt time.Time         `json:"-"`
+}
+
+// Expired reports whether the token can no longer be used
+func (t OneTimeToken) Expired() bool {
+	return !time.Now().Before(t.ExpiresAt)
 }
 
-// RefreshToken holds authentication token details
+// MFAEnrollment holds the secret of two-factor authentication being set up
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Session represents a single logged in device of a user.
+// Token holds the hash of the session's current refresh token, which is rotated on every refresh.
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	Token      string    `json:"-" pg:",unique"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the session can no longer be refreshed
+func (s Session) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+// Login methods recorded in login events
+const (
+	LoginPassword  = "password"
+	LoginMFA       = "mfa"
+	LoginRefresh   = "refresh"
+	LoginOIDC      = "oidc"
+	LoginMagicLink = "magic_link"
+)
+
+// LoginEvent represents a single login attempt of a user, or refresh of user's session
+type LoginEvent struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Success   bool      `json:"success" pg:",notnull,use_zero"`
+	Method    string    `json:"method"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RefreshToken represents a refresh token that was rotated out of a session.
+// Presenting it again means it was stolen, so the whole session gets revoked.
 type RefreshToken struct {
-	Token string `json:"token"`
+	ID        int       `json:"-"`
+	SessionID int       `json:"-"`
+	UserID    int       `json:"-"`
+	Token     string    `json:"-" pg:",unique"`
+	UsedAt    time.Time `json:"-"`
+}
+
+// RevokedToken represents a JWT that was revoked before its expiration, identified by its jti claim.
+// It can be removed once ExpiresAt passes, as the token is rejected anyway.
+type RevokedToken struct {
+	ID        int       `json:"-"`
+	JTI       string    `json:"-" pg:",unique"`
+	UserID    int       `json:"-"`
+	ExpiresAt time.Time `json:"-"`
 }
 
 // RBACService represents role-based access control service interface
@@ -24,4 +156,6 @@ type RBACService interface {
 	EnforceLocation(echo.Context, int) error
 	AccountCreate(echo.Context, AccessRole, int, int) error
 	IsLowerRole(echo.Context, AccessRole) error
+	Can(echo.Context, string, Resource) error
+	Scope(echo.Context, string) (*Resource, error)
 }
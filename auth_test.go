@@ -0,0 +1,67 @@
+package gorsk_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ribice/gorsk"
+)
+
+func TestSessionExpired(t *testing.T) {
+	cases := map[string]struct {
+		session gorsk.Session
+		want    bool
+	}{
+		"Without expiration": {
+			session: gorsk.Session{},
+		},
+		"Not expired": {
+			session: gorsk.Session{ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		"Expired": {
+			session: gorsk.Session{ExpiresAt: time.Now().Add(-time.Hour)},
+			want:    true,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if tt.session.Expired() != tt.want {
+				t.Errorf("Expected expired to be %v", tt.want)
+			}
+		})
+	}
+}
+
+func TestOneTimeTokenExpired(t *testing.T) {
+	if (gorsk.OneTimeToken{ExpiresAt: time.Now().Add(time.Minute)}).Expired() {
+		t.Error("Pending token reported as expired")
+	}
+	if !(gorsk.OneTimeToken{ExpiresAt: time.Now().Add(-time.Minute)}).Expired() {
+		t.Error("Expired token reported as pending")
+	}
+}
+
+func TestAuthTokenRedacted(t *testing.T) {
+	token := gorsk.AuthToken{
+		Token:                 "jwttoken",
+		RefreshToken:          "refreshtoken",
+		MFAEnrollmentRequired: true,
+		RecoveryCodes:         []string{"code1", "code2"},
+	}
+	want := gorsk.AuthToken{
+		Token:                 "[REDACTED]",
+		RefreshToken:          "[REDACTED]",
+		MFAEnrollmentRequired: true,
+		RecoveryCodes:         []string{"[REDACTED]", "[REDACTED]"},
+	}
+	if got := token.Redacted(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if token.RecoveryCodes[0] != "code1" {
+		t.Error("Redacting modified original token")
+	}
+	if got := (gorsk.AuthToken{MFAToken: "mfatoken"}).Redacted(); !reflect.DeepEqual(got, gorsk.AuthToken{MFAToken: "[REDACTED]"}) {
+		t.Errorf("Expected redacted MFA token, got %v", got)
+	}
+}
@@ -15,12 +15,7 @@ import (
 
 func main() {
 	dbInsert := `INSERT INTO public.companies VALUES (1, now(), now(), NULL, 'admin_company', true);
-	INSERT INTO public.locations VALUES (1, now(), now(), NULL, 'admin_location', true, 'admin_address', 1);
-	INSERT INTO public.roles VALUES (100, 100, 'SUPER_ADMIN');
-	INSERT INTO public.roles VALUES (110, 110, 'ADMIN');
-	INSERT INTO public.roles VALUES (120, 120, 'COMPANY_ADMIN');
-	INSERT INTO public.roles VALUES (130, 130, 'LOCATION_ADMIN');
-	INSERT INTO public.roles VALUES (200, 200, 'USER');`
+	INSERT INTO public.locations VALUES (1, now(), now(), NULL, 'admin_location', true, 'admin_address', 1);`
 	var psn = os.Getenv("DATABASE_URL")
 	queries := strings.Split(dbInsert, ";")
 
@@ -29,16 +24,32 @@ func main() {
 	db := pg.Connect(u)
 	_, err = db.Exec("SELECT 1")
 	checkErr(err)
-	createSchema(db, &gorsk.Company{}, &gorsk.Location{}, &gorsk.Role{}, &gorsk.User{})
+	createSchema(db, &gorsk.Company{}, &gorsk.Location{}, &gorsk.Role{}, &gorsk.User{}, &gorsk.Session{}, &gorsk.RefreshToken{}, &gorsk.RevokedToken{}, &gorsk.OneTimeToken{}, &gorsk.LoginEvent{}, &gorsk.APIKey{})
 
 	for _, v := range queries[0 : len(queries)-1] {
 		_, err := db.Exec(v)
 		checkErr(err)
 	}
 
-	sec := secure.New(1, nil)
+	roles := []gorsk.Role{
+		{ID: gorsk.SuperAdminRole, Name: "SUPER_ADMIN"},
+		{ID: gorsk.AdminRole, Name: "ADMIN"},
+		{ID: gorsk.CompanyAdminRole, Name: "COMPANY_ADMIN"},
+		{ID: gorsk.LocationAdminRole, Name: "LOCATION_ADMIN"},
+		{ID: gorsk.UserRole, Name: "USER"},
+	}
+	for _, role := range roles {
+		role.AccessLevel = role.ID
+		role.Permissions = gorsk.DefaultPermissions[role.ID]
+		checkErr(db.Insert(&role))
+	}
+	// custom roles get IDs clear of the built-in ones
+	_, err = db.Exec("SELECT setval('roles_id_seq', 1000, false)")
+	checkErr(err)
+
+	sec := secure.New(1)
 
-	userInsert := `INSERT INTO public.users (id, created_at, updated_at, first_name, last_name, username, password, email, active, role_id, company_id, location_id) VALUES (1, now(),now(),'Admin', 'Admin', 'admin', '%s', 'johndoe@mail.com', true, 100, 1, 1);`
+	userInsert := `INSERT INTO public.users (id, created_at, updated_at, first_name, last_name, username, password, email, active, email_verified_at, role_id, company_id, location_id) VALUES (1, now(),now(),'Admin', 'Admin', 'admin', '%s', 'johndoe@mail.com', true, now(), 100, 1, 1);`
 	_, err = db.Exec(fmt.Sprintf(userInsert, sec.Hash("admin")))
 	checkErr(err)
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/ribice/gorsk"
+
+	"github.com/ribice/gorsk/pkg/api/auth/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/config"
+	"github.com/ribice/gorsk/pkg/utl/postgres"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
+)
+
+const usage = `Usage: policy explain [flags]
+
+Explains whether the RBAC policy, configured by application.policy_path, allows a user to perform an action on a resource, and why.
+The user is loaded from the database given by DATABASE_URL, along with the company and location of the user the resource belongs to,
+as the API does when authorizing the request.
+
+Example:
+  policy explain -user 2 -action users:view -resource-user 5
+
+Flags:
+`
+
+func main() {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if len(os.Args) < 2 || os.Args[1] != "explain" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfgPath := fs.String("p", "./cmd/api/conf.local.yaml", "Path to config file")
+	userID := fs.Int("user", 0, "ID of user performing the action")
+	action := fs.String("action", "", "Permission to check, e.g. users:view")
+	resUserID := fs.Int("resource-user", 0, "ID of user the resource belongs to, if known")
+	resCompanyID := fs.Int("resource-company", 0, "ID of company the resource belongs to, if known")
+	resLocationID := fs.Int("resource-location", 0, "ID of location the resource belongs to, if known")
+	resRole := fs.String("resource-role", "", "Access level of role the resource grants, as built-in role name or number, if known")
+	checkErr(fs.Parse(os.Args[2:]))
+
+	if *userID == 0 {
+		checkErr(fmt.Errorf("user is required"))
+	}
+	if *action == "" {
+		checkErr(fmt.Errorf("action is required"))
+	}
+	var level gorsk.AccessRole
+	if *resRole != "" {
+		var ok bool
+		if level, ok = rbac.ParseRole(*resRole); !ok {
+			checkErr(fmt.Errorf("unknown role %s", *resRole))
+		}
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	checkErr(err)
+	if cfg.App == nil || cfg.App.PolicyPath == "" {
+		checkErr(fmt.Errorf("policy_path is not set in %s", *cfgPath))
+	}
+	policy, err := rbac.LoadPolicy(cfg.App.PolicyPath)
+	checkErr(err)
+
+	db, err := postgres.New(os.Getenv("DATABASE_URL"), cfg.DB.Timeout, cfg.DB.LogQueries)
+	checkErr(err)
+	defer db.Close()
+
+	u, err := pgsql.User{}.View(db, *userID)
+	if err == pg.ErrNoRows {
+		err = fmt.Errorf("user %d does not exist", *userID)
+	}
+	checkErr(err)
+	if u.Role == nil {
+		checkErr(fmt.Errorf("role %d of user %d does not exist", u.RoleID, u.ID))
+	}
+	if !u.Active {
+		fmt.Printf("user %d is not active and cannot log in\n", u.ID)
+	}
+
+	d, err := rbac.Initialize(db, policy).Explain(
+		gorsk.AuthUser{ID: u.ID, CompanyID: u.CompanyID, LocationID: u.LocationID, Role: u.Role.AccessLevel, RoleID: u.RoleID},
+		*action,
+		gorsk.Resource{UserID: *resUserID, CompanyID: *resCompanyID, LocationID: *resLocationID, Role: level},
+	)
+	checkErr(err)
+	for _, t := range d.Trace {
+		fmt.Println(t)
+	}
+	fmt.Println(d)
+}
+
+func checkErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
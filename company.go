@@ -7,4 +7,7 @@ type Company struct {
 	Active    bool       `json:"active"`
 	Locations []Location `json:"locations,omitempty"`
 	Owner     User       `json:"owner"`
+
+	// MagicLinkLogin allows company's users to log in by a link sent to their email, without password
+	MagicLinkLogin bool `json:"magic_link_login" pg:",notnull,default:false,use_zero"`
 }
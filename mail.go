@@ -0,0 +1,7 @@
+package gorsk
+
+// Mailer represents email sending interface
+type Mailer interface {
+	// to, subject, body
+	Send(string, string, string) error
+}
@@ -21,39 +21,63 @@
 //
 //     Security:
 //     - bearer: []
+//     - apiKey: []
 //
 //     SecurityDefinitions:
 //     bearer:
 //          type: apiKey
 //          name: Authorization
 //          in: header
+//     apiKey:
+//          type: apiKey
+//          name: X-API-Key
+//          in: header
 //
 // swagger:meta
 package api
 
 import (
-	"crypto/sha1"
+	"errors"
+	"io/ioutil"
 	"os"
+	"syscall"
+	"time"
 
+	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/utl/zlog"
 
+	"github.com/ribice/gorsk/pkg/api/apikey"
+	kl "github.com/ribice/gorsk/pkg/api/apikey/logging"
+	kt "github.com/ribice/gorsk/pkg/api/apikey/transport"
 	"github.com/ribice/gorsk/pkg/api/auth"
 	al "github.com/ribice/gorsk/pkg/api/auth/logging"
 	at "github.com/ribice/gorsk/pkg/api/auth/transport"
+	"github.com/ribice/gorsk/pkg/api/company"
+	cl "github.com/ribice/gorsk/pkg/api/company/logging"
+	ct "github.com/ribice/gorsk/pkg/api/company/transport"
+	"github.com/ribice/gorsk/pkg/api/location"
+	ll "github.com/ribice/gorsk/pkg/api/location/logging"
+	lt "github.com/ribice/gorsk/pkg/api/location/transport"
 	"github.com/ribice/gorsk/pkg/api/password"
 	pl "github.com/ribice/gorsk/pkg/api/password/logging"
 	pt "github.com/ribice/gorsk/pkg/api/password/transport"
+	"github.com/ribice/gorsk/pkg/api/role"
+	rl "github.com/ribice/gorsk/pkg/api/role/logging"
+	rt "github.com/ribice/gorsk/pkg/api/role/transport"
 	"github.com/ribice/gorsk/pkg/api/user"
 	ul "github.com/ribice/gorsk/pkg/api/user/logging"
 	ut "github.com/ribice/gorsk/pkg/api/user/transport"
 
 	"github.com/ribice/gorsk/pkg/utl/config"
 	"github.com/ribice/gorsk/pkg/utl/jwt"
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	authMw "github.com/ribice/gorsk/pkg/utl/middleware/auth"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
 	"github.com/ribice/gorsk/pkg/utl/postgres"
 	"github.com/ribice/gorsk/pkg/utl/rbac"
 	"github.com/ribice/gorsk/pkg/utl/secure"
 	"github.com/ribice/gorsk/pkg/utl/server"
+	"github.com/ribice/gorsk/pkg/utl/totp"
 )
 
 // Start starts the API service
@@ -63,27 +87,97 @@ func Start(cfg *config.Configuration) error {
 		return err
 	}
 
-	sec := secure.New(cfg.App.MinPasswordStr, sha1.New())
-	rbac := rbac.Service{}
-	jwt, err := jwt.New(cfg.JWT.SigningAlgorithm, os.Getenv("JWT_SECRET"), cfg.JWT.DurationMinutes, cfg.JWT.MinSecretLength)
+	sec := secure.New(cfg.App.MinPasswordStr)
+	var policy *rbac.Policy
+	if cfg.App.PolicyPath != "" {
+		if policy, err = rbac.LoadPolicy(cfg.App.PolicyPath); err != nil {
+			return err
+		}
+	}
+	rbac := rbac.Initialize(db, policy)
+	activeKey, err := jwtKey(cfg.JWT.KeyID, cfg.JWT.SigningAlgorithm, "JWT_SECRET", cfg.JWT.PrivateKeyPath)
+	if err != nil {
+		return err
+	}
+	retiredKeys := make([]jwt.Key, len(cfg.JWT.RetiredKeys))
+	for i, k := range cfg.JWT.RetiredKeys {
+		if retiredKeys[i], err = jwtKey(k.ID, k.SigningAlgorithm, k.SecretEnv, k.KeyPath); err != nil {
+			return err
+		}
+	}
+	jwt, err := jwt.NewKeyring(activeKey, retiredKeys, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.DurationMinutes, cfg.JWT.MinSecretLength)
 	if err != nil {
 		return err
 	}
 
 	log := zlog.New()
 
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		return err
+	}
+
 	e := server.New()
 	e.Static("/swaggerui", cfg.App.SwaggerUIPath)
+	if policy != nil {
+		policy.ReloadOnSignal(func(err error) {
+			if err != nil {
+				e.Logger.Error(err)
+				return
+			}
+			e.Logger.Info("RBAC policy reloaded")
+		}, syscall.SIGHUP)
+	}
 
-	authMiddleware := authMw.Middleware(jwt)
+	var idp auth.OIDCProvider
+	oidcCfg := new(config.OIDC)
+	if cfg.OIDC != nil && cfg.OIDC.Issuer != "" {
+		oidcCfg = cfg.OIDC
+		idp = oidc.New(oidcCfg.Issuer, oidcCfg.ClientID, os.Getenv("OIDC_CLIENT_SECRET"), oidcCfg.RedirectURL, oidcCfg.Scopes, nil)
+	}
+
+	authSvc := auth.Initialize(db, jwt, sec, rbac, totp.New(cfg.App.MFAIssuer, time.Now), idp, mailer, auth.Config{
+		RefreshDuration:       time.Duration(cfg.JWT.RefreshDuration) * time.Minute,
+		MaxRefresh:            time.Duration(cfg.JWT.MaxRefresh) * time.Minute,
+		MaxLoginAttempts:      cfg.App.MaxLoginAttempts,
+		LockoutDuration:       time.Duration(cfg.App.LockoutDuration) * time.Minute,
+		MaxLockoutDuration:    time.Duration(cfg.App.MaxLockoutDuration) * time.Minute,
+		ForceMFARole:          gorsk.AccessRole(cfg.App.ForceMFARole),
+		RequireVerifiedEmail:  cfg.App.RequireVerifiedEmail,
+		OIDCDefaultRole:       gorsk.AccessRole(oidcCfg.DefaultRole),
+		OIDCDefaultCompanyID:  oidcCfg.DefaultCompanyID,
+		OIDCDefaultLocationID: oidcCfg.DefaultLocationID,
+		ImpersonationDuration: time.Duration(cfg.JWT.Impersonation) * time.Minute,
+		MagicLinkURL:          cfg.App.MagicLinkURL,
+		MagicLinkDuration:     time.Duration(cfg.App.MagicLinkDuration) * time.Minute,
+		NotifyNewLogin:        cfg.App.NotifyNewLogin,
+	})
+	var cookies *authMw.Cookies
+	if cfg.Cookie != nil && cfg.Cookie.Enabled {
+		if cookies, err = authMw.NewCookies(cfg.Cookie.Domain, cfg.Cookie.SameSite, time.Duration(cfg.JWT.MaxRefresh)*time.Minute); err != nil {
+			return err
+		}
+	}
+	keySvc := apikey.Initialize(db, rbac, sec)
+	authMiddleware := authMw.Middleware(jwt, authSvc, cookies, keySvc)
 
-	at.NewHTTP(al.New(auth.Initialize(db, jwt, sec, rbac), log), e, authMiddleware)
+	at.NewHTTP(al.New(authSvc, log), e, authMiddleware, cookies, cfg.JWT.LegacyRefresh)
 
 	v1 := e.Group("/v1")
 	v1.Use(authMiddleware)
 
-	ut.NewHTTP(ul.New(user.Initialize(db, rbac, sec), log), v1)
-	pt.NewHTTP(pl.New(password.Initialize(db, rbac, sec), log), v1)
+	ut.NewHTTP(ul.New(user.Initialize(db, rbac, sec, mailer, user.Config{
+		VerifyURL:      cfg.App.VerificationURL,
+		VerifyDuration: time.Duration(cfg.App.VerificationDuration) * time.Minute,
+	}), log), e, v1)
+	pt.NewHTTP(pl.New(password.Initialize(db, rbac, sec, mailer, password.Config{
+		ResetURL:      cfg.App.PasswordResetURL,
+		ResetDuration: time.Duration(cfg.App.PasswordResetDuration) * time.Minute,
+	}), log), e, v1)
+	ct.NewHTTP(cl.New(company.Initialize(db, rbac), log), v1)
+	lt.NewHTTP(ll.New(location.Initialize(db, rbac), log), v1)
+	kt.NewHTTP(kl.New(keySvc, log), v1)
+	rt.NewHTTP(rl.New(role.Initialize(db, rbac), log), v1)
 
 	server.Start(e, &server.Config{
 		Port:                cfg.Server.Port,
@@ -94,3 +188,28 @@ func Start(cfg *config.Configuration) error {
 
 	return nil
 }
+
+// newMailer creates mailer sending emails through SMTP server. Writing emails to files instead,
+// meant for development only, has to be enabled by setting the directory explicitly.
+func newMailer(cfg *config.Mail) (gorsk.Mailer, error) {
+	switch {
+	case cfg == nil:
+	case cfg.SMTPHost != "":
+		return mail.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, os.Getenv("SMTP_PASSWORD"), cfg.From), nil
+	case cfg.Dir != "":
+		return mail.NewFile(cfg.Dir, cfg.From), nil
+	}
+	return nil, errors.New("mail is not configured, set mail.smtp_host, or mail.dir to write emails to files in development")
+}
+
+// jwtKey loads jwt key from PEM file at path, or from env var secretEnv if path is not set
+func jwtKey(id, algo, secretEnv, path string) (jwt.Key, error) {
+	if path == "" {
+		return jwt.Key{ID: id, Algorithm: algo, Key: os.Getenv(secretEnv)}, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+	return jwt.Key{ID: id, Algorithm: algo, Key: string(b)}, nil
+}
@@ -0,0 +1,211 @@
+// Package apikey contains API key application services
+package apikey
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/query"
+)
+
+// Custom errors
+var (
+	ErrInvalidKey        = echo.NewHTTPError(http.StatusUnauthorized, "API key is invalid")
+	ErrKeyExpired        = echo.NewHTTPError(http.StatusUnauthorized, "API key has expired")
+	ErrKeyNotAllowed     = echo.NewHTTPError(http.StatusForbidden, "API keys cannot be managed using an API key")
+	ErrImpersonated      = echo.NewHTTPError(http.StatusForbidden, "API keys cannot be created while impersonating a user")
+	ErrInvalidRole       = echo.NewHTTPError(http.StatusBadRequest, "Company API keys cannot have role above company admin")
+	ErrUnknownRole       = echo.NewHTTPError(http.StatusBadRequest, "API key role must be the access level of a built-in role")
+	ErrInvalidExpiration = echo.NewHTTPError(http.StatusBadRequest, "Expiration must be in the future")
+)
+
+const (
+	// keyPrefix makes API keys recognizable, e.g. by secret scanners
+	keyPrefix = "gsk_"
+	// prefixLen is the length of the part of the key stored in plain text, used to identify the key
+	prefixLen = len(keyPrefix) + 8
+	// lastUsedInterval limits how often key's last usage is written to database
+	lastUsedInterval = time.Minute
+)
+
+// Create contains details of API key to create.
+// If CompanyID is set, the key is owned by that company, otherwise it is owned by the requesting user.
+type Create struct {
+	Name      string
+	Role      gorsk.AccessRole
+	CompanyID int
+	ExpiresAt time.Time
+}
+
+// Create creates a new API key. The returned key is the only time it is available in plain text.
+// By default rules, the key cannot have higher role than the requesting user. Its role must be one of the built-in access levels,
+// as the request made with the key gets the permissions of the built-in role with that level.
+func (k APIKey) Create(c echo.Context, req Create) (gorsk.APIKey, error) {
+	au := k.rbac.User(c)
+	if au.APIKeyID != 0 {
+		return gorsk.APIKey{}, ErrKeyNotAllowed
+	}
+	if au.ImpersonatorID != 0 {
+		return gorsk.APIKey{}, ErrImpersonated
+	}
+	if _, ok := gorsk.DefaultPermissions[req.Role]; !ok {
+		return gorsk.APIKey{}, ErrUnknownRole
+	}
+	res := gorsk.Resource{UserID: au.ID, Role: req.Role}
+	if req.CompanyID != 0 {
+		res = gorsk.Resource{CompanyID: req.CompanyID, Role: req.Role}
+	}
+	if err := k.rbac.Can(c, gorsk.PermissionAPIKeysCreate, res); err != nil {
+		return gorsk.APIKey{}, err
+	}
+	if !req.ExpiresAt.IsZero() && !req.ExpiresAt.After(time.Now()) {
+		return gorsk.APIKey{}, ErrInvalidExpiration
+	}
+
+	key := gorsk.APIKey{
+		Name:      req.Name,
+		Role:      req.Role,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.CompanyID != 0 {
+		if req.Role < gorsk.CompanyAdminRole {
+			return gorsk.APIKey{}, ErrInvalidRole
+		}
+		key.CompanyID = req.CompanyID
+	} else {
+		key.UserID = au.ID
+		key.CompanyID = au.CompanyID
+		key.LocationID = au.LocationID
+	}
+
+	token, err := k.sec.Token()
+	if err != nil {
+		return gorsk.APIKey{}, err
+	}
+	plain := keyPrefix + token
+	key.Prefix = plain[:prefixLen]
+	key.Hash = k.sec.HashToken(plain)
+
+	key, err = k.kdb.Create(k.db, key)
+	if err != nil {
+		return gorsk.APIKey{}, err
+	}
+	key.Key = plain
+	return key, nil
+}
+
+// List returns list of API keys. Admins see all keys, company and location admins keys of their company or location,
+// others only their own keys.
+func (k APIKey) List(c echo.Context, p gorsk.Pagination) ([]gorsk.APIKey, error) {
+	sc, err := k.rbac.Scope(c, gorsk.PermissionAPIKeysView)
+	if err != nil {
+		return nil, err
+	}
+	return k.kdb.List(k.db, query.APIKeys(sc), p)
+}
+
+// Revoke revokes an API key. By default rules, user's keys can be revoked by the user and admins of the user's company or location,
+// company's keys by company admins.
+func (k APIKey) Revoke(c echo.Context, id int) error {
+	if k.rbac.User(c).APIKeyID != 0 {
+		return ErrKeyNotAllowed
+	}
+	key, err := k.kdb.View(k.db, id)
+	if err != nil {
+		return err
+	}
+	if err := k.rbac.Can(c, gorsk.PermissionAPIKeysRevoke, gorsk.Resource{UserID: key.UserID, CompanyID: key.CompanyID, LocationID: key.LocationID}); err != nil {
+		return err
+	}
+	return k.kdb.Delete(k.db, key)
+}
+
+// Authenticate returns the user on whose behalf the request with the API key is made.
+// User's keys stop working once the user is deactivated or deleted, and never grant higher role than the user currently has.
+// Company's keys stop working once the company is deactivated or deleted.
+func (k APIKey) Authenticate(c echo.Context, plain string) (gorsk.AuthUser, error) {
+	key, err := k.kdb.FindByHash(k.db, k.sec.HashToken(plain))
+	if err == pg.ErrNoRows {
+		return gorsk.AuthUser{}, ErrInvalidKey
+	}
+	if err != nil {
+		return gorsk.AuthUser{}, err
+	}
+	if key.Expired() {
+		return gorsk.AuthUser{}, ErrKeyExpired
+	}
+
+	var au gorsk.AuthUser
+	if key.UserID != 0 {
+		if au, err = k.keyUser(key); err != nil {
+			return gorsk.AuthUser{}, err
+		}
+	} else {
+		if au, err = k.keyCompany(key); err != nil {
+			return gorsk.AuthUser{}, err
+		}
+	}
+	au.APIKeyID = key.ID
+
+	if time.Since(key.LastUsedAt) > lastUsedInterval {
+		key.LastUsedAt = time.Now()
+		if err := k.kdb.UpdateLastUsed(k.db, key); err != nil {
+			return gorsk.AuthUser{}, err
+		}
+	}
+
+	return au, nil
+}
+
+func (k APIKey) keyUser(key gorsk.APIKey) (gorsk.AuthUser, error) {
+	u, err := k.kdb.ViewUser(k.db, key.UserID)
+	if err == pg.ErrNoRows {
+		return gorsk.AuthUser{}, ErrInvalidKey
+	}
+	if err != nil {
+		return gorsk.AuthUser{}, err
+	}
+	if !u.Active || u.Role == nil {
+		return gorsk.AuthUser{}, ErrInvalidKey
+	}
+	au := gorsk.AuthUser{
+		ID:         u.ID,
+		CompanyID:  u.CompanyID,
+		LocationID: u.LocationID,
+		Username:   u.Username,
+		Email:      u.Email,
+		Role:       key.Role,
+		RoleID:     key.Role,
+	}
+	// key with the access level of user's role or higher gets the permissions of user's role,
+	// otherwise those of the built-in role of key's access level
+	if u.Role.AccessLevel >= key.Role {
+		au.Role = u.Role.AccessLevel
+		au.RoleID = u.RoleID
+	}
+	return au, nil
+}
+
+func (k APIKey) keyCompany(key gorsk.APIKey) (gorsk.AuthUser, error) {
+	cmp, err := k.kdb.ViewCompany(k.db, key.CompanyID)
+	if err == pg.ErrNoRows {
+		return gorsk.AuthUser{}, ErrInvalidKey
+	}
+	if err != nil {
+		return gorsk.AuthUser{}, err
+	}
+	if !cmp.Active {
+		return gorsk.AuthUser{}, ErrInvalidKey
+	}
+	return gorsk.AuthUser{
+		CompanyID:  key.CompanyID,
+		LocationID: key.LocationID,
+		Username:   "apikey:" + key.Prefix,
+		Role:       key.Role,
+		RoleID:     key.Role,
+	}, nil
+}
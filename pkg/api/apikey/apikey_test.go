@@ -0,0 +1,440 @@
+package apikey_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sec = &mock.Secure{
+	TokenFn: func() (string, error) {
+		return "0123456789abcdef", nil
+	},
+	HashTokenFn: func(s string) string {
+		return "hash:" + s
+	},
+}
+
+func rbacUser(u gorsk.AuthUser) func(echo.Context) gorsk.AuthUser {
+	return func(echo.Context) gorsk.AuthUser {
+		return u
+	}
+}
+
+func TestCreate(t *testing.T) {
+	user := gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Username: "johndoe", Role: gorsk.CompanyAdminRole}
+	can := func(want gorsk.Resource) func(echo.Context, string, gorsk.Resource) error {
+		return func(c echo.Context, p string, r gorsk.Resource) error {
+			if p != gorsk.PermissionAPIKeysCreate || r != want {
+				return echo.ErrForbidden
+			}
+			return nil
+		}
+	}
+	cases := []struct {
+		name     string
+		req      apikey.Create
+		wantErr  error
+		wantData gorsk.APIKey
+		kdb      *mockdb.APIKey
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on API key authentication",
+			req:  apikey.Create{Name: "ci", Role: gorsk.UserRole},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 1, Role: gorsk.UserRole, APIKeyID: 5}),
+			},
+			wantErr: apikey.ErrKeyNotAllowed,
+		},
+		{
+			name: "Fail on impersonation",
+			req:  apikey.Create{Name: "ci", Role: gorsk.UserRole},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 1, Role: gorsk.UserRole, ImpersonatorID: 4}),
+			},
+			wantErr: apikey.ErrImpersonated,
+		},
+		{
+			name: "Fail on level of no built-in role",
+			req:  apikey.Create{Name: "ci", Role: 125},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+			},
+			wantErr: apikey.ErrUnknownRole,
+		},
+		{
+			name: "Fail on higher role",
+			req:  apikey.Create{Name: "ci", Role: gorsk.AdminRole},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Fail on past expiration",
+			req:  apikey.Create{Name: "ci", Role: gorsk.UserRole, ExpiresAt: mock.TestTime(2000)},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn:  can(gorsk.Resource{UserID: 1, Role: gorsk.UserRole}),
+			},
+			wantErr: apikey.ErrInvalidExpiration,
+		},
+		{
+			name: "Fail on other company",
+			req:  apikey.Create{Name: "billing", Role: gorsk.UserRole, CompanyID: 7},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn:  can(gorsk.Resource{CompanyID: 2, Role: gorsk.UserRole}),
+			},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Fail on company key above company admin",
+			req:  apikey.Create{Name: "billing", Role: gorsk.AdminRole, CompanyID: 2},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 1, Role: gorsk.SuperAdminRole}),
+				CanFn:  can(gorsk.Resource{CompanyID: 2, Role: gorsk.AdminRole}),
+			},
+			wantErr: apikey.ErrInvalidRole,
+		},
+		{
+			name: "Fail on create",
+			req:  apikey.Create{Name: "ci", Role: gorsk.UserRole},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn:  can(gorsk.Resource{UserID: 1, Role: gorsk.UserRole}),
+			},
+			kdb: &mockdb.APIKey{
+				CreateFn: func(orm.DB, gorsk.APIKey) (gorsk.APIKey, error) {
+					return gorsk.APIKey{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success user key",
+			req:  apikey.Create{Name: "ci", Role: gorsk.UserRole},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn:  can(gorsk.Resource{UserID: 1, Role: gorsk.UserRole}),
+			},
+			kdb: &mockdb.APIKey{
+				CreateFn: func(db orm.DB, key gorsk.APIKey) (gorsk.APIKey, error) {
+					key.ID = 1
+					return key, nil
+				},
+			},
+			wantData: gorsk.APIKey{
+				Base:       gorsk.Base{ID: 1},
+				Name:       "ci",
+				Prefix:     "gsk_01234567",
+				Key:        "gsk_0123456789abcdef",
+				Hash:       "hash:gsk_0123456789abcdef",
+				UserID:     1,
+				CompanyID:  2,
+				LocationID: 3,
+				Role:       gorsk.UserRole,
+			},
+		},
+		{
+			name: "Success company key",
+			req:  apikey.Create{Name: "billing", Role: gorsk.CompanyAdminRole, CompanyID: 2, ExpiresAt: mock.TestTime(2100)},
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(user),
+				CanFn:  can(gorsk.Resource{CompanyID: 2, Role: gorsk.CompanyAdminRole}),
+			},
+			kdb: &mockdb.APIKey{
+				CreateFn: func(db orm.DB, key gorsk.APIKey) (gorsk.APIKey, error) {
+					key.ID = 2
+					return key, nil
+				},
+			},
+			wantData: gorsk.APIKey{
+				Base:      gorsk.Base{ID: 2},
+				Name:      "billing",
+				Prefix:    "gsk_01234567",
+				Key:       "gsk_0123456789abcdef",
+				Hash:      "hash:gsk_0123456789abcdef",
+				CompanyID: 2,
+				Role:      gorsk.CompanyAdminRole,
+				ExpiresAt: mock.TestTime(2100),
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := apikey.New(nil, tt.kdb, tt.rbac, sec)
+			key, err := s.Create(nil, tt.req)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, key)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name     string
+		scope    *gorsk.Resource
+		scopeErr error
+		wantData []gorsk.APIKey
+		wantErr  bool
+	}{
+		{
+			name:     "Fail on Scope",
+			scopeErr: echo.ErrForbidden,
+			wantErr:  true,
+		},
+		{
+			name:     "Success user",
+			scope:    &gorsk.Resource{UserID: 1},
+			wantData: []gorsk.APIKey{{Name: "ci", UserID: 1}},
+		},
+		{
+			name:     "Success company admin",
+			scope:    &gorsk.Resource{CompanyID: 2},
+			wantData: []gorsk.APIKey{{Name: "billing", CompanyID: 2}},
+		},
+	}
+	kdb := &mockdb.APIKey{
+		ListFn: func(db orm.DB, q *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.APIKey, error) {
+			if q.Query == "user_id = ?" {
+				return []gorsk.APIKey{{Name: "ci", UserID: q.ID}}, nil
+			}
+			return []gorsk.APIKey{{Name: "billing", CompanyID: q.ID}}, nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				ScopeFn: func(c echo.Context, permission string) (*gorsk.Resource, error) {
+					if permission != gorsk.PermissionAPIKeysView {
+						return nil, gorsk.ErrGeneric
+					}
+					return tt.scope, tt.scopeErr
+				},
+			}
+			s := apikey.New(nil, kdb, rbac, sec)
+			keys, err := s.List(nil, gorsk.Pagination{Limit: 10})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, keys)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	kdb := &mockdb.APIKey{
+		ViewFn: func(db orm.DB, id int) (gorsk.APIKey, error) {
+			switch id {
+			case 1:
+				return gorsk.APIKey{Base: gorsk.Base{ID: 1}, UserID: 1, CompanyID: 2}, nil
+			case 2:
+				return gorsk.APIKey{Base: gorsk.Base{ID: 2}, CompanyID: 2}, nil
+			}
+			return gorsk.APIKey{}, pg.ErrNoRows
+		},
+		DeleteFn: func(orm.DB, gorsk.APIKey) error {
+			return nil
+		},
+	}
+	canRevoke := func(c echo.Context, p string, r gorsk.Resource) error {
+		if p != gorsk.PermissionAPIKeysRevoke || r.UserID != 1 {
+			return echo.ErrForbidden
+		}
+		return nil
+	}
+	cases := []struct {
+		name    string
+		id      int
+		rbac    *mock.RBAC
+		wantErr error
+	}{
+		{
+			name:    "Fail on API key authentication",
+			id:      1,
+			rbac:    &mock.RBAC{UserFn: rbacUser(gorsk.AuthUser{ID: 1, APIKeyID: 5})},
+			wantErr: apikey.ErrKeyNotAllowed,
+		},
+		{
+			name:    "Fail on view",
+			id:      3,
+			rbac:    &mock.RBAC{UserFn: rbacUser(gorsk.AuthUser{ID: 1})},
+			wantErr: pg.ErrNoRows,
+		},
+		{
+			name: "Fail on company key of other user",
+			id:   2,
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 1}),
+				CanFn:  canRevoke,
+			},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Success own key",
+			id:   1,
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 1}),
+				CanFn:  canRevoke,
+			},
+		},
+		{
+			name: "Success company key",
+			id:   2,
+			rbac: &mock.RBAC{
+				UserFn: rbacUser(gorsk.AuthUser{ID: 3}),
+				CanFn: func(c echo.Context, p string, r gorsk.Resource) error {
+					if p != gorsk.PermissionAPIKeysRevoke || r != (gorsk.Resource{CompanyID: 2}) {
+						return echo.ErrForbidden
+					}
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := apikey.New(nil, kdb, tt.rbac, sec)
+			assert.Equal(t, tt.wantErr, s.Revoke(nil, tt.id))
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := map[string]gorsk.APIKey{
+		"hash:gsk_user":     {Base: gorsk.Base{ID: 1}, UserID: 1, CompanyID: 2, Role: gorsk.CompanyAdminRole},
+		"hash:gsk_demoted":  {Base: gorsk.Base{ID: 2}, UserID: 2, CompanyID: 2, Role: gorsk.CompanyAdminRole, LastUsedAt: time.Now()},
+		"hash:gsk_inactive": {Base: gorsk.Base{ID: 3}, UserID: 3, CompanyID: 2, Role: gorsk.UserRole},
+		"hash:gsk_company":  {Base: gorsk.Base{ID: 4}, CompanyID: 2, Prefix: "gsk_company", Role: gorsk.UserRole},
+		"hash:gsk_closed":   {Base: gorsk.Base{ID: 5}, CompanyID: 3, Role: gorsk.UserRole},
+		"hash:gsk_expired":  {Base: gorsk.Base{ID: 6}, UserID: 1, Role: gorsk.UserRole, ExpiresAt: mock.TestTime(2000)},
+		"hash:gsk_fail":     {Base: gorsk.Base{ID: 7}, UserID: 1, Role: gorsk.UserRole},
+		"hash:gsk_deleted":  {Base: gorsk.Base{ID: 8}, UserID: 5, CompanyID: 2, Role: gorsk.UserRole},
+		"hash:gsk_custom":   {Base: gorsk.Base{ID: 9}, UserID: 4, CompanyID: 2, Role: gorsk.CompanyAdminRole},
+		"hash:gsk_limited":  {Base: gorsk.Base{ID: 10}, UserID: 4, CompanyID: 2, Role: gorsk.LocationAdminRole},
+	}
+	users := map[int]gorsk.User{
+		1: {Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: "johndoe@mail.com", CompanyID: 2, LocationID: 3, RoleID: gorsk.AdminRole, Role: &gorsk.Role{ID: gorsk.AdminRole, AccessLevel: gorsk.AdminRole}, Active: true},
+		2: {Base: gorsk.Base{ID: 2}, Username: "janedoe", CompanyID: 2, LocationID: 3, RoleID: gorsk.UserRole, Role: &gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole}, Active: true},
+		3: {Base: gorsk.Base{ID: 3}, Username: "inactive", RoleID: gorsk.UserRole, Role: &gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole}},
+		4: {Base: gorsk.Base{ID: 4}, Username: "auditor", CompanyID: 2, LocationID: 3, RoleID: 1000, Role: &gorsk.Role{ID: 1000, AccessLevel: 125}, Active: true},
+	}
+	var used []int
+	kdb := &mockdb.APIKey{
+		FindByHashFn: func(db orm.DB, hash string) (gorsk.APIKey, error) {
+			if key, ok := keys[hash]; ok {
+				return key, nil
+			}
+			return gorsk.APIKey{}, pg.ErrNoRows
+		},
+		ViewUserFn: func(db orm.DB, id int) (gorsk.User, error) {
+			// deleted users are not returned
+			if u, ok := users[id]; ok {
+				return u, nil
+			}
+			return gorsk.User{}, pg.ErrNoRows
+		},
+		ViewCompanyFn: func(db orm.DB, id int) (gorsk.Company, error) {
+			return gorsk.Company{Base: gorsk.Base{ID: id}, Active: id == 2}, nil
+		},
+		UpdateLastUsedFn: func(db orm.DB, key gorsk.APIKey) error {
+			if key.ID == 7 {
+				return gorsk.ErrGeneric
+			}
+			used = append(used, key.ID)
+			return nil
+		},
+	}
+	cases := []struct {
+		name     string
+		key      string
+		wantData gorsk.AuthUser
+		wantErr  error
+	}{
+		{
+			name:    "Unknown key",
+			key:     "gsk_unknown",
+			wantErr: apikey.ErrInvalidKey,
+		},
+		{
+			name:    "Expired key",
+			key:     "gsk_expired",
+			wantErr: apikey.ErrKeyExpired,
+		},
+		{
+			name:    "Inactive user",
+			key:     "gsk_inactive",
+			wantErr: apikey.ErrInvalidKey,
+		},
+		{
+			name:    "Deleted user",
+			key:     "gsk_deleted",
+			wantErr: apikey.ErrInvalidKey,
+		},
+		{
+			name:    "Inactive company",
+			key:     "gsk_closed",
+			wantErr: apikey.ErrInvalidKey,
+		},
+		{
+			name:    "Fail on last used update",
+			key:     "gsk_fail",
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name:     "Success user key",
+			key:      "gsk_user",
+			wantData: gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Username: "johndoe", Email: "johndoe@mail.com", Role: gorsk.CompanyAdminRole, RoleID: gorsk.CompanyAdminRole, APIKeyID: 1},
+		},
+		{
+			name:     "Success key of demoted user",
+			key:      "gsk_demoted",
+			wantData: gorsk.AuthUser{ID: 2, CompanyID: 2, LocationID: 3, Username: "janedoe", Role: gorsk.UserRole, RoleID: gorsk.UserRole, APIKeyID: 2},
+		},
+		{
+			name:     "Success company key",
+			key:      "gsk_company",
+			wantData: gorsk.AuthUser{CompanyID: 2, Username: "apikey:gsk_company", Role: gorsk.UserRole, RoleID: gorsk.UserRole, APIKeyID: 4},
+		},
+		{
+			name:     "Success key of user with custom role",
+			key:      "gsk_custom",
+			wantData: gorsk.AuthUser{ID: 4, CompanyID: 2, LocationID: 3, Username: "auditor", Role: 125, RoleID: 1000, APIKeyID: 9},
+		},
+		{
+			name:     "Success key with lower role than user's custom role",
+			key:      "gsk_limited",
+			wantData: gorsk.AuthUser{ID: 4, CompanyID: 2, LocationID: 3, Username: "auditor", Role: gorsk.LocationAdminRole, RoleID: gorsk.LocationAdminRole, APIKeyID: 10},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := apikey.New(nil, kdb, nil, sec)
+			au, err := s.Authenticate(nil, tt.key)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, au)
+		})
+	}
+	// recently used key is not updated again
+	assert.Equal(t, []int{1, 4, 9, 10}, used)
+}
+
+func TestInitialize(t *testing.T) {
+	k := apikey.Initialize(nil, nil, nil)
+	if k == nil {
+		t.Error("API key service not initialized")
+	}
+}
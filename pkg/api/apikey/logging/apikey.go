@@ -0,0 +1,73 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey"
+)
+
+// New creates new API key logging service
+func New(svc apikey.Service, logger gorsk.Logger) *LogService {
+	return &LogService{
+		Service: svc,
+		logger:  logger,
+	}
+}
+
+// LogService represents API key logging service
+type LogService struct {
+	apikey.Service
+	logger gorsk.Logger
+}
+
+const name = "apikey"
+
+// Create logging
+func (ls *LogService) Create(c echo.Context, req apikey.Create) (resp gorsk.APIKey, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Create API key request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp.Redacted(),
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Create(c, req)
+}
+
+// List logging
+func (ls *LogService) List(c echo.Context, req gorsk.Pagination) (resp []gorsk.APIKey, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "List API key request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.List(c, req)
+}
+
+// Revoke logging
+func (ls *LogService) Revoke(c echo.Context, req int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Revoke API key request", err,
+			map[string]interface{}{
+				"req":  req,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Revoke(c, req)
+}
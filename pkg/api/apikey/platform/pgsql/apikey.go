@@ -0,0 +1,69 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// APIKey represents the client for api_keys table
+type APIKey struct{}
+
+// Create creates a new API key on database
+func (k APIKey) Create(db orm.DB, key gorsk.APIKey) (gorsk.APIKey, error) {
+	err := db.Insert(&key)
+	return key, err
+}
+
+// View returns single API key by ID
+func (k APIKey) View(db orm.DB, id int) (gorsk.APIKey, error) {
+	key := gorsk.APIKey{Base: gorsk.Base{ID: id}}
+	err := db.Select(&key)
+	return key, err
+}
+
+// List returns list of all API keys retrievable for the current user, depending on role
+func (k APIKey) List(db orm.DB, qp *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.APIKey, error) {
+	var keys []gorsk.APIKey
+	q := db.Model(&keys).Limit(p.Limit).Offset(p.Offset).Order("api_key.id desc")
+	if qp != nil {
+		q.Where(qp.Query, qp.ID)
+	}
+	err := q.Select()
+	return keys, err
+}
+
+// Delete revokes the API key
+func (k APIKey) Delete(db orm.DB, key gorsk.APIKey) error {
+	return db.Delete(&key)
+}
+
+// FindByHash queries for single API key by its hash. Revoked keys are not returned.
+func (k APIKey) FindByHash(db orm.DB, hash string) (gorsk.APIKey, error) {
+	var key gorsk.APIKey
+	err := db.Model(&key).Where("hash = ?", hash).Select()
+	return key, err
+}
+
+// UpdateLastUsed updates time the API key was last used at
+func (k APIKey) UpdateLastUsed(db orm.DB, key gorsk.APIKey) error {
+	_, err := db.Model(&key).Set("last_used_at = ?", key.LastUsedAt).WherePK().Update()
+	return err
+}
+
+// ViewUser returns user owning the API key along with user's role. Deleted users are not returned.
+func (k APIKey) ViewUser(db orm.DB, id int) (gorsk.User, error) {
+	var user gorsk.User
+	sql := `SELECT "user".*, "role"."id" AS "role__id", "role"."access_level" AS "role__access_level", "role"."name" AS "role__name"
+	FROM "users" AS "user" LEFT JOIN "roles" AS "role" ON "role"."id" = "user"."role_id"
+	WHERE ("user"."id" = ? and "user"."deleted_at" is null)`
+	_, err := db.QueryOne(&user, sql, id)
+	return user, err
+}
+
+// ViewCompany returns company owning the API key. Deleted companies are not returned.
+func (k APIKey) ViewCompany(db orm.DB, id int) (gorsk.Company, error) {
+	var company gorsk.Company
+	err := db.Model(&company).Where(`"company"."id" = ? and "company"."deleted_at" is null`, id).Select()
+	return company, err
+}
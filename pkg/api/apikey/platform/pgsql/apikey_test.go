@@ -0,0 +1,75 @@
+package pgsql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+)
+
+func TestAPIKey(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.Company{}, &gorsk.User{}, &gorsk.APIKey{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme", Active: true},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: "johndoe@mail.com", RoleID: 1, CompanyID: 1, LocationID: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 2, DeletedAt: mock.TestTime(2018)}, Username: "janedoe", Email: "janedoe@mail.com", Active: true, RoleID: 1, CompanyID: 1, LocationID: 1},
+	); err != nil {
+		t.Error(err)
+	}
+
+	kdb := pgsql.APIKey{}
+
+	userKey, err := kdb.Create(db, gorsk.APIKey{Name: "ci", Prefix: "gsk_aaaaaaaa", Hash: "userhash", UserID: 1, CompanyID: 1, LocationID: 1, Role: 1})
+	assert.Nil(t, err)
+	companyKey, err := kdb.Create(db, gorsk.APIKey{Name: "billing", Prefix: "gsk_bbbbbbbb", Hash: "companyhash", CompanyID: 1, Role: 120})
+	assert.Nil(t, err)
+
+	_, err = kdb.Create(db, gorsk.APIKey{Name: "duplicate", Hash: "userhash", UserID: 1, CompanyID: 1})
+	assert.NotNil(t, err)
+
+	key, err := kdb.View(db, userKey.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "ci", key.Name)
+
+	keys, err := kdb.List(db, &gorsk.ListQuery{Query: "user_id = ?", ID: 1}, gorsk.Pagination{Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+
+	keys, err = kdb.List(db, nil, gorsk.Pagination{Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+
+	key.LastUsedAt = time.Now().Round(time.Millisecond)
+	assert.Nil(t, kdb.UpdateLastUsed(db, key))
+
+	key, err = kdb.FindByHash(db, "userhash")
+	assert.Nil(t, err)
+	assert.Equal(t, userKey.ID, key.ID)
+	assert.False(t, key.LastUsedAt.IsZero())
+
+	assert.Nil(t, kdb.Delete(db, companyKey))
+	_, err = kdb.FindByHash(db, "companyhash")
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	user, err := kdb.ViewUser(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "johndoe", user.Username)
+	assert.Equal(t, gorsk.AccessRole(1), user.Role.AccessLevel)
+
+	_, err = kdb.ViewUser(db, 2)
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	cmp, err := kdb.ViewCompany(db, 1)
+	assert.Nil(t, err)
+	assert.True(t, cmp.Active)
+}
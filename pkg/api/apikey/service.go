@@ -0,0 +1,61 @@
+package apikey
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey/platform/pgsql"
+)
+
+// Service represents API key application interface
+type Service interface {
+	Create(echo.Context, Create) (gorsk.APIKey, error)
+	List(echo.Context, gorsk.Pagination) ([]gorsk.APIKey, error)
+	Revoke(echo.Context, int) error
+	Authenticate(echo.Context, string) (gorsk.AuthUser, error)
+}
+
+// New creates new API key application service
+func New(db *pg.DB, kdb KDB, rbac RBAC, sec Securer) *APIKey {
+	return &APIKey{db: db, kdb: kdb, rbac: rbac, sec: sec}
+}
+
+// Initialize initalizes API key application service with defaults
+func Initialize(db *pg.DB, rbac RBAC, sec Securer) *APIKey {
+	return New(db, pgsql.APIKey{}, rbac, sec)
+}
+
+// APIKey represents API key application service
+type APIKey struct {
+	db   *pg.DB
+	kdb  KDB
+	rbac RBAC
+	sec  Securer
+}
+
+// KDB represents API key repository interface
+type KDB interface {
+	Create(orm.DB, gorsk.APIKey) (gorsk.APIKey, error)
+	View(orm.DB, int) (gorsk.APIKey, error)
+	List(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.APIKey, error)
+	Delete(orm.DB, gorsk.APIKey) error
+	FindByHash(orm.DB, string) (gorsk.APIKey, error)
+	UpdateLastUsed(orm.DB, gorsk.APIKey) error
+	ViewUser(orm.DB, int) (gorsk.User, error)
+	ViewCompany(orm.DB, int) (gorsk.Company, error)
+}
+
+// Securer represents security interface
+type Securer interface {
+	Token() (string, error)
+	HashToken(string) string
+}
+
+// RBAC represents role-based-access-control interface
+type RBAC interface {
+	User(echo.Context) gorsk.AuthUser
+	Can(echo.Context, string, gorsk.Resource) error
+	Scope(echo.Context, string) (*gorsk.Resource, error)
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey"
+
+	"github.com/labstack/echo"
+)
+
+// HTTP represents API key http service
+type HTTP struct {
+	svc apikey.Service
+}
+
+// NewHTTP creates new API key http service
+func NewHTTP(svc apikey.Service, r *echo.Group) {
+	h := HTTP{svc}
+	kr := r.Group("/api-keys")
+	// swagger:operation POST /v1/api-keys apikeys apiKeyCreate
+	// ---
+	// summary: Creates new API key.
+	// description: Creates new API key owned by the requesting user, or by the company if company_id is set. The key is returned only in this response, and is sent in X-API-Key header to authenticate. It cannot have higher role than the requesting user.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/apiKeyCreate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/apiKeyResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	kr.POST("", h.create)
+
+	// swagger:operation GET /v1/api-keys apikeys listAPIKeys
+	// ---
+	// summary: Returns list of API keys.
+	// description: Returns list of API keys. Depending on the user role requesting it, it may return all keys for SuperAdmin/Admin users, keys of user's company for Company admins, and user's own keys for other users.
+	// parameters:
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/apiKeyListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	kr.GET("", h.list)
+
+	// swagger:operation DELETE /v1/api-keys/{id} apikeys apiKeyRevoke
+	// ---
+	// summary: Revokes an API key
+	// description: Revokes an API key with requested ID, after which it can no longer be used.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of API key
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	kr.DELETE("/:id", h.revoke)
+}
+
+// API key create request
+// swagger:model apiKeyCreate
+type createReq struct {
+	Name      string           `json:"name" validate:"required,min=2"`
+	Role      gorsk.AccessRole `json:"role" validate:"required,oneof=100 110 120 130 200"`
+	CompanyID int              `json:"company_id,omitempty"`
+	ExpiresAt time.Time        `json:"expires_at,omitempty"`
+}
+
+func (h HTTP) create(c echo.Context) error {
+	r := new(createReq)
+
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	key, err := h.svc.Create(c, apikey.Create{
+		Name:      r.Name,
+		Role:      r.Role,
+		CompanyID: r.CompanyID,
+		ExpiresAt: r.ExpiresAt,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+type listResponse struct {
+	APIKeys []gorsk.APIKey `json:"api_keys"`
+	Page    int            `json:"page"`
+}
+
+func (h HTTP) list(c echo.Context) error {
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	result, err := h.svc.List(c, req.Transform())
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, listResponse{result, req.Page})
+}
+
+func (h HTTP) revoke(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	if err := h.svc.Revoke(c, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
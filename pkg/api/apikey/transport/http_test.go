@@ -0,0 +1,241 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/apikey"
+	"github.com/ribice/gorsk/pkg/api/apikey/transport"
+
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/server"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.APIKey
+		kdb        *mockdb.APIKey
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Fail on validation",
+			req:        `{"name":"ci","role":50}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			req:  `{"name":"ci","role":110}`,
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1, Role: gorsk.UserRole}
+				},
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `{"name":"ci","role":200}`,
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Role: gorsk.UserRole}
+				},
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			kdb: &mockdb.APIKey{
+				CreateFn: func(db orm.DB, key gorsk.APIKey) (gorsk.APIKey, error) {
+					key.ID = 1
+					key.CreatedAt = mock.TestTime(2018)
+					key.UpdatedAt = mock.TestTime(2018)
+					return key, nil
+				},
+			},
+			wantResp: &gorsk.APIKey{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(2018),
+					UpdatedAt: mock.TestTime(2018),
+				},
+				Name:       "ci",
+				Prefix:     "gsk_01234567",
+				Key:        "gsk_0123456789abcdef",
+				UserID:     1,
+				CompanyID:  2,
+				LocationID: 3,
+				Role:       gorsk.UserRole,
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "0123456789abcdef", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(apikey.New(nil, tt.kdb, tt.rbac, sec), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/api-keys"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.APIKey)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	type listResponse struct {
+		APIKeys []gorsk.APIKey `json:"api_keys"`
+		Page    int            `json:"page"`
+	}
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *listResponse
+		kdb        *mockdb.APIKey
+	}{
+		{
+			name:       "Invalid request",
+			req:        `?limit=2222&page=-1`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Success",
+			req:  `?limit=100&page=1`,
+			kdb: &mockdb.APIKey{
+				ListFn: func(db orm.DB, q *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.APIKey, error) {
+					return []gorsk.APIKey{{Base: gorsk.Base{ID: 1}, Name: "ci", Hash: "secret", UserID: q.ID}}, nil
+				},
+			},
+			wantResp: &listResponse{
+				APIKeys: []gorsk.APIKey{{Base: gorsk.Base{ID: 1}, Name: "ci", UserID: 1}},
+				Page:    1,
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1, Role: gorsk.UserRole}
+		},
+		ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+			return &gorsk.Resource{UserID: 1}, nil
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(apikey.New(nil, tt.kdb, rbac, nil), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/api-keys" + tt.req
+			res, err := http.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(listResponse)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		kdb        *mockdb.APIKey
+	}{
+		{
+			name:       "Invalid request",
+			id:         `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Success",
+			id:   `1`,
+			kdb: &mockdb.APIKey{
+				ViewFn: func(db orm.DB, id int) (gorsk.APIKey, error) {
+					return gorsk.APIKey{Base: gorsk.Base{ID: id}, UserID: 1}, nil
+				},
+				DeleteFn: func(orm.DB, gorsk.APIKey) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1, Role: gorsk.UserRole}
+		},
+		CanFn: func(echo.Context, string, gorsk.Resource) error {
+			return nil
+		},
+	}
+
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(apikey.New(nil, tt.kdb, rbac, nil), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/api-keys/" + tt.id
+			req, _ := http.NewRequest("DELETE", path, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"github.com/ribice/gorsk"
+)
+
+// API key model response
+// swagger:response apiKeyResp
+type swaggAPIKeyResponse struct {
+	// in:body
+	Body struct {
+		*gorsk.APIKey
+	}
+}
+
+// API keys model response
+// swagger:response apiKeyListResp
+type swaggAPIKeyListResponse struct {
+	// in:body
+	Body struct {
+		APIKeys []gorsk.APIKey `json:"api_keys"`
+		Page    int            `json:"page"`
+	}
+}
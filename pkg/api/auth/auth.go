@@ -1,58 +1,322 @@
 package auth
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth/platform/pgsql"
 )
 
 // Custom errors
 var (
 	ErrInvalidCredentials = echo.NewHTTPError(http.StatusUnauthorized, "Username or password does not exist")
+	ErrTokenReused        = echo.NewHTTPError(http.StatusUnauthorized, "Refresh token was already used, please log in again")
+	ErrTokenExpired       = echo.NewHTTPError(http.StatusUnauthorized, "Refresh token has expired, please log in again")
+	ErrSessionExpired     = echo.NewHTTPError(http.StatusUnauthorized, "Session has reached its maximum duration, please log in again")
+	ErrInvalidToken       = echo.NewHTTPError(http.StatusUnauthorized, "Refresh token is invalid")
+	ErrSessionNotFound    = echo.NewHTTPError(http.StatusNotFound, "Session does not exist")
+	ErrEmailNotVerified   = echo.NewHTTPError(http.StatusForbidden, "Email is not verified, please follow the link sent to your email")
 )
 
-// Authenticate tries to authenticate the user provided by username and password
+// Authenticate tries to authenticate the user provided by username and password.
+// Failed attempts of existing users are recorded in their login history.
 func (a Auth) Authenticate(c echo.Context, user, pass string) (gorsk.AuthToken, error) {
 	u, err := a.udb.FindByUsername(a.db, user)
 	if err != nil {
 		return gorsk.AuthToken{}, err
 	}
 
+	token, err := a.authenticate(c, u, pass)
+	if err != nil {
+		return gorsk.AuthToken{}, a.loginFailedEvent(c, u.ID, gorsk.LoginPassword, err)
+	}
+	return token, nil
+}
+
+func (a Auth) authenticate(c echo.Context, u gorsk.User, pass string) (gorsk.AuthToken, error) {
+	if u.Locked() {
+		return gorsk.AuthToken{}, errLocked(u.LockedUntil)
+	}
+
 	if !a.sec.HashMatchesPassword(u.Password, pass) {
-		return gorsk.AuthToken{}, ErrInvalidCredentials
+		return gorsk.AuthToken{}, a.loginFailed(u, ErrInvalidCredentials)
+	}
+
+	if a.cfg.RequireVerifiedEmail && !u.EmailVerified() {
+		return gorsk.AuthToken{}, ErrEmailNotVerified
 	}
 
 	if !u.Active {
 		return gorsk.AuthToken{}, gorsk.ErrUnauthorized
 	}
 
+	if a.mfaRequired(u) {
+		return a.challenge(u)
+	}
+
+	return a.login(c, u, gorsk.LoginPassword)
+}
+
+// login issues tokens to the authenticated user, starts a new session and records it in user's login history
+func (a Auth) login(c echo.Context, u gorsk.User, method string) (gorsk.AuthToken, error) {
 	token, err := a.tg.GenerateToken(u)
 	if err != nil {
 		return gorsk.AuthToken{}, gorsk.ErrUnauthorized
 	}
 
-	u.UpdateLastLogin(a.sec.Token(token))
+	u.UpdateLastLogin()
 
 	if err := a.udb.Update(a.db, u); err != nil {
 		return gorsk.AuthToken{}, err
 	}
 
-	return gorsk.AuthToken{Token: token, RefreshToken: u.Token}, nil
+	refreshToken, err := a.sec.Token()
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	now := time.Now()
+	session := gorsk.Session{
+		UserID:     u.ID,
+		Token:      a.sec.HashToken(refreshToken),
+		UserAgent:  c.Request().UserAgent(),
+		IP:         c.RealIP(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	if a.cfg.MaxRefresh > 0 {
+		session.ExpiresAt = now.Add(a.cfg.MaxRefresh)
+	}
+
+	if err := a.udb.CreateSession(a.db, session); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if err := a.loginSucceeded(c, u, method); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	return gorsk.AuthToken{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// loginFailed records failed login attempt, locking the user out once configured number of attempts is reached.
+// If the user is not locked out, err is returned.
+func (a Auth) loginFailed(u gorsk.User, err error) error {
+	if a.cfg.MaxLoginAttempts <= 0 {
+		return err
+	}
+
+	u.LoginFailed(a.cfg.MaxLoginAttempts, a.cfg.LockoutDuration, a.cfg.MaxLockoutDuration)
+
+	if err := a.udb.UpdateLoginAttempts(a.db, u); err != nil {
+		return err
+	}
+
+	if u.Locked() {
+		return errLocked(u.LockedUntil)
+	}
+
+	return err
+}
+
+// lockout is returned as error message when user is locked out
+type lockout struct {
+	Message      string `json:"message"`
+	RetryMinutes int    `json:"retry_in_minutes"`
 }
 
-// Refresh refreshes jwt token and puts new claims inside
-func (a Auth) Refresh(c echo.Context, refreshToken string) (string, error) {
-	user, err := a.udb.FindByToken(a.db, refreshToken)
+// errLocked returns error telling the user in how many minutes logging in can be retried
+func errLocked(until time.Time) error {
+	minutes := int(math.Ceil(time.Until(until).Minutes()))
+	return echo.NewHTTPError(http.StatusTooManyRequests, lockout{
+		Message:      fmt.Sprintf("Too many failed login attempts, try again in %d minutes", minutes),
+		RetryMinutes: minutes,
+	})
+}
+
+// Refresh exchanges refresh token for a new jwt token and a new refresh token.
+// The old refresh token is invalidated, and presenting it again revokes the session it belonged to.
+// Refreshes of known sessions are recorded in user's login history.
+func (a Auth) Refresh(c echo.Context, refreshToken string) (gorsk.AuthToken, error) {
+	hash := a.sec.HashToken(refreshToken)
+	session, err := a.udb.FindSession(a.db, hash)
+	if err == pg.ErrNoRows {
+		return gorsk.AuthToken{}, a.reused(c, hash)
+	}
 	if err != nil {
-		return "", err
+		return gorsk.AuthToken{}, err
 	}
-	return a.tg.GenerateToken(user)
+
+	token, err := a.refresh(c, session)
+	if err != nil {
+		return gorsk.AuthToken{}, a.loginFailedEvent(c, session.UserID, gorsk.LoginRefresh, err)
+	}
+
+	if err := a.udb.CreateLoginEvent(a.db, loginEvent(c, session.UserID, gorsk.LoginRefresh, true)); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	return token, nil
+}
+
+func (a Auth) refresh(c echo.Context, session gorsk.Session) (gorsk.AuthToken, error) {
+	now := time.Now()
+	if a.cfg.RefreshDuration > 0 && now.Sub(session.LastUsedAt) > a.cfg.RefreshDuration {
+		return gorsk.AuthToken{}, ErrTokenExpired
+	}
+
+	if session.Expired() {
+		return gorsk.AuthToken{}, ErrSessionExpired
+	}
+
+	user, err := a.udb.View(a.db, session.UserID)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if !user.Active {
+		return gorsk.AuthToken{}, gorsk.ErrUnauthorized
+	}
+
+	token, err := a.tg.GenerateToken(user)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	next, err := a.sec.Token()
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	rotated := session
+	rotated.Token = a.sec.HashToken(next)
+	rotated.UserAgent = c.Request().UserAgent()
+	rotated.IP = c.RealIP()
+	rotated.LastUsedAt = now
+
+	if err := a.udb.RotateSession(a.db, session.Token, rotated); err != nil {
+		if err == pgsql.ErrTokenUsed {
+			return gorsk.AuthToken{}, a.revoke(session.UserID, session.ID)
+		}
+		return gorsk.AuthToken{}, err
+	}
+
+	return gorsk.AuthToken{Token: token, RefreshToken: next}, nil
+}
+
+// reused revokes the session of a refresh token that was already rotated out of it
+func (a Auth) reused(c echo.Context, hash string) error {
+	rt, err := a.udb.FindUsedToken(a.db, hash)
+	if err == pg.ErrNoRows {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	return a.loginFailedEvent(c, rt.UserID, gorsk.LoginRefresh, a.revoke(rt.UserID, rt.SessionID))
+}
+
+// revoke deletes the session a replayed refresh token belongs to
+func (a Auth) revoke(userID, sessionID int) error {
+	if err := a.udb.DeleteSession(a.db, userID, sessionID); err != nil && err != pg.ErrNoRows {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// Logout ends the current session. It revokes the jwt token used for the request,
+// and the session the provided refresh token belongs to.
+func (a Auth) Logout(c echo.Context, refreshToken string) error {
+	au := a.rbac.User(c)
+
+	if jti, ok := c.Get("jti").(string); ok && jti != "" {
+		exp, _ := c.Get("exp").(time.Time)
+		if err := a.udb.RevokeToken(a.db, gorsk.RevokedToken{
+			JTI:       jti,
+			UserID:    au.ID,
+			ExpiresAt: exp,
+		}); err != nil {
+			return err
+		}
+	}
+
+	session, err := a.udb.FindSession(a.db, a.sec.HashToken(refreshToken))
+	if err == pg.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != au.ID {
+		return nil
+	}
+
+	return a.udb.DeleteSession(a.db, au.ID, session.ID)
+}
+
+// LogoutAll ends all sessions of the current user, on every device.
+// All issued jwt tokens are revoked, and all sessions deleted.
+func (a Auth) LogoutAll(c echo.Context) error {
+	au, err := a.selfUser(c)
+	if err != nil {
+		return err
+	}
+	u, err := a.udb.View(a.db, au.ID)
+	if err != nil {
+		return err
+	}
+
+	u.RevokeTokens()
+
+	if err := a.udb.Update(a.db, u); err != nil {
+		return err
+	}
+
+	return a.udb.DeleteUserSessions(a.db, u.ID)
+}
+
+// IsRevoked reports whether jwt token identified by jti, issued to user with given token version, was revoked
+func (a Auth) IsRevoked(c echo.Context, jti string, userID, version int) (bool, error) {
+	return a.udb.IsRevoked(a.db, jti, userID, version)
 }
 
 // Me returns info about currently logged user
 func (a Auth) Me(c echo.Context) (gorsk.User, error) {
 	au := a.rbac.User(c)
-	return a.udb.View(a.db, au.ID)
+	u, err := a.udb.View(a.db, au.ID)
+	if err != nil {
+		return gorsk.User{}, err
+	}
+	u.ImpersonatedBy = au.ImpersonatorID
+	return u, nil
+}
+
+// JWKS returns public keys used for verifying jwt tokens
+func (a Auth) JWKS(c echo.Context) gorsk.JWKS {
+	return a.tg.JWKS()
+}
+
+// Sessions returns active sessions of currently logged user
+func (a Auth) Sessions(c echo.Context) ([]gorsk.Session, error) {
+	au := a.rbac.User(c)
+	return a.udb.ListSessions(a.db, au.ID)
+}
+
+// DeleteSession ends one of the sessions of currently logged user.
+// Jwt tokens already issued for the session stay valid until they expire.
+func (a Auth) DeleteSession(c echo.Context, id int) error {
+	au := a.rbac.User(c)
+	if err := a.udb.DeleteSession(a.db, au.ID, id); err != nil {
+		if err == pg.ErrNoRows {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
 }
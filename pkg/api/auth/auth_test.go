@@ -1,13 +1,19 @@
 package auth_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/api/auth/platform/pgsql"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
 
@@ -43,9 +49,77 @@ func TestAuthenticate(t *testing.T) {
 			args:    args{user: "juzernejm", pass: "notHashedPassword"},
 			wantErr: true,
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
 					return gorsk.User{Username: user}, nil
 				},
+				UpdateLoginAttemptsFn: func(db orm.DB, u gorsk.User) error {
+					return nil
+				},
+			},
+			sec: &mock.Secure{
+				HashMatchesPasswordFn: func(string, string) bool {
+					return false
+				},
+			},
+		},
+		{
+			name:    "Locked out user",
+			args:    args{user: "juzernejm", pass: "pass"},
+			wantErr: true,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+					return gorsk.User{
+						Username:    user,
+						Active:      true,
+						LockedUntil: time.Now().Add(time.Hour),
+					}, nil
+				},
+			},
+		},
+		{
+			name:    "Failed login is recorded",
+			args:    args{user: "juzernejm", pass: "notHashedPassword"},
+			wantErr: true,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+					return gorsk.User{Username: user, FailedLogins: 1}, nil
+				},
+				UpdateLoginAttemptsFn: func(db orm.DB, u gorsk.User) error {
+					if u.FailedLogins != 2 || u.Locked() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			sec: &mock.Secure{
+				HashMatchesPasswordFn: func(string, string) bool {
+					return false
+				},
+			},
+		},
+		{
+			name:    "Fail on recording failed login",
+			args:    args{user: "juzernejm", pass: "notHashedPassword"},
+			wantErr: true,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+					return gorsk.User{Username: user}, nil
+				},
+				UpdateLoginAttemptsFn: func(db orm.DB, u gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
 			},
 			sec: &mock.Secure{
 				HashMatchesPasswordFn: func(string, string) bool {
@@ -58,6 +132,9 @@ func TestAuthenticate(t *testing.T) {
 			args:    args{user: "juzernejm", pass: "pass"},
 			wantErr: true,
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
 					return gorsk.User{
 						Username: user,
@@ -77,6 +154,9 @@ func TestAuthenticate(t *testing.T) {
 			args:    args{user: "juzernejm", pass: "pass"},
 			wantErr: true,
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
 					return gorsk.User{
 						Username: user,
@@ -101,6 +181,45 @@ func TestAuthenticate(t *testing.T) {
 			args:    args{user: "juzernejm", pass: "pass"},
 			wantErr: true,
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+					return gorsk.User{
+						Username: user,
+						Password: "pass",
+						Active:   true,
+					}, nil
+				},
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			sec: &mock.Secure{
+				HashMatchesPasswordFn: func(string, string) bool {
+					return true
+				},
+				TokenFn: func() (string, error) {
+					return "refreshtoken", nil
+				},
+				HashTokenFn: func(string) string {
+					return "hashedtoken"
+				},
+			},
+			jwt: &mock.JWT{
+				GenerateTokenFn: func(u gorsk.User) (string, error) {
+					return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9", nil
+				},
+			},
+		},
+		{
+			name:    "Fail on creating session",
+			args:    args{user: "juzernejm", pass: "pass"},
+			wantErr: true,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
 					return gorsk.User{
 						Username: user,
@@ -109,6 +228,9 @@ func TestAuthenticate(t *testing.T) {
 					}, nil
 				},
 				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					return nil
+				},
+				CreateSessionFn: func(db orm.DB, s gorsk.Session) error {
 					return gorsk.ErrGeneric
 				},
 			},
@@ -116,8 +238,11 @@ func TestAuthenticate(t *testing.T) {
 				HashMatchesPasswordFn: func(string, string) bool {
 					return true
 				},
-				TokenFn: func(string) string {
-					return "refreshtoken"
+				TokenFn: func() (string, error) {
+					return "refreshtoken", nil
+				},
+				HashTokenFn: func(string) string {
+					return "hashedtoken"
 				},
 			},
 			jwt: &mock.JWT{
@@ -130,6 +255,9 @@ func TestAuthenticate(t *testing.T) {
 			name: "Success",
 			args: args{user: "juzernejm", pass: "pass"},
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
 					return gorsk.User{
 						Username: user,
@@ -140,6 +268,15 @@ func TestAuthenticate(t *testing.T) {
 				UpdateFn: func(db orm.DB, u gorsk.User) error {
 					return nil
 				},
+				CreateSessionFn: func(db orm.DB, s gorsk.Session) error {
+					if s.Token != "hashedtoken" || s.UserAgent != "Mozilla/5.0" || s.IP != "192.0.2.1" {
+						return gorsk.ErrGeneric
+					}
+					if s.ExpiresAt.Sub(s.CreatedAt) != 24*time.Hour {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
 			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(u gorsk.User) (string, error) {
@@ -150,8 +287,11 @@ func TestAuthenticate(t *testing.T) {
 				HashMatchesPasswordFn: func(string, string) bool {
 					return true
 				},
-				TokenFn: func(string) string {
-					return "refreshtoken"
+				TokenFn: func() (string, error) {
+					return "refreshtoken", nil
+				},
+				HashTokenFn: func(string) string {
+					return "hashedtoken"
 				},
 			},
 			wantData: gorsk.AuthToken{
@@ -162,8 +302,13 @@ func TestAuthenticate(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := auth.New(nil, tt.udb, tt.jwt, tt.sec, nil)
-			token, err := s.Authenticate(nil, tt.args.user, tt.args.pass)
+			s := auth.New(nil, tt.udb, tt.jwt, tt.sec, nil, nil, nil, nil, auth.Config{
+				MaxRefresh:         24 * time.Hour,
+				MaxLoginAttempts:   3,
+				LockoutDuration:    time.Minute,
+				MaxLockoutDuration: time.Hour,
+			})
+			token, err := s.Authenticate(newCtx(), tt.args.user, tt.args.pass)
 			if tt.wantData.RefreshToken != "" {
 				tt.wantData.RefreshToken = token.RefreshToken
 				assert.Equal(t, tt.wantData, token)
@@ -172,43 +317,219 @@ func TestAuthenticate(t *testing.T) {
 		})
 	}
 }
-func TestRefresh(t *testing.T) {
-	type args struct {
-		c     echo.Context
-		token string
+func TestAuthenticateLockout(t *testing.T) {
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+			return gorsk.User{Username: user, FailedLogins: 2}, nil
+		},
+		UpdateLoginAttemptsFn: func(db orm.DB, u gorsk.User) error {
+			return nil
+		},
+	}
+	sec := &mock.Secure{
+		HashMatchesPasswordFn: func(string, string) bool {
+			return false
+		},
+	}
+	cfg := auth.Config{MaxLoginAttempts: 3, LockoutDuration: 5 * time.Minute}
+
+	s := auth.New(nil, udb, nil, sec, nil, nil, nil, nil, cfg)
+	_, err := s.Authenticate(newCtx(), "juzernejm", "pass")
+
+	herr, ok := err.(*echo.HTTPError)
+	if !ok {
+		t.Fatalf("Expected http error, got %v", err)
 	}
+	assert.Equal(t, http.StatusTooManyRequests, herr.Code)
+	assert.Contains(t, fmt.Sprint(herr.Message), "try again in 5 minutes")
+
+	s = auth.New(nil, udb, nil, sec, nil, nil, nil, nil, auth.Config{})
+	_, err = s.Authenticate(newCtx(), "juzernejm", "pass")
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+}
+
+func TestAuthenticateUnverified(t *testing.T) {
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		FindByUsernameFn: func(db orm.DB, user string) (gorsk.User, error) {
+			return gorsk.User{Username: user, Active: true}, nil
+		},
+	}
+	sec := &mock.Secure{
+		HashMatchesPasswordFn: func(string, string) bool {
+			return true
+		},
+	}
+
+	s := auth.New(nil, udb, nil, sec, nil, nil, nil, nil, auth.Config{RequireVerifiedEmail: true})
+	_, err := s.Authenticate(newCtx(), "juzernejm", "pass")
+	assert.Equal(t, auth.ErrEmailNotVerified, err)
+}
+
+func TestRefresh(t *testing.T) {
 	cases := []struct {
 		name     string
-		args     args
-		wantData string
-		wantErr  bool
+		token    string
+		wantData gorsk.AuthToken
+		wantErr  error
 		udb      *mockdb.User
 		jwt      *mock.JWT
+		cfg      auth.Config
 	}{
 		{
-			name:    "Fail on finding token",
-			args:    args{token: "refreshtoken"},
-			wantErr: true,
+			name:    "Fail on finding session",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
 			udb: &mockdb.User{
-				FindByTokenFn: func(db orm.DB, token string) (gorsk.User, error) {
-					return gorsk.User{}, gorsk.ErrGeneric
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, gorsk.ErrGeneric
 				},
 			},
 		},
 		{
-			name:    "Fail on token generation",
-			args:    args{token: "refreshtoken"},
-			wantErr: true,
+			name:    "Invalid token",
+			token:   "refreshtoken",
+			wantErr: auth.ErrInvalidToken,
 			udb: &mockdb.User{
-				FindByTokenFn: func(db orm.DB, token string) (gorsk.User, error) {
-					return gorsk.User{
-						Username: "username",
-						Password: "password",
-						Active:   true,
-						Token:    token,
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+				FindUsedTokenFn: func(orm.DB, string) (gorsk.RefreshToken, error) {
+					return gorsk.RefreshToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on finding used token",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+				FindUsedTokenFn: func(orm.DB, string) (gorsk.RefreshToken, error) {
+					return gorsk.RefreshToken{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Reused token revokes session",
+			token:   "refreshtoken",
+			wantErr: auth.ErrTokenReused,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+				FindUsedTokenFn: func(db orm.DB, token string) (gorsk.RefreshToken, error) {
+					return gorsk.RefreshToken{ID: 1, SessionID: 2, UserID: 3, Token: token}, nil
+				},
+				DeleteSessionFn: func(db orm.DB, userID, id int) error {
+					if userID != 3 || id != 2 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:    "Fail on revoking session",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+				FindUsedTokenFn: func(db orm.DB, token string) (gorsk.RefreshToken, error) {
+					return gorsk.RefreshToken{ID: 1, SessionID: 2, UserID: 3, Token: token}, nil
+				},
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Expired refresh token",
+			token:   "refreshtoken",
+			wantErr: auth.ErrTokenExpired,
+			cfg:     auth.Config{RefreshDuration: time.Hour},
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{
+						ID:         1,
+						UserID:     1,
+						Token:      token,
+						CreatedAt:  time.Now().Add(-2 * time.Hour),
+						LastUsedAt: time.Now().Add(-2 * time.Hour),
 					}, nil
 				},
 			},
+		},
+		{
+			name:    "Expired session",
+			token:   "refreshtoken",
+			wantErr: auth.ErrSessionExpired,
+			cfg:     auth.Config{RefreshDuration: time.Hour},
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{
+						ID:         1,
+						UserID:     1,
+						Token:      token,
+						CreatedAt:  mock.TestTime(2000),
+						LastUsedAt: time.Now().Add(-time.Minute),
+						ExpiresAt:  mock.TestTime(2001),
+					}, nil
+				},
+			},
+		},
+		{
+			name:    "Inactive user",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrUnauthorized,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Username: "username", Active: false}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on token generation",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Username: "username", Active: true}, nil
+				},
+			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(u gorsk.User) (string, error) {
 					return "", gorsk.ErrGeneric
@@ -216,31 +537,286 @@ func TestRefresh(t *testing.T) {
 			},
 		},
 		{
-			name: "Success",
-			args: args{token: "refreshtoken"},
+			name:    "Concurrently rotated token revokes session",
+			token:   "refreshtoken",
+			wantErr: auth.ErrTokenReused,
 			udb: &mockdb.User{
-				FindByTokenFn: func(db orm.DB, token string) (gorsk.User, error) {
-					return gorsk.User{
-						Username: "username",
-						Password: "password",
-						Active:   true,
-						Token:    token,
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Username: "username", Active: true}, nil
+				},
+				RotateSessionFn: func(orm.DB, string, gorsk.Session) error {
+					return pgsql.ErrTokenUsed
+				},
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return nil
+				},
+			},
+			jwt: &mock.JWT{
+				GenerateTokenFn: func(u gorsk.User) (string, error) {
+					return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9", nil
+				},
+			},
+		},
+		{
+			name:    "Fail on rotating session",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Username: "username", Active: true}, nil
+				},
+				RotateSessionFn: func(orm.DB, string, gorsk.Session) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			jwt: &mock.JWT{
+				GenerateTokenFn: func(u gorsk.User) (string, error) {
+					return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9", nil
+				},
+			},
+		},
+		{
+			name:  "Success",
+			token: "refreshtoken",
+			cfg:   auth.Config{RefreshDuration: time.Hour, MaxRefresh: 24 * time.Hour},
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{
+						ID:         1,
+						UserID:     1,
+						Token:      token,
+						CreatedAt:  time.Now().Add(-time.Hour),
+						LastUsedAt: time.Now().Add(-time.Minute),
+						ExpiresAt:  time.Now().Add(time.Hour),
 					}, nil
 				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Username: "username", Active: true}, nil
+				},
+				RotateSessionFn: func(db orm.DB, token string, s gorsk.Session) error {
+					if token != "hashed:refreshtoken" || s.Token != "hashed:newrefreshtoken" {
+						return gorsk.ErrGeneric
+					}
+					if s.ID != 1 || s.UserAgent != "Mozilla/5.0" || s.IP != "192.0.2.1" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
 			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(u gorsk.User) (string, error) {
 					return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9", nil
 				},
 			},
-			wantData: "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+			wantData: gorsk.AuthToken{
+				Token:        "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+				RefreshToken: "newrefreshtoken",
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "newrefreshtoken", nil
+		},
+		HashTokenFn: func(token string) string {
+			return "hashed:" + token
 		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := auth.New(nil, tt.udb, tt.jwt, nil, nil)
-			token, err := s.Refresh(tt.args.c, tt.args.token)
+			s := auth.New(nil, tt.udb, tt.jwt, sec, nil, nil, nil, nil, tt.cfg)
+			token, err := s.Refresh(newCtx(), tt.token)
 			assert.Equal(t, tt.wantData, token)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	cases := []struct {
+		name    string
+		token   string
+		wantErr error
+		udb     *mockdb.User
+	}{
+		{
+			name:    "Fail on revoking jwt token",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				RevokeTokenFn: func(orm.DB, gorsk.RevokedToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:  "Session does not exist",
+			token: "refreshtoken",
+			udb: &mockdb.User{
+				RevokeTokenFn: func(orm.DB, gorsk.RevokedToken) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on finding session",
+			token:   "refreshtoken",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				RevokeTokenFn: func(orm.DB, gorsk.RevokedToken) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:  "Session of another user is left intact",
+			token: "refreshtoken",
+			udb: &mockdb.User{
+				RevokeTokenFn: func(orm.DB, gorsk.RevokedToken) error {
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 5, UserID: 2, Token: token}, nil
+				},
+			},
+		},
+		{
+			name:  "Success",
+			token: "refreshtoken",
+			udb: &mockdb.User{
+				RevokeTokenFn: func(db orm.DB, t gorsk.RevokedToken) error {
+					if t.JTI != "tokenid" || t.UserID != 1 || !t.ExpiresAt.Equal(mock.TestTime(2020)) {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					if token != "hashed:refreshtoken" {
+						return gorsk.Session{}, pg.ErrNoRows
+					}
+					return gorsk.Session{ID: 5, UserID: 1, Token: token}, nil
+				},
+				DeleteSessionFn: func(db orm.DB, userID, id int) error {
+					if userID != 1 || id != 5 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(token string) string {
+			return "hashed:" + token
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, sec, rbac, nil, nil, nil, auth.Config{})
+			ctx := mock.EchoCtxWithKeys([]string{"jti", "exp"}, "tokenid", mock.TestTime(2020))
+			err := s.Logout(ctx, tt.token)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	cases := []struct {
+		name    string
+		user    gorsk.AuthUser
+		wantErr bool
+		udb     *mockdb.User
+	}{
+		{
+			name:    "Impersonated user",
+			user:    gorsk.AuthUser{ID: 1, ImpersonatorID: 2},
+			wantErr: true,
+		},
+		{
+			name:    "API key",
+			user:    gorsk.AuthUser{ID: 1, APIKeyID: 3},
+			wantErr: true,
+		},
+		{
+			name:    "Fail on user view",
+			user:    gorsk.AuthUser{ID: 1},
+			wantErr: true,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Fail on update",
+			user:    gorsk.AuthUser{ID: 1},
+			wantErr: true,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}}, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			user: gorsk.AuthUser{ID: 1},
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}, TokenVersion: 3}, nil
+				},
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if u.TokenVersion != 4 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserSessionsFn: func(db orm.DB, id int) error {
+					if id != 1 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return tt.user
+				},
+			}
+			s := auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{})
+			err := s.LogoutAll(nil)
 			assert.Equal(t, tt.wantErr, err != nil)
 		})
 	}
@@ -290,13 +866,160 @@ func TestMe(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Impersonated",
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 9, ImpersonatorID: 1}
+				},
+			},
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}}, nil
+				},
+			},
+			wantData: gorsk.User{Base: gorsk.Base{ID: 9}, ImpersonatedBy: 1},
+		},
+		{
+			name: "Fail on View",
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 9}
+				},
+			},
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := auth.New(nil, tt.udb, nil, nil, tt.rbac)
+			s := auth.New(nil, tt.udb, nil, nil, tt.rbac, nil, nil, nil, auth.Config{})
 			user, err := s.Me(nil)
 			assert.Equal(t, tt.wantData, user)
 			assert.Equal(t, tt.wantErr, err != nil)
 		})
 	}
 }
+
+func TestJWKS(t *testing.T) {
+	want := gorsk.JWKS{Keys: []gorsk.JWK{{Kty: "OKP", Use: "sig", Alg: "EdDSA", Kid: "kid", Crv: "Ed25519", X: "x"}}}
+	jwt := &mock.JWT{
+		JWKSFn: func() gorsk.JWKS {
+			return want
+		},
+	}
+	s := auth.New(nil, nil, jwt, nil, nil, nil, nil, nil, auth.Config{})
+	assert.Equal(t, want, s.JWKS(nil))
+}
+
+func TestSessions(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantData []gorsk.Session
+		wantErr  bool
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Fail on listing sessions",
+			wantErr: true,
+			udb: &mockdb.User{
+				ListSessionsFn: func(orm.DB, int) ([]gorsk.Session, error) {
+					return nil, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				ListSessionsFn: func(db orm.DB, userID int) ([]gorsk.Session, error) {
+					return []gorsk.Session{
+						{ID: 1, UserID: userID, UserAgent: "Mozilla/5.0", IP: "192.0.2.1"},
+						{ID: 2, UserID: userID, UserAgent: "curl/7.64.1", IP: "192.0.2.2"},
+					}, nil
+				},
+			},
+			wantData: []gorsk.Session{
+				{ID: 1, UserID: 9, UserAgent: "Mozilla/5.0", IP: "192.0.2.1"},
+				{ID: 2, UserID: 9, UserAgent: "curl/7.64.1", IP: "192.0.2.2"},
+			},
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 9}
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{})
+			sessions, err := s.Sessions(nil)
+			assert.Equal(t, tt.wantData, sessions)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	cases := []struct {
+		name    string
+		id      int
+		wantErr error
+		udb     *mockdb.User
+	}{
+		{
+			name:    "Session does not exist",
+			id:      3,
+			wantErr: auth.ErrSessionNotFound,
+			udb: &mockdb.User{
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on deleting session",
+			id:      3,
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			id:   3,
+			udb: &mockdb.User{
+				DeleteSessionFn: func(db orm.DB, userID, id int) error {
+					if userID != 9 || id != 3 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 9}
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{})
+			err := s.DeleteSession(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func newCtx() echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.RemoteAddr = "192.0.2.1:1234"
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
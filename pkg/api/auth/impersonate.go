@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Duration of impersonation tokens, if not configured
+const defaultImpersonationDuration = 15 * time.Minute
+
+// Custom errors
+var (
+	ErrImpersonationNotAllowed = echo.NewHTTPError(http.StatusForbidden, "Impersonation can be started only by an admin logged in as themselves")
+	ErrImpersonateInactive     = echo.NewHTTPError(http.StatusConflict, "Inactive users cannot be impersonated")
+	ErrSecurityNotAllowed      = echo.NewHTTPError(http.StatusForbidden, "Account security can be changed only by the user logged in as themselves")
+)
+
+// Impersonate issues a short-lived token for acting as the user with the given ID, on behalf of the requesting admin.
+// Only users with lower role than the admin can be impersonated. The token cannot be refreshed,
+// and carries admin's ID, so every request made with it is logged with both IDs.
+func (a Auth) Impersonate(c echo.Context, id int) (gorsk.AuthToken, error) {
+	au := a.rbac.User(c)
+	if au.ImpersonatorID != 0 || au.APIKeyID != 0 {
+		return gorsk.AuthToken{}, ErrImpersonationNotAllowed
+	}
+
+	u, err := a.udb.View(a.db, id)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	if err := a.rbac.Can(c, gorsk.PermissionUsersImpersonate, gorsk.Resource{CompanyID: u.CompanyID, LocationID: u.LocationID}); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	if err := a.rbac.IsLowerRole(c, u.RoleID); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	if !u.Active {
+		return gorsk.AuthToken{}, ErrImpersonateInactive
+	}
+
+	ttl := a.cfg.ImpersonationDuration
+	if ttl <= 0 {
+		ttl = defaultImpersonationDuration
+	}
+	token, err := a.tg.GenerateImpersonationToken(u, au.ID, ttl)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	return gorsk.AuthToken{Token: token}, nil
+}
+
+// selfUser returns currently logged user, unless the request is made by an impersonating admin or with an API key.
+// It guards changes of account security, such as two-factor authentication or ending all sessions.
+func (a Auth) selfUser(c echo.Context) (gorsk.AuthUser, error) {
+	au := a.rbac.User(c)
+	if au.ImpersonatorID != 0 || au.APIKeyID != 0 {
+		return gorsk.AuthUser{}, ErrSecurityNotAllowed
+	}
+	return au, nil
+}
@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpersonate(t *testing.T) {
+	admin := func(au gorsk.AuthUser) *mock.RBAC {
+		return &mock.RBAC{
+			UserFn: func(echo.Context) gorsk.AuthUser {
+				return au
+			},
+			CanFn: func(c echo.Context, permission string, r gorsk.Resource) error {
+				if permission != gorsk.PermissionUsersImpersonate || au.Role > gorsk.AdminRole {
+					return gorsk.ErrGeneric
+				}
+				return nil
+			},
+			IsLowerRoleFn: func(c echo.Context, r gorsk.AccessRole) error {
+				if au.Role >= r {
+					return gorsk.ErrGeneric
+				}
+				return nil
+			},
+		}
+	}
+	udb := func(u gorsk.User) *mockdb.User {
+		return &mockdb.User{
+			ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+				if id != 2 {
+					return gorsk.User{}, gorsk.ErrGeneric
+				}
+				return u, nil
+			},
+		}
+	}
+	target := gorsk.User{Base: gorsk.Base{ID: 2}, Active: true, RoleID: gorsk.UserRole, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}
+	jwt := &mock.JWT{
+		GenerateImpersonationTokenFn: func(u gorsk.User, impersonatorID int, ttl time.Duration) (string, error) {
+			assert.Equal(t, 10*time.Minute, ttl)
+			return "jwttoken", nil
+		},
+	}
+
+	cases := []struct {
+		name     string
+		id       int
+		rbac     *mock.RBAC
+		udb      *mockdb.User
+		wantErr  error
+		wantData gorsk.AuthToken
+	}{
+		{
+			name:    "Fail on permission",
+			id:      2,
+			rbac:    admin(gorsk.AuthUser{ID: 1, Role: gorsk.CompanyAdminRole}),
+			udb:     udb(target),
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name:    "Fail when already impersonating",
+			id:      2,
+			rbac:    admin(gorsk.AuthUser{ID: 3, Role: gorsk.AdminRole, ImpersonatorID: 1}),
+			wantErr: auth.ErrImpersonationNotAllowed,
+		},
+		{
+			name:    "Fail with API key",
+			id:      2,
+			rbac:    admin(gorsk.AuthUser{ID: 1, Role: gorsk.AdminRole, APIKeyID: 4}),
+			wantErr: auth.ErrImpersonationNotAllowed,
+		},
+		{
+			name:    "Fail on View",
+			id:      5,
+			rbac:    admin(gorsk.AuthUser{ID: 1, Role: gorsk.AdminRole}),
+			udb:     udb(target),
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name:    "Fail on equal role",
+			id:      2,
+			rbac:    admin(gorsk.AuthUser{ID: 1, Role: gorsk.AdminRole}),
+			udb:     udb(gorsk.User{Base: gorsk.Base{ID: 2}, Active: true, RoleID: gorsk.AdminRole, Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}}),
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name:    "Fail on inactive user",
+			id:      2,
+			rbac:    admin(gorsk.AuthUser{ID: 1, Role: gorsk.AdminRole}),
+			udb:     udb(gorsk.User{Base: gorsk.Base{ID: 2}, RoleID: gorsk.UserRole, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}),
+			wantErr: auth.ErrImpersonateInactive,
+		},
+		{
+			name:     "Success",
+			id:       2,
+			rbac:     admin(gorsk.AuthUser{ID: 1, Role: gorsk.AdminRole}),
+			udb:      udb(target),
+			wantData: gorsk.AuthToken{Token: "jwttoken"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, jwt, nil, tt.rbac, nil, nil, nil, auth.Config{ImpersonationDuration: 10 * time.Minute})
+			token, err := s.Impersonate(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, token)
+		})
+	}
+}
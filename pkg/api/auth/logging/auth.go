@@ -41,19 +41,121 @@ func (ls *LogService) Authenticate(c echo.Context, user, password string) (resp
 }
 
 // Refresh logging
-func (ls *LogService) Refresh(c echo.Context, req string) (token string, err error) {
+func (ls *LogService) Refresh(c echo.Context, refreshToken string) (resp gorsk.AuthToken, err error) {
 	defer func(begin time.Time) {
 		ls.logger.Log(
 			c,
 			name, "Refresh request", err,
 			map[string]interface{}{
-				"req":  req,
-				"resp": token,
+				"resp": resp.Redacted(),
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Refresh(c, refreshToken)
+}
+
+// OIDCLogin logging
+func (ls *LogService) OIDCLogin(c echo.Context) (authURL, state string, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "OIDC login request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.OIDCLogin(c)
+}
+
+// OIDCCallback logging
+func (ls *LogService) OIDCCallback(c echo.Context, state, code string) (resp gorsk.AuthToken, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "OIDC callback request", err,
+			map[string]interface{}{
+				"resp": resp.Redacted(),
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.OIDCCallback(c, state, code)
+}
+
+// MagicLink logging
+func (ls *LogService) MagicLink(c echo.Context, email string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Magic link request", err,
+			map[string]interface{}{
+				"req":  email,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.MagicLink(c, email)
+}
+
+// MagicLinkLogin logging
+func (ls *LogService) MagicLinkLogin(c echo.Context, token string) (resp gorsk.AuthToken, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Magic link login request", err,
+			map[string]interface{}{
+				"resp": resp.Redacted(),
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.MagicLinkLogin(c, token)
+}
+
+// Impersonate logging
+func (ls *LogService) Impersonate(c echo.Context, id int) (resp gorsk.AuthToken, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Impersonate request", err,
+			map[string]interface{}{
+				"req":  id,
+				"resp": resp.Redacted(),
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Impersonate(c, id)
+}
+
+// Logout logging
+func (ls *LogService) Logout(c echo.Context, refreshToken string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Logout request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Logout(c, refreshToken)
+}
+
+// LogoutAll logging
+func (ls *LogService) LogoutAll(c echo.Context) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "LogoutAll request", err,
+			map[string]interface{}{
 				"took": time.Since(begin),
 			},
 		)
 	}(time.Now())
-	return ls.Service.Refresh(c, req)
+	return ls.Service.LogoutAll(c)
 }
 
 // Me logging
@@ -70,3 +172,136 @@ func (ls *LogService) Me(c echo.Context) (resp gorsk.User, err error) {
 	}(time.Now())
 	return ls.Service.Me(c)
 }
+
+// Sessions logging
+func (ls *LogService) Sessions(c echo.Context) (resp []gorsk.Session, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Sessions request", err,
+			map[string]interface{}{
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Sessions(c)
+}
+
+// DeleteSession logging
+func (ls *LogService) DeleteSession(c echo.Context, req int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "DeleteSession request", err,
+			map[string]interface{}{
+				"req":  req,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.DeleteSession(c, req)
+}
+
+// Logins logging
+func (ls *LogService) Logins(c echo.Context, req gorsk.Pagination) (resp []gorsk.LoginEvent, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Logins request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Logins(c, req)
+}
+
+// UserLogins logging
+func (ls *LogService) UserLogins(c echo.Context, id int, req gorsk.Pagination) (resp []gorsk.LoginEvent, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "UserLogins request", err,
+			map[string]interface{}{
+				"req":  req,
+				"id":   id,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.UserLogins(c, id, req)
+}
+
+// VerifyMFA logging
+func (ls *LogService) VerifyMFA(c echo.Context, mfaToken, code string) (resp gorsk.AuthToken, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "VerifyMFA request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.VerifyMFA(c, mfaToken, code)
+}
+
+// EnrollMFAChallenge logging
+func (ls *LogService) EnrollMFAChallenge(c echo.Context, mfaToken string) (resp gorsk.MFAEnrollment, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "EnrollMFAChallenge request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.EnrollMFAChallenge(c, mfaToken)
+}
+
+// EnrollMFA logging
+func (ls *LogService) EnrollMFA(c echo.Context) (resp gorsk.MFAEnrollment, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "EnrollMFA request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.EnrollMFA(c)
+}
+
+// EnableMFA logging
+func (ls *LogService) EnableMFA(c echo.Context, code string) (resp []string, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "EnableMFA request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.EnableMFA(c, code)
+}
+
+// DisableMFA logging
+func (ls *LogService) DisableMFA(c echo.Context, code string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "DisableMFA request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.DisableMFA(c, code)
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Logins returns login history of currently logged user, the latest first
+func (a Auth) Logins(c echo.Context, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+	return a.udb.ListLoginEvents(a.db, a.rbac.User(c).ID, p)
+}
+
+// UserLogins returns login history of the user, the latest first
+func (a Auth) UserLogins(c echo.Context, id int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+	if err := a.rbac.Can(c, gorsk.PermissionLoginsView, gorsk.Resource{UserID: id}); err != nil {
+		return nil, err
+	}
+	return a.udb.ListLoginEvents(a.db, id, p)
+}
+
+// loginEvent returns login attempt of the user made by the request
+func loginEvent(c echo.Context, userID int, method string, success bool) gorsk.LoginEvent {
+	return gorsk.LoginEvent{
+		UserID:    userID,
+		Success:   success,
+		Method:    method,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// loginSucceeded records successful login of the user. If enabled, the user is notified by email
+// when logging in from IP and user agent not seen before.
+func (a Auth) loginSucceeded(c echo.Context, u gorsk.User, method string) error {
+	event := loginEvent(c, u.ID, method, true)
+
+	known := true
+	if a.cfg.NotifyNewLogin {
+		var err error
+		if known, err = a.udb.KnownLogin(a.db, event); err != nil {
+			return err
+		}
+	}
+
+	if err := a.udb.CreateLoginEvent(a.db, event); err != nil {
+		return err
+	}
+
+	if !known {
+		// failing to notify must not prevent the user from logging in
+		if err := a.mail.Send(u.Email, "New login to your account", fmt.Sprintf(newLoginMail,
+			u.FirstName, event.CreatedAt.UTC().Format(time.RFC1123), event.IP, event.UserAgent)); err != nil {
+			c.Logger().Errorf("sending new login notification to user %d: %v", u.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// loginFailedEvent records failed login attempt of the user, returning err
+func (a Auth) loginFailedEvent(c echo.Context, userID int, method string, err error) error {
+	if dbErr := a.udb.CreateLoginEvent(a.db, loginEvent(c, userID, method, false)); dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+const newLoginMail = `Hi %s,
+
+your account was just logged into from a device or network it was not used from before:
+
+Time: %s
+IP address: %s
+Device: %s
+
+If this was you, you can ignore this email. Otherwise, change your password and log out of all sessions right away.
+`
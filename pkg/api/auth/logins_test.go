@@ -0,0 +1,224 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/utl/mail"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogins(t *testing.T) {
+	events := []gorsk.LoginEvent{{ID: 2, UserID: 1, Success: true, Method: gorsk.LoginPassword}, {ID: 1, UserID: 1, Method: gorsk.LoginPassword}}
+	cases := []struct {
+		name     string
+		udb      *mockdb.User
+		wantData []gorsk.LoginEvent
+		wantErr  error
+	}{
+		{
+			name: "Fail on ListLoginEvents",
+			udb: &mockdb.User{
+				ListLoginEventsFn: func(orm.DB, int, gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+					return nil, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				ListLoginEventsFn: func(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+					if userID != 1 || p.Limit != 10 {
+						return nil, gorsk.ErrGeneric
+					}
+					return events, nil
+				},
+			},
+			wantData: events,
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{})
+			resp, err := s.Logins(nil, gorsk.Pagination{Limit: 10})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestUserLogins(t *testing.T) {
+	events := []gorsk.LoginEvent{{ID: 1, UserID: 2, Success: true, Method: gorsk.LoginOIDC}}
+	cases := []struct {
+		name     string
+		id       int
+		rbac     *mock.RBAC
+		wantData []gorsk.LoginEvent
+		wantErr  error
+	}{
+		{
+			name: "Fail on RBAC",
+			id:   2,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			id:   2,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			wantData: events,
+		},
+	}
+	udb := &mockdb.User{
+		ListLoginEventsFn: func(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+			if userID != 2 {
+				return nil, gorsk.ErrGeneric
+			}
+			return events, nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, udb, nil, nil, tt.rbac, nil, nil, nil, auth.Config{})
+			resp, err := s.UserLogins(nil, tt.id, gorsk.Pagination{Limit: 10})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestLoginEvents(t *testing.T) {
+	user := gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Username: "johndoe", Email: "johndoe@mail.com", Password: "password", Active: true}
+	cases := []struct {
+		name      string
+		pass      string
+		cfg       auth.Config
+		known     func(orm.DB, gorsk.LoginEvent) (bool, error)
+		wantEvent gorsk.LoginEvent
+		wantMail  bool
+		wantErr   error
+	}{
+		{
+			name:      "Failed login",
+			pass:      "wrong",
+			wantEvent: gorsk.LoginEvent{UserID: 1, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0"},
+			wantErr:   auth.ErrInvalidCredentials,
+		},
+		{
+			name:      "Notifications disabled",
+			pass:      "password",
+			wantEvent: gorsk.LoginEvent{UserID: 1, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0"},
+		},
+		{
+			name: "Fail on KnownLogin",
+			pass: "password",
+			cfg:  auth.Config{NotifyNewLogin: true},
+			known: func(orm.DB, gorsk.LoginEvent) (bool, error) {
+				return false, gorsk.ErrGeneric
+			},
+			wantEvent: gorsk.LoginEvent{UserID: 1, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0"},
+			wantErr:   gorsk.ErrGeneric,
+		},
+		{
+			name: "Known device",
+			pass: "password",
+			cfg:  auth.Config{NotifyNewLogin: true},
+			known: func(orm.DB, gorsk.LoginEvent) (bool, error) {
+				return true, nil
+			},
+			wantEvent: gorsk.LoginEvent{UserID: 1, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0"},
+		},
+		{
+			name: "New device",
+			pass: "password",
+			cfg:  auth.Config{NotifyNewLogin: true},
+			known: func(db orm.DB, e gorsk.LoginEvent) (bool, error) {
+				if e.UserID != 1 || e.IP != "192.0.2.1" || e.UserAgent != "Mozilla/5.0" {
+					return false, gorsk.ErrGeneric
+				}
+				return false, nil
+			},
+			wantEvent: gorsk.LoginEvent{UserID: 1, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0"},
+			wantMail:  true,
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	sec := &mock.Secure{
+		HashMatchesPasswordFn: func(hash, pass string) bool {
+			return hash == pass
+		},
+		TokenFn: func() (string, error) {
+			return "refreshtoken", nil
+		},
+		HashTokenFn: func(string) string {
+			return "hashedtoken"
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []gorsk.LoginEvent
+			udb := &mockdb.User{
+				FindByUsernameFn: func(orm.DB, string) (gorsk.User, error) {
+					return user, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+				KnownLoginFn: tt.known,
+				CreateLoginEventFn: func(db orm.DB, e gorsk.LoginEvent) error {
+					events = append(events, e)
+					return nil
+				},
+			}
+			mailer := new(mail.Memory)
+			s := auth.New(nil, udb, jwt, sec, nil, nil, nil, mailer, tt.cfg)
+			_, err := s.Authenticate(newCtx(), "johndoe", tt.pass)
+			assert.Equal(t, tt.wantErr, err)
+			if assert.Len(t, events, 1) {
+				assert.False(t, events[0].CreatedAt.IsZero())
+				events[0].CreatedAt = tt.wantEvent.CreatedAt
+				assert.Equal(t, tt.wantEvent, events[0])
+			}
+			msgs := mailer.Messages()
+			if !tt.wantMail {
+				assert.Empty(t, msgs)
+				return
+			}
+			if assert.Len(t, msgs, 1) {
+				assert.Equal(t, "johndoe@mail.com", msgs[0].To)
+				assert.True(t, strings.HasPrefix(msgs[0].Body, "Hi John,"))
+				assert.Contains(t, msgs[0].Body, "IP address: 192.0.2.1\n")
+				assert.Contains(t, msgs[0].Body, "Device: Mozilla/5.0\n")
+			}
+		})
+	}
+}
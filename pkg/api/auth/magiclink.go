@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+const (
+	// Duration for which the magic link can be used if not configured
+	defaultMagicLinkDuration = 15 * time.Minute
+	// At most maxMagicLinks links are sent to the same user within magicLinkWindow
+	maxMagicLinks   = 5
+	magicLinkWindow = time.Hour
+)
+
+// Custom errors
+var (
+	ErrMagicLinkNotConfigured = echo.NewHTTPError(http.StatusNotFound, "Login by email link is not enabled")
+	ErrInvalidMagicLink       = echo.NewHTTPError(http.StatusUnauthorized, "Login link is invalid or has expired, please request a new one")
+	ErrMagicLinkNotAllowed    = echo.NewHTTPError(http.StatusForbidden, "Login by email link is not enabled for your company")
+)
+
+// MagicLink sends a single-use login link to the user with given email, if user's company allows logging in by email link.
+// To avoid revealing which emails are registered, no error is returned if the link is not sent.
+// Requesting a new link invalidates the links sent before. To keep the mailbox from being flooded, at most maxMagicLinks
+// links are sent to the same user within magicLinkWindow; further requests are silently ignored.
+func (a Auth) MagicLink(c echo.Context, email string) error {
+	if a.cfg.MagicLinkURL == "" {
+		return ErrMagicLinkNotConfigured
+	}
+
+	u, err := a.udb.FindByEmail(a.db, email)
+	if err == pg.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !u.Active || u.Locked() {
+		return nil
+	}
+
+	if err := a.magicLinkAllowed(u); err != nil {
+		if err == ErrMagicLinkNotAllowed {
+			return nil
+		}
+		return err
+	}
+
+	if !u.MagicLinkSent(maxMagicLinks, magicLinkWindow) {
+		return nil
+	}
+	if err := a.udb.UpdateMagicLinks(a.db, u); err != nil {
+		return err
+	}
+
+	if err := a.udb.DeleteUserTokens(a.db, gorsk.TokenMagicLink, u.ID); err != nil {
+		return err
+	}
+
+	token, err := a.sec.Token()
+	if err != nil {
+		return err
+	}
+	duration := a.magicLinkDuration()
+	if err := a.udb.CreateToken(a.db, gorsk.OneTimeToken{
+		UserID:    u.ID,
+		Purpose:   gorsk.TokenMagicLink,
+		Token:     a.sec.HashToken(token),
+		ExpiresAt: time.Now().Add(duration),
+	}); err != nil {
+		return err
+	}
+
+	return a.mail.Send(u.Email, "Your login link", fmt.Sprintf(magicLinkMail, u.FirstName, int(duration.Minutes()), a.magicLinkURL(token)))
+}
+
+// MagicLinkLogin logs in the user the magic link was sent to. The link can be used only once.
+// Users with two-factor authentication still have to complete it.
+func (a Auth) MagicLinkLogin(c echo.Context, token string) (gorsk.AuthToken, error) {
+	if a.cfg.MagicLinkURL == "" {
+		return gorsk.AuthToken{}, ErrMagicLinkNotConfigured
+	}
+
+	link, err := a.udb.FindToken(a.db, gorsk.TokenMagicLink, a.sec.HashToken(token))
+	if err == pg.ErrNoRows {
+		return gorsk.AuthToken{}, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if err := a.udb.DeleteToken(a.db, link.ID); err != nil {
+		if err == pg.ErrNoRows {
+			return gorsk.AuthToken{}, ErrInvalidMagicLink
+		}
+		return gorsk.AuthToken{}, err
+	}
+
+	if link.Expired() {
+		return gorsk.AuthToken{}, ErrInvalidMagicLink
+	}
+
+	u, err := a.udb.View(a.db, link.UserID)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if u.Locked() {
+		return gorsk.AuthToken{}, errLocked(u.LockedUntil)
+	}
+
+	if !u.Active {
+		return gorsk.AuthToken{}, gorsk.ErrUnauthorized
+	}
+
+	// company may have disabled login by email link after it was sent
+	if err := a.magicLinkAllowed(u); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	// following the link proves the user owns the email
+	if !u.EmailVerified() {
+		u.EmailVerifiedAt = time.Now()
+	}
+
+	if a.mfaRequired(u) {
+		if err := a.udb.Update(a.db, u); err != nil {
+			return gorsk.AuthToken{}, err
+		}
+		return a.challenge(u)
+	}
+
+	return a.login(c, u, gorsk.LoginMagicLink)
+}
+
+// magicLinkAllowed checks whether user's company allows logging in by email link
+func (a Auth) magicLinkAllowed(u gorsk.User) error {
+	cmp, err := a.udb.ViewCompany(a.db, u.CompanyID)
+	if err == pg.ErrNoRows {
+		return ErrMagicLinkNotAllowed
+	}
+	if err != nil {
+		return err
+	}
+	if !cmp.Active || !cmp.MagicLinkLogin {
+		return ErrMagicLinkNotAllowed
+	}
+	return nil
+}
+
+const magicLinkMail = `Hi %s,
+
+to log in, follow the link below within %d minutes. The link can be used only once:
+
+%s
+
+If you did not request to log in, you can ignore this email.
+`
+
+func (a Auth) magicLinkURL(token string) string {
+	return strings.TrimSuffix(a.cfg.MagicLinkURL, "/") + "/" + url.PathEscape(token)
+}
+
+func (a Auth) magicLinkDuration() time.Duration {
+	if a.cfg.MagicLinkDuration > 0 {
+		return a.cfg.MagicLinkDuration
+	}
+	return defaultMagicLinkDuration
+}
@@ -0,0 +1,394 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/utl/mail"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMagicLink(t *testing.T) {
+	cfg := auth.Config{MagicLinkURL: "https://api.gorsk.com/login/magic/", MagicLinkDuration: 10 * time.Minute}
+	user := gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: "johndoe@mail.com", Active: true, CompanyID: 2}
+	findUser := func(u gorsk.User) func(orm.DB, string) (gorsk.User, error) {
+		return func(orm.DB, string) (gorsk.User, error) {
+			return u, nil
+		}
+	}
+	updateLinks := func(orm.DB, gorsk.User) error {
+		return nil
+	}
+	deleteLinks := func(db orm.DB, purpose string, userID int) error {
+		if purpose != gorsk.TokenMagicLink || userID != 1 {
+			return gorsk.ErrGeneric
+		}
+		return nil
+	}
+	viewCompany := func(enabled bool) func(orm.DB, int) (gorsk.Company, error) {
+		return func(db orm.DB, id int) (gorsk.Company, error) {
+			if id != 2 {
+				return gorsk.Company{}, gorsk.ErrGeneric
+			}
+			return gorsk.Company{Base: gorsk.Base{ID: id}, Active: true, MagicLinkLogin: enabled}, nil
+		}
+	}
+	cases := []struct {
+		name     string
+		cfg      auth.Config
+		udb      *mockdb.User
+		wantErr  error
+		wantMail bool
+	}{
+		{
+			name:    "Not configured",
+			wantErr: auth.ErrMagicLinkNotConfigured,
+		},
+		{
+			name: "Unknown email",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name: "Fail on FindByEmail",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Inactive user",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, Email: "johndoe@mail.com", CompanyID: 2}),
+			},
+		},
+		{
+			name: "Locked user",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, Email: "johndoe@mail.com", Active: true, LockedUntil: time.Now().Add(time.Minute)}),
+			},
+		},
+		{
+			name: "Disabled for company",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(user),
+				ViewCompanyFn: viewCompany(false),
+			},
+		},
+		{
+			name: "Fail on ViewCompany",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, Email: "johndoe@mail.com", Active: true, CompanyID: 3}),
+				ViewCompanyFn: viewCompany(true),
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on UpdateMagicLinks",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(user),
+				ViewCompanyFn: viewCompany(true),
+				UpdateMagicLinksFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on DeleteUserTokens",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn:      findUser(user),
+				ViewCompanyFn:      viewCompany(true),
+				UpdateMagicLinksFn: updateLinks,
+				DeleteUserTokensFn: func(orm.DB, string, int) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on CreateToken",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn:      findUser(user),
+				ViewCompanyFn:      viewCompany(true),
+				UpdateMagicLinksFn: updateLinks,
+				DeleteUserTokensFn: deleteLinks,
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Too many links sent",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: "johndoe@mail.com", Active: true, CompanyID: 2,
+					MagicLinks: 5, MagicLinksSince: time.Now().Add(-10 * time.Minute)}),
+				ViewCompanyFn: viewCompany(true),
+			},
+		},
+		{
+			name: "Links sent long ago are not counted",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: "johndoe@mail.com", Active: true, CompanyID: 2,
+					MagicLinks: 5, MagicLinksSince: time.Now().Add(-2 * time.Hour)}),
+				ViewCompanyFn: viewCompany(true),
+				UpdateMagicLinksFn: func(db orm.DB, u gorsk.User) error {
+					if u.MagicLinks != 1 || time.Since(u.MagicLinksSince) > time.Minute {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserTokensFn: deleteLinks,
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+			wantMail: true,
+		},
+		{
+			name: "Success",
+			cfg:  cfg,
+			udb: &mockdb.User{
+				FindByEmailFn: findUser(gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: "johndoe@mail.com", Active: true, CompanyID: 2,
+					MagicLinks: 4, MagicLinksSince: time.Now().Add(-10 * time.Minute)}),
+				ViewCompanyFn: viewCompany(true),
+				UpdateMagicLinksFn: func(db orm.DB, u gorsk.User) error {
+					if u.MagicLinks != 5 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserTokensFn: deleteLinks,
+				CreateTokenFn: func(db orm.DB, link gorsk.OneTimeToken) error {
+					if link.UserID != 1 || link.Purpose != gorsk.TokenMagicLink || link.Token != "hash:logintoken" {
+						return gorsk.ErrGeneric
+					}
+					if d := time.Until(link.ExpiresAt); d < 9*time.Minute || d > 10*time.Minute {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantMail: true,
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "logintoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := new(mail.Memory)
+			s := auth.New(nil, tt.udb, nil, sec, nil, nil, nil, mailer, tt.cfg)
+			err := s.MagicLink(nil, "johndoe@mail.com")
+			assert.Equal(t, tt.wantErr, err)
+			msgs := mailer.Messages()
+			if !tt.wantMail {
+				assert.Empty(t, msgs)
+				return
+			}
+			if assert.Len(t, msgs, 1) {
+				assert.Equal(t, "johndoe@mail.com", msgs[0].To)
+				assert.True(t, strings.HasPrefix(msgs[0].Body, "Hi John,"))
+				assert.Contains(t, msgs[0].Body, "within 10 minutes")
+				assert.Contains(t, msgs[0].Body, "https://api.gorsk.com/login/magic/logintoken\n")
+			}
+		})
+	}
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	cfg := auth.Config{MagicLinkURL: "https://api.gorsk.com/login/magic"}
+	pending := gorsk.OneTimeToken{ID: 1, UserID: 1, Purpose: gorsk.TokenMagicLink, Token: "hash:logintoken", ExpiresAt: time.Now().Add(time.Minute)}
+	findLink := func(link gorsk.OneTimeToken) func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+		return func(db orm.DB, purpose, token string) (gorsk.OneTimeToken, error) {
+			if purpose != link.Purpose || token != link.Token {
+				return gorsk.OneTimeToken{}, pg.ErrNoRows
+			}
+			return link, nil
+		}
+	}
+	deleteLink := func(orm.DB, int) error {
+		return nil
+	}
+	viewUser := func(u gorsk.User) func(orm.DB, int) (gorsk.User, error) {
+		return func(orm.DB, int) (gorsk.User, error) {
+			return u, nil
+		}
+	}
+	viewCompany := func(enabled bool) func(orm.DB, int) (gorsk.Company, error) {
+		return func(db orm.DB, id int) (gorsk.Company, error) {
+			return gorsk.Company{Base: gorsk.Base{ID: id}, Active: true, MagicLinkLogin: enabled}, nil
+		}
+	}
+	user := gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: "johndoe@mail.com", Active: true, CompanyID: 2, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}
+
+	cases := []struct {
+		name     string
+		token    string
+		cfg      auth.Config
+		udb      *mockdb.User
+		wantData gorsk.AuthToken
+		wantErr  error
+	}{
+		{
+			name:    "Not configured",
+			token:   "logintoken",
+			wantErr: auth.ErrMagicLinkNotConfigured,
+		},
+		{
+			name:  "Unknown token",
+			token: "other",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn: findLink(pending),
+			},
+			wantErr: auth.ErrInvalidMagicLink,
+		},
+		{
+			name:  "Link already used",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn: findLink(pending),
+				DeleteTokenFn: func(orm.DB, int) error {
+					return pg.ErrNoRows
+				},
+			},
+			wantErr: auth.ErrInvalidMagicLink,
+		},
+		{
+			name:  "Expired link",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn:   findLink(gorsk.OneTimeToken{ID: 1, UserID: 1, Purpose: gorsk.TokenMagicLink, Token: "hash:logintoken", ExpiresAt: mock.TestTime(2000)}),
+				DeleteTokenFn: deleteLink,
+			},
+			wantErr: auth.ErrInvalidMagicLink,
+		},
+		{
+			name:  "Inactive user",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn:   findLink(pending),
+				DeleteTokenFn: deleteLink,
+				ViewFn:        viewUser(gorsk.User{Base: gorsk.Base{ID: 1}, CompanyID: 2}),
+			},
+			wantErr: gorsk.ErrUnauthorized,
+		},
+		{
+			name:  "Disabled for company after link was sent",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn:   findLink(pending),
+				DeleteTokenFn: deleteLink,
+				ViewFn:        viewUser(user),
+				ViewCompanyFn: viewCompany(false),
+			},
+			wantErr: auth.ErrMagicLinkNotAllowed,
+		},
+		{
+			name:  "Challenge user with two-factor authentication",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				FindTokenFn:   findLink(pending),
+				DeleteTokenFn: deleteLink,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					u := user
+					u.MFAEnabled = true
+					return u, nil
+				},
+				ViewCompanyFn: viewCompany(true),
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if !u.EmailVerified() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{MFAToken: "refreshtoken"},
+		},
+		{
+			name:  "Success",
+			token: "logintoken",
+			cfg:   cfg,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn:   findLink(pending),
+				DeleteTokenFn: deleteLink,
+				ViewFn:        viewUser(user),
+				ViewCompanyFn: viewCompany(true),
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if !u.EmailVerified() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "refreshtoken"},
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "refreshtoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, jwt, sec, nil, nil, nil, nil, tt.cfg)
+			token, err := s.MagicLinkLogin(newCtx(), tt.token)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, token)
+		})
+	}
+}
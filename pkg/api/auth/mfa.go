@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Duration for which the second step of login can be completed
+const mfaChallengeDuration = 5 * time.Minute
+
+// Number of recovery codes generated when two-factor authentication is enabled
+const recoveryCodesCount = 10
+
+// Number of wrong codes after which a login challenge is discarded
+const maxChallengeAttempts = 5
+
+// Custom errors
+var (
+	ErrInvalidChallenge  = echo.NewHTTPError(http.StatusUnauthorized, "Login challenge is invalid or has expired, please log in again")
+	ErrInvalidMFACode    = echo.NewHTTPError(http.StatusUnauthorized, "Two-factor authentication code is invalid")
+	ErrMFANotEnrolled    = echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication is not set up")
+	ErrMFAAlreadyEnabled = echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	ErrMFARequired       = echo.NewHTTPError(http.StatusForbidden, "Two-factor authentication is required for your role")
+)
+
+// VerifyMFA completes login of a user with two-factor authentication, by code from authenticator app or by recovery code.
+// If the user was required to set up two-factor authentication during login, it gets enabled and recovery codes are returned.
+// The challenge is discarded after maxChallengeAttempts wrong codes.
+func (a Auth) VerifyMFA(c echo.Context, mfaToken, code string) (gorsk.AuthToken, error) {
+	ch, u, err := a.findChallenge(mfaToken)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if u.MFASecret == "" {
+		return gorsk.AuthToken{}, ErrMFANotEnrolled
+	}
+
+	enrolling := !u.MFAEnabled
+	if !a.validate(&u, code) && (enrolling || !u.UseRecoveryCode(a.recoveryCodeHash(code))) {
+		if err := a.challengeFailed(ch); err != nil {
+			return gorsk.AuthToken{}, err
+		}
+		return gorsk.AuthToken{}, a.loginFailed(u, ErrInvalidMFACode)
+	}
+
+	var codes []string
+	if enrolling {
+		if codes, err = a.enable(&u); err != nil {
+			return gorsk.AuthToken{}, err
+		}
+	}
+
+	if err := a.udb.UpdateMFA(a.db, u); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if err := a.udb.DeleteToken(a.db, ch.ID); err != nil {
+		if err == pg.ErrNoRows {
+			return gorsk.AuthToken{}, ErrInvalidChallenge
+		}
+		return gorsk.AuthToken{}, err
+	}
+
+	token, err := a.login(c, u, gorsk.LoginMFA)
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	token.RecoveryCodes = codes
+	return token, nil
+}
+
+// EnrollMFAChallenge starts setting up two-factor authentication for a user required to have it before logging in
+func (a Auth) EnrollMFAChallenge(c echo.Context, mfaToken string) (gorsk.MFAEnrollment, error) {
+	_, u, err := a.findChallenge(mfaToken)
+	if err != nil {
+		return gorsk.MFAEnrollment{}, err
+	}
+	return a.enroll(u)
+}
+
+// EnrollMFA starts setting up two-factor authentication for currently logged user.
+// It is enabled once a code generated from the returned secret is confirmed by EnableMFA.
+func (a Auth) EnrollMFA(c echo.Context) (gorsk.MFAEnrollment, error) {
+	au, err := a.selfUser(c)
+	if err != nil {
+		return gorsk.MFAEnrollment{}, err
+	}
+	u, err := a.udb.View(a.db, au.ID)
+	if err != nil {
+		return gorsk.MFAEnrollment{}, err
+	}
+	return a.enroll(u)
+}
+
+// EnableMFA enables two-factor authentication for currently logged user and returns new recovery codes
+func (a Auth) EnableMFA(c echo.Context, code string) ([]string, error) {
+	au, err := a.selfUser(c)
+	if err != nil {
+		return nil, err
+	}
+	u, err := a.udb.View(a.db, au.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if u.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if !a.validate(&u, code) {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := a.enable(&u)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.udb.UpdateMFA(a.db, u); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableMFA disables two-factor authentication for currently logged user, unless it is required for user's role
+func (a Auth) DisableMFA(c echo.Context, code string) error {
+	au, err := a.selfUser(c)
+	if err != nil {
+		return err
+	}
+	u, err := a.udb.View(a.db, au.ID)
+	if err != nil {
+		return err
+	}
+
+	if !u.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+
+	if a.mfaForced(u) {
+		return ErrMFARequired
+	}
+
+	if !a.validate(&u, code) && !u.UseRecoveryCode(a.recoveryCodeHash(code)) {
+		return ErrInvalidMFACode
+	}
+
+	u.DisableMFA()
+	return a.udb.UpdateMFA(a.db, u)
+}
+
+// mfaRequired reports whether logging in requires the second step
+func (a Auth) mfaRequired(u gorsk.User) bool {
+	return u.MFAEnabled || a.mfaForced(u)
+}
+
+// mfaForced reports whether two-factor authentication is required for user's role
+func (a Auth) mfaForced(u gorsk.User) bool {
+	return a.cfg.ForceMFARole > 0 && u.Role != nil && u.Role.AccessLevel <= a.cfg.ForceMFARole
+}
+
+// validate checks code from authenticator app, recording its time step so the code cannot be used again
+func (a Auth) validate(u *gorsk.User, code string) bool {
+	step, ok := a.otp.Validate(u.MFASecret, code, u.MFACounter)
+	if ok {
+		u.MFACounter = step
+	}
+	return ok
+}
+
+// challenge starts the second step of login
+func (a Auth) challenge(u gorsk.User) (gorsk.AuthToken, error) {
+	token, err := a.sec.Token()
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	if err := a.udb.CreateToken(a.db, gorsk.OneTimeToken{
+		UserID:    u.ID,
+		Purpose:   gorsk.TokenMFAChallenge,
+		Token:     a.sec.HashToken(token),
+		ExpiresAt: time.Now().Add(mfaChallengeDuration),
+	}); err != nil {
+		return gorsk.AuthToken{}, err
+	}
+	return gorsk.AuthToken{MFAToken: token, MFAEnrollmentRequired: !u.MFAEnabled}, nil
+}
+
+// challengeFailed counts wrong code entered for the challenge, discarding it once maxChallengeAttempts is reached
+func (a Auth) challengeFailed(ch gorsk.OneTimeToken) error {
+	ch.Attempts++
+	if ch.Attempts >= maxChallengeAttempts {
+		if err := a.udb.DeleteToken(a.db, ch.ID); err != nil && err != pg.ErrNoRows {
+			return err
+		}
+		return nil
+	}
+	return a.udb.UpdateToken(a.db, ch)
+}
+
+// findChallenge returns pending challenge and the user allowed to complete it
+func (a Auth) findChallenge(mfaToken string) (gorsk.OneTimeToken, gorsk.User, error) {
+	ch, err := a.udb.FindToken(a.db, gorsk.TokenMFAChallenge, a.sec.HashToken(mfaToken))
+	if err == pg.ErrNoRows {
+		return gorsk.OneTimeToken{}, gorsk.User{}, ErrInvalidChallenge
+	}
+	if err != nil {
+		return gorsk.OneTimeToken{}, gorsk.User{}, err
+	}
+
+	if ch.Expired() {
+		return gorsk.OneTimeToken{}, gorsk.User{}, ErrInvalidChallenge
+	}
+
+	u, err := a.udb.View(a.db, ch.UserID)
+	if err != nil {
+		return gorsk.OneTimeToken{}, gorsk.User{}, err
+	}
+
+	if u.Locked() {
+		return gorsk.OneTimeToken{}, gorsk.User{}, errLocked(u.LockedUntil)
+	}
+
+	if !u.Active {
+		return gorsk.OneTimeToken{}, gorsk.User{}, gorsk.ErrUnauthorized
+	}
+
+	return ch, u, nil
+}
+
+// enroll generates new secret for the user, replacing the one not confirmed yet
+func (a Auth) enroll(u gorsk.User) (gorsk.MFAEnrollment, error) {
+	if u.MFAEnabled {
+		return gorsk.MFAEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := a.otp.GenerateSecret()
+	if err != nil {
+		return gorsk.MFAEnrollment{}, err
+	}
+
+	u.MFASecret = secret
+	if err := a.udb.UpdateMFA(a.db, u); err != nil {
+		return gorsk.MFAEnrollment{}, err
+	}
+
+	return gorsk.MFAEnrollment{Secret: secret, URI: a.otp.URI(secret, u.Username)}, nil
+}
+
+// enable enables two-factor authentication, returning recovery codes while only their hashes are kept
+func (a Auth) enable(u *gorsk.User) ([]string, error) {
+	codes, err := a.otp.RecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = a.recoveryCodeHash(c)
+	}
+
+	u.EnableMFA(hashes)
+	return codes, nil
+}
+
+func (a Auth) recoveryCodeHash(code string) string {
+	return a.sec.HashToken(strings.ToUpper(strings.TrimSpace(code)))
+}
@@ -0,0 +1,754 @@
+package auth_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateMFA(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      auth.Config
+		user     gorsk.User
+		wantData gorsk.AuthToken
+		wantErr  bool
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Fail on creating challenge",
+			user:    gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"},
+			wantErr: true,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Challenge when enabled",
+			user: gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"},
+			udb: &mockdb.User{
+				CreateTokenFn: func(db orm.DB, ch gorsk.OneTimeToken) error {
+					if ch.UserID != 1 || ch.Purpose != gorsk.TokenMFAChallenge || ch.Token != "hashedtoken" || ch.Expired() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{MFAToken: "mfatoken"},
+		},
+		{
+			name: "Enrollment required for role",
+			cfg:  auth.Config{ForceMFARole: gorsk.CompanyAdminRole},
+			user: gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}},
+			udb: &mockdb.User{
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{MFAToken: "mfatoken", MFAEnrollmentRequired: true},
+		},
+		{
+			name: "Not required for role",
+			cfg:  auth.Config{ForceMFARole: gorsk.CompanyAdminRole},
+			user: gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}},
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "mfatoken"},
+		},
+	}
+	sec := &mock.Secure{
+		HashMatchesPasswordFn: func(string, string) bool {
+			return true
+		},
+		TokenFn: func() (string, error) {
+			return "mfatoken", nil
+		},
+		HashTokenFn: func(string) string {
+			return "hashedtoken"
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.udb.FindByUsernameFn = func(orm.DB, string) (gorsk.User, error) {
+				return tt.user, nil
+			}
+			s := auth.New(nil, tt.udb, jwt, sec, nil, nil, nil, nil, tt.cfg)
+			token, err := s.Authenticate(newCtx(), "juzernejm", "pass")
+			assert.Equal(t, tt.wantData, token)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	challenge := func(db orm.DB, purpose, token string) (gorsk.OneTimeToken, error) {
+		if purpose != gorsk.TokenMFAChallenge {
+			return gorsk.OneTimeToken{}, pg.ErrNoRows
+		}
+		return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+	}
+	cases := []struct {
+		name     string
+		code     string
+		wantData gorsk.AuthToken
+		wantErr  error
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Unknown challenge",
+			wantErr: auth.ErrInvalidChallenge,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Expired challenge",
+			wantErr: auth.ErrInvalidChallenge,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil
+				},
+			},
+		},
+		{
+			name:    "Inactive user",
+			wantErr: gorsk.ErrUnauthorized,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+			},
+		},
+		{
+			name:    "Not enrolled",
+			wantErr: auth.ErrMFANotEnrolled,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true}, nil
+				},
+			},
+		},
+		{
+			name:    "Invalid code",
+			code:    "000000",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET", RecoveryCodes: []string{"hash:OTHER"}}, nil
+				},
+				UpdateTokenFn: func(db orm.DB, ch gorsk.OneTimeToken) error {
+					if ch.ID != 3 || ch.Attempts != 1 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:    "Fail on UpdateToken",
+			code:    "000000",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Challenge is discarded after too many wrong codes",
+			code:    "000000",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 3, UserID: 1, Attempts: 4, ExpiresAt: time.Now().Add(time.Minute)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFACounter != 10 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteTokenFn: func(db orm.DB, id int) error {
+					if id != 3 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:    "Replayed code",
+			code:    "123456",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET", MFACounter: 10}, nil
+				},
+				UpdateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+		},
+		{
+			name:    "Recovery codes are not accepted during enrollment",
+			code:    "RECOVERY",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFASecret: "SECRET", RecoveryCodes: []string{"hash:RECOVERY"}}, nil
+				},
+				UpdateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+		},
+		{
+			name:    "Challenge already completed",
+			code:    "123456",
+			wantErr: auth.ErrInvalidChallenge,
+			udb: &mockdb.User{
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				DeleteTokenFn: func(orm.DB, int) error {
+					return pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name: "Success with code",
+			code: "123456",
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFACounter != 10 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteTokenFn: func(db orm.DB, id int) error {
+					if id != 3 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "refreshtoken"},
+		},
+		{
+			name: "Success with recovery code",
+			code: " recovery ",
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET", RecoveryCodes: []string{"hash:OTHER", "hash:RECOVERY"}}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if len(u.RecoveryCodes) != 1 || u.RecoveryCodes[0] != "hash:OTHER" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "refreshtoken"},
+		},
+		{
+			name: "Success with enrollment",
+			code: "123456",
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn: challenge,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if !u.MFAEnabled || len(u.RecoveryCodes) != 2 || u.RecoveryCodes[0] != "hash:AAAA" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{
+				Token:         "jwttoken",
+				RefreshToken:  "refreshtoken",
+				RecoveryCodes: []string{"AAAA", "BBBB"},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "refreshtoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	otp := &mock.TOTP{
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 10, secret == "SECRET" && code == "123456" && last < 10
+		},
+		RecoveryCodesFn: func(int) ([]string, error) {
+			return []string{"AAAA", "BBBB"}, nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.udb.UpdateFn = func(orm.DB, gorsk.User) error {
+				return nil
+			}
+			tt.udb.CreateSessionFn = func(orm.DB, gorsk.Session) error {
+				return nil
+			}
+			s := auth.New(nil, tt.udb, jwt, sec, nil, otp, nil, nil, auth.Config{})
+			token, err := s.VerifyMFA(newCtx(), "mfatoken", tt.code)
+			assert.Equal(t, tt.wantData, token)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestVerifyMFALockout(t *testing.T) {
+	udb := &mockdb.User{
+		FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+			return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+		},
+		ViewFn: func(orm.DB, int) (gorsk.User, error) {
+			return gorsk.User{Base: gorsk.Base{ID: 1}, Active: true, MFAEnabled: true, MFASecret: "SECRET", FailedLogins: 2}, nil
+		},
+		UpdateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+			return nil
+		},
+		UpdateLoginAttemptsFn: func(db orm.DB, u gorsk.User) error {
+			if u.FailedLogins != 3 {
+				return gorsk.ErrGeneric
+			}
+			return nil
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	otp := &mock.TOTP{
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 1, false
+		},
+	}
+
+	s := auth.New(nil, udb, nil, sec, nil, otp, nil, nil, auth.Config{MaxLoginAttempts: 3, LockoutDuration: 5 * time.Minute})
+	_, err := s.VerifyMFA(newCtx(), "mfatoken", "000000")
+
+	herr, ok := err.(*echo.HTTPError)
+	if !ok {
+		t.Fatalf("Expected http error, got %v", err)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, herr.Code)
+}
+
+func TestEnrollMFAChallenge(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantData gorsk.MFAEnrollment
+		wantErr  error
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Unknown challenge",
+			wantErr: auth.ErrInvalidChallenge,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Username: "juzernejm", Active: true}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFASecret != "SECRET" || u.MFAEnabled {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantData: gorsk.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/gorsk:juzernejm?secret=SECRET"},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, sec, nil, newTOTP(), nil, nil, auth.Config{})
+			enrollment, err := s.EnrollMFAChallenge(nil, "mfatoken")
+			assert.Equal(t, tt.wantData, enrollment)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestEnrollMFA(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantData gorsk.MFAEnrollment
+		wantErr  error
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Fail on viewing user",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Already enabled",
+			wantErr: auth.ErrMFAAlreadyEnabled,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on updating user",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, Username: "juzernejm"}, nil
+				},
+				UpdateMFAFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, Username: "juzernejm", MFASecret: "UNCONFIRMED"}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFASecret != "SECRET" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantData: gorsk.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/gorsk:juzernejm?secret=SECRET"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, nil, mfaRBAC(), newTOTP(), nil, nil, auth.Config{})
+			enrollment, err := s.EnrollMFA(nil)
+			assert.Equal(t, tt.wantData, enrollment)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestEnableMFA(t *testing.T) {
+	cases := []struct {
+		name     string
+		code     string
+		wantData []string
+		wantErr  error
+		udb      *mockdb.User
+	}{
+		{
+			name:    "Already enabled",
+			wantErr: auth.ErrMFAAlreadyEnabled,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+			},
+		},
+		{
+			name:    "Not enrolled",
+			wantErr: auth.ErrMFANotEnrolled,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}}, nil
+				},
+			},
+		},
+		{
+			name:    "Invalid code",
+			code:    "000000",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFASecret: "SECRET"}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on updating user",
+			code:    "123456",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			code: "123456",
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if !u.MFAEnabled || u.MFASecret != "SECRET" || len(u.RecoveryCodes) != 2 || u.RecoveryCodes[1] != "hash:BBBB" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantData: []string{"AAAA", "BBBB"},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, sec, mfaRBAC(), newTOTP(), nil, nil, auth.Config{})
+			codes, err := s.EnableMFA(nil, tt.code)
+			assert.Equal(t, tt.wantData, codes)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestDisableMFA(t *testing.T) {
+	cases := []struct {
+		name    string
+		code    string
+		cfg     auth.Config
+		wantErr error
+		udb     *mockdb.User
+	}{
+		{
+			name:    "Not enabled",
+			wantErr: auth.ErrMFANotEnrolled,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFASecret: "SECRET"}, nil
+				},
+			},
+		},
+		{
+			name:    "Required for role",
+			code:    "123456",
+			cfg:     auth.Config{ForceMFARole: gorsk.CompanyAdminRole},
+			wantErr: auth.ErrMFARequired,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true, MFASecret: "SECRET", Role: &gorsk.Role{AccessLevel: gorsk.CompanyAdminRole}}, nil
+				},
+			},
+		},
+		{
+			name:    "Invalid code",
+			code:    "000000",
+			wantErr: auth.ErrInvalidMFACode,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+			},
+		},
+		{
+			name: "Success with recovery code",
+			code: "recovery",
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true, MFASecret: "SECRET", RecoveryCodes: []string{"hash:RECOVERY"}}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFAEnabled || u.MFASecret != "" || len(u.RecoveryCodes) != 0 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name: "Success",
+			code: "123456",
+			cfg:  auth.Config{ForceMFARole: gorsk.CompanyAdminRole},
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 9}, MFAEnabled: true, MFASecret: "SECRET", Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, nil
+				},
+				UpdateMFAFn: func(db orm.DB, u gorsk.User) error {
+					if u.MFAEnabled {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, nil, sec, mfaRBAC(), newTOTP(), nil, nil, tt.cfg)
+			err := s.DisableMFA(nil, tt.code)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestMFANotAllowed(t *testing.T) {
+	cases := []struct {
+		name string
+		user gorsk.AuthUser
+	}{
+		{
+			name: "Impersonated user",
+			user: gorsk.AuthUser{ID: 9, ImpersonatorID: 1},
+		},
+		{
+			name: "API key",
+			user: gorsk.AuthUser{ID: 9, APIKeyID: 3},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rbac := &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return tt.user
+				},
+			}
+			s := auth.New(nil, nil, nil, nil, rbac, newTOTP(), nil, nil, auth.Config{})
+
+			_, err := s.EnrollMFA(nil)
+			assert.Equal(t, auth.ErrSecurityNotAllowed, err)
+
+			_, err = s.EnableMFA(nil, "123456")
+			assert.Equal(t, auth.ErrSecurityNotAllowed, err)
+
+			assert.Equal(t, auth.ErrSecurityNotAllowed, s.DisableMFA(nil, "123456"))
+		})
+	}
+}
+
+func mfaRBAC() *mock.RBAC {
+	return &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 9}
+		},
+	}
+}
+
+func newTOTP() *mock.TOTP {
+	return &mock.TOTP{
+		GenerateSecretFn: func() (string, error) {
+			return "SECRET", nil
+		},
+		URIFn: func(secret, account string) string {
+			return "otpauth://totp/gorsk:" + account + "?secret=" + secret
+		},
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 1, secret == "SECRET" && code == "123456"
+		},
+		RecoveryCodesFn: func(int) ([]string, error) {
+			return []string{"AAAA", "BBBB"}, nil
+		},
+	}
+}
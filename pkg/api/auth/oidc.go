@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
+)
+
+// Duration for which login through identity provider can be completed
+const oidcLoginDuration = 10 * time.Minute
+
+// Custom errors
+var (
+	ErrOIDCNotConfigured    = echo.NewHTTPError(http.StatusNotFound, "Login through identity provider is not configured")
+	ErrInvalidOIDCState     = echo.NewHTTPError(http.StatusUnauthorized, "Login through identity provider is invalid or has expired, please log in again")
+	ErrOIDCEmailNotVerified = echo.NewHTTPError(http.StatusForbidden, "Identity provider did not return a verified email")
+	ErrOIDCUnknownUser      = echo.NewHTTPError(http.StatusForbidden, "No account exists for the email returned by identity provider")
+	ErrOIDCLoginFailed      = echo.NewHTTPError(http.StatusUnauthorized, "Login through identity provider failed")
+)
+
+// usernameChars matches characters not allowed in usernames
+var usernameChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// OIDCLogin starts login through identity provider. It returns URL of provider's login page,
+// and the state that has to be presented to OIDCCallback by the same client.
+func (a Auth) OIDCLogin(c echo.Context) (string, string, error) {
+	if a.idp == nil {
+		return "", "", ErrOIDCNotConfigured
+	}
+
+	state, err := a.sec.Token()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := a.sec.Token()
+	if err != nil {
+		return "", "", err
+	}
+	// PKCE requires verifier of 43 to 128 characters
+	verifier, err := a.sec.Token()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := a.idp.AuthURL(state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := a.udb.CreateToken(a.db, gorsk.OneTimeToken{
+		Purpose:   gorsk.TokenOIDCLogin,
+		Token:     a.sec.HashToken(state),
+		Data:      map[string]string{"nonce": nonce, "verifier": verifier},
+		ExpiresAt: time.Now().Add(oidcLoginDuration),
+	}); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// OIDCCallback completes login through identity provider by exchanging the authorization code.
+// The user is found by the verified email returned by the provider, or created with configured default role if it doesn't exist.
+// Users with two-factor authentication still have to complete it.
+func (a Auth) OIDCCallback(c echo.Context, state, code string) (gorsk.AuthToken, error) {
+	if a.idp == nil {
+		return gorsk.AuthToken{}, ErrOIDCNotConfigured
+	}
+
+	login, err := a.udb.FindToken(a.db, gorsk.TokenOIDCLogin, a.sec.HashToken(state))
+	if err == pg.ErrNoRows {
+		return gorsk.AuthToken{}, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if err := a.udb.DeleteToken(a.db, login.ID); err != nil {
+		if err == pg.ErrNoRows {
+			return gorsk.AuthToken{}, ErrInvalidOIDCState
+		}
+		return gorsk.AuthToken{}, err
+	}
+
+	if login.Expired() {
+		return gorsk.AuthToken{}, ErrInvalidOIDCState
+	}
+
+	claims, err := a.idp.Exchange(code, login.Data["verifier"], login.Data["nonce"])
+	if err != nil {
+		// provider's error may reveal its configuration, so it is only logged
+		c.Logger().Errorf("exchanging code with identity provider: %v", err)
+		return gorsk.AuthToken{}, ErrOIDCLoginFailed
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return gorsk.AuthToken{}, ErrOIDCEmailNotVerified
+	}
+
+	u, err := a.udb.FindByEmail(a.db, claims.Email)
+	if err == pg.ErrNoRows {
+		u, err = a.createOIDCUser(claims)
+	}
+	if err != nil {
+		return gorsk.AuthToken{}, err
+	}
+
+	if u.Locked() {
+		return gorsk.AuthToken{}, errLocked(u.LockedUntil)
+	}
+
+	if !u.Active {
+		return gorsk.AuthToken{}, gorsk.ErrUnauthorized
+	}
+
+	newlyVerified := !u.EmailVerified()
+	if newlyVerified {
+		u.EmailVerifiedAt = time.Now()
+	}
+
+	if a.mfaRequired(u) {
+		// the user is saved on login only after the second step, but the email is verified already
+		if newlyVerified {
+			if err := a.udb.Update(a.db, u); err != nil {
+				return gorsk.AuthToken{}, err
+			}
+		}
+		return a.challenge(u)
+	}
+
+	return a.login(c, u, gorsk.LoginOIDC)
+}
+
+// createOIDCUser creates user logging in through identity provider for the first time.
+// The user has no password, so it can log in only through the provider until it resets the password.
+func (a Auth) createOIDCUser(claims oidc.Claims) (gorsk.User, error) {
+	if a.cfg.OIDCDefaultRole == 0 {
+		return gorsk.User{}, ErrOIDCUnknownUser
+	}
+
+	username, err := a.oidcUsername(claims)
+	if err != nil {
+		return gorsk.User{}, err
+	}
+
+	u, err := a.udb.Create(a.db, gorsk.User{
+		FirstName:       claims.GivenName,
+		LastName:        claims.FamilyName,
+		Username:        username,
+		Email:           claims.Email,
+		Active:          true,
+		EmailVerifiedAt: time.Now(),
+		RoleID:          a.cfg.OIDCDefaultRole,
+		CompanyID:       a.cfg.OIDCDefaultCompanyID,
+		LocationID:      a.cfg.OIDCDefaultLocationID,
+	})
+	if err != nil {
+		return gorsk.User{}, err
+	}
+
+	// role is needed for issuing tokens
+	return a.udb.View(a.db, u.ID)
+}
+
+// oidcUsername picks username for user created through identity provider, from its preferred username or email.
+// If the username is taken, a random suffix is appended.
+func (a Auth) oidcUsername(claims oidc.Claims) (string, error) {
+	username := usernameChars.ReplaceAllString(claims.PreferredUsername, "")
+	if len(username) < 3 {
+		username = usernameChars.ReplaceAllString(strings.SplitN(claims.Email, "@", 2)[0], "")
+	}
+	if len(username) < 3 {
+		username = "user" + username
+	}
+
+	_, err := a.udb.FindByUsername(a.db, username)
+	if err == pg.ErrNoRows {
+		return username, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	suffix, err := a.sec.Token()
+	if err != nil {
+		return "", err
+	}
+	return username + suffix[:6], nil
+}
@@ -0,0 +1,354 @@
+package auth_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/auth"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	var n int
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			n++
+			return fmt.Sprintf("token%d", n), nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	cases := []struct {
+		name      string
+		idp       auth.OIDCProvider
+		udb       *mockdb.User
+		wantURL   string
+		wantState string
+		wantErr   error
+	}{
+		{
+			name:    "Not configured",
+			wantErr: auth.ErrOIDCNotConfigured,
+		},
+		{
+			name: "Fail on discovery",
+			idp: &mock.OIDC{
+				AuthURLFn: func(string, string, string) (string, error) {
+					return "", gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			idp: &mock.OIDC{
+				AuthURLFn: func(state, nonce, verifier string) (string, error) {
+					return "https://idp.test/authorize?state=" + state, nil
+				},
+			},
+			udb: &mockdb.User{
+				CreateTokenFn: func(db orm.DB, login gorsk.OneTimeToken) error {
+					if login.Purpose != gorsk.TokenOIDCLogin || login.Token != "hash:token1" || login.Data["nonce"] != "token2" || login.Data["verifier"] != "token3" || login.Expired() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantURL:   "https://idp.test/authorize?state=token1",
+			wantState: "token1",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			n = 0
+			s := auth.New(nil, tt.udb, nil, sec, nil, nil, tt.idp, nil, auth.Config{})
+			authURL, state, err := s.OIDCLogin(nil)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantURL, authURL)
+			assert.Equal(t, tt.wantState, state)
+		})
+	}
+}
+
+func TestOIDCCallback(t *testing.T) {
+	pending := gorsk.OneTimeToken{ID: 1, Purpose: gorsk.TokenOIDCLogin, Token: "hash:state", Data: map[string]string{"nonce": "nonce", "verifier": "verifier"}, ExpiresAt: time.Now().Add(time.Minute)}
+	findLogin := func(login gorsk.OneTimeToken) func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+		return func(db orm.DB, purpose, state string) (gorsk.OneTimeToken, error) {
+			if purpose != login.Purpose || state != login.Token {
+				return gorsk.OneTimeToken{}, pg.ErrNoRows
+			}
+			return login, nil
+		}
+	}
+	deleteLogin := func(orm.DB, int) error {
+		return nil
+	}
+	idp := &mock.OIDC{
+		ExchangeFn: func(code, verifier, nonce string) (oidc.Claims, error) {
+			if verifier != "verifier" || nonce != "nonce" {
+				return oidc.Claims{}, gorsk.ErrGeneric
+			}
+			switch code {
+			case "unverified":
+				return oidc.Claims{Subject: "1", Email: "johndoe@mail.com"}, nil
+			case "new":
+				return oidc.Claims{Subject: "2", Email: "jane.doe@mail.com", EmailVerified: true, GivenName: "Jane", FamilyName: "Doe"}, nil
+			}
+			return oidc.Claims{Subject: "1", Email: "johndoe@mail.com", EmailVerified: true}, nil
+		},
+	}
+	existing := gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: "johndoe@mail.com", Active: true, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "refreshtoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	cases := []struct {
+		name     string
+		code     string
+		state    string
+		cfg      auth.Config
+		udb      *mockdb.User
+		wantData gorsk.AuthToken
+		wantErr  bool
+	}{
+		{
+			name:  "Unknown state",
+			state: "other",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn: findLogin(pending),
+			},
+			wantErr: true,
+		},
+		{
+			name:  "State already used",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn: findLogin(pending),
+				DeleteTokenFn: func(orm.DB, int) error {
+					return pg.ErrNoRows
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Expired login",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(gorsk.OneTimeToken{ID: 1, Purpose: gorsk.TokenOIDCLogin, Token: "hash:state", ExpiresAt: mock.TestTime(2000)}),
+				DeleteTokenFn: deleteLogin,
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Fail on exchange",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(gorsk.OneTimeToken{ID: 1, Purpose: gorsk.TokenOIDCLogin, Token: "hash:state", Data: map[string]string{"nonce": "othernonce"}, ExpiresAt: time.Now().Add(time.Minute)}),
+				DeleteTokenFn: deleteLogin,
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Unverified email",
+			state: "state",
+			code:  "unverified",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Unknown user without default role",
+			state: "state",
+			code:  "new",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Inactive user",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, Email: "johndoe@mail.com"}, nil
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Challenge user with two-factor authentication",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					u := existing
+					u.MFAEnabled = true
+					return u, nil
+				},
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if !u.EmailVerified() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{MFAToken: "refreshtoken"},
+		},
+		{
+			name:  "Fail on saving verified email of user with two-factor authentication",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					u := existing
+					u.MFAEnabled = true
+					return u, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Success existing user",
+			state: "state",
+			code:  "code",
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return existing, nil
+				},
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if !u.EmailVerified() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "refreshtoken"},
+		},
+		{
+			name:  "Success new user",
+			state: "state",
+			code:  "new",
+			cfg:   auth.Config{OIDCDefaultRole: gorsk.UserRole, OIDCDefaultCompanyID: 2, OIDCDefaultLocationID: 3},
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn:   findLogin(pending),
+				DeleteTokenFn: deleteLogin,
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+				FindByUsernameFn: func(db orm.DB, username string) (gorsk.User, error) {
+					if username != "janedoe" {
+						return gorsk.User{}, gorsk.ErrGeneric
+					}
+					return gorsk.User{}, pg.ErrNoRows
+				},
+				CreateFn: func(db orm.DB, u gorsk.User) (gorsk.User, error) {
+					if u.Username != "janedoe" || u.FirstName != "Jane" || !u.Active || !u.EmailVerified() ||
+						u.RoleID != gorsk.UserRole || u.CompanyID != 2 || u.LocationID != 3 || u.Password != "" {
+						return gorsk.User{}, gorsk.ErrGeneric
+					}
+					u.ID = 2
+					return u, nil
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}, Active: true, EmailVerifiedAt: time.Now(), Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+			},
+			wantData: gorsk.AuthToken{Token: "jwttoken", RefreshToken: "refreshtoken"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := auth.New(nil, tt.udb, jwt, sec, nil, nil, idp, nil, tt.cfg)
+			token, err := s.OIDCCallback(newCtx(), tt.state, tt.code)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, token)
+		})
+	}
+}
+
+func TestOIDCCallbackProviderError(t *testing.T) {
+	udb := &mockdb.User{
+		FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+			return gorsk.OneTimeToken{ID: 1, Purpose: gorsk.TokenOIDCLogin, Token: "hash:state", ExpiresAt: time.Now().Add(time.Minute)}, nil
+		},
+		DeleteTokenFn: func(orm.DB, int) error {
+			return nil
+		},
+	}
+	idp := &mock.OIDC{
+		ExchangeFn: func(string, string, string) (oidc.Claims, error) {
+			return oidc.Claims{}, fmt.Errorf("invalid_client: client secret mismatch")
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+
+	s := auth.New(nil, udb, nil, sec, nil, nil, idp, nil, auth.Config{})
+	_, err := s.OIDCCallback(newCtx(), "state", "code")
+
+	// provider's error is logged, not returned to the client
+	assert.Equal(t, auth.ErrOIDCLoginFailed, err)
+	assert.Nil(t, auth.ErrOIDCLoginFailed.Internal)
+}
@@ -1,13 +1,25 @@
 package pgsql
 
 import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 
 	"github.com/ribice/gorsk"
+	onetime "github.com/ribice/gorsk/pkg/utl/onetime/platform/pgsql"
 )
 
-// User represents the client for user table
-type User struct{}
+// User represents the client for user table, and for one-time tokens given out to users
+type User struct {
+	onetime.Token
+}
+
+// Custom errors
+var (
+	ErrTokenUsed = errors.New("refresh token was already rotated")
+)
 
 // View returns single user by ID
 func (u User) View(db orm.DB, id int) (gorsk.User, error) {
@@ -29,13 +41,19 @@ func (u User) FindByUsername(db orm.DB, uname string) (gorsk.User, error) {
 	return user, err
 }
 
-// FindByToken queries for single user by token
-func (u User) FindByToken(db orm.DB, token string) (gorsk.User, error) {
+// FindByEmail queries for single user by email
+func (u User) FindByEmail(db orm.DB, email string) (gorsk.User, error) {
 	var user gorsk.User
 	sql := `SELECT "user".*, "role"."id" AS "role__id", "role"."access_level" AS "role__access_level", "role"."name" AS "role__name" 
 	FROM "users" AS "user" LEFT JOIN "roles" AS "role" ON "role"."id" = "user"."role_id" 
-	WHERE ("user"."token" = ? and deleted_at is null)`
-	_, err := db.QueryOne(&user, sql, token)
+	WHERE (lower("user"."email") = lower(?) and deleted_at is null)`
+	_, err := db.QueryOne(&user, sql, email)
+	return user, err
+}
+
+// Create creates a new user on database
+func (u User) Create(db orm.DB, user gorsk.User) (gorsk.User, error) {
+	err := db.Insert(&user)
 	return user, err
 }
 
@@ -43,3 +61,143 @@ func (u User) FindByToken(db orm.DB, token string) (gorsk.User, error) {
 func (u User) Update(db orm.DB, user gorsk.User) error {
 	return db.Update(&user)
 }
+
+// UpdateLoginAttempts updates user's failed login attempts and lockout
+func (u User) UpdateLoginAttempts(db orm.DB, user gorsk.User) error {
+	_, err := db.Model(&user).Column("failed_logins", "locked_until").WherePK().Update()
+	return err
+}
+
+// UpdateMagicLinks updates number of login links sent to the user by email
+func (u User) UpdateMagicLinks(db orm.DB, user gorsk.User) error {
+	_, err := db.Model(&user).Column("magic_links", "magic_links_since").WherePK().Update()
+	return err
+}
+
+// UpdateMFA updates user's two-factor authentication settings
+func (u User) UpdateMFA(db orm.DB, user gorsk.User) error {
+	_, err := db.Model(&user).Column("mfa_enabled", "mfa_secret", "mfa_counter", "recovery_codes").WherePK().Update()
+	return err
+}
+
+// ViewCompany returns company the user belongs to
+func (u User) ViewCompany(db orm.DB, id int) (gorsk.Company, error) {
+	company := gorsk.Company{Base: gorsk.Base{ID: id}}
+	err := db.Select(&company)
+	return company, err
+}
+
+// CreateLoginEvent stores login attempt of the user
+func (u User) CreateLoginEvent(db orm.DB, event gorsk.LoginEvent) error {
+	return db.Insert(&event)
+}
+
+// KnownLogin reports whether the user has logged in successfully from the same IP and user agent before.
+// Login of a user who has never logged in successfully is known as well, as there is nothing to compare it to.
+func (u User) KnownLogin(db orm.DB, event gorsk.LoginEvent) (bool, error) {
+	var known bool
+	sql := `SELECT NOT EXISTS (SELECT 1 FROM "login_events" WHERE "user_id" = ?0 AND "success") 
+	OR EXISTS (SELECT 1 FROM "login_events" WHERE "user_id" = ?0 AND "success" AND "ip" = ?1 AND "user_agent" = ?2)`
+	_, err := db.QueryOne(pg.Scan(&known), sql, event.UserID, event.IP, event.UserAgent)
+	return known, err
+}
+
+// ListLoginEvents returns login attempts of the user, the latest first
+func (u User) ListLoginEvents(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+	var events []gorsk.LoginEvent
+	err := db.Model(&events).Where("user_id = ?", userID).
+		Limit(p.Limit).Offset(p.Offset).Order("created_at desc", "id desc").Select()
+	return events, err
+}
+
+// CreateSession stores newly created session
+func (u User) CreateSession(db orm.DB, session gorsk.Session) error {
+	return db.Insert(&session)
+}
+
+// FindSession queries for single session by its refresh token hash
+func (u User) FindSession(db orm.DB, token string) (gorsk.Session, error) {
+	var session gorsk.Session
+	err := db.Model(&session).Where("token = ?", token).Select()
+	return session, err
+}
+
+// ListSessions returns user's sessions that have not expired yet
+func (u User) ListSessions(db orm.DB, userID int) ([]gorsk.Session, error) {
+	var sessions []gorsk.Session
+	err := db.Model(&sessions).Where("user_id = ?", userID).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("expires_at is null").WhereOr("expires_at > ?", time.Now()), nil
+		}).
+		Order("last_used_at desc").Select()
+	return sessions, err
+}
+
+// RotateSession replaces session's refresh token and keeps the replaced one, so its reuse can be detected.
+// It returns ErrTokenUsed if the token was already rotated by a concurrent request.
+func (u User) RotateSession(db orm.DB, token string, session gorsk.Session) error {
+	res, err := db.Model(&session).Column("token", "user_agent", "ip", "last_used_at").
+		Where("id = ? and token = ?", session.ID, token).Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrTokenUsed
+	}
+	return db.Insert(&gorsk.RefreshToken{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Token:     token,
+		UsedAt:    session.LastUsedAt,
+	})
+}
+
+// FindUsedToken queries for single refresh token that was rotated out of a session
+func (u User) FindUsedToken(db orm.DB, token string) (gorsk.RefreshToken, error) {
+	var rt gorsk.RefreshToken
+	err := db.Model(&rt).Where("token = ?", token).Select()
+	return rt, err
+}
+
+// DeleteSession deletes user's session along with its used refresh tokens.
+// It returns pg.ErrNoRows if user has no such session.
+func (u User) DeleteSession(db orm.DB, userID, id int) error {
+	res, err := db.Model((*gorsk.Session)(nil)).Where("id = ? and user_id = ?", id, userID).Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	_, err = db.Model((*gorsk.RefreshToken)(nil)).Where("session_id = ?", id).Delete()
+	return err
+}
+
+// DeleteUserSessions deletes all sessions of a user along with their used refresh tokens
+func (u User) DeleteUserSessions(db orm.DB, userID int) error {
+	if _, err := db.Model((*gorsk.Session)(nil)).Where("user_id = ?", userID).Delete(); err != nil {
+		return err
+	}
+	_, err := db.Model((*gorsk.RefreshToken)(nil)).Where("user_id = ?", userID).Delete()
+	return err
+}
+
+// RevokeToken adds jwt token to the revocation list.
+// Entries of tokens that have already expired are cleaned up.
+func (u User) RevokeToken(db orm.DB, token gorsk.RevokedToken) error {
+	if _, err := db.Model((*gorsk.RevokedToken)(nil)).Where("expires_at < ?", time.Now()).Delete(); err != nil {
+		return err
+	}
+	_, err := db.Model(&token).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+// IsRevoked reports whether jwt token was revoked, either by its jti or by user's token version.
+// Tokens of deleted or deactivated users are reported as revoked as well.
+func (u User) IsRevoked(db orm.DB, jti string, userID, version int) (bool, error) {
+	var revoked bool
+	sql := `SELECT EXISTS (SELECT 1 FROM "revoked_tokens" WHERE "jti" = ?) 
+	OR NOT EXISTS (SELECT 1 FROM "users" WHERE "id" = ? AND "token_version" = ? AND "active" AND "deleted_at" IS NULL)`
+	_, err := db.QueryOne(pg.Scan(&revoked), sql, jti, userID, version)
+	return revoked, err
+}
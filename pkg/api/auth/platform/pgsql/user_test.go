@@ -2,6 +2,9 @@ package pgsql_test
 
 import (
 	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/utl/mock"
@@ -143,39 +146,67 @@ func TestFindByUsername(t *testing.T) {
 	}
 }
 
-func TestFindByToken(t *testing.T) {
+func TestCreateSession(t *testing.T) {
+	cases := []struct {
+		name    string
+		wantErr bool
+		req     gorsk.Session
+	}{
+		{
+			name: "Success",
+			req: gorsk.Session{
+				UserID:    1,
+				Token:     "loginrefresh",
+				UserAgent: "Mozilla/5.0",
+				IP:        "192.0.2.1",
+			},
+		},
+		{
+			name:    "Token already exists",
+			wantErr: true,
+			req: gorsk.Session{
+				UserID: 2,
+				Token:  "loginrefresh",
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Session{})
+
+	udb := pgsql.User{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := udb.CreateSession(db, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestFindSession(t *testing.T) {
 	cases := []struct {
 		name     string
 		wantErr  bool
 		token    string
-		wantData gorsk.User
+		wantData gorsk.Session
 	}{
 		{
-			name:    "User does not exist",
+			name:    "Session does not exist",
 			wantErr: true,
 			token:   "notExists",
 		},
 		{
 			name:  "Success",
 			token: "loginrefresh",
-			wantData: gorsk.User{
-				Email:      "johndoe@mail.com",
-				FirstName:  "John",
-				LastName:   "Doe",
-				Username:   "johndoe",
-				RoleID:     1,
-				CompanyID:  1,
-				LocationID: 1,
-				Password:   "hunter2",
-				Base: gorsk.Base{
-					ID: 1,
-				},
-				Role: &gorsk.Role{
-					ID:          1,
-					AccessLevel: 1,
-					Name:        "SUPER_ADMIN",
-				},
-				Token: "loginrefresh",
+			wantData: gorsk.Session{
+				ID:        1,
+				UserID:    1,
+				Token:     "loginrefresh",
+				UserAgent: "Mozilla/5.0",
+				IP:        "192.0.2.1",
 			},
 		},
 	}
@@ -183,12 +214,9 @@ func TestFindByToken(t *testing.T) {
 	dbCon := mock.NewPGContainer(t)
 	defer dbCon.Shutdown()
 
-	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+	db := mock.NewDB(t, dbCon, &gorsk.Session{})
 
-	if err := mock.InsertMultiple(db, &gorsk.Role{
-		ID:          1,
-		AccessLevel: 1,
-		Name:        "SUPER_ADMIN"}, &cases[1].wantData); err != nil {
+	if err := mock.InsertMultiple(db, &cases[1].wantData); err != nil {
 		t.Error(err)
 	}
 
@@ -196,18 +224,196 @@ func TestFindByToken(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := udb.FindByToken(db, tt.token)
+			session, err := udb.FindSession(db, tt.token)
 			assert.Equal(t, tt.wantErr, err != nil)
 			if tt.wantData.ID != 0 {
-				tt.wantData.CreatedAt = user.CreatedAt
-				tt.wantData.UpdatedAt = user.UpdatedAt
-				assert.Equal(t, tt.wantData, user)
-
+				assert.Equal(t, tt.wantData, session)
 			}
 		})
 	}
 }
 
+func TestListSessions(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Session{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Session{ID: 1, UserID: 1, Token: "first", LastUsedAt: mock.TestTime(2019)},
+		&gorsk.Session{ID: 2, UserID: 1, Token: "second", LastUsedAt: mock.TestTime(2020), ExpiresAt: time.Now().Add(time.Hour)},
+		&gorsk.Session{ID: 3, UserID: 1, Token: "expired", ExpiresAt: mock.TestTime(2000)},
+		&gorsk.Session{ID: 4, UserID: 2, Token: "other"},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	sessions, err := udb.ListSessions(db, 1)
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, 2, sessions[0].ID)
+		assert.Equal(t, 1, sessions[1].ID)
+	}
+}
+
+func TestRotateSession(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Session{}, &gorsk.RefreshToken{})
+
+	session := gorsk.Session{ID: 1, UserID: 1, Token: "loginrefresh"}
+	if err := mock.InsertMultiple(db, &session); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	rotated := session
+	rotated.Token = "nextrefresh"
+	rotated.IP = "192.0.2.2"
+	rotated.LastUsedAt = time.Now()
+
+	assert.Nil(t, udb.RotateSession(db, "loginrefresh", rotated))
+	assert.Equal(t, pgsql.ErrTokenUsed, udb.RotateSession(db, "loginrefresh", rotated))
+
+	current, err := udb.FindSession(db, "nextrefresh")
+	assert.Nil(t, err)
+	assert.Equal(t, "192.0.2.2", current.IP)
+
+	used, err := udb.FindUsedToken(db, "loginrefresh")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, used.SessionID)
+	assert.Equal(t, 1, used.UserID)
+}
+
+func TestDeleteSession(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Session{}, &gorsk.RefreshToken{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Session{ID: 1, UserID: 1, Token: "current"},
+		&gorsk.Session{ID: 2, UserID: 1, Token: "other"},
+		&gorsk.RefreshToken{ID: 1, SessionID: 1, UserID: 1, Token: "used"},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	assert.Equal(t, pg.ErrNoRows, udb.DeleteSession(db, 2, 1))
+	assert.Nil(t, udb.DeleteSession(db, 1, 1))
+
+	_, err := udb.FindSession(db, "current")
+	assert.NotNil(t, err)
+
+	_, err = udb.FindUsedToken(db, "used")
+	assert.NotNil(t, err)
+
+	_, err = udb.FindSession(db, "other")
+	assert.Nil(t, err)
+}
+
+func TestDeleteUserSessions(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Session{}, &gorsk.RefreshToken{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Session{ID: 1, UserID: 1, Token: "first"},
+		&gorsk.Session{ID: 2, UserID: 1, Token: "second"},
+		&gorsk.Session{ID: 3, UserID: 2, Token: "other"},
+		&gorsk.RefreshToken{ID: 1, SessionID: 1, UserID: 1, Token: "used"},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	if err := udb.DeleteUserSessions(db, 1); err != nil {
+		t.Error(err)
+	}
+
+	_, err := udb.FindSession(db, "first")
+	assert.NotNil(t, err)
+
+	_, err = udb.FindSession(db, "second")
+	assert.NotNil(t, err)
+
+	_, err = udb.FindUsedToken(db, "used")
+	assert.NotNil(t, err)
+
+	_, err = udb.FindSession(db, "other")
+	assert.Nil(t, err)
+}
+
+func TestIsRevoked(t *testing.T) {
+	cases := []struct {
+		name        string
+		jti         string
+		userID      int
+		version     int
+		wantRevoked bool
+	}{
+		{
+			name:        "Revoked jti",
+			jti:         "revoked",
+			userID:      1,
+			version:     1,
+			wantRevoked: true,
+		},
+		{
+			name:        "Outdated token version",
+			jti:         "valid",
+			userID:      1,
+			wantRevoked: true,
+		},
+		{
+			name:        "Inactive user",
+			jti:         "valid",
+			userID:      2,
+			wantRevoked: true,
+		},
+		{
+			name:    "Success",
+			jti:     "valid",
+			userID:  1,
+			version: 1,
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{}, &gorsk.RevokedToken{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", RoleID: 1, Active: true, TokenVersion: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 2}, Username: "tomjones", RoleID: 1}); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	if err := udb.RevokeToken(db, gorsk.RevokedToken{JTI: "revoked", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Error(err)
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := udb.IsRevoked(db, tt.jti, tt.userID, tt.version)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -283,3 +489,173 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateMFA(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	user := gorsk.User{
+		Base:      gorsk.Base{ID: 1},
+		FirstName: "Tom",
+		LastName:  "Jones",
+		Username:  "tomjones",
+		Email:     "tom@jones.com",
+		Active:    true,
+		RoleID:    1,
+	}
+	if err := mock.InsertMultiple(db, &gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"}, &user); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	user.FirstName = "Changed"
+	user.EnableMFA([]string{"first", "second"})
+	user.MFASecret = "SECRET"
+	user.MFACounter = 37037036
+	assert.Nil(t, udb.UpdateMFA(db, user))
+
+	updated, err := udb.View(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Tom", updated.FirstName)
+	assert.True(t, updated.MFAEnabled)
+	assert.Equal(t, "SECRET", updated.MFASecret)
+	assert.Equal(t, int64(37037036), updated.MFACounter)
+	assert.Equal(t, []string{"first", "second"}, updated.RecoveryCodes)
+
+	updated.DisableMFA()
+	assert.Nil(t, udb.UpdateMFA(db, updated))
+
+	disabled, err := udb.View(db, 1)
+	assert.Nil(t, err)
+	assert.False(t, disabled.MFAEnabled)
+	assert.Empty(t, disabled.MFASecret)
+	assert.Empty(t, disabled.RecoveryCodes)
+}
+
+func TestUpdateMagicLinks(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	user := gorsk.User{
+		Base:      gorsk.Base{ID: 1},
+		FirstName: "Tom",
+		LastName:  "Jones",
+		Username:  "tomjones",
+		Email:     "tom@jones.com",
+		Active:    true,
+		RoleID:    1,
+	}
+	if err := mock.InsertMultiple(db, &gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"}, &user); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	user.FirstName = "Changed"
+	user.MagicLinks = 3
+	user.MagicLinksSince = mock.TestTime(2019)
+	assert.Nil(t, udb.UpdateMagicLinks(db, user))
+
+	updated, err := udb.View(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Tom", updated.FirstName)
+	assert.Equal(t, 3, updated.MagicLinks)
+	assert.True(t, mock.TestTime(2019).Equal(updated.MagicLinksSince))
+}
+
+func TestFindByEmail(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Email: "tomjones@mail.com", Username: "tomjones", RoleID: 1},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	user, err := udb.FindByEmail(db, "TomJones@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, user.ID)
+	assert.Equal(t, gorsk.AccessRole(1), user.Role.AccessLevel)
+
+	_, err = udb.FindByEmail(db, "notexists@mail.com")
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	created, err := udb.Create(db, gorsk.User{Email: "janedoe@mail.com", Username: "janedoe", RoleID: 1})
+	assert.Nil(t, err)
+	assert.NotZero(t, created.ID)
+}
+
+func TestViewCompany(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme", Active: true, MagicLinkLogin: true},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	cmp, err := udb.ViewCompany(db, 1)
+	assert.Nil(t, err)
+	assert.True(t, cmp.MagicLinkLogin)
+
+	_, err = udb.ViewCompany(db, 2)
+	assert.Equal(t, pg.ErrNoRows, err)
+}
+
+func TestLoginEvents(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.LoginEvent{})
+
+	udb := pgsql.User{}
+
+	newLogin := gorsk.LoginEvent{UserID: 1, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2020)}
+
+	known, err := udb.KnownLogin(db, newLogin)
+	assert.Nil(t, err)
+	assert.True(t, known)
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.LoginEvent{ID: 1, UserID: 1, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.2", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2018)},
+		&gorsk.LoginEvent{ID: 2, UserID: 1, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2019)},
+		&gorsk.LoginEvent{ID: 3, UserID: 2, Success: true, Method: gorsk.LoginPassword, IP: "192.0.2.1", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2019)},
+	); err != nil {
+		t.Error(err)
+	}
+
+	known, err = udb.KnownLogin(db, newLogin)
+	assert.Nil(t, err)
+	assert.False(t, known)
+
+	assert.Nil(t, udb.CreateLoginEvent(db, newLogin))
+
+	known, err = udb.KnownLogin(db, newLogin)
+	assert.Nil(t, err)
+	assert.True(t, known)
+
+	events, err := udb.ListLoginEvents(db, 1, gorsk.Pagination{Limit: 2})
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "192.0.2.1", events[0].IP)
+		assert.True(t, events[0].Success)
+		assert.Equal(t, 2, events[1].ID)
+		assert.False(t, events[1].Success)
+	}
+}
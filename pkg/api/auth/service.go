@@ -1,35 +1,103 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/auth/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
 )
 
 // New creates new iam service
-func New(db *pg.DB, udb UserDB, j TokenGenerator, sec Securer, rbac RBAC) Auth {
+// Login through external identity provider is disabled if idp is nil.
+func New(db *pg.DB, udb UserDB, j TokenGenerator, sec Securer, rbac RBAC, otp TOTP, idp OIDCProvider, mail gorsk.Mailer, cfg Config) Auth {
 	return Auth{
 		db:   db,
 		udb:  udb,
 		tg:   j,
 		sec:  sec,
 		rbac: rbac,
+		otp:  otp,
+		idp:  idp,
+		mail: mail,
+		cfg:  cfg,
 	}
 }
 
 // Initialize initializes auth application service
-func Initialize(db *pg.DB, j TokenGenerator, sec Securer, rbac RBAC) Auth {
-	return New(db, pgsql.User{}, j, sec, rbac)
+func Initialize(db *pg.DB, j TokenGenerator, sec Securer, rbac RBAC, otp TOTP, idp OIDCProvider, mail gorsk.Mailer, cfg Config) Auth {
+	return New(db, pgsql.User{}, j, sec, rbac, otp, idp, mail, cfg)
+}
+
+// Config represents auth application service configuration
+type Config struct {
+	// Duration for which a refresh token can be exchanged. Unlimited if zero.
+	RefreshDuration time.Duration
+
+	// Duration after login during which the session can be refreshed. Unlimited if zero.
+	MaxRefresh time.Duration
+
+	// Number of consecutive failed logins after which the user gets locked out. Lockout is disabled if zero.
+	MaxLoginAttempts int
+
+	// Duration of the first lockout. Every further failed login doubles it, up to MaxLockoutDuration.
+	LockoutDuration time.Duration
+
+	// Upper limit of lockout duration. Lockout does not grow if it's not greater than LockoutDuration.
+	MaxLockoutDuration time.Duration
+
+	// Users with this role, or a more privileged one, have to use two-factor authentication. Not enforced if zero.
+	ForceMFARole gorsk.AccessRole
+
+	// Refuse login of users who have not verified their email
+	RequireVerifiedEmail bool
+
+	// Role, company and location given to users created on their first login through identity provider.
+	// If OIDCDefaultRole is zero, only existing users can log in through identity provider.
+	OIDCDefaultRole       gorsk.AccessRole
+	OIDCDefaultCompanyID  int
+	OIDCDefaultLocationID int
+
+	// Duration of tokens issued to admins impersonating users. Capped at the duration of regular tokens.
+	ImpersonationDuration time.Duration
+
+	// URL of magic link login endpoint, to which the login token is appended. Magic link login is disabled if empty.
+	MagicLinkURL string
+
+	// Duration for which the magic link can be used
+	MagicLinkDuration time.Duration
+
+	// Notify users by email when they log in from IP and user agent not seen before
+	NotifyNewLogin bool
 }
 
 // Service represents auth service interface
 type Service interface {
 	Authenticate(echo.Context, string, string) (gorsk.AuthToken, error)
-	Refresh(echo.Context, string) (string, error)
+	Refresh(echo.Context, string) (gorsk.AuthToken, error)
+	Logout(echo.Context, string) error
+	LogoutAll(echo.Context) error
+	IsRevoked(echo.Context, string, int, int) (bool, error)
 	Me(echo.Context) (gorsk.User, error)
+	Sessions(echo.Context) ([]gorsk.Session, error)
+	DeleteSession(echo.Context, int) error
+	VerifyMFA(echo.Context, string, string) (gorsk.AuthToken, error)
+	EnrollMFAChallenge(echo.Context, string) (gorsk.MFAEnrollment, error)
+	EnrollMFA(echo.Context) (gorsk.MFAEnrollment, error)
+	EnableMFA(echo.Context, string) ([]string, error)
+	DisableMFA(echo.Context, string) error
+	JWKS(echo.Context) gorsk.JWKS
+	OIDCLogin(echo.Context) (string, string, error)
+	OIDCCallback(echo.Context, string, string) (gorsk.AuthToken, error)
+	Impersonate(echo.Context, int) (gorsk.AuthToken, error)
+	MagicLink(echo.Context, string) error
+	MagicLinkLogin(echo.Context, string) (gorsk.AuthToken, error)
+	Logins(echo.Context, gorsk.Pagination) ([]gorsk.LoginEvent, error)
+	UserLogins(echo.Context, int, gorsk.Pagination) ([]gorsk.LoginEvent, error)
 }
 
 // Auth represents auth application service
@@ -39,28 +107,73 @@ type Auth struct {
 	tg   TokenGenerator
 	sec  Securer
 	rbac RBAC
+	otp  TOTP
+	idp  OIDCProvider
+	mail gorsk.Mailer
+	cfg  Config
 }
 
 // UserDB represents user repository interface
 type UserDB interface {
 	View(orm.DB, int) (gorsk.User, error)
 	FindByUsername(orm.DB, string) (gorsk.User, error)
-	FindByToken(orm.DB, string) (gorsk.User, error)
+	FindByEmail(orm.DB, string) (gorsk.User, error)
+	Create(orm.DB, gorsk.User) (gorsk.User, error)
 	Update(orm.DB, gorsk.User) error
+	UpdateLoginAttempts(orm.DB, gorsk.User) error
+	UpdateMagicLinks(orm.DB, gorsk.User) error
+	UpdateMFA(orm.DB, gorsk.User) error
+	CreateToken(orm.DB, gorsk.OneTimeToken) error
+	FindToken(orm.DB, string, string) (gorsk.OneTimeToken, error)
+	UpdateToken(orm.DB, gorsk.OneTimeToken) error
+	DeleteToken(orm.DB, int) error
+	DeleteUserTokens(orm.DB, string, int) error
+	ViewCompany(orm.DB, int) (gorsk.Company, error)
+	CreateLoginEvent(orm.DB, gorsk.LoginEvent) error
+	KnownLogin(orm.DB, gorsk.LoginEvent) (bool, error)
+	ListLoginEvents(orm.DB, int, gorsk.Pagination) ([]gorsk.LoginEvent, error)
+	CreateSession(orm.DB, gorsk.Session) error
+	FindSession(orm.DB, string) (gorsk.Session, error)
+	ListSessions(orm.DB, int) ([]gorsk.Session, error)
+	RotateSession(orm.DB, string, gorsk.Session) error
+	FindUsedToken(orm.DB, string) (gorsk.RefreshToken, error)
+	DeleteSession(orm.DB, int, int) error
+	DeleteUserSessions(orm.DB, int) error
+	RevokeToken(orm.DB, gorsk.RevokedToken) error
+	IsRevoked(orm.DB, string, int, int) (bool, error)
 }
 
 // TokenGenerator represents token generator (jwt) interface
 type TokenGenerator interface {
 	GenerateToken(gorsk.User) (string, error)
+	GenerateImpersonationToken(gorsk.User, int, time.Duration) (string, error)
+	JWKS() gorsk.JWKS
 }
 
 // Securer represents security interface
 type Securer interface {
 	HashMatchesPassword(string, string) bool
-	Token(string) string
+	Token() (string, error)
+	HashToken(string) string
+}
+
+// TOTP represents time-based one-time password interface
+type TOTP interface {
+	GenerateSecret() (string, error)
+	URI(string, string) string
+	Validate(string, string, int64) (int64, bool)
+	RecoveryCodes(int) ([]string, error)
+}
+
+// OIDCProvider represents external OpenID Connect identity provider interface
+type OIDCProvider interface {
+	AuthURL(string, string, string) (string, error)
+	Exchange(string, string, string) (oidc.Claims, error)
 }
 
 // RBAC represents role-based-access-control interface
 type RBAC interface {
 	User(echo.Context) gorsk.AuthUser
+	Can(echo.Context, string, gorsk.Resource) error
+	IsLowerRole(echo.Context, gorsk.AccessRole) error
 }
@@ -1,21 +1,28 @@
 package transport
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strconv"
 
+	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/auth"
+	authMw "github.com/ribice/gorsk/pkg/utl/middleware/auth"
 
 	"github.com/labstack/echo"
 )
 
 // HTTP represents auth http service
 type HTTP struct {
-	svc auth.Service
+	svc     auth.Service
+	cookies *authMw.Cookies
 }
 
-// NewHTTP creates new auth http service
-func NewHTTP(svc auth.Service, e *echo.Echo, mw echo.MiddlewareFunc) {
-	h := HTTP{svc}
+// NewHTTP creates new auth http service.
+// If cookies is not nil, issued tokens are set as cookies instead of being returned in response body.
+// Deprecated GET /refresh/:token route, which exposes refresh tokens in URLs, is registered only if legacyRefresh is set.
+func NewHTTP(svc auth.Service, e *echo.Echo, mw echo.MiddlewareFunc, cookies *authMw.Cookies, legacyRefresh bool) {
+	h := HTTP{svc, cookies}
 	// swagger:route POST /login auth login
 	// Logs in user by username and password.
 	// responses:
@@ -26,26 +33,160 @@ func NewHTTP(svc auth.Service, e *echo.Echo, mw echo.MiddlewareFunc) {
 	//  404: errMsg
 	//  500: err
 	e.POST("/login", h.login)
-	// swagger:operation GET /refresh/{token} auth refresh
+
+	// swagger:route POST /login/mfa auth loginMFA
+	// Completes login of a user with two-factor authentication, by code from authenticator app or by recovery code.
+	// If two-factor authentication was set up during login, recovery codes are returned as well.
+	// responses:
+	//  200: loginResp
+	//  400: errMsg
+	//  401: errMsg
+	//  429: errMsg
+	//  500: err
+	e.POST("/login/mfa", h.verifyMFA)
+
+	// swagger:route POST /login/mfa/enroll auth loginMFAEnroll
+	// Sets up two-factor authentication for a user required to have it before logging in.
+	// responses:
+	//  200: mfaEnrollResp
+	//  400: errMsg
+	//  401: errMsg
+	//  409: errMsg
+	//  500: err
+	e.POST("/login/mfa/enroll", h.enrollMFAChallenge)
+
+	// swagger:route POST /refresh auth refresh
+	// Refreshes jwt token.
+	// Exchanges refresh token for a new jwt token and a new refresh token. The used refresh token is invalidated, and reusing it revokes the session.
+	// In cookie mode, refresh token cookie is used if set, and request has to carry CSRF header matching CSRF cookie.
+	// responses:
+	//  200: refreshResp
+	//  400: errMsg
+	//  401: errMsg
+	//  403: errMsg
+	//  500: err
+	e.POST("/refresh", h.refresh)
+
+	if legacyRefresh {
+		// swagger:operation GET /refresh/{token} auth refreshLegacy
+		// ---
+		// summary: Refreshes jwt token.
+		// description: Deprecated in favor of POST /refresh, as refresh token in URL path ends up in access logs.
+		// deprecated: true
+		// parameters:
+		// - name: token
+		//   in: path
+		//   description: refresh token
+		//   type: string
+		//   required: true
+		// responses:
+		//   "200":
+		//     "$ref": "#/responses/refreshResp"
+		//   "400":
+		//     "$ref": "#/responses/errMsg"
+		//   "401":
+		//     "$ref": "#/responses/err"
+		//   "500":
+		//     "$ref": "#/responses/err"
+		e.GET("/refresh/:token", h.refreshLegacy)
+	}
+
+	// swagger:route POST /login/magic auth magicLink
+	// Sends a single-use login link to the user with given email, if user's company allows logging in by email link.
+	// The response is the same whether or not the link was sent.
+	// responses:
+	//  200: ok
+	//  400: errMsg
+	//  404: errMsg
+	//  500: err
+	e.POST("/login/magic", h.magicLink)
+
+	// swagger:operation GET /login/magic/{token} auth magicLinkLogin
 	// ---
-	// summary: Refreshes jwt token.
-	// description: Refreshes jwt token by checking at database whether refresh token exists.
+	// summary: Logs in user by the link sent to user's email.
+	// description: The link can be used only once. Users with two-factor authentication still have to complete it.
 	// parameters:
 	// - name: token
 	//   in: path
-	//   description: refresh token
+	//   description: login token from the link
 	//   type: string
 	//   required: true
 	// responses:
 	//   "200":
-	//     "$ref": "#/responses/refreshResp"
-	//   "400":
-	//     "$ref": "#/responses/errMsg"
+	//     "$ref": "#/responses/loginResp"
 	//   "401":
+	//     "$ref": "#/responses/errMsg"
+	//   "403":
+	//     "$ref": "#/responses/errMsg"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "429":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.GET("/login/magic/:token", h.magicLinkLogin)
+
+	// swagger:route GET /auth/oidc/login auth oidcLogin
+	// Starts login through external OpenID Connect identity provider, redirecting to its login page.
+	// responses:
+	//  302: ok
+	//  404: errMsg
+	//  500: err
+	e.GET("/auth/oidc/login", h.oidcLogin)
+
+	// swagger:operation GET /auth/oidc/callback auth oidcCallback
+	// ---
+	// summary: Completes login through external OpenID Connect identity provider.
+	// description: Identity provider redirects here after the user logs in. The user is found by verified email returned by the provider, or created if default role for such users is configured.
+	// parameters:
+	// - name: code
+	//   in: query
+	//   description: authorization code
+	//   type: string
+	//   required: true
+	// - name: state
+	//   in: query
+	//   description: state sent to identity provider
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/loginResp"
+	//   "400":
 	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/errMsg"
+	//   "403":
+	//     "$ref": "#/responses/errMsg"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
 	//   "500":
 	//     "$ref": "#/responses/err"
-	e.GET("/refresh/:token", h.refresh)
+	e.GET("/auth/oidc/callback", h.oidcCallback)
+
+	// swagger:route GET /.well-known/jwks.json auth jwks
+	// Gets public keys for verifying jwt tokens, as JSON Web Key Set.
+	// The set is empty if tokens are signed with a shared secret.
+	// responses:
+	//  200: jwksResp
+	e.GET("/.well-known/jwks.json", h.jwks)
+
+	// swagger:route POST /logout auth logout
+	// Ends current session by revoking the jwt token and the provided refresh token.
+	// responses:
+	//  200: ok
+	//  400: errMsg
+	//  401: err
+	//  500: err
+	e.POST("/logout", h.logout, mw)
+
+	// swagger:route POST /logout/all auth logoutAll
+	// Ends all sessions of the user, on every device.
+	// responses:
+	//  200: ok
+	//  401: err
+	//  500: err
+	e.POST("/logout/all", h.logoutAll, mw)
 
 	// swagger:route GET /me auth meReq
 	// Gets user's info from session.
@@ -53,6 +194,151 @@ func NewHTTP(svc auth.Service, e *echo.Echo, mw echo.MiddlewareFunc) {
 	//  200: userResp
 	//  500: err
 	e.GET("/me", h.me, mw)
+
+	// swagger:route GET /v1/me/sessions auth sessions
+	// Lists active sessions of the user, one per logged in device.
+	// responses:
+	//  200: sessionListResp
+	//  401: err
+	//  500: err
+	e.GET("/v1/me/sessions", h.sessions, mw)
+
+	// swagger:operation DELETE /v1/me/sessions/{id} auth sessionDelete
+	// ---
+	// summary: Ends a single session of the user.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of session
+	//   type: integer
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.DELETE("/v1/me/sessions/:id", h.deleteSession, mw)
+
+	// swagger:operation GET /v1/me/logins auth logins
+	// ---
+	// summary: Returns login history of the user.
+	// description: Lists successful and failed logins and token refreshes of the user, the latest first.
+	// parameters:
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/loginListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.GET("/v1/me/logins", h.logins, mw)
+
+	// swagger:route POST /v1/me/mfa auth mfaEnroll
+	// Starts setting up two-factor authentication. Returns a secret to be added to authenticator app.
+	// responses:
+	//  200: mfaEnrollResp
+	//  401: err
+	//  409: errMsg
+	//  500: err
+	e.POST("/v1/me/mfa", h.enrollMFA, mw)
+
+	// swagger:route POST /v1/me/mfa/enable auth mfaEnable
+	// Enables two-factor authentication by confirming a code generated from the enrolled secret.
+	// responses:
+	//  200: mfaEnableResp
+	//  400: errMsg
+	//  401: errMsg
+	//  409: errMsg
+	//  500: err
+	e.POST("/v1/me/mfa/enable", h.enableMFA, mw)
+
+	// swagger:route POST /v1/me/mfa/disable auth mfaDisable
+	// Disables two-factor authentication, unless it is required for user's role.
+	// responses:
+	//  200: ok
+	//  400: errMsg
+	//  401: errMsg
+	//  403: errMsg
+	//  500: err
+	e.POST("/v1/me/mfa/disable", h.disableMFA, mw)
+
+	// swagger:operation POST /v1/users/{id}/impersonate auth impersonate
+	// ---
+	// summary: Issues a short-lived jwt token for acting as the user.
+	// description: Available to admins, for users with lower role. The token cannot be refreshed, and is always returned in response body. Requests made with it are logged with IDs of both the user and the admin, and GET /me returns admin's ID as impersonated_by.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of user
+	//   type: integer
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/impersonateResp"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/errMsg"
+	//   "404":
+	//     "$ref": "#/responses/err"
+	//   "409":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.POST("/v1/users/:id/impersonate", h.impersonate, mw)
+
+	// swagger:operation GET /v1/users/{id}/logins auth userLogins
+	// ---
+	// summary: Returns login history of a single user.
+	// description: Lists successful and failed logins and token refreshes of the user, the latest first. Available to admins and the user itself.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of user
+	//   type: integer
+	//   required: true
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/loginListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.GET("/v1/users/:id/logins", h.userLogins, mw)
 }
 
 type credentials struct {
@@ -69,17 +355,169 @@ func (h *HTTP) login(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, r)
+	return h.tokenResponse(c, r)
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 func (h *HTTP) refresh(c echo.Context) error {
-	token, err := h.svc.Refresh(c, c.Param("token"))
+	var token string
+	if h.cookies != nil {
+		if token = h.cookies.RefreshToken(c); token != "" {
+			if err := h.cookies.CheckCSRF(c); err != nil {
+				return err
+			}
+		}
+	}
+	if token == "" {
+		req := new(refreshReq)
+		if err := c.Bind(req); err != nil {
+			return err
+		}
+		token = req.RefreshToken
+	}
+	r, err := h.svc.Refresh(c, token)
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, map[string]string{
-		"token": token,
+	return h.tokenResponse(c, r)
+}
+
+func (h *HTTP) refreshLegacy(c echo.Context) error {
+	c.Response().Header().Set("Deprecation", "true")
+	c.Response().Header().Set("Link", `</refresh>; rel="successor-version"`)
+	r, err := h.svc.Refresh(c, c.Param("token"))
+	if err != nil {
+		return err
+	}
+	return h.tokenResponse(c, r)
+}
+
+// tokenResponse returns issued tokens in response body, or sets them as cookies in cookie mode
+func (h *HTTP) tokenResponse(c echo.Context, t gorsk.AuthToken) error {
+	if h.cookies != nil && t.Token != "" {
+		if err := h.cookies.Set(c, t); err != nil {
+			return err
+		}
+		t.Token, t.RefreshToken = "", ""
+	}
+	return c.JSON(http.StatusOK, t)
+}
+
+type magicLinkReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h *HTTP) magicLink(c echo.Context) error {
+	req := new(magicLinkReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if err := h.svc.MagicLink(c, req.Email); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *HTTP) magicLinkLogin(c echo.Context) error {
+	r, err := h.svc.MagicLinkLogin(c, c.Param("token"))
+	if err != nil {
+		return err
+	}
+	return h.tokenResponse(c, r)
+}
+
+// oidcStateCookie binds login through identity provider to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+func (h *HTTP) oidcLogin(c echo.Context) error {
+	authURL, state, err := h.svc.OIDCLogin(c)
+	if err != nil {
+		return err
+	}
+	// Lax, as the callback is a cross-site navigation from identity provider
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc",
+		MaxAge:   600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	})
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *HTTP) oidcCallback(c echo.Context) error {
+	if c.QueryParam("error") != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Login through identity provider was cancelled or refused")
+	}
+
+	state := c.QueryParam("state")
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return auth.ErrInvalidOIDCState
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/auth/oidc",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+	})
+
+	code := c.QueryParam("code")
+	if code == "" {
+		return gorsk.ErrBadRequest
+	}
+
+	r, err := h.svc.OIDCCallback(c, state, code)
+	if err != nil {
+		return err
+	}
+	return h.tokenResponse(c, r)
+}
+
+func (h *HTTP) jwks(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	return c.JSON(http.StatusOK, h.svc.JWKS(c))
+}
+
+type logoutReq struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (h *HTTP) logout(c echo.Context) error {
+	var token string
+	if h.cookies != nil {
+		token = h.cookies.RefreshToken(c)
+	}
+	if token == "" {
+		req := new(logoutReq)
+		if err := c.Bind(req); err != nil {
+			return err
+		}
+		token = req.RefreshToken
+	}
+	if err := h.svc.Logout(c, token); err != nil {
+		return err
+	}
+	if h.cookies != nil {
+		h.cookies.Clear(c)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *HTTP) logoutAll(c echo.Context) error {
+	if err := h.svc.LogoutAll(c); err != nil {
+		return err
+	}
+	if h.cookies != nil {
+		h.cookies.Clear(c)
+	}
+	return c.NoContent(http.StatusOK)
 }
 
 func (h *HTTP) me(c echo.Context) error {
@@ -89,3 +527,144 @@ func (h *HTTP) me(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, user)
 }
+
+func (h *HTTP) sessions(c echo.Context) error {
+	sessions, err := h.svc.Sessions(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, sessionListResponse{sessions})
+}
+
+type sessionListResponse struct {
+	Sessions []gorsk.Session `json:"sessions"`
+}
+
+func (h *HTTP) logins(c echo.Context) error {
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	logins, err := h.svc.Logins(c, req.Transform())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, loginListResponse{logins, req.Page})
+}
+
+func (h *HTTP) userLogins(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	logins, err := h.svc.UserLogins(c, id, req.Transform())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, loginListResponse{logins, req.Page})
+}
+
+type loginListResponse struct {
+	Logins []gorsk.LoginEvent `json:"logins"`
+	Page   int                `json:"page"`
+}
+
+func (h *HTTP) deleteSession(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+	if err := h.svc.DeleteSession(c, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// impersonate returns the token in response body even in cookie mode, so that admin's own session is kept
+func (h *HTTP) impersonate(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+	r, err := h.svc.Impersonate(c, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type mfaLoginReq struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+func (h *HTTP) verifyMFA(c echo.Context) error {
+	req := new(mfaLoginReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	r, err := h.svc.VerifyMFA(c, req.MFAToken, req.Code)
+	if err != nil {
+		return err
+	}
+	return h.tokenResponse(c, r)
+}
+
+type mfaChallengeReq struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+func (h *HTTP) enrollMFAChallenge(c echo.Context) error {
+	req := new(mfaChallengeReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	r, err := h.svc.EnrollMFAChallenge(c, req.MFAToken)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *HTTP) enrollMFA(c echo.Context) error {
+	r, err := h.svc.EnrollMFA(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type mfaCodeReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type mfaEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *HTTP) enableMFA(c echo.Context) error {
+	req := new(mfaCodeReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	codes, err := h.svc.EnableMFA(c, req.Code)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, mfaEnableResponse{codes})
+}
+
+func (h *HTTP) disableMFA(c echo.Context) error {
+	req := new(mfaCodeReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if err := h.svc.DisableMFA(c, req.Code); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
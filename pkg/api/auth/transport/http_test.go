@@ -3,9 +3,13 @@ package transport_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo"
 
@@ -13,11 +17,15 @@ import (
 	"github.com/ribice/gorsk/pkg/api/auth"
 	"github.com/ribice/gorsk/pkg/api/auth/transport"
 	"github.com/ribice/gorsk/pkg/utl/jwt"
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	authMw "github.com/ribice/gorsk/pkg/utl/middleware/auth"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
 	"github.com/ribice/gorsk/pkg/utl/server"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/stretchr/testify/assert"
 )
@@ -47,11 +55,30 @@ func TestLogin(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "Locked out user",
+			req:        `{"username":"juzernejm","password":"hunter123"}`,
+			wantStatus: http.StatusTooManyRequests,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindByUsernameFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{
+						Active:      true,
+						LockedUntil: time.Now().Add(time.Hour),
+					}, nil
+				},
+			},
+		},
 		{
 			name:       "Success",
 			req:        `{"username":"juzernejm","password":"hunter123"}`,
 			wantStatus: http.StatusOK,
 			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
 				FindByUsernameFn: func(orm.DB, string) (gorsk.User, error) {
 					return gorsk.User{
 						Password: "hunter123",
@@ -61,6 +88,9 @@ func TestLogin(t *testing.T) {
 				UpdateFn: func(db orm.DB, u gorsk.User) error {
 					return nil
 				},
+				CreateSessionFn: func(db orm.DB, s gorsk.Session) error {
+					return nil
+				},
 			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(gorsk.User) (string, error) {
@@ -71,8 +101,11 @@ func TestLogin(t *testing.T) {
 				HashMatchesPasswordFn: func(string, string) bool {
 					return true
 				},
-				TokenFn: func(string) string {
-					return "refreshtoken"
+				TokenFn: func() (string, error) {
+					return "refreshtoken", nil
+				},
+				HashTokenFn: func(string) string {
+					return "hashedtoken"
 				},
 			},
 			wantResp: &gorsk.AuthToken{Token: "jwttokenstring", RefreshToken: "refreshtoken"},
@@ -82,7 +115,7 @@ func TestLogin(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
-			transport.NewHTTP(auth.New(nil, tt.udb, tt.jwt, tt.sec, nil), r, nil)
+			transport.NewHTTP(auth.New(nil, tt.udb, tt.jwt, tt.sec, nil, nil, nil, nil, auth.Config{}), r, nil, nil, false)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/login"
@@ -109,55 +142,97 @@ func TestRefresh(t *testing.T) {
 		name       string
 		req        string
 		wantStatus int
-		wantResp   *gorsk.RefreshToken
+		wantResp   *gorsk.AuthToken
 		udb        *mockdb.User
 		jwt        *mock.JWT
 	}{
 		{
-			name:       "Fail on FindByToken",
-			req:        "refreshtoken",
+			name:       "Invalid request",
+			req:        `{"refresh_token":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on FindSession",
+			req:        `{"refresh_token":"refreshtoken"}`,
 			wantStatus: http.StatusInternalServerError,
 			udb: &mockdb.User{
-				FindByTokenFn: func(orm.DB, string) (gorsk.User, error) {
-					return gorsk.User{}, gorsk.ErrGeneric
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:       "Fail on reused token",
+			req:        `{"refresh_token":"refreshtoken"}`,
+			wantStatus: http.StatusUnauthorized,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, pg.ErrNoRows
+				},
+				FindUsedTokenFn: func(orm.DB, string) (gorsk.RefreshToken, error) {
+					return gorsk.RefreshToken{SessionID: 1, UserID: 1}, nil
+				},
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return nil
 				},
 			},
 		},
 		{
 			name:       "Success",
-			req:        "refreshtoken",
+			req:        `{"refresh_token":"refreshtoken"}`,
 			wantStatus: http.StatusOK,
 			udb: &mockdb.User{
-				FindByTokenFn: func(orm.DB, string) (gorsk.User, error) {
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
 					return gorsk.User{
 						Username: "johndoe",
 						Active:   true,
 					}, nil
 				},
+				RotateSessionFn: func(orm.DB, string, gorsk.Session) error {
+					return nil
+				},
 			},
 			jwt: &mock.JWT{
 				GenerateTokenFn: func(gorsk.User) (string, error) {
 					return "jwttokenstring", nil
 				},
 			},
-			wantResp: &gorsk.RefreshToken{Token: "jwttokenstring"},
+			wantResp: &gorsk.AuthToken{Token: "jwttokenstring", RefreshToken: "newrefreshtoken"},
+		},
+	}
+
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "newrefreshtoken", nil
+		},
+		HashTokenFn: func(token string) string {
+			return token
 		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
-			transport.NewHTTP(auth.New(nil, tt.udb, tt.jwt, nil, nil), r, nil)
+			transport.NewHTTP(auth.New(nil, tt.udb, tt.jwt, sec, nil, nil, nil, nil, auth.Config{}), r, nil, nil, false)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
-			path := ts.URL + "/refresh/" + tt.req
-			res, err := http.Get(path)
+			path := ts.URL + "/refresh"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
 			if err != nil {
 				t.Fatal(err)
 			}
 			defer res.Body.Close()
 			if tt.wantResp != nil {
-				response := new(gorsk.RefreshToken)
+				response := new(gorsk.AuthToken)
 				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
 					t.Fatal(err)
 				}
@@ -168,62 +243,218 @@ func TestRefresh(t *testing.T) {
 	}
 }
 
-func TestMe(t *testing.T) {
+func TestRefreshLegacy(t *testing.T) {
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+			if token != "refreshtoken" {
+				return gorsk.Session{}, gorsk.ErrGeneric
+			}
+			return gorsk.Session{ID: 1, UserID: 1}, nil
+		},
+		ViewFn: func(orm.DB, int) (gorsk.User, error) {
+			return gorsk.User{Username: "johndoe", Active: true}, nil
+		},
+		RotateSessionFn: func(orm.DB, string, gorsk.Session) error {
+			return nil
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttokenstring", nil
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "newrefreshtoken", nil
+		},
+		HashTokenFn: func(token string) string {
+			return token
+		},
+	}
+
 	cases := []struct {
 		name       string
+		legacy     bool
 		wantStatus int
-		wantResp   gorsk.User
+	}{
+		{
+			name:       "Disabled",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Enabled",
+			legacy:     true,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, jwt, sec, nil, nil, nil, nil, auth.Config{}), r, nil, nil, tt.legacy)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + "/refresh/refreshtoken")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.legacy {
+				assert.Equal(t, "true", res.Header.Get("Deprecation"))
+				response := new(gorsk.AuthToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, &gorsk.AuthToken{Token: "jwttokenstring", RefreshToken: "newrefreshtoken"}, response)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
 		header     string
+		wantStatus int
 		udb        *mockdb.User
-		rbac       *mock.RBAC
 	}{
 		{
-			name:       "Fail on user view",
-			wantStatus: http.StatusInternalServerError,
+			name:       "Unauthorized",
+			req:        `{"refresh_token":"refreshtoken"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Revoked jwt token",
+			req:        `{"refresh_token":"refreshtoken"}`,
+			header:     mock.HeaderValid(),
+			wantStatus: http.StatusUnauthorized,
 			udb: &mockdb.User{
-				ViewFn: func(orm.DB, int) (gorsk.User, error) {
-					return gorsk.User{}, gorsk.ErrGeneric
+				IsRevokedFn: func(orm.DB, string, int, int) (bool, error) {
+					return true, nil
 				},
 			},
-			rbac: &mock.RBAC{
-				UserFn: func(echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{ID: 1}
+		},
+		{
+			name:       "Invalid request",
+			req:        `{"refresh_token":""}`,
+			header:     mock.HeaderValid(),
+			wantStatus: http.StatusBadRequest,
+			udb: &mockdb.User{
+				IsRevokedFn: func(orm.DB, string, int, int) (bool, error) {
+					return false, nil
+				},
+			},
+		},
+		{
+			name:       "Fail on finding session",
+			req:        `{"refresh_token":"refreshtoken"}`,
+			header:     mock.HeaderValid(),
+			wantStatus: http.StatusInternalServerError,
+			udb: &mockdb.User{
+				IsRevokedFn: func(orm.DB, string, int, int) (bool, error) {
+					return false, nil
+				},
+				FindSessionFn: func(orm.DB, string) (gorsk.Session, error) {
+					return gorsk.Session{}, gorsk.ErrGeneric
 				},
 			},
-			header: mock.HeaderValid(),
 		},
 		{
 			name:       "Success",
+			req:        `{"refresh_token":"refreshtoken"}`,
+			header:     mock.HeaderValid(),
 			wantStatus: http.StatusOK,
 			udb: &mockdb.User{
-				ViewFn: func(db orm.DB, i int) (gorsk.User, error) {
-					return gorsk.User{
-						Base: gorsk.Base{
-							ID: i,
-						},
-						CompanyID:  2,
-						LocationID: 3,
-						Email:      "john@mail.com",
-						FirstName:  "John",
-						LastName:   "Doe",
-					}, nil
+				IsRevokedFn: func(orm.DB, string, int, int) (bool, error) {
+					return false, nil
+				},
+				FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+					return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+				},
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return nil
+				},
+				RevokeTokenFn: func(db orm.DB, token gorsk.RevokedToken) error {
+					if token.JTI != "tokenid" {
+						return gorsk.ErrGeneric
+					}
+					return nil
 				},
 			},
-			rbac: &mock.RBAC{
-				UserFn: func(echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(token string) string {
+			return token
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			svc := auth.New(nil, tt.udb, nil, sec, rbac, nil, nil, nil, auth.Config{})
+			transport.NewHTTP(svc, r, authMw.Middleware(jwt, svc, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/logout", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", tt.header)
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	cases := []struct {
+		name       string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Fail on user view",
+			wantStatus: http.StatusInternalServerError,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
 				},
 			},
-			header: mock.HeaderValid(),
-			wantResp: gorsk.User{
-				Base: gorsk.Base{
-					ID: 1,
+		},
+		{
+			name:       "Success",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}}, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				DeleteUserSessionsFn: func(orm.DB, int) error {
+					return nil
 				},
-				CompanyID:  2,
-				LocationID: 3,
-				Email:      "john@mail.com",
-				FirstName:  "John",
-				LastName:   "Doe",
 			},
 		},
 	}
@@ -233,32 +464,1412 @@ func TestMe(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
-			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, tt.rbac), r, authMw.Middleware(jwt))
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
-			path := ts.URL + "/me"
-			req, err := http.NewRequest("GET", path, nil)
-			req.Header.Set("Authorization", tt.header)
+			req, err := http.NewRequest("POST", ts.URL+"/logout/all", nil)
 			if err != nil {
 				t.Fatal(err)
 			}
+			req.Header.Set("Authorization", mock.HeaderValid())
 			res, err := client.Do(req)
 			if err != nil {
 				t.Fatal(err)
 			}
 			defer res.Body.Close()
-			if tt.wantResp.ID != 0 {
-				var response gorsk.User
-				if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-					t.Fatal(err)
-				}
-				assert.Equal(t, tt.wantResp, response)
-			}
 			assert.Equal(t, tt.wantStatus, res.StatusCode)
 		})
 	}
 }
+
+func TestCookies(t *testing.T) {
+	accessToken := strings.TrimPrefix(mock.HeaderValid(), "Bearer ")
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		req         string
+		cookies     map[string]string
+		csrf        string
+		wantStatus  int
+		wantResp    *gorsk.AuthToken
+		wantCookies []string
+	}{
+		{
+			name:        "Login",
+			method:      "POST",
+			path:        "/login",
+			req:         `{"username":"juzernejm","password":"hunter123"}`,
+			wantStatus:  http.StatusOK,
+			wantResp:    &gorsk.AuthToken{},
+			wantCookies: []string{"access_token=jwttokenstring", "refresh_token=newrefreshtoken", "csrf_token="},
+		},
+		{
+			name:       "Refresh without CSRF token",
+			method:     "POST",
+			path:       "/refresh",
+			cookies:    map[string]string{authMw.RefreshCookie: "refreshtoken", authMw.CSRFCookie: "csrftoken"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Refresh without refresh token",
+			method:     "POST",
+			path:       "/refresh",
+			cookies:    map[string]string{authMw.CSRFCookie: "csrftoken"},
+			csrf:       "csrftoken",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Refresh with token in body",
+			method:      "POST",
+			path:        "/refresh",
+			req:         `{"refresh_token":"refreshtoken"}`,
+			wantStatus:  http.StatusOK,
+			wantResp:    &gorsk.AuthToken{},
+			wantCookies: []string{"access_token=jwttokenstring", "refresh_token=newrefreshtoken", "csrf_token="},
+		},
+		{
+			name:        "Refresh",
+			method:      "POST",
+			path:        "/refresh",
+			cookies:     map[string]string{authMw.RefreshCookie: "refreshtoken", authMw.CSRFCookie: "csrftoken"},
+			csrf:        "csrftoken",
+			wantStatus:  http.StatusOK,
+			wantResp:    &gorsk.AuthToken{},
+			wantCookies: []string{"access_token=jwttokenstring", "refresh_token=newrefreshtoken", "csrf_token="},
+		},
+		{
+			name:       "Logout without CSRF token",
+			method:     "POST",
+			path:       "/logout",
+			cookies:    map[string]string{authMw.AccessCookie: accessToken, authMw.RefreshCookie: "refreshtoken", authMw.CSRFCookie: "csrftoken"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "Logout",
+			method:      "POST",
+			path:        "/logout",
+			cookies:     map[string]string{authMw.AccessCookie: accessToken, authMw.RefreshCookie: "refreshtoken", authMw.CSRFCookie: "csrftoken"},
+			csrf:        "csrftoken",
+			wantStatus:  http.StatusOK,
+			wantCookies: []string{"access_token=;", "refresh_token=;", "csrf_token=;"},
+		},
+	}
+
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		FindByUsernameFn: func(orm.DB, string) (gorsk.User, error) {
+			return gorsk.User{Password: "hunter123", Active: true}, nil
+		},
+		UpdateFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+		CreateSessionFn: func(orm.DB, gorsk.Session) error {
+			return nil
+		},
+		FindSessionFn: func(db orm.DB, token string) (gorsk.Session, error) {
+			if token != "refreshtoken" {
+				return gorsk.Session{}, gorsk.ErrGeneric
+			}
+			return gorsk.Session{ID: 1, UserID: 1, Token: token}, nil
+		},
+		ViewFn: func(orm.DB, int) (gorsk.User, error) {
+			return gorsk.User{Username: "johndoe", Active: true}, nil
+		},
+		RotateSessionFn: func(orm.DB, string, gorsk.Session) error {
+			return nil
+		},
+		IsRevokedFn: func(orm.DB, string, int, int) (bool, error) {
+			return false, nil
+		},
+		DeleteSessionFn: func(orm.DB, int, int) error {
+			return nil
+		},
+		RevokeTokenFn: func(orm.DB, gorsk.RevokedToken) error {
+			return nil
+		},
+	}
+	tg := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttokenstring", nil
+		},
+	}
+	sec := &mock.Secure{
+		HashMatchesPasswordFn: func(string, string) bool {
+			return true
+		},
+		TokenFn: func() (string, error) {
+			return "newrefreshtoken", nil
+		},
+		HashTokenFn: func(token string) string {
+			return token
+		},
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err := authMw.NewCookies("", "strict", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			svc := auth.New(nil, udb, tg, sec, rbac, nil, nil, nil, auth.Config{})
+			transport.NewHTTP(svc, r, authMw.Middleware(jwt, svc, cookies, nil), cookies, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(authMw.CSRFHeader, tt.csrf)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantResp != nil {
+				response := new(gorsk.AuthToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			setCookies := res.Header["Set-Cookie"]
+			if assert.Len(t, setCookies, len(tt.wantCookies)) {
+				for i, want := range tt.wantCookies {
+					assert.True(t, strings.HasPrefix(setCookies[i], want), setCookies[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	cases := []struct {
+		name     string
+		jwt      *mock.JWT
+		wantResp gorsk.JWKS
+	}{
+		{
+			name: "Shared secret",
+			jwt: &mock.JWT{
+				JWKSFn: func() gorsk.JWKS {
+					return gorsk.JWKS{Keys: []gorsk.JWK{}}
+				},
+			},
+			wantResp: gorsk.JWKS{Keys: []gorsk.JWK{}},
+		},
+		{
+			name: "Success",
+			jwt: &mock.JWT{
+				JWKSFn: func() gorsk.JWKS {
+					return gorsk.JWKS{Keys: []gorsk.JWK{{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: "kid", N: "n", E: "AQAB"}}}
+				},
+			},
+			wantResp: gorsk.JWKS{Keys: []gorsk.JWK{{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: "kid", N: "n", E: "AQAB"}}},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, nil, tt.jwt, nil, nil, nil, nil, nil, auth.Config{}), r, nil, nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + "/.well-known/jwks.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "public, max-age=3600", res.Header.Get("Cache-Control"))
+			var resp gorsk.JWKS
+			if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestMe(t *testing.T) {
+	cases := []struct {
+		name       string
+		wantStatus int
+		wantResp   gorsk.User
+		header     string
+		udb        *mockdb.User
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Fail on user view",
+			wantStatus: http.StatusInternalServerError,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1}
+				},
+			},
+			header: mock.HeaderValid(),
+		},
+		{
+			name:       "Success",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, i int) (gorsk.User, error) {
+					return gorsk.User{
+						Base: gorsk.Base{
+							ID: i,
+						},
+						CompanyID:  2,
+						LocationID: 3,
+						Email:      "john@mail.com",
+						FirstName:  "John",
+						LastName:   "Doe",
+					}, nil
+				},
+			},
+			rbac: &mock.RBAC{
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1}
+				},
+			},
+			header: mock.HeaderValid(),
+			wantResp: gorsk.User{
+				Base: gorsk.Base{
+					ID: 1,
+				},
+				CompanyID:  2,
+				LocationID: 3,
+				Email:      "john@mail.com",
+				FirstName:  "John",
+				LastName:   "Doe",
+			},
+		},
+	}
+
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, tt.rbac, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/me"
+			req, err := http.NewRequest("GET", path, nil)
+			req.Header.Set("Authorization", tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp.ID != 0 {
+				var response gorsk.User
+				if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestSessions(t *testing.T) {
+	cases := []struct {
+		name       string
+		wantStatus int
+		wantResp   []gorsk.Session
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Fail on listing sessions",
+			wantStatus: http.StatusInternalServerError,
+			udb: &mockdb.User{
+				ListSessionsFn: func(orm.DB, int) ([]gorsk.Session, error) {
+					return nil, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:       "Success",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				ListSessionsFn: func(db orm.DB, userID int) ([]gorsk.Session, error) {
+					return []gorsk.Session{
+						{ID: 1, UserID: userID, UserAgent: "Mozilla/5.0", IP: "192.0.2.1", CreatedAt: mock.TestTime(2019), LastUsedAt: mock.TestTime(2020)},
+						{ID: 2, UserID: userID, UserAgent: "curl/7.64.1", IP: "192.0.2.2", CreatedAt: mock.TestTime(2019), LastUsedAt: mock.TestTime(2019)},
+					}, nil
+				},
+			},
+			wantResp: []gorsk.Session{
+				{ID: 1, UserAgent: "Mozilla/5.0", IP: "192.0.2.1", CreatedAt: mock.TestTime(2019), LastUsedAt: mock.TestTime(2020)},
+				{ID: 2, UserAgent: "curl/7.64.1", IP: "192.0.2.2", CreatedAt: mock.TestTime(2019), LastUsedAt: mock.TestTime(2019)},
+			},
+		},
+	}
+
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("GET", ts.URL+"/v1/me/sessions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(struct {
+					Sessions []gorsk.Session `json:"sessions"`
+				})
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response.Sessions)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestLogins(t *testing.T) {
+	cases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantResp   []gorsk.LoginEvent
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Invalid pagination",
+			query:      "?page=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on listing logins",
+			wantStatus: http.StatusInternalServerError,
+			udb: &mockdb.User{
+				ListLoginEventsFn: func(orm.DB, int, gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+					return nil, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:       "Success",
+			query:      "?limit=20&page=1",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				ListLoginEventsFn: func(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+					if userID != 1 || p.Limit != 20 || p.Offset != 20 {
+						return nil, gorsk.ErrGeneric
+					}
+					return []gorsk.LoginEvent{
+						{ID: 2, UserID: userID, Success: true, Method: gorsk.LoginRefresh, IP: "192.0.2.1", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2020)},
+						{ID: 1, UserID: userID, Method: gorsk.LoginPassword, IP: "192.0.2.2", UserAgent: "curl/7.64.1", CreatedAt: mock.TestTime(2019)},
+					}, nil
+				},
+			},
+			wantResp: []gorsk.LoginEvent{
+				{ID: 2, Success: true, Method: gorsk.LoginRefresh, IP: "192.0.2.1", UserAgent: "Mozilla/5.0", CreatedAt: mock.TestTime(2020)},
+				{ID: 1, Method: gorsk.LoginPassword, IP: "192.0.2.2", UserAgent: "curl/7.64.1", CreatedAt: mock.TestTime(2019)},
+			},
+		},
+	}
+
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("GET", ts.URL+"/v1/me/logins"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(struct {
+					Logins []gorsk.LoginEvent `json:"logins"`
+					Page   int                `json:"page"`
+				})
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response.Logins)
+				assert.Equal(t, 1, response.Page)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUserLogins(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		user       gorsk.User
+		wantStatus int
+		wantResp   []gorsk.LoginEvent
+	}{
+		{
+			name:       "Invalid id",
+			id:         "a",
+			user:       gorsk.User{Base: gorsk.Base{ID: 1}, Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			id:         "2",
+			user:       gorsk.User{Base: gorsk.Base{ID: 3}, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Success for own logins",
+			id:         "2",
+			user:       gorsk.User{Base: gorsk.Base{ID: 2}, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}},
+			wantStatus: http.StatusOK,
+			wantResp:   []gorsk.LoginEvent{{ID: 1, Success: true, Method: gorsk.LoginMagicLink, CreatedAt: mock.TestTime(2020)}},
+		},
+		{
+			name:       "Success for admin",
+			id:         "2",
+			user:       gorsk.User{Base: gorsk.Base{ID: 1}, Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}},
+			wantStatus: http.StatusOK,
+			wantResp:   []gorsk.LoginEvent{{ID: 1, Success: true, Method: gorsk.LoginMagicLink, CreatedAt: mock.TestTime(2020)}},
+		},
+	}
+
+	udb := &mockdb.User{
+		ListLoginEventsFn: func(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+			if userID != 2 {
+				return nil, gorsk.ErrGeneric
+			}
+			return []gorsk.LoginEvent{{ID: 1, UserID: 2, Success: true, Method: gorsk.LoginMagicLink, CreatedAt: mock.TestTime(2020)}}, nil
+		},
+	}
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, nil, nil, rbac.Service{}, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			token, err := jwt.GenerateToken(tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest("GET", ts.URL+"/v1/users/"+tt.id+"/logins", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(struct {
+					Logins []gorsk.LoginEvent `json:"logins"`
+				})
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response.Logins)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Invalid id",
+			id:         "a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Session does not exist",
+			id:         "3",
+			wantStatus: http.StatusNotFound,
+			udb: &mockdb.User{
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Success",
+			id:         "3",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				DeleteSessionFn: func(orm.DB, int, int) error {
+					return nil
+				},
+			},
+		},
+	}
+
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, rbac, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("DELETE", ts.URL+"/v1/me/sessions/"+tt.id, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.AuthToken
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Invalid request",
+			req:        `{"mfa_token":"mfatoken"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid challenge",
+			req:        `{"mfa_token":"mfatoken","code":"123456"}`,
+			wantStatus: http.StatusUnauthorized,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Invalid code",
+			req:        `{"mfa_token":"mfatoken","code":"000000"}`,
+			wantStatus: http.StatusUnauthorized,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"mfa_token":"mfatoken","code":"123456"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+					return nil
+				},
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Active: true, MFAEnabled: true, MFASecret: "SECRET"}, nil
+				},
+				UpdateMFAFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				CreateSessionFn: func(orm.DB, gorsk.Session) error {
+					return nil
+				},
+			},
+			wantResp: &gorsk.AuthToken{Token: "jwttokenstring", RefreshToken: "refreshtoken"},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "refreshtoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttokenstring", nil
+		},
+	}
+	otp := &mock.TOTP{
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 1, code == "123456"
+		},
+	}
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, jwt, sec, nil, otp, nil, nil, auth.Config{}), r, nil, nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/login/mfa", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.AuthToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestEnrollMFA(t *testing.T) {
+	cases := []struct {
+		name       string
+		wantStatus int
+		wantResp   *gorsk.MFAEnrollment
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Already enabled",
+			wantStatus: http.StatusConflict,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{MFAEnabled: true}, nil
+				},
+			},
+		},
+		{
+			name:       "Success",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{Username: "johndoe"}, nil
+				},
+				UpdateMFAFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+			},
+			wantResp: &gorsk.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/gorsk:johndoe?secret=SECRET"},
+		},
+	}
+	otp := &mock.TOTP{
+		GenerateSecretFn: func() (string, error) {
+			return "SECRET", nil
+		},
+		URIFn: func(secret, account string) string {
+			return "otpauth://totp/gorsk:" + account + "?secret=" + secret
+		},
+	}
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, tt.udb, nil, nil, rbac, otp, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/v1/me/mfa", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.MFAEnrollment)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestEnableMFA(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   []string
+	}{
+		{
+			name:       "Invalid request",
+			req:        `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid code",
+			req:        `{"code":"000000"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Success",
+			req:        `{"code":"123456"}`,
+			wantStatus: http.StatusOK,
+			wantResp:   []string{"AAAA", "BBBB"},
+		},
+	}
+	udb := &mockdb.User{
+		ViewFn: func(orm.DB, int) (gorsk.User, error) {
+			return gorsk.User{MFASecret: "SECRET"}, nil
+		},
+		UpdateMFAFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+	otp := &mock.TOTP{
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 1, code == "123456"
+		},
+		RecoveryCodesFn: func(int) ([]string, error) {
+			return []string{"AAAA", "BBBB"}, nil
+		},
+	}
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, nil, sec, rbac, otp, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/v1/me/mfa/enable", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(struct {
+					RecoveryCodes []string `json:"recovery_codes"`
+				})
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response.RecoveryCodes)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDisableMFA(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		cfg        auth.Config
+	}{
+		{
+			name:       "Invalid request",
+			req:        `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Required for role",
+			req:        `{"code":"123456"}`,
+			cfg:        auth.Config{ForceMFARole: gorsk.AdminRole},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Invalid code",
+			req:        `{"code":"000000"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Success",
+			req:        `{"code":"123456"}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+	udb := &mockdb.User{
+		ViewFn: func(orm.DB, int) (gorsk.User, error) {
+			return gorsk.User{MFAEnabled: true, MFASecret: "SECRET", Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}}, nil
+		},
+		UpdateMFAFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+	otp := &mock.TOTP{
+		ValidateFn: func(secret, code string, last int64) (int64, bool) {
+			return 1, code == "123456"
+		},
+	}
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbac := &mock.RBAC{
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1}
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, nil, sec, rbac, otp, nil, nil, tt.cfg), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/v1/me/mfa/disable", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", mock.HeaderValid())
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	cases := []struct {
+		name       string
+		noCookie   bool
+		refused    bool
+		wantStatus int
+	}{
+		{
+			name:       "Refused by identity provider",
+			refused:    true,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Missing state cookie",
+			noCookie:   true,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Success",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	idp := mock.NewIdP("gorsk")
+	defer idp.Close()
+
+	logins := map[string]gorsk.OneTimeToken{}
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		CreateTokenFn: func(db orm.DB, login gorsk.OneTimeToken) error {
+			login.ID = len(logins) + 1
+			logins[login.Token] = login
+			return nil
+		},
+		FindTokenFn: func(db orm.DB, purpose, state string) (gorsk.OneTimeToken, error) {
+			login, ok := logins[state]
+			if !ok {
+				return gorsk.OneTimeToken{}, pg.ErrNoRows
+			}
+			return login, nil
+		},
+		DeleteTokenFn: func(db orm.DB, id int) error {
+			for state, login := range logins {
+				if login.ID == id {
+					delete(logins, state)
+					return nil
+				}
+			}
+			return pg.ErrNoRows
+		},
+		FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+			if email != "johndoe@mail.com" {
+				return gorsk.User{}, pg.ErrNoRows
+			}
+			return gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: email, Active: true, EmailVerifiedAt: time.Now(), Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, nil
+		},
+		UpdateFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+		CreateSessionFn: func(orm.DB, gorsk.Session) error {
+			return nil
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	var n int
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			n++
+			return fmt.Sprintf("token%038d", n), nil
+		},
+		HashTokenFn: func(token string) string {
+			return "hash:" + token
+		},
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			provider := oidc.New(idp.URL, "gorsk", "", ts.URL+"/auth/oidc/callback", nil, nil)
+			transport.NewHTTP(auth.New(nil, udb, jwt, sec, nil, nil, provider, nil, auth.Config{}), r, nil, nil, false)
+
+			res, err := client.Get(ts.URL + "/auth/oidc/login")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			assert.Equal(t, http.StatusFound, res.StatusCode)
+			cookies := res.Cookies()
+			assert.Len(t, cookies, 1)
+
+			code, state, err := idp.Authorize(res.Header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := url.Values{"code": {code}, "state": {state}}
+			if tt.refused {
+				query = url.Values{"error": {"access_denied"}, "state": {state}}
+			}
+			req, _ := http.NewRequest("GET", ts.URL+"/auth/oidc/callback?"+query.Encode(), nil)
+			if !tt.noCookie {
+				req.AddCookie(cookies[0])
+			}
+			res, err = client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				response := new(gorsk.AuthToken)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, "jwttoken", response.Token)
+				assert.NotEmpty(t, response.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantUser   gorsk.User
+	}{
+		{
+			name:       "Invalid id",
+			id:         "a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on user view",
+			id:         "3",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Success",
+			id:         "2",
+			wantStatus: http.StatusOK,
+			wantUser: gorsk.User{
+				Base:           gorsk.Base{ID: 2},
+				Username:       "janedoe",
+				Active:         true,
+				Role:           &gorsk.Role{AccessLevel: gorsk.UserRole},
+				ImpersonatedBy: 1,
+			},
+		},
+	}
+
+	udb := &mockdb.User{
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			if id != 2 {
+				return gorsk.User{}, echo.ErrNotFound
+			}
+			return gorsk.User{
+				Base:     gorsk.Base{ID: 2},
+				Username: "janedoe",
+				Active:   true,
+				RoleID:   gorsk.UserRole,
+				Role:     &gorsk.Role{AccessLevel: gorsk.UserRole},
+			}, nil
+		},
+	}
+	client := &http.Client{}
+	jwt, err := jwt.New("HS256", "jwtsecret123", 60, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, err := jwt.GenerateToken(gorsk.User{Base: gorsk.Base{ID: 1}, Role: &gorsk.Role{AccessLevel: gorsk.AdminRole}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, jwt, nil, rbac.Service{}, nil, nil, nil, auth.Config{}), r, authMw.Middleware(jwt, nil, nil, nil), nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest("POST", ts.URL+"/v1/users/"+tt.id+"/impersonate", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var token gorsk.AuthToken
+			if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+				t.Fatal(err)
+			}
+			assert.Empty(t, token.RefreshToken)
+
+			req, err = http.NewRequest("GET", ts.URL+"/me", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+			res, err = client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			var user gorsk.User
+			if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantUser, user)
+
+			// impersonating again with the impersonation token is refused
+			req, err = http.NewRequest("POST", ts.URL+"/v1/users/2/impersonate", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+			res, err = client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		})
+	}
+}
+
+func TestMagicLink(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantMail   bool
+	}{
+		{
+			name:       "Invalid email",
+			req:        `{"email":"johndoe"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown email",
+			req:        `{"email":"nobody@mail.com"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Success",
+			req:        `{"email":"johndoe@mail.com"}`,
+			wantStatus: http.StatusOK,
+			wantMail:   true,
+		},
+	}
+
+	links := map[string]gorsk.OneTimeToken{}
+	udb := &mockdb.User{
+		CreateLoginEventFn: func(orm.DB, gorsk.LoginEvent) error {
+			return nil
+		},
+		FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+			if email != "johndoe@mail.com" {
+				return gorsk.User{}, pg.ErrNoRows
+			}
+			return gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: email, Active: true, CompanyID: 1}, nil
+		},
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			return gorsk.User{Base: gorsk.Base{ID: id}, Active: true, CompanyID: 1, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, nil
+		},
+		ViewCompanyFn: func(db orm.DB, id int) (gorsk.Company, error) {
+			return gorsk.Company{Base: gorsk.Base{ID: id}, Active: true, MagicLinkLogin: true}, nil
+		},
+		UpdateMagicLinksFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+		DeleteUserTokensFn: func(orm.DB, string, int) error {
+			return nil
+		},
+		CreateTokenFn: func(db orm.DB, link gorsk.OneTimeToken) error {
+			link.ID = 1
+			links[link.Token] = link
+			return nil
+		},
+		FindTokenFn: func(db orm.DB, purpose, token string) (gorsk.OneTimeToken, error) {
+			link, ok := links[token]
+			if !ok {
+				return gorsk.OneTimeToken{}, pg.ErrNoRows
+			}
+			return link, nil
+		},
+		DeleteTokenFn: func(db orm.DB, id int) error {
+			for k, v := range links {
+				if v.ID == id {
+					delete(links, k)
+					return nil
+				}
+			}
+			return pg.ErrNoRows
+		},
+		UpdateFn: func(orm.DB, gorsk.User) error {
+			return nil
+		},
+		CreateSessionFn: func(orm.DB, gorsk.Session) error {
+			return nil
+		},
+	}
+	var n int
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			n++
+			return fmt.Sprintf("token%d", n), nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hash:" + s
+		},
+	}
+	jwt := &mock.JWT{
+		GenerateTokenFn: func(gorsk.User) (string, error) {
+			return "jwttoken", nil
+		},
+	}
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := new(mail.Memory)
+			r := server.New()
+			transport.NewHTTP(auth.New(nil, udb, jwt, sec, nil, nil, nil, mailer, auth.Config{MagicLinkURL: "https://api.gorsk.com/login/magic"}), r, nil, nil, false)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			res, err := client.Post(ts.URL+"/login/magic", "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+
+			msgs := mailer.Messages()
+			if !tt.wantMail {
+				assert.Empty(t, msgs)
+				return
+			}
+			if !assert.Len(t, msgs, 1) {
+				return
+			}
+			i := strings.Index(msgs[0].Body, "https://api.gorsk.com")
+			link := strings.Replace(strings.Fields(msgs[0].Body[i:])[0], "https://api.gorsk.com", ts.URL, 1)
+
+			res, err = client.Get(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			var token gorsk.AuthToken
+			if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "jwttoken", token.Token)
+
+			// the link can be used only once
+			res, err = client.Get(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		})
+	}
+}
@@ -20,11 +20,106 @@ type swaggLoginResp struct {
 	}
 }
 
+// Magic link request
+// swagger:parameters magicLink
+type swaggMagicLinkReq struct {
+	// in:body
+	Body magicLinkReq
+}
+
+// Logout request
+// swagger:parameters logout
+type swaggLogoutReq struct {
+	// in:body
+	Body logoutReq
+}
+
+// Token refresh request
+// swagger:parameters refresh
+type swaggRefreshReq struct {
+	// in:body
+	Body refreshReq
+}
+
 // Token refresh response
 // swagger:response refreshResp
 type swaggRefreshResp struct {
 	// in:body
 	Body struct {
-		*gorsk.RefreshToken
+		*gorsk.AuthToken
+	}
+}
+
+// Impersonation response
+// swagger:response impersonateResp
+type swaggImpersonateResp struct {
+	// in:body
+	Body struct {
+		*gorsk.AuthToken
+	}
+}
+
+// JSON Web Key Set response
+// swagger:response jwksResp
+type swaggJWKSResp struct {
+	// in:body
+	Body struct {
+		*gorsk.JWKS
+	}
+}
+
+// Sessions response
+// swagger:response sessionListResp
+type swaggSessionListResp struct {
+	// in:body
+	Body struct {
+		Sessions []gorsk.Session `json:"sessions"`
+	}
+}
+
+// Login history response
+// swagger:response loginListResp
+type swaggLoginListResp struct {
+	// in:body
+	Body struct {
+		Logins []gorsk.LoginEvent `json:"logins"`
+		Page   int                `json:"page"`
+	}
+}
+
+// Second step of login request
+// swagger:parameters loginMFA
+type swaggMFALoginReq struct {
+	// in:body
+	Body mfaLoginReq
+}
+
+// Two-factor authentication setup during login request
+// swagger:parameters loginMFAEnroll
+type swaggMFAChallengeReq struct {
+	// in:body
+	Body mfaChallengeReq
+}
+
+// Two-factor authentication code request
+// swagger:parameters mfaEnable mfaDisable
+type swaggMFACodeReq struct {
+	// in:body
+	Body mfaCodeReq
+}
+
+// Two-factor authentication setup response
+// swagger:response mfaEnrollResp
+type swaggMFAEnrollResp struct {
+	// in:body
+	Body struct {
+		*gorsk.MFAEnrollment
 	}
 }
+
+// Two-factor authentication enabled response
+// swagger:response mfaEnableResp
+type swaggMFAEnableResp struct {
+	// in:body
+	Body mfaEnableResponse
+}
@@ -0,0 +1,75 @@
+// Package company contains company application services
+package company
+
+import (
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/query"
+)
+
+// Create creates a new company
+func (cp Company) Create(c echo.Context, req gorsk.Company) (gorsk.Company, error) {
+	if err := cp.rbac.Can(c, gorsk.PermissionCompaniesCreate, gorsk.Resource{}); err != nil {
+		return gorsk.Company{}, err
+	}
+	return cp.cdb.Create(cp.db, req)
+}
+
+// List returns list of companies
+func (cp Company) List(c echo.Context, p gorsk.Pagination) ([]gorsk.Company, error) {
+	sc, err := cp.rbac.Scope(c, gorsk.PermissionCompaniesView)
+	if err != nil {
+		return nil, err
+	}
+	return cp.cdb.List(cp.db, query.Companies(sc), p)
+}
+
+// View returns single company
+func (cp Company) View(c echo.Context, id int) (gorsk.Company, error) {
+	if err := cp.rbac.Can(c, gorsk.PermissionCompaniesView, gorsk.Resource{CompanyID: id}); err != nil {
+		return gorsk.Company{}, err
+	}
+	return cp.cdb.View(cp.db, id)
+}
+
+// Update contains company's information used for updating
+type Update struct {
+	ID   int
+	Name string
+	// MagicLinkLogin enables or disables login by emailed link, if set
+	MagicLinkLogin *bool
+}
+
+// Update updates company's information
+func (cp Company) Update(c echo.Context, r Update) (gorsk.Company, error) {
+	if err := cp.rbac.Can(c, gorsk.PermissionCompaniesUpdate, gorsk.Resource{CompanyID: r.ID}); err != nil {
+		return gorsk.Company{}, err
+	}
+
+	if err := cp.cdb.Update(cp.db, gorsk.Company{
+		Base: gorsk.Base{ID: r.ID},
+		Name: r.Name,
+	}); err != nil {
+		return gorsk.Company{}, err
+	}
+
+	if r.MagicLinkLogin != nil {
+		if err := cp.cdb.SetMagicLinkLogin(cp.db, r.ID, *r.MagicLinkLogin); err != nil {
+			return gorsk.Company{}, err
+		}
+	}
+
+	return cp.cdb.View(cp.db, r.ID)
+}
+
+// Deactivate deactivates a company
+func (cp Company) Deactivate(c echo.Context, id int) error {
+	if err := cp.rbac.Can(c, gorsk.PermissionCompaniesDeactivate, gorsk.Resource{CompanyID: id}); err != nil {
+		return err
+	}
+	if _, err := cp.cdb.View(cp.db, id); err != nil {
+		return err
+	}
+	return cp.cdb.Deactivate(cp.db, id)
+}
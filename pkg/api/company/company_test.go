@@ -0,0 +1,374 @@
+package company_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/company"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	type args struct {
+		c   echo.Context
+		req gorsk.Company
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantErr  bool
+		wantData gorsk.Company
+		cdb      *mockdb.Company
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: true,
+			args:    args{req: gorsk.Company{Name: "Acme"}},
+		},
+		{
+			name: "Success",
+			args: args{req: gorsk.Company{Name: "Acme", Active: true}},
+			cdb: &mockdb.Company{
+				CreateFn: func(db orm.DB, cmp gorsk.Company) (gorsk.Company, error) {
+					cmp.CreatedAt = mock.TestTime(2000)
+					cmp.UpdatedAt = mock.TestTime(2000)
+					cmp.Base.ID = 1
+					return cmp, nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			wantData: gorsk.Company{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(2000),
+					UpdatedAt: mock.TestTime(2000),
+				},
+				Name:   "Acme",
+				Active: true,
+			}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := company.New(nil, tt.cdb, tt.rbac)
+			cmp, err := s.Create(tt.args.c, tt.args.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, cmp)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	type args struct {
+		c   echo.Context
+		pgn gorsk.Pagination
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantData []gorsk.Company
+		wantErr  bool
+		cdb      *mockdb.Company
+		rbac     *mock.RBAC
+	}{
+		{
+			name:    "Fail on Scope",
+			args:    args{pgn: gorsk.Pagination{Limit: 100, Offset: 200}},
+			wantErr: true,
+			rbac: &mock.RBAC{
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, gorsk.ErrGeneric
+				}}},
+		{
+			name: "Success",
+			args: args{pgn: gorsk.Pagination{Limit: 100, Offset: 200}},
+			rbac: &mock.RBAC{
+				ScopeFn: func(c echo.Context, permission string) (*gorsk.Resource, error) {
+					if permission != gorsk.PermissionCompaniesView {
+						return nil, gorsk.ErrGeneric
+					}
+					return &gorsk.Resource{CompanyID: 2}, nil
+				}},
+			cdb: &mockdb.Company{
+				ListFn: func(db orm.DB, q *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.Company, error) {
+					if q == nil || q.ID != 2 {
+						return nil, gorsk.ErrGeneric
+					}
+					return []gorsk.Company{
+						{
+							Base:   gorsk.Base{ID: 2},
+							Name:   "Acme",
+							Active: true,
+						},
+					}, nil
+				}},
+			wantData: []gorsk.Company{
+				{
+					Base:   gorsk.Base{ID: 2},
+					Name:   "Acme",
+					Active: true,
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := company.New(nil, tt.cdb, tt.rbac)
+			cmps, err := s.List(tt.args.c, tt.args.pgn)
+			assert.Equal(t, tt.wantData, cmps)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	type args struct {
+		c  echo.Context
+		id int
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantData gorsk.Company
+		wantErr  error
+		cdb      *mockdb.Company
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			args: args{id: 5},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			args: args{id: 1},
+			wantData: gorsk.Company{
+				Base:   gorsk.Base{ID: 1},
+				Name:   "Acme",
+				Active: true,
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{
+						Base:   gorsk.Base{ID: id},
+						Name:   "Acme",
+						Active: true,
+					}, nil
+				}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := company.New(nil, tt.cdb, tt.rbac)
+			cmp, err := s.View(tt.args.c, tt.args.id)
+			assert.Equal(t, tt.wantData, cmp)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	disabled := false
+	type args struct {
+		c   echo.Context
+		upd company.Update
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantData gorsk.Company
+		wantErr  error
+		cdb      *mockdb.Company
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			args: args{upd: company.Update{ID: 1}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on Update",
+			args: args{upd: company.Update{ID: 1}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			wantErr: gorsk.ErrGeneric,
+			cdb: &mockdb.Company{
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			args: args{upd: company.Update{ID: 1, Name: "Acme Corp"}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			wantData: gorsk.Company{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(1990),
+					UpdatedAt: mock.TestTime(2000),
+				},
+				Name:   "Acme Corp",
+				Active: true,
+			},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{
+						Base: gorsk.Base{
+							ID:        1,
+							CreatedAt: mock.TestTime(1990),
+							UpdatedAt: mock.TestTime(2000),
+						},
+						Name:   "Acme Corp",
+						Active: true,
+					}, nil
+				},
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return nil
+				},
+			},
+		},
+		{
+			name: "Fail on SetMagicLinkLogin",
+			args: args{upd: company.Update{ID: 1, MagicLinkLogin: &disabled}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			wantErr: gorsk.ErrGeneric,
+			cdb: &mockdb.Company{
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return nil
+				},
+				SetMagicLinkLoginFn: func(db orm.DB, id int, enabled bool) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success with magic link login disabled",
+			args: args{upd: company.Update{ID: 1, MagicLinkLogin: &disabled}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			wantData: gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"}, nil
+				},
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return nil
+				},
+				SetMagicLinkLoginFn: func(db orm.DB, id int, enabled bool) error {
+					if enabled {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := company.New(nil, tt.cdb, tt.rbac)
+			cmp, err := s.Update(tt.args.c, tt.args.upd)
+			assert.Equal(t, tt.wantData, cmp)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	cases := []struct {
+		name    string
+		id      int
+		wantErr error
+		cdb     *mockdb.Company
+		rbac    *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			id:   1,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on View",
+			id:   1,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			id:   1,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{Base: gorsk.Base{ID: id}, Active: true}, nil
+				},
+				DeactivateFn: func(db orm.DB, id int) error {
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := company.New(nil, tt.cdb, tt.rbac)
+			err := s.Deactivate(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	c := company.Initialize(nil, nil)
+	if c == nil {
+		t.Error("Company service not initialized")
+	}
+}
@@ -0,0 +1,105 @@
+package company
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/company"
+)
+
+// New creates new company logging service
+func New(svc company.Service, logger gorsk.Logger) *LogService {
+	return &LogService{
+		Service: svc,
+		logger:  logger,
+	}
+}
+
+// LogService represents company logging service
+type LogService struct {
+	company.Service
+	logger gorsk.Logger
+}
+
+const name = "company"
+
+// Create logging
+func (ls *LogService) Create(c echo.Context, req gorsk.Company) (resp gorsk.Company, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Create company request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Create(c, req)
+}
+
+// List logging
+func (ls *LogService) List(c echo.Context, req gorsk.Pagination) (resp []gorsk.Company, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "List company request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.List(c, req)
+}
+
+// View logging
+func (ls *LogService) View(c echo.Context, req int) (resp gorsk.Company, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "View company request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.View(c, req)
+}
+
+// Update logging
+func (ls *LogService) Update(c echo.Context, req company.Update) (resp gorsk.Company, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Update company request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Update(c, req)
+}
+
+// Deactivate logging
+func (ls *LogService) Deactivate(c echo.Context, req int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Deactivate company request", err,
+			map[string]interface{}{
+				"req":  req,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Deactivate(c, req)
+}
@@ -0,0 +1,71 @@
+package pgsql
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Company represents the client for company table
+type Company struct{}
+
+// Custom errors
+var (
+	ErrAlreadyExists = echo.NewHTTPError(http.StatusInternalServerError, "Company name already exists.")
+)
+
+// Create creates a new company on database
+func (cp Company) Create(db orm.DB, cmp gorsk.Company) (gorsk.Company, error) {
+	var company = new(gorsk.Company)
+	err := db.Model(company).Where("lower(name) = ? and deleted_at is null",
+		strings.ToLower(cmp.Name)).Select()
+	if err == nil || err != pg.ErrNoRows {
+		return gorsk.Company{}, ErrAlreadyExists
+	}
+
+	err = db.Insert(&cmp)
+	return cmp, err
+}
+
+// View returns single company by ID, including its locations
+func (cp Company) View(db orm.DB, id int) (gorsk.Company, error) {
+	company := gorsk.Company{Base: gorsk.Base{ID: id}}
+	err := db.Model(&company).Relation("Locations").WherePK().Select()
+	return company, err
+}
+
+// Update updates company's info
+func (cp Company) Update(db orm.DB, company gorsk.Company) error {
+	_, err := db.Model(&company).WherePK().UpdateNotZero()
+	return err
+}
+
+// SetMagicLinkLogin enables or disables login by emailed link for company's users
+func (cp Company) SetMagicLinkLogin(db orm.DB, id int, enabled bool) error {
+	company := gorsk.Company{Base: gorsk.Base{ID: id}}
+	_, err := db.Model(&company).Set("magic_link_login = ?", enabled).WherePK().Update()
+	return err
+}
+
+// List returns list of all companies retrievable for the current user, depending on role
+func (cp Company) List(db orm.DB, qp *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.Company, error) {
+	var companies []gorsk.Company
+	q := db.Model(&companies).Limit(p.Limit).Offset(p.Offset).Order("company.id desc")
+	if qp != nil {
+		q.Where(qp.Query, qp.ID)
+	}
+	err := q.Select()
+	return companies, err
+}
+
+// Deactivate sets company's active flag to false
+func (cp Company) Deactivate(db orm.DB, id int) error {
+	company := gorsk.Company{Base: gorsk.Base{ID: id}}
+	_, err := db.Model(&company).Set("active = ?", false).WherePK().Update()
+	return err
+}
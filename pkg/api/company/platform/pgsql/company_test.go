@@ -0,0 +1,297 @@
+package pgsql_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk"
+
+	"github.com/ribice/gorsk/pkg/api/company/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantErr  bool
+		req      gorsk.Company
+		wantData gorsk.Company
+	}{
+		{
+			name:    "Fail on insert duplicate ID",
+			wantErr: true,
+			req: gorsk.Company{
+				Name: "Acme",
+				Base: gorsk.Base{
+					ID: 1,
+				},
+			},
+		},
+		{
+			name: "Success",
+			req: gorsk.Company{
+				Name:   "Globex",
+				Active: true,
+				Base: gorsk.Base{
+					ID: 2,
+				},
+			},
+			wantData: gorsk.Company{
+				Name:   "Globex",
+				Active: true,
+				Base: gorsk.Base{
+					ID: 2,
+				},
+			},
+		},
+		{
+			name:    "Company already exists",
+			wantErr: true,
+			req: gorsk.Company{
+				Name: "globex",
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Company{
+		Name: "Initech",
+		Base: gorsk.Base{
+			ID: 1,
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	cdb := pgsql.Company{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := cdb.Create(db, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData.ID != 0 {
+				if resp.ID == 0 {
+					t.Error("expected data, but got empty struct.")
+					return
+				}
+				tt.wantData.CreatedAt = resp.CreatedAt
+				tt.wantData.UpdatedAt = resp.UpdatedAt
+				assert.Equal(t, tt.wantData, resp)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantErr  bool
+		id       int
+		wantData gorsk.Company
+	}{
+		{
+			name:    "Company does not exist",
+			wantErr: true,
+			id:      1000,
+		},
+		{
+			name: "Success",
+			id:   1,
+			wantData: gorsk.Company{
+				Name:   "Acme",
+				Active: true,
+				Base: gorsk.Base{
+					ID: 1,
+				},
+				Locations: []gorsk.Location{
+					{
+						Name:      "HQ",
+						Active:    true,
+						Address:   "Main street",
+						CompanyID: 1,
+						Base: gorsk.Base{
+							ID: 1,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Company{
+		Name:   "Acme",
+		Active: true,
+		Base: gorsk.Base{
+			ID: 1,
+		},
+	}, &cases[1].wantData.Locations[0]); err != nil {
+		t.Error(err)
+	}
+
+	cdb := pgsql.Company{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp, err := cdb.View(db, tt.id)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData.ID != 0 {
+				if cmp.ID == 0 {
+					t.Errorf("response was empty due to: %v", err)
+					return
+				}
+				tt.wantData.CreatedAt = cmp.CreatedAt
+				tt.wantData.UpdatedAt = cmp.UpdatedAt
+				for i, v := range cmp.Locations {
+					tt.wantData.Locations[i].CreatedAt = v.CreatedAt
+					tt.wantData.Locations[i].UpdatedAt = v.UpdatedAt
+				}
+				assert.Equal(t, tt.wantData, cmp)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantErr  bool
+		qp       *gorsk.ListQuery
+		pg       gorsk.Pagination
+		wantData []gorsk.Company
+	}{
+		{
+			name:    "Invalid pagination values",
+			wantErr: true,
+			pg: gorsk.Pagination{
+				Limit: -100,
+			},
+		},
+		{
+			name: "Success",
+			pg: gorsk.Pagination{
+				Limit:  100,
+				Offset: 0,
+			},
+			qp: &gorsk.ListQuery{
+				ID:    2,
+				Query: "id = ?",
+			},
+			wantData: []gorsk.Company{
+				{
+					Name:   "Globex",
+					Active: true,
+					Base: gorsk.Base{
+						ID: 2,
+					},
+				},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Company{
+		Name:   "Acme",
+		Active: true,
+		Base: gorsk.Base{
+			ID: 1,
+		},
+	}, &cases[1].wantData[0]); err != nil {
+		t.Error(err)
+	}
+
+	cdb := pgsql.Company{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			companies, err := cdb.List(db, tt.qp, tt.pg)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData != nil {
+				for i, v := range companies {
+					tt.wantData[i].CreatedAt = v.CreatedAt
+					tt.wantData[i].UpdatedAt = v.UpdatedAt
+				}
+				assert.Equal(t, tt.wantData, companies)
+			}
+		})
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Company{
+		Name:   "Acme",
+		Active: true,
+		Base: gorsk.Base{
+			ID: 1,
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	cdb := pgsql.Company{}
+
+	if err := cdb.Deactivate(db, 1); err != nil {
+		t.Error(err)
+	}
+
+	cmp := gorsk.Company{Base: gorsk.Base{ID: 1}}
+	if err := db.Select(&cmp); err != nil {
+		t.Error(err)
+	}
+	assert.False(t, cmp.Active)
+}
+
+func TestSetMagicLinkLogin(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Company{
+		Name:   "Acme",
+		Active: true,
+		Base: gorsk.Base{
+			ID: 1,
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	cdb := pgsql.Company{}
+
+	if err := cdb.SetMagicLinkLogin(db, 1, true); err != nil {
+		t.Error(err)
+	}
+	cmp := gorsk.Company{Base: gorsk.Base{ID: 1}}
+	if err := db.Select(&cmp); err != nil {
+		t.Error(err)
+	}
+	assert.True(t, cmp.MagicLinkLogin)
+
+	if err := cdb.SetMagicLinkLogin(db, 1, false); err != nil {
+		t.Error(err)
+	}
+	cmp = gorsk.Company{Base: gorsk.Base{ID: 1}}
+	if err := db.Select(&cmp); err != nil {
+		t.Error(err)
+	}
+	assert.False(t, cmp.MagicLinkLogin)
+}
@@ -0,0 +1,53 @@
+package company
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/company/platform/pgsql"
+)
+
+// Service represents company application interface
+type Service interface {
+	Create(echo.Context, gorsk.Company) (gorsk.Company, error)
+	List(echo.Context, gorsk.Pagination) ([]gorsk.Company, error)
+	View(echo.Context, int) (gorsk.Company, error)
+	Update(echo.Context, Update) (gorsk.Company, error)
+	Deactivate(echo.Context, int) error
+}
+
+// New creates new company application service
+func New(db *pg.DB, cdb CDB, rbac RBAC) *Company {
+	return &Company{db: db, cdb: cdb, rbac: rbac}
+}
+
+// Initialize initalizes Company application service with defaults
+func Initialize(db *pg.DB, rbac RBAC) *Company {
+	return New(db, pgsql.Company{}, rbac)
+}
+
+// Company represents company application service
+type Company struct {
+	db   *pg.DB
+	cdb  CDB
+	rbac RBAC
+}
+
+// CDB represents company repository interface
+type CDB interface {
+	Create(orm.DB, gorsk.Company) (gorsk.Company, error)
+	View(orm.DB, int) (gorsk.Company, error)
+	List(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.Company, error)
+	Update(orm.DB, gorsk.Company) error
+	SetMagicLinkLogin(orm.DB, int, bool) error
+	Deactivate(orm.DB, int) error
+}
+
+// RBAC represents role-based-access-control interface
+type RBAC interface {
+	User(echo.Context) gorsk.AuthUser
+	Can(echo.Context, string, gorsk.Resource) error
+	Scope(echo.Context, string) (*gorsk.Resource, error)
+}
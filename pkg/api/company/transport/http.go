@@ -0,0 +1,240 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/company"
+
+	"github.com/labstack/echo"
+)
+
+// HTTP represents company http service
+type HTTP struct {
+	svc company.Service
+}
+
+// NewHTTP creates new company http service
+func NewHTTP(svc company.Service, r *echo.Group) {
+	h := HTTP{svc}
+	cr := r.Group("/companies")
+	// swagger:route POST /v1/companies companies companyCreate
+	// Creates new company.
+	// responses:
+	//  200: companyResp
+	//  400: errMsg
+	//  401: err
+	//  403: errMsg
+	//  500: err
+	cr.POST("", h.create)
+
+	// swagger:operation GET /v1/companies companies listCompanies
+	// ---
+	// summary: Returns list of companies.
+	// description: Returns list of companies. Depending on the user role requesting it, it may return all companies for SuperAdmin/Admin users, user's own company for Company admins, and an error for other users.
+	// parameters:
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/companyListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	cr.GET("", h.list)
+
+	// swagger:operation GET /v1/companies/{id} companies getCompany
+	// ---
+	// summary: Returns a single company.
+	// description: Returns a single company with its locations by its ID.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/companyResp"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	cr.GET("/:id", h.view)
+
+	// swagger:operation PATCH /v1/companies/{id} companies companyUpdate
+	// ---
+	// summary: Updates company's information
+	// description: Updates company's information -> name, and whether its users can log in by emailed link.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/companyUpdate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/companyResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	cr.PATCH("/:id", h.update)
+
+	// swagger:operation POST /v1/companies/{id}/deactivate companies companyDeactivate
+	// ---
+	// summary: Deactivates a company
+	// description: Marks a company with requested ID as inactive.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	cr.POST("/:id/deactivate", h.deactivate)
+}
+
+// Company create request
+// swagger:model companyCreate
+type createReq struct {
+	Name string `json:"name" validate:"required,min=2"`
+}
+
+func (h HTTP) create(c echo.Context) error {
+	r := new(createReq)
+
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	cmp, err := h.svc.Create(c, gorsk.Company{
+		Name:   r.Name,
+		Active: true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cmp)
+}
+
+type listResponse struct {
+	Companies []gorsk.Company `json:"companies"`
+	Page      int             `json:"page"`
+}
+
+func (h HTTP) list(c echo.Context) error {
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	result, err := h.svc.List(c, req.Transform())
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, listResponse{result, req.Page})
+}
+
+func (h HTTP) view(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	result, err := h.svc.View(c, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// Company update request
+// swagger:model companyUpdate
+type updateReq struct {
+	ID             int    `json:"-"`
+	Name           string `json:"name,omitempty" validate:"omitempty,min=2"`
+	MagicLinkLogin *bool  `json:"magic_link_login,omitempty"`
+}
+
+func (h HTTP) update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	req := new(updateReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	cmp, err := h.svc.Update(c, company.Update{
+		ID:             id,
+		Name:           req.Name,
+		MagicLinkLogin: req.MagicLinkLogin,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cmp)
+}
+
+func (h HTTP) deactivate(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	if err := h.svc.Deactivate(c, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
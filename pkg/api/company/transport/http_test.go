@@ -0,0 +1,467 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/company"
+	"github.com/ribice/gorsk/pkg/api/company/transport"
+
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/server"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.Company
+		cdb        *mockdb.Company
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Fail on validation",
+			req:        `{"name":"a"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			req:  `{"name":"Acme"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `{"name":"Acme"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			cdb: &mockdb.Company{
+				CreateFn: func(db orm.DB, cmp gorsk.Company) (gorsk.Company, error) {
+					cmp.ID = 1
+					cmp.CreatedAt = mock.TestTime(2018)
+					cmp.UpdatedAt = mock.TestTime(2018)
+					return cmp, nil
+				},
+			},
+			wantResp: &gorsk.Company{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(2018),
+					UpdatedAt: mock.TestTime(2018),
+				},
+				Name:   "Acme",
+				Active: true,
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(company.New(nil, tt.cdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.Company)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	type listResponse struct {
+		Companies []gorsk.Company `json:"companies"`
+		Page      int             `json:"page"`
+	}
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *listResponse
+		cdb        *mockdb.Company
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `?limit=2222&page=-1`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on Scope",
+			req:  `?limit=100&page=1`,
+			rbac: &mock.RBAC{
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, echo.ErrForbidden
+				}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `?limit=100&page=1`,
+			rbac: &mock.RBAC{
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, nil
+				}},
+			cdb: &mockdb.Company{
+				ListFn: func(db orm.DB, q *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.Company, error) {
+					if p.Limit == 100 && p.Offset == 100 {
+						return []gorsk.Company{
+							{
+								Base: gorsk.Base{
+									ID:        10,
+									CreatedAt: mock.TestTime(2001),
+									UpdatedAt: mock.TestTime(2002),
+								},
+								Name:   "Acme",
+								Active: true,
+							},
+						}, nil
+					}
+					return nil, gorsk.ErrGeneric
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: &listResponse{
+				Companies: []gorsk.Company{
+					{
+						Base: gorsk.Base{
+							ID:        10,
+							CreatedAt: mock.TestTime(2001),
+							UpdatedAt: mock.TestTime(2002),
+						},
+						Name:   "Acme",
+						Active: true,
+					},
+				}, Page: 1},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(company.New(nil, tt.cdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies" + tt.req
+			res, err := http.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(listResponse)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   gorsk.Company
+		cdb        *mockdb.Company
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			req:  `1`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `1`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{
+						Base: gorsk.Base{
+							ID:        1,
+							CreatedAt: mock.TestTime(2000),
+							UpdatedAt: mock.TestTime(2000),
+						},
+						Name:   "Acme",
+						Active: true,
+						Locations: []gorsk.Location{
+							{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 1},
+						},
+					}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: gorsk.Company{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(2000),
+					UpdatedAt: mock.TestTime(2000),
+				},
+				Name:   "Acme",
+				Active: true,
+				Locations: []gorsk.Location{
+					{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 1},
+				},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(company.New(nil, tt.cdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.req
+			res, err := http.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp.ID != 0 {
+				response := new(gorsk.Company)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, &tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		id         string
+		wantStatus int
+		wantResp   gorsk.Company
+		cdb        *mockdb.Company
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			id:         `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on validation",
+			id:         `1`,
+			req:        `{"name":"a"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			id:   `1`,
+			req:  `{"name":"Acme Corp"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			id:   `1`,
+			req:  `{"name":"Acme Corp"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{
+						Base: gorsk.Base{
+							ID:        1,
+							CreatedAt: mock.TestTime(2000),
+							UpdatedAt: mock.TestTime(2000),
+						},
+						Name:   "Acme Corp",
+						Active: true,
+					}, nil
+				},
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: gorsk.Company{
+				Base: gorsk.Base{
+					ID:        1,
+					CreatedAt: mock.TestTime(2000),
+					UpdatedAt: mock.TestTime(2000),
+				},
+				Name:   "Acme Corp",
+				Active: true,
+			},
+		},
+		{
+			name: "Success with magic link login",
+			id:   `1`,
+			req:  `{"magic_link_login":true}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme", MagicLinkLogin: true}, nil
+				},
+				UpdateFn: func(db orm.DB, cmp gorsk.Company) error {
+					return nil
+				},
+				SetMagicLinkLoginFn: func(db orm.DB, id int, enabled bool) error {
+					if !enabled {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme", MagicLinkLogin: true},
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(company.New(nil, tt.cdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.id
+			req, _ := http.NewRequest("PATCH", path, bytes.NewBufferString(tt.req))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp.ID != 0 {
+				response := new(gorsk.Company)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, &tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		cdb        *mockdb.Company
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			id:         `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			id:   `1`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			id:   `1`,
+			cdb: &mockdb.Company{
+				ViewFn: func(db orm.DB, id int) (gorsk.Company, error) {
+					return gorsk.Company{Base: gorsk.Base{ID: id}, Active: true}, nil
+				},
+				DeactivateFn: func(orm.DB, int) error {
+					return nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(company.New(nil, tt.cdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.id + "/deactivate"
+			res, err := http.Post(path, "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package location contains location application services
+package location
+
+import (
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Create creates a new location within a company
+func (l Location) Create(c echo.Context, req gorsk.Location) (gorsk.Location, error) {
+	if err := l.rbac.Can(c, gorsk.PermissionLocationsCreate, gorsk.Resource{CompanyID: req.CompanyID}); err != nil {
+		return gorsk.Location{}, err
+	}
+	return l.ldb.Create(l.db, req)
+}
+
+// List returns list of company's locations
+func (l Location) List(c echo.Context, companyID int, p gorsk.Pagination) ([]gorsk.Location, error) {
+	if err := l.rbac.Can(c, gorsk.PermissionLocationsView, gorsk.Resource{CompanyID: companyID}); err != nil {
+		return nil, err
+	}
+	return l.ldb.List(l.db, companyID, p)
+}
+
+// View returns single location of a company
+func (l Location) View(c echo.Context, companyID, id int) (gorsk.Location, error) {
+	if err := l.rbac.Can(c, gorsk.PermissionLocationsView, gorsk.Resource{CompanyID: companyID, LocationID: id}); err != nil {
+		return gorsk.Location{}, err
+	}
+	return l.ldb.View(l.db, companyID, id)
+}
+
+// Update contains location's information used for updating
+type Update struct {
+	ID        int
+	CompanyID int
+	Name      string
+	Address   string
+}
+
+// Update updates location's information
+func (l Location) Update(c echo.Context, r Update) (gorsk.Location, error) {
+	if err := l.rbac.Can(c, gorsk.PermissionLocationsUpdate, gorsk.Resource{CompanyID: r.CompanyID, LocationID: r.ID}); err != nil {
+		return gorsk.Location{}, err
+	}
+
+	if _, err := l.ldb.View(l.db, r.CompanyID, r.ID); err != nil {
+		return gorsk.Location{}, err
+	}
+
+	if err := l.ldb.Update(l.db, gorsk.Location{
+		Base:    gorsk.Base{ID: r.ID},
+		Name:    r.Name,
+		Address: r.Address,
+	}); err != nil {
+		return gorsk.Location{}, err
+	}
+
+	return l.ldb.View(l.db, r.CompanyID, r.ID)
+}
+
+// Delete deletes a location of a company
+func (l Location) Delete(c echo.Context, companyID, id int) error {
+	if err := l.rbac.Can(c, gorsk.PermissionLocationsDelete, gorsk.Resource{CompanyID: companyID, LocationID: id}); err != nil {
+		return err
+	}
+	loc, err := l.ldb.View(l.db, companyID, id)
+	if err != nil {
+		return err
+	}
+	return l.ldb.Delete(l.db, loc)
+}
@@ -0,0 +1,264 @@
+package location_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/location"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      gorsk.Location
+		wantErr  bool
+		wantData gorsk.Location
+		ldb      *mockdb.Location
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			req:  gorsk.Location{Name: "HQ", CompanyID: 2},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			req:  gorsk.Location{Name: "HQ", CompanyID: 2},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				CreateFn: func(db orm.DB, loc gorsk.Location) (gorsk.Location, error) {
+					loc.ID = 1
+					return loc, nil
+				},
+			},
+			wantData: gorsk.Location{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: 2},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := location.New(nil, tt.ldb, tt.rbac)
+			loc, err := s.Create(nil, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, loc)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name      string
+		companyID int
+		wantErr   bool
+		wantData  []gorsk.Location
+		ldb       *mockdb.Location
+		rbac      *mock.RBAC
+	}{
+		{
+			name:      "Fail on RBAC",
+			companyID: 2,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: true,
+		},
+		{
+			name:      "Success",
+			companyID: 2,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				ListFn: func(db orm.DB, companyID int, p gorsk.Pagination) ([]gorsk.Location, error) {
+					return []gorsk.Location{{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: companyID}}, nil
+				},
+			},
+			wantData: []gorsk.Location{{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: 2}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := location.New(nil, tt.ldb, tt.rbac)
+			locs, err := s.List(nil, tt.companyID, gorsk.Pagination{Limit: 100})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, locs)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	ldb := &mockdb.Location{
+		ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+			return gorsk.Location{Base: gorsk.Base{ID: id}, Name: "HQ", CompanyID: companyID}, nil
+		},
+	}
+	cases := []struct {
+		name     string
+		wantErr  error
+		wantData gorsk.Location
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				}},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Success",
+			rbac: &mock.RBAC{
+				CanFn: func(c echo.Context, p string, r gorsk.Resource) error {
+					if p != gorsk.PermissionLocationsView || r != (gorsk.Resource{CompanyID: 2, LocationID: 3}) {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				}},
+			wantData: gorsk.Location{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 2},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := location.New(nil, ldb, tt.rbac)
+			loc, err := s.View(nil, 2, 3)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, loc)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name     string
+		upd      location.Update
+		wantErr  error
+		wantData gorsk.Location
+		ldb      *mockdb.Location
+		rbac     *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			upd:  location.Update{ID: 3, CompanyID: 2},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				}},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Fail on View",
+			upd:  location.Update{ID: 3, CompanyID: 2},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				ViewFn: func(orm.DB, int, int) (gorsk.Location, error) {
+					return gorsk.Location{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			upd:  location.Update{ID: 3, CompanyID: 2, Name: "Branch"},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+					return gorsk.Location{Base: gorsk.Base{ID: id}, Name: "Branch", CompanyID: companyID}, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.Location) error {
+					return nil
+				},
+			},
+			wantData: gorsk.Location{Base: gorsk.Base{ID: 3}, Name: "Branch", CompanyID: 2},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := location.New(nil, tt.ldb, tt.rbac)
+			loc, err := s.Update(nil, tt.upd)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, loc)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name    string
+		wantErr error
+		ldb     *mockdb.Location
+		rbac    *mock.RBAC
+	}{
+		{
+			name: "Fail on RBAC",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				}},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Fail on View",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				ViewFn: func(orm.DB, int, int) (gorsk.Location, error) {
+					return gorsk.Location{}, gorsk.ErrGeneric
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Success",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				}},
+			ldb: &mockdb.Location{
+				ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+					return gorsk.Location{Base: gorsk.Base{ID: id}, CompanyID: companyID}, nil
+				},
+				DeleteFn: func(orm.DB, gorsk.Location) error {
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := location.New(nil, tt.ldb, tt.rbac)
+			err := s.Delete(nil, 2, 3)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	l := location.Initialize(nil, nil)
+	if l == nil {
+		t.Error("Location service not initialized")
+	}
+}
@@ -0,0 +1,108 @@
+package location
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/location"
+)
+
+// New creates new location logging service
+func New(svc location.Service, logger gorsk.Logger) *LogService {
+	return &LogService{
+		Service: svc,
+		logger:  logger,
+	}
+}
+
+// LogService represents location logging service
+type LogService struct {
+	location.Service
+	logger gorsk.Logger
+}
+
+const name = "location"
+
+// Create logging
+func (ls *LogService) Create(c echo.Context, req gorsk.Location) (resp gorsk.Location, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Create location request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Create(c, req)
+}
+
+// List logging
+func (ls *LogService) List(c echo.Context, companyID int, req gorsk.Pagination) (resp []gorsk.Location, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "List location request", err,
+			map[string]interface{}{
+				"company_id": companyID,
+				"req":        req,
+				"resp":       resp,
+				"took":       time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.List(c, companyID, req)
+}
+
+// View logging
+func (ls *LogService) View(c echo.Context, companyID, req int) (resp gorsk.Location, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "View location request", err,
+			map[string]interface{}{
+				"company_id": companyID,
+				"req":        req,
+				"resp":       resp,
+				"took":       time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.View(c, companyID, req)
+}
+
+// Update logging
+func (ls *LogService) Update(c echo.Context, req location.Update) (resp gorsk.Location, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Update location request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Update(c, req)
+}
+
+// Delete logging
+func (ls *LogService) Delete(c echo.Context, companyID, req int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Delete location request", err,
+			map[string]interface{}{
+				"company_id": companyID,
+				"req":        req,
+				"took":       time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Delete(c, companyID, req)
+}
@@ -0,0 +1,42 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Location represents the client for location table
+type Location struct{}
+
+// Create creates a new location on database
+func (l Location) Create(db orm.DB, loc gorsk.Location) (gorsk.Location, error) {
+	err := db.Insert(&loc)
+	return loc, err
+}
+
+// View returns single location by company ID and location ID
+func (l Location) View(db orm.DB, companyID, id int) (gorsk.Location, error) {
+	var loc gorsk.Location
+	err := db.Model(&loc).Where("id = ? and company_id = ?", id, companyID).Select()
+	return loc, err
+}
+
+// Update updates location's info
+func (l Location) Update(db orm.DB, loc gorsk.Location) error {
+	_, err := db.Model(&loc).WherePK().UpdateNotZero()
+	return err
+}
+
+// List returns list of all locations belonging to a company
+func (l Location) List(db orm.DB, companyID int, p gorsk.Pagination) ([]gorsk.Location, error) {
+	var locs []gorsk.Location
+	err := db.Model(&locs).Where("company_id = ?", companyID).
+		Limit(p.Limit).Offset(p.Offset).Order("location.id desc").Select()
+	return locs, err
+}
+
+// Delete sets deleted_at for a location
+func (l Location) Delete(db orm.DB, loc gorsk.Location) error {
+	return db.Delete(&loc)
+}
@@ -0,0 +1,161 @@
+package pgsql_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk"
+
+	"github.com/ribice/gorsk/pkg/api/location/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+)
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name      string
+		wantErr   bool
+		companyID int
+		id        int
+		wantData  gorsk.Location
+	}{
+		{
+			name:      "Location does not exist",
+			wantErr:   true,
+			companyID: 1,
+			id:        1000,
+		},
+		{
+			name:      "Location belongs to another company",
+			wantErr:   true,
+			companyID: 2,
+			id:        1,
+		},
+		{
+			name:      "Success",
+			companyID: 1,
+			id:        1,
+			wantData: gorsk.Location{
+				Name:      "HQ",
+				Active:    true,
+				Address:   "Main street",
+				CompanyID: 1,
+				Base: gorsk.Base{
+					ID: 1,
+				},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"},
+		&gorsk.Company{Base: gorsk.Base{ID: 2}, Name: "Globex"},
+		&cases[2].wantData); err != nil {
+		t.Error(err)
+	}
+
+	ldb := pgsql.Location{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := ldb.View(db, tt.companyID, tt.id)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData.ID != 0 {
+				tt.wantData.CreatedAt = loc.CreatedAt
+				tt.wantData.UpdatedAt = loc.UpdatedAt
+				assert.Equal(t, tt.wantData, loc)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name      string
+		wantErr   bool
+		companyID int
+		pg        gorsk.Pagination
+		wantData  []gorsk.Location
+	}{
+		{
+			name:    "Invalid pagination values",
+			wantErr: true,
+			pg: gorsk.Pagination{
+				Limit: -100,
+			},
+		},
+		{
+			name:      "Success",
+			companyID: 2,
+			pg: gorsk.Pagination{
+				Limit:  100,
+				Offset: 0,
+			},
+			wantData: []gorsk.Location{
+				{
+					Name:      "Branch",
+					Address:   "Side street",
+					CompanyID: 2,
+					Base: gorsk.Base{
+						ID: 2,
+					},
+				},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"},
+		&gorsk.Company{Base: gorsk.Base{ID: 2}, Name: "Globex"},
+		&gorsk.Location{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: 1},
+		&cases[1].wantData[0]); err != nil {
+		t.Error(err)
+	}
+
+	ldb := pgsql.Location{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			locs, err := ldb.List(db, tt.companyID, tt.pg)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData != nil {
+				for i, v := range locs {
+					tt.wantData[i].CreatedAt = v.CreatedAt
+					tt.wantData[i].UpdatedAt = v.UpdatedAt
+				}
+				assert.Equal(t, tt.wantData, locs)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{})
+
+	loc := gorsk.Location{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: 1}
+	if err := mock.InsertMultiple(db, &gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"}, &loc); err != nil {
+		t.Error(err)
+	}
+
+	ldb := pgsql.Location{}
+
+	if err := ldb.Delete(db, loc); err != nil {
+		t.Error(err)
+	}
+
+	_, err := ldb.View(db, 1, 1)
+	assert.NotNil(t, err)
+}
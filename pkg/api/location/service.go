@@ -0,0 +1,51 @@
+package location
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/location/platform/pgsql"
+)
+
+// Service represents location application interface
+type Service interface {
+	Create(echo.Context, gorsk.Location) (gorsk.Location, error)
+	List(echo.Context, int, gorsk.Pagination) ([]gorsk.Location, error)
+	View(echo.Context, int, int) (gorsk.Location, error)
+	Update(echo.Context, Update) (gorsk.Location, error)
+	Delete(echo.Context, int, int) error
+}
+
+// New creates new location application service
+func New(db *pg.DB, ldb LDB, rbac RBAC) *Location {
+	return &Location{db: db, ldb: ldb, rbac: rbac}
+}
+
+// Initialize initalizes Location application service with defaults
+func Initialize(db *pg.DB, rbac RBAC) *Location {
+	return New(db, pgsql.Location{}, rbac)
+}
+
+// Location represents location application service
+type Location struct {
+	db   *pg.DB
+	ldb  LDB
+	rbac RBAC
+}
+
+// LDB represents location repository interface
+type LDB interface {
+	Create(orm.DB, gorsk.Location) (gorsk.Location, error)
+	View(orm.DB, int, int) (gorsk.Location, error)
+	List(orm.DB, int, gorsk.Pagination) ([]gorsk.Location, error)
+	Update(orm.DB, gorsk.Location) error
+	Delete(orm.DB, gorsk.Location) error
+}
+
+// RBAC represents role-based-access-control interface
+type RBAC interface {
+	User(echo.Context) gorsk.AuthUser
+	Can(echo.Context, string, gorsk.Resource) error
+}
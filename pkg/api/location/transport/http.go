@@ -0,0 +1,303 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/location"
+
+	"github.com/labstack/echo"
+)
+
+// HTTP represents location http service
+type HTTP struct {
+	svc location.Service
+}
+
+// NewHTTP creates new location http service
+func NewHTTP(svc location.Service, r *echo.Group) {
+	h := HTTP{svc}
+	lr := r.Group("/companies/:id/locations")
+	// swagger:operation POST /v1/companies/{id}/locations locations locationCreate
+	// ---
+	// summary: Creates new location.
+	// description: Creates new location for the company with requested ID.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/locationCreate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/locationResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	lr.POST("", h.create)
+
+	// swagger:operation GET /v1/companies/{id}/locations locations listLocations
+	// ---
+	// summary: Returns list of company's locations.
+	// description: Returns list of locations for the company with requested ID. Available to SuperAdmin/Admin users and Company admins of the requested company.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/locationListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	lr.GET("", h.list)
+
+	// swagger:operation GET /v1/companies/{id}/locations/{location_id} locations getLocation
+	// ---
+	// summary: Returns a single location.
+	// description: Returns a single location of a company. Location admins can only view their own location.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: location_id
+	//   in: path
+	//   description: id of location
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/locationResp"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	lr.GET("/:location_id", h.view)
+
+	// swagger:operation PATCH /v1/companies/{id}/locations/{location_id} locations locationUpdate
+	// ---
+	// summary: Updates location's information
+	// description: Updates location's information -> name, address. Location admins can only update their own location.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: location_id
+	//   in: path
+	//   description: id of location
+	//   type: int
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/locationUpdate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/locationResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	lr.PATCH("/:location_id", h.update)
+
+	// swagger:operation DELETE /v1/companies/{id}/locations/{location_id} locations locationDelete
+	// ---
+	// summary: Deletes a location
+	// description: Deletes a location of a company.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of company
+	//   type: int
+	//   required: true
+	// - name: location_id
+	//   in: path
+	//   description: id of location
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	lr.DELETE("/:location_id", h.delete)
+}
+
+// Location create request
+// swagger:model locationCreate
+type createReq struct {
+	Name    string `json:"name" validate:"required,min=2"`
+	Address string `json:"address" validate:"required"`
+}
+
+func (h HTTP) create(c echo.Context) error {
+	companyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	r := new(createReq)
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	loc, err := h.svc.Create(c, gorsk.Location{
+		Name:      r.Name,
+		Address:   r.Address,
+		Active:    true,
+		CompanyID: companyID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, loc)
+}
+
+type listResponse struct {
+	Locations []gorsk.Location `json:"locations"`
+	Page      int              `json:"page"`
+}
+
+func (h HTTP) list(c echo.Context) error {
+	companyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	result, err := h.svc.List(c, companyID, req.Transform())
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, listResponse{result, req.Page})
+}
+
+func (h HTTP) view(c echo.Context) error {
+	companyID, id, err := params(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.svc.View(c, companyID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// Location update request
+// swagger:model locationUpdate
+type updateReq struct {
+	Name    string `json:"name,omitempty" validate:"omitempty,min=2"`
+	Address string `json:"address,omitempty"`
+}
+
+func (h HTTP) update(c echo.Context) error {
+	companyID, id, err := params(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(updateReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	loc, err := h.svc.Update(c, location.Update{
+		ID:        id,
+		CompanyID: companyID,
+		Name:      req.Name,
+		Address:   req.Address,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, loc)
+}
+
+func (h HTTP) delete(c echo.Context) error {
+	companyID, id, err := params(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.svc.Delete(c, companyID, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func params(c echo.Context) (int, int, error) {
+	companyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, gorsk.ErrBadRequest
+	}
+	id, err := strconv.Atoi(c.Param("location_id"))
+	if err != nil {
+		return 0, 0, gorsk.ErrBadRequest
+	}
+	return companyID, id, nil
+}
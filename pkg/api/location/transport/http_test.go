@@ -0,0 +1,385 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/location"
+	"github.com/ribice/gorsk/pkg/api/location/transport"
+
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/server"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name       string
+		companyID  string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.Location
+		ldb        *mockdb.Location
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid company ID",
+			companyID:  `a`,
+			req:        `{"name":"HQ","address":"Main street"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on validation",
+			companyID:  `1`,
+			req:        `{"name":"HQ"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "Fail on RBAC",
+			companyID: `1`,
+			req:       `{"name":"HQ","address":"Main street"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:      "Success",
+			companyID: `1`,
+			req:       `{"name":"HQ","address":"Main street"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			ldb: &mockdb.Location{
+				CreateFn: func(db orm.DB, loc gorsk.Location) (gorsk.Location, error) {
+					loc.ID = 4
+					return loc, nil
+				},
+			},
+			wantResp: &gorsk.Location{
+				Base:      gorsk.Base{ID: 4},
+				Name:      "HQ",
+				Address:   "Main street",
+				Active:    true,
+				CompanyID: 1,
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(location.New(nil, tt.ldb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.companyID + "/locations"
+			res, err := http.Post(path, "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.Location)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	type listResponse struct {
+		Locations []gorsk.Location `json:"locations"`
+		Page      int              `json:"page"`
+	}
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *listResponse
+		ldb        *mockdb.Location
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `1/locations?limit=2222&page=-1`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			req:  `1/locations?limit=100&page=1`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `1/locations?limit=100&page=1`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			ldb: &mockdb.Location{
+				ListFn: func(db orm.DB, companyID int, p gorsk.Pagination) ([]gorsk.Location, error) {
+					if companyID == 1 && p.Limit == 100 && p.Offset == 100 {
+						return []gorsk.Location{{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 1}}, nil
+					}
+					return nil, gorsk.ErrGeneric
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: &listResponse{
+				Locations: []gorsk.Location{{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 1}},
+				Page:      1,
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(location.New(nil, tt.ldb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.req
+			res, err := http.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(listResponse)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   gorsk.Location
+		ldb        *mockdb.Location
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `1/locations/a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			req:  `1/locations/3`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `1/locations/3`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			ldb: &mockdb.Location{
+				ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+					return gorsk.Location{Base: gorsk.Base{ID: id}, Name: "HQ", CompanyID: companyID}, nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   gorsk.Location{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 1},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(location.New(nil, tt.ldb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.req
+			res, err := http.Get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp.ID != 0 {
+				response := new(gorsk.Location)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, &tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		path       string
+		wantStatus int
+		wantResp   gorsk.Location
+		ldb        *mockdb.Location
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			path:       `a/locations/3`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on validation",
+			path:       `1/locations/3`,
+			req:        `{"name":"a"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Success",
+			path: `1/locations/3`,
+			req:  `{"name":"Branch"}`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			ldb: &mockdb.Location{
+				ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+					return gorsk.Location{Base: gorsk.Base{ID: id}, Name: "Branch", CompanyID: companyID}, nil
+				},
+				UpdateFn: func(orm.DB, gorsk.Location) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   gorsk.Location{Base: gorsk.Base{ID: 3}, Name: "Branch", CompanyID: 1},
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(location.New(nil, tt.ldb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.path
+			req, _ := http.NewRequest("PATCH", path, bytes.NewBufferString(tt.req))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp.ID != 0 {
+				response := new(gorsk.Location)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, &tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		wantStatus int
+		ldb        *mockdb.Location
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			path:       `1/locations/a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			path: `1/locations/3`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			path: `1/locations/3`,
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+			},
+			ldb: &mockdb.Location{
+				ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+					return gorsk.Location{Base: gorsk.Base{ID: id}, CompanyID: companyID}, nil
+				},
+				DeleteFn: func(orm.DB, gorsk.Location) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(location.New(nil, tt.ldb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/companies/" + tt.path
+			req, _ := http.NewRequest("DELETE", path, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
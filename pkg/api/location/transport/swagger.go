@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"github.com/ribice/gorsk"
+)
+
+// Location model response
+// swagger:response locationResp
+type swaggLocationResponse struct {
+	// in:body
+	Body struct {
+		*gorsk.Location
+	}
+}
+
+// Locations model response
+// swagger:response locationListResp
+type swaggLocationListResponse struct {
+	// in:body
+	Body struct {
+		Locations []gorsk.Location `json:"locations"`
+		Page      int              `json:"page"`
+	}
+}
@@ -39,3 +39,32 @@ func (ls *LogService) Change(c echo.Context, id int, oldPass, newPass string) (e
 	}(time.Now())
 	return ls.Service.Change(c, id, oldPass, newPass)
 }
+
+// Forgot logging
+func (ls *LogService) Forgot(c echo.Context, email string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Forgot password request", err,
+			map[string]interface{}{
+				"req":  email,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Forgot(c, email)
+}
+
+// Reset logging
+func (ls *LogService) Reset(c echo.Context, token, newPass string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Reset password request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Reset(c, token, newPass)
+}
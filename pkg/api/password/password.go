@@ -1,20 +1,30 @@
 package password
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
 )
 
+// Duration for which the reset token is valid if not configured
+const defaultResetDuration = 30 * time.Minute
+
 // Custom errors
 var (
 	ErrIncorrectPassword = echo.NewHTTPError(http.StatusBadRequest, "incorrect old password")
 	ErrInsecurePassword  = echo.NewHTTPError(http.StatusBadRequest, "insecure password")
+	ErrInvalidResetToken = echo.NewHTTPError(http.StatusBadRequest, "password reset token is invalid or has expired")
 )
 
 // Change changes user's password
 func (p Password) Change(c echo.Context, userID int, oldPass, newPass string) error {
-	if err := p.rbac.EnforceUser(c, userID); err != nil {
+	if err := p.rbac.Can(c, gorsk.PermissionPasswordsChange, gorsk.Resource{UserID: userID}); err != nil {
 		return err
 	}
 
@@ -35,3 +45,100 @@ func (p Password) Change(c echo.Context, userID int, oldPass, newPass string) er
 
 	return p.udb.Update(p.db, u)
 }
+
+// Forgot sends password reset token to the user with given email.
+// To avoid revealing which emails are registered, no error is returned if there is no such active user.
+func (p Password) Forgot(c echo.Context, email string) error {
+	u, err := p.udb.FindByEmail(p.db, email)
+	if err == pg.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !u.Active {
+		return nil
+	}
+
+	token, err := p.sec.Token()
+	if err != nil {
+		return err
+	}
+	duration := p.resetDuration()
+	if err := p.udb.CreateToken(p.db, gorsk.OneTimeToken{
+		UserID:    u.ID,
+		Purpose:   gorsk.TokenPasswordReset,
+		Token:     p.sec.HashToken(token),
+		ExpiresAt: time.Now().Add(duration),
+	}); err != nil {
+		return err
+	}
+
+	return p.mail.Send(u.Email, "Reset your password", fmt.Sprintf(resetMail, u.FirstName, int(duration.Minutes()), p.resetLink(token)))
+}
+
+// Reset sets new password of the user the reset token was sent to. The token can be used only once,
+// and all other reset tokens and sessions of the user are ended as well.
+func (p Password) Reset(c echo.Context, token, newPass string) error {
+	reset, err := p.udb.FindToken(p.db, gorsk.TokenPasswordReset, p.sec.HashToken(token))
+	if err == pg.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if reset.Expired() {
+		return ErrInvalidResetToken
+	}
+
+	u, err := p.udb.View(p.db, reset.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !p.sec.Password(newPass, u.FirstName, u.LastName, u.Username, u.Email) {
+		return ErrInsecurePassword
+	}
+
+	if err := p.udb.DeleteToken(p.db, reset.ID); err != nil {
+		if err == pg.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if err := p.udb.DeleteUserTokens(p.db, gorsk.TokenPasswordReset, u.ID); err != nil {
+		return err
+	}
+
+	u.ChangePassword(p.sec.Hash(newPass))
+	u.RevokeTokens()
+
+	if err := p.udb.Update(p.db, u); err != nil {
+		return err
+	}
+
+	return p.udb.DeleteUserSessions(p.db, u.ID)
+}
+
+const resetMail = `Hi %s,
+
+we received a request to reset your password. To choose a new password, follow the link below within %d minutes:
+
+%s
+
+If you did not request a password reset, you can ignore this email and your password will stay the same.
+`
+
+func (p Password) resetLink(token string) string {
+	return p.cfg.ResetURL + "?token=" + url.QueryEscape(token)
+}
+
+func (p Password) resetDuration() time.Duration {
+	if p.cfg.ResetDuration > 0 {
+		return p.cfg.ResetDuration
+	}
+	return defaultResetDuration
+}
@@ -1,14 +1,18 @@
 package password_test
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/password"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
 
@@ -33,7 +37,7 @@ func TestChange(t *testing.T) {
 			name: "Fail on EnforceUser",
 			args: args{id: 1},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return gorsk.ErrGeneric
 				}},
 			wantErr: true,
@@ -43,7 +47,7 @@ func TestChange(t *testing.T) {
 			args:    args{id: 1},
 			wantErr: true,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				}},
 			udb: &mockdb.User{
@@ -59,7 +63,7 @@ func TestChange(t *testing.T) {
 			name: "Fail on PasswordMatch",
 			args: args{id: 1, oldpass: "hunter123"},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				}},
 			wantErr: true,
@@ -80,7 +84,7 @@ func TestChange(t *testing.T) {
 			name: "Fail on InsecurePassword",
 			args: args{id: 1, oldpass: "hunter123"},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				}},
 			wantErr: true,
@@ -104,7 +108,7 @@ func TestChange(t *testing.T) {
 			name: "Success",
 			args: args{id: 1, oldpass: "hunter123", newpass: "password"},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				}},
 			udb: &mockdb.User{
@@ -132,10 +136,267 @@ func TestChange(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := password.New(nil, tt.udb, tt.rbac, tt.sec)
+			s := password.New(nil, tt.udb, tt.rbac, tt.sec, nil, password.Config{})
 			err := s.Change(nil, tt.args.id, tt.args.oldpass, tt.args.newpass)
 			assert.Equal(t, tt.wantErr, err != nil)
 			// Check whether password was changed
 		})
 	}
 }
+
+func TestForgot(t *testing.T) {
+	cases := []struct {
+		name     string
+		email    string
+		wantErr  bool
+		wantMail bool
+		udb      *mockdb.User
+	}{
+		{
+			name:  "Unknown email",
+			email: "nobody@mail.com",
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on FindByEmail",
+			email:   "johndoe@mail.com",
+			wantErr: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:  "Inactive user",
+			email: "johndoe@mail.com",
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on CreateToken",
+			email:   "johndoe@mail.com",
+			wantErr: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email, Active: true}, nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:     "Success",
+			email:    "johndoe@mail.com",
+			wantMail: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: email, Active: true}, nil
+				},
+				CreateTokenFn: func(db orm.DB, reset gorsk.OneTimeToken) error {
+					if reset.UserID != 1 || reset.Purpose != gorsk.TokenPasswordReset || reset.Token != "hashed:resettoken" {
+						return gorsk.ErrGeneric
+					}
+					if d := time.Until(reset.ExpiresAt); d < 14*time.Minute || d > 15*time.Minute {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "resettoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := new(mail.Memory)
+			s := password.New(nil, tt.udb, nil, sec, mailer, password.Config{
+				ResetURL:      "https://gorsk.com/password/reset",
+				ResetDuration: 15 * time.Minute,
+			})
+			err := s.Forgot(nil, tt.email)
+			assert.Equal(t, tt.wantErr, err != nil)
+			msgs := mailer.Messages()
+			if !tt.wantMail {
+				assert.Empty(t, msgs)
+				return
+			}
+			if assert.Len(t, msgs, 1) {
+				assert.Equal(t, tt.email, msgs[0].To)
+				assert.True(t, strings.HasPrefix(msgs[0].Body, "Hi John,"))
+				assert.Contains(t, msgs[0].Body, "within 15 minutes")
+				assert.Contains(t, msgs[0].Body, "https://gorsk.com/password/reset?token=resettoken")
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	reset := func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+		return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+	}
+	view := func(db orm.DB, id int) (gorsk.User, error) {
+		return gorsk.User{Base: gorsk.Base{ID: id}, Password: "oldhash", TokenVersion: 2}, nil
+	}
+	cases := []struct {
+		name    string
+		wantErr error
+		udb     *mockdb.User
+		sec     *mock.Secure
+	}{
+		{
+			name:    "Unknown token",
+			wantErr: password.ErrInvalidResetToken,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Expired token",
+			wantErr: password.ErrInvalidResetToken,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on View",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindTokenFn: reset,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Insecure password",
+			wantErr: password.ErrInsecurePassword,
+			udb: &mockdb.User{
+				FindTokenFn: reset,
+				ViewFn:      view,
+			},
+			sec: &mock.Secure{
+				PasswordFn: func(string, ...string) bool {
+					return false
+				},
+			},
+		},
+		{
+			name:    "Token already used",
+			wantErr: password.ErrInvalidResetToken,
+			udb: &mockdb.User{
+				FindTokenFn: reset,
+				ViewFn:      view,
+				DeleteTokenFn: func(orm.DB, int) error {
+					return pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on DeleteUserTokens",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindTokenFn: reset,
+				ViewFn:      view,
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+				DeleteUserTokensFn: func(orm.DB, string, int) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Fail on Update",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindTokenFn: reset,
+				ViewFn:      view,
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+				DeleteUserTokensFn: func(orm.DB, string, int) error {
+					return nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				FindTokenFn: func(db orm.DB, purpose, token string) (gorsk.OneTimeToken, error) {
+					if purpose != gorsk.TokenPasswordReset || token != "hashed:resettoken" {
+						return gorsk.OneTimeToken{}, pg.ErrNoRows
+					}
+					return reset(db, purpose, token)
+				},
+				ViewFn: view,
+				DeleteTokenFn: func(db orm.DB, id int) error {
+					if id != 3 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserTokensFn: func(db orm.DB, purpose string, userID int) error {
+					if purpose != gorsk.TokenPasswordReset || userID != 1 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				UpdateFn: func(db orm.DB, u gorsk.User) error {
+					if u.Password != "newhash" || u.TokenVersion != 3 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserSessionsFn: func(db orm.DB, id int) error {
+					if id != 1 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			sec := tt.sec
+			if sec == nil {
+				sec = &mock.Secure{
+					PasswordFn: func(string, ...string) bool {
+						return true
+					},
+				}
+			}
+			sec.HashTokenFn = func(s string) string {
+				return "hashed:" + s
+			}
+			sec.HashFn = func(string) string {
+				return "newhash"
+			}
+			s := password.New(nil, tt.udb, nil, sec, nil, password.Config{})
+			err := s.Reset(nil, "resettoken", "newpassword")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
@@ -1,13 +1,18 @@
 package pgsql
 
 import (
+	"strings"
+
 	"github.com/go-pg/pg/v9/orm"
 
 	"github.com/ribice/gorsk"
+	onetime "github.com/ribice/gorsk/pkg/utl/onetime/platform/pgsql"
 )
 
-// User represents the client for user table
-type User struct{}
+// User represents the client for user table, and for one-time tokens given out to users
+type User struct {
+	onetime.Token
+}
 
 // View returns single user by ID
 func (u User) View(db orm.DB, id int) (gorsk.User, error) {
@@ -20,3 +25,19 @@ func (u User) View(db orm.DB, id int) (gorsk.User, error) {
 func (u User) Update(db orm.DB, user gorsk.User) error {
 	return db.Update(&user)
 }
+
+// FindByEmail queries for single user by email
+func (u User) FindByEmail(db orm.DB, email string) (gorsk.User, error) {
+	var user gorsk.User
+	err := db.Model(&user).Where("lower(email) = ? and deleted_at is null", strings.ToLower(email)).Select()
+	return user, err
+}
+
+// DeleteUserSessions deletes all sessions of a user along with their used refresh tokens
+func (u User) DeleteUserSessions(db orm.DB, userID int) error {
+	if _, err := db.Model((*gorsk.Session)(nil)).Where("user_id = ?", userID).Delete(); err != nil {
+		return err
+	}
+	_, err := db.Model((*gorsk.RefreshToken)(nil)).Where("user_id = ?", userID).Delete()
+	return err
+}
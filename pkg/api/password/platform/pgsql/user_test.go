@@ -2,7 +2,9 @@ package pgsql_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ribice/gorsk"
@@ -147,3 +149,28 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestFindByEmail(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	now := time.Now()
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Email: "tomjones@mail.com", Username: "tomjones", RoleID: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 2, DeletedAt: now}, Email: "deleted@mail.com", Username: "deleted", RoleID: 1},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	user, err := udb.FindByEmail(db, "TomJones@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, user.ID)
+
+	_, err = udb.FindByEmail(db, "deleted@mail.com")
+	assert.Equal(t, pg.ErrNoRows, err)
+}
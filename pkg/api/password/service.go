@@ -1,6 +1,8 @@
 package password
 
 import (
+	"time"
+
 	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
@@ -12,21 +14,25 @@ import (
 // Service represents password application interface
 type Service interface {
 	Change(echo.Context, int, string, string) error
+	Forgot(echo.Context, string) error
+	Reset(echo.Context, string, string) error
 }
 
 // New creates new password application service
-func New(db *pg.DB, udb UserDB, rbac RBAC, sec Securer) Password {
+func New(db *pg.DB, udb UserDB, rbac RBAC, sec Securer, mail gorsk.Mailer, cfg Config) Password {
 	return Password{
 		db:   db,
 		udb:  udb,
 		rbac: rbac,
 		sec:  sec,
+		mail: mail,
+		cfg:  cfg,
 	}
 }
 
 // Initialize initalizes password application service with defaults
-func Initialize(db *pg.DB, rbac RBAC, sec Securer) Password {
-	return New(db, pgsql.User{}, rbac, sec)
+func Initialize(db *pg.DB, rbac RBAC, sec Securer, mail gorsk.Mailer, cfg Config) Password {
+	return New(db, pgsql.User{}, rbac, sec, mail, cfg)
 }
 
 // Password represents password application service
@@ -35,12 +41,28 @@ type Password struct {
 	udb  UserDB
 	rbac RBAC
 	sec  Securer
+	mail gorsk.Mailer
+	cfg  Config
+}
+
+// Config represents password application configuration
+type Config struct {
+	// URL of the page where the password is reset, sent to user with reset token appended as token query parameter
+	ResetURL string
+	// Duration for which the reset token is valid, defaults to 30 minutes
+	ResetDuration time.Duration
 }
 
 // UserDB represents user repository interface
 type UserDB interface {
 	View(orm.DB, int) (gorsk.User, error)
 	Update(orm.DB, gorsk.User) error
+	FindByEmail(orm.DB, string) (gorsk.User, error)
+	CreateToken(orm.DB, gorsk.OneTimeToken) error
+	FindToken(orm.DB, string, string) (gorsk.OneTimeToken, error)
+	DeleteToken(orm.DB, int) error
+	DeleteUserTokens(orm.DB, string, int) error
+	DeleteUserSessions(orm.DB, int) error
 }
 
 // Securer represents security interface
@@ -48,9 +70,11 @@ type Securer interface {
 	Hash(string) string
 	HashMatchesPassword(string, string) bool
 	Password(string, ...string) bool
+	Token() (string, error)
+	HashToken(string) string
 }
 
 // RBAC represents role-based-access-control interface
 type RBAC interface {
-	EnforceUser(echo.Context, int) error
+	Can(echo.Context, string, gorsk.Resource) error
 }
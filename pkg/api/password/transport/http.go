@@ -16,10 +16,50 @@ type HTTP struct {
 }
 
 // NewHTTP creates new password http service
-func NewHTTP(svc password.Service, er *echo.Group) {
+func NewHTTP(svc password.Service, e *echo.Echo, er *echo.Group) {
 	h := HTTP{svc}
 	pr := er.Group("/password")
 
+	// swagger:operation POST /password/forgot password pwForgot
+	// ---
+	// summary: Sends password reset link to user's email.
+	// description: The response is the same whether the email belongs to a user or not.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/pwForgot"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.POST("/password/forgot", h.forgot)
+
+	// swagger:operation POST /password/reset password pwReset
+	// ---
+	// summary: Resets user's password.
+	// description: Sets new password using the token from password reset link. The token can be used only once, and all user's sessions are ended.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/pwReset"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.POST("/password/reset", h.reset)
+
 	// swagger:operation PATCH /v1/password/{id} password pwChange
 	// ---
 	// summary: Changes user's password.
@@ -85,3 +125,47 @@ func (h *HTTP) change(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+// Password forgot request
+// swagger:model pwForgot
+type forgotReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h *HTTP) forgot(c echo.Context) error {
+	r := new(forgotReq)
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	if err := h.svc.Forgot(c, r.Email); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// Password reset request
+// swagger:model pwReset
+type resetReq struct {
+	Token              string `json:"token" validate:"required"`
+	NewPassword        string `json:"new_password" validate:"required,min=8"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required"`
+}
+
+func (h *HTTP) reset(c echo.Context) error {
+	r := new(resetReq)
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	if r.NewPassword != r.NewPasswordConfirm {
+		return ErrPasswordsNotMaching
+	}
+
+	if err := h.svc.Reset(c, r.Token, r.NewPassword); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
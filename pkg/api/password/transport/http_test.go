@@ -5,15 +5,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/password"
 	"github.com/ribice/gorsk/pkg/api/password/transport"
 
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
 	"github.com/ribice/gorsk/pkg/utl/server"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
@@ -50,7 +53,7 @@ func TestChangePassword(t *testing.T) {
 			name: "Fail on RBAC",
 			req:  `{"new_password":"newpassw","old_password":"oldpassw", "new_password_confirm":"newpassw"}`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return echo.ErrForbidden
 				},
 			},
@@ -61,7 +64,7 @@ func TestChangePassword(t *testing.T) {
 			name: "Success",
 			req:  `{"new_password":"newpassw","old_password":"oldpassw", "new_password_confirm":"newpassw"}`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				},
 			},
@@ -95,7 +98,7 @@ func TestChangePassword(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(password.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(password.New(nil, tt.udb, tt.rbac, tt.sec, nil, password.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/password/" + tt.id
@@ -113,3 +116,159 @@ func TestChangePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestForgotPassword(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Fail on Bind",
+			req:        `{"email":"notanemail"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown email",
+			req:        `{"email":"nobody@mail.com"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"email":"johndoe@mail.com"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email, Active: true}, nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "resettoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(password.New(nil, tt.udb, nil, sec, new(mail.Memory), password.Config{}), r, r.Group("/v1"))
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/password/forgot", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Fail on Bind",
+			req:        `{"token":"resettoken","new_password":"short","new_password_confirm":"short"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Different passwords",
+			req:        `{"token":"resettoken","new_password":"newpassword","new_password_confirm":"newpasswort"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid token",
+			req:        `{"token":"resettoken","new_password":"newpassword","new_password_confirm":"newpassword"}`,
+			wantStatus: http.StatusBadRequest,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"token":"resettoken","new_password":"newpassword","new_password_confirm":"newpassword"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, nil
+				},
+				DeleteTokenFn: func(orm.DB, int) error {
+					return nil
+				},
+				DeleteUserTokensFn: func(orm.DB, string, int) error {
+					return nil
+				},
+				UpdateFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				DeleteUserSessionsFn: func(orm.DB, int) error {
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+		PasswordFn: func(string, ...string) bool {
+			return true
+		},
+		HashFn: func(string) string {
+			return "hash3d"
+		},
+	}
+
+	client := &http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(password.New(nil, tt.udb, nil, sec, nil, password.Config{}), r, r.Group("/v1"))
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, err := http.NewRequest("POST", ts.URL+"/password/reset", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package role
+
+import (
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/role"
+)
+
+// New creates new role logging service
+func New(svc role.Service, logger gorsk.Logger) *LogService {
+	return &LogService{
+		Service: svc,
+		logger:  logger,
+	}
+}
+
+// LogService represents role logging service
+type LogService struct {
+	role.Service
+	logger gorsk.Logger
+}
+
+const name = "role"
+
+// Create logging
+func (ls *LogService) Create(c echo.Context, req gorsk.Role) (resp gorsk.Role, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Create role request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Create(c, req)
+}
+
+// List logging
+func (ls *LogService) List(c echo.Context, req gorsk.Pagination) (resp []gorsk.Role, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "List role request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.List(c, req)
+}
+
+// View logging
+func (ls *LogService) View(c echo.Context, req gorsk.AccessRole) (resp gorsk.Role, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "View role request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.View(c, req)
+}
+
+// Update logging
+func (ls *LogService) Update(c echo.Context, req role.Update) (resp gorsk.Role, err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Update role request", err,
+			map[string]interface{}{
+				"req":  req,
+				"resp": resp,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Update(c, req)
+}
+
+// Delete logging
+func (ls *LogService) Delete(c echo.Context, req gorsk.AccessRole) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Delete role request", err,
+			map[string]interface{}{
+				"req":  req,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Delete(c, req)
+}
+
+// Assign logging
+func (ls *LogService) Assign(c echo.Context, req gorsk.AccessRole, userID int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Assign role request", err,
+			map[string]interface{}{
+				"req":     req,
+				"user_id": userID,
+				"took":    time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Assign(c, req, userID)
+}
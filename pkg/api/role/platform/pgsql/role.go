@@ -0,0 +1,66 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Role represents the client for role table
+type Role struct{}
+
+// Create creates a new role on database. Its ID is assigned by the database.
+func (r Role) Create(db orm.DB, role gorsk.Role) (gorsk.Role, error) {
+	role.ID = 0
+	err := db.Insert(&role)
+	return role, err
+}
+
+// View returns single role by ID
+func (r Role) View(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+	role := gorsk.Role{ID: id}
+	err := db.Select(&role)
+	return role, err
+}
+
+// List returns list of all roles, ordered by access level
+func (r Role) List(db orm.DB, p gorsk.Pagination) ([]gorsk.Role, error) {
+	var roles []gorsk.Role
+	err := db.Model(&roles).Limit(p.Limit).Offset(p.Offset).Order("access_level", "id").Select()
+	return roles, err
+}
+
+// Update updates role's name and permissions
+func (r Role) Update(db orm.DB, role gorsk.Role) error {
+	_, err := db.Model(&role).Column("name", "permissions").WherePK().Update()
+	return err
+}
+
+// Delete deletes a role
+func (r Role) Delete(db orm.DB, role gorsk.Role) error {
+	return db.Delete(&role)
+}
+
+// InUse reports whether the role is assigned to any user, including deleted ones still referencing it
+func (r Role) InUse(db orm.DB, id gorsk.AccessRole) (bool, error) {
+	return db.Model((*gorsk.User)(nil)).AllWithDeleted().Where("role_id = ?", id).Exists()
+}
+
+// Assign assigns the role to user and revokes user's tokens.
+// It returns pg.ErrNoRows if user does not exist.
+func (r Role) Assign(db orm.DB, userID int, id gorsk.AccessRole) error {
+	res, err := db.Model((*gorsk.User)(nil)).
+		Set("role_id = ?", id).
+		Set("token_version = token_version + 1").
+		Set("updated_at = now()").
+		Where("id = ? and deleted_at is null", userID).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package pgsql_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk"
+
+	"github.com/ribice/gorsk/pkg/api/role/platform/pgsql"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+)
+
+func TestCreate(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{})
+
+	rdb := pgsql.Role{}
+
+	role, err := rdb.Create(db, gorsk.Role{ID: gorsk.UserRole, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, gorsk.UserRole, role.ID)
+
+	stored, err := rdb.View(db, role.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, role, stored)
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantErr  bool
+		pg       gorsk.Pagination
+		wantData []gorsk.Role
+	}{
+		{
+			name:    "Invalid pagination values",
+			wantErr: true,
+			pg: gorsk.Pagination{
+				Limit: -100,
+			},
+		},
+		{
+			name: "Success",
+			pg: gorsk.Pagination{
+				Limit:  100,
+				Offset: 0,
+			},
+			wantData: []gorsk.Role{
+				{ID: gorsk.LocationAdminRole, AccessLevel: gorsk.LocationAdminRole, Name: "LOCATION_ADMIN"},
+				{ID: 1000, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}},
+				{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole, Name: "USER"},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{})
+
+	if err := mock.InsertMultiple(db, &cases[1].wantData[2], &cases[1].wantData[1], &cases[1].wantData[0]); err != nil {
+		t.Error(err)
+	}
+
+	rdb := pgsql.Role{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := rdb.List(db, tt.pg)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantData != nil {
+				assert.Equal(t, tt.wantData, roles)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{})
+
+	role := gorsk.Role{ID: 1000, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}}
+	if err := mock.InsertMultiple(db, &role); err != nil {
+		t.Error(err)
+	}
+
+	rdb := pgsql.Role{}
+
+	// access level is never updated
+	if err := rdb.Update(db, gorsk.Role{ID: 1000, AccessLevel: 100, Name: "READER", Permissions: []string{}}); err != nil {
+		t.Error(err)
+	}
+
+	stored, err := rdb.View(db, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, gorsk.Role{ID: 1000, AccessLevel: 150, Name: "READER", Permissions: []string{}}, stored)
+}
+
+func TestDelete(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{})
+
+	role := gorsk.Role{ID: 1000, AccessLevel: 150, Name: "AUDITOR"}
+	if err := mock.InsertMultiple(db, &role); err != nil {
+		t.Error(err)
+	}
+
+	rdb := pgsql.Role{}
+
+	if err := rdb.Delete(db, role); err != nil {
+		t.Error(err)
+	}
+
+	_, err := rdb.View(db, 1000)
+	assert.Equal(t, pg.ErrNoRows, err)
+}
+
+func TestAssign(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole, Name: "USER"},
+		&gorsk.Role{ID: 1000, AccessLevel: 150, Name: "AUDITOR"},
+		&gorsk.Role{ID: 1001, AccessLevel: 150, Name: "READER"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Email: "johndoe@mail.com", RoleID: gorsk.UserRole, TokenVersion: 2}); err != nil {
+		t.Error(err)
+	}
+
+	rdb := pgsql.Role{}
+
+	inUse, err := rdb.InUse(db, 1000)
+	assert.Nil(t, err)
+	assert.False(t, inUse)
+
+	assert.Equal(t, pg.ErrNoRows, rdb.Assign(db, 2, 1000))
+
+	if err := rdb.Assign(db, 1, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	var user gorsk.User
+	if err := db.Model(&user).Where("id = ?", 1).Select(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, gorsk.AccessRole(1000), user.RoleID)
+	assert.Equal(t, 3, user.TokenVersion)
+
+	inUse, err = rdb.InUse(db, 1000)
+	assert.Nil(t, err)
+	assert.True(t, inUse)
+
+	inUse, err = rdb.InUse(db, 1001)
+	assert.Nil(t, err)
+	assert.False(t, inUse)
+}
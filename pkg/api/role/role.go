@@ -0,0 +1,153 @@
+// Package role contains role application services
+package role
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Custom errors
+var (
+	ErrNotFound      = echo.NewHTTPError(http.StatusNotFound, "Role does not exist")
+	ErrUserNotFound  = echo.NewHTTPError(http.StatusNotFound, "User does not exist")
+	ErrBuiltIn       = echo.NewHTTPError(http.StatusConflict, "Built-in roles cannot be deleted")
+	ErrInUse         = echo.NewHTTPError(http.StatusConflict, "Role is assigned to users and cannot be deleted")
+	ErrAssignOwnRole = echo.NewHTTPError(http.StatusConflict, "You cannot change your own role")
+)
+
+// Create creates a new custom role
+func (r Role) Create(c echo.Context, req gorsk.Role) (gorsk.Role, error) {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesCreate, gorsk.Resource{}); err != nil {
+		return gorsk.Role{}, err
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		return gorsk.Role{}, err
+	}
+	return r.rdb.Create(r.db, req)
+}
+
+// List returns list of roles, which can be viewed by every user able to create accounts
+func (r Role) List(c echo.Context, p gorsk.Pagination) ([]gorsk.Role, error) {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesView, gorsk.Resource{}); err != nil {
+		return nil, err
+	}
+	return r.rdb.List(r.db, p)
+}
+
+// View returns single role
+func (r Role) View(c echo.Context, id gorsk.AccessRole) (gorsk.Role, error) {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesView, gorsk.Resource{}); err != nil {
+		return gorsk.Role{}, err
+	}
+	return r.view(id)
+}
+
+// Update contains role's information used for updating
+type Update struct {
+	ID          gorsk.AccessRole
+	Name        string
+	Permissions []string
+}
+
+// Update updates role's name and permissions. Permissions are replaced unless nil.
+// Access level cannot be changed, as users already holding the role were created and authorized by it.
+func (r Role) Update(c echo.Context, req Update) (gorsk.Role, error) {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesUpdate, gorsk.Resource{}); err != nil {
+		return gorsk.Role{}, err
+	}
+
+	role, err := r.view(req.ID)
+	if err != nil {
+		return gorsk.Role{}, err
+	}
+
+	if req.Name != "" {
+		role.Name = req.Name
+	}
+	if req.Permissions != nil {
+		if err := validatePermissions(req.Permissions); err != nil {
+			return gorsk.Role{}, err
+		}
+		role.Permissions = req.Permissions
+	}
+
+	if err := r.rdb.Update(r.db, role); err != nil {
+		return gorsk.Role{}, err
+	}
+
+	return role, nil
+}
+
+// Delete deletes a custom role that is not assigned to any user
+func (r Role) Delete(c echo.Context, id gorsk.AccessRole) error {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesDelete, gorsk.Resource{}); err != nil {
+		return err
+	}
+
+	role, err := r.view(id)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn() {
+		return ErrBuiltIn
+	}
+
+	inUse, err := r.rdb.InUse(r.db, id)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrInUse
+	}
+
+	return r.rdb.Delete(r.db, role)
+}
+
+// Assign assigns the role to the user with given ID. User's tokens issued so far are revoked,
+// so the user has to log in again to act with the new role.
+func (r Role) Assign(c echo.Context, id gorsk.AccessRole, userID int) error {
+	if err := r.rbac.Can(c, gorsk.PermissionRolesAssign, gorsk.Resource{UserID: userID}); err != nil {
+		return err
+	}
+
+	// prevents the last SuperAdmin from locking everyone out of managing roles
+	if r.rbac.User(c).ID == userID {
+		return ErrAssignOwnRole
+	}
+
+	if _, err := r.view(id); err != nil {
+		return err
+	}
+
+	if err := r.rdb.Assign(r.db, userID, id); err != nil {
+		if err == pg.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r Role) view(id gorsk.AccessRole) (gorsk.Role, error) {
+	role, err := r.rdb.View(r.db, id)
+	if err == pg.ErrNoRows {
+		return gorsk.Role{}, ErrNotFound
+	}
+	return role, err
+}
+
+// validatePermissions checks that all permissions can be assigned to roles
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !gorsk.ValidPermission(p) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown permission: %s", p))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package role_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/role"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func can(permission string, err error) *mock.RBAC {
+	return &mock.RBAC{
+		CanFn: func(c echo.Context, p string, r gorsk.Resource) error {
+			if p != permission {
+				return gorsk.ErrGeneric
+			}
+			return err
+		},
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1, Role: gorsk.SuperAdminRole}
+		},
+	}
+}
+
+func viewRole(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+	switch id {
+	case gorsk.UserRole:
+		return gorsk.Role{ID: id, AccessLevel: id, Name: "USER", Permissions: gorsk.DefaultPermissions[id]}, nil
+	case 1000:
+		return gorsk.Role{ID: id, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}}, nil
+	}
+	return gorsk.Role{}, pg.ErrNoRows
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      gorsk.Role
+		rbac     *mock.RBAC
+		rdb      *mockdb.Role
+		wantErr  bool
+		wantData gorsk.Role
+	}{
+		{
+			name:    "Fail on RBAC",
+			req:     gorsk.Role{Name: "AUDITOR", AccessLevel: 150},
+			rbac:    can(gorsk.PermissionRolesCreate, echo.ErrForbidden),
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown permission",
+			req:     gorsk.Role{Name: "AUDITOR", AccessLevel: 150, Permissions: []string{gorsk.PermissionUsersView, "users:destroy"}},
+			rbac:    can(gorsk.PermissionRolesCreate, nil),
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			req:  gorsk.Role{Name: "AUDITOR", AccessLevel: 150, Permissions: []string{gorsk.PermissionUsersView}},
+			rbac: can(gorsk.PermissionRolesCreate, nil),
+			rdb: &mockdb.Role{
+				CreateFn: func(db orm.DB, r gorsk.Role) (gorsk.Role, error) {
+					r.ID = 1000
+					return r, nil
+				},
+			},
+			wantData: gorsk.Role{ID: 1000, Name: "AUDITOR", AccessLevel: 150, Permissions: []string{gorsk.PermissionUsersView}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := role.New(nil, tt.rdb, tt.rbac)
+			resp, err := s.Create(nil, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	roles := []gorsk.Role{{ID: gorsk.SuperAdminRole, AccessLevel: gorsk.SuperAdminRole}, {ID: 1000, AccessLevel: 150}}
+	cases := []struct {
+		name     string
+		rbac     *mock.RBAC
+		wantErr  error
+		wantData []gorsk.Role
+	}{
+		{
+			name: "Fail on RBAC",
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return echo.ErrForbidden
+				}},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Success",
+			rbac: &mock.RBAC{
+				CanFn: func(c echo.Context, p string, r gorsk.Resource) error {
+					if p != gorsk.PermissionRolesView {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				}},
+			wantData: roles,
+		},
+	}
+	rdb := &mockdb.Role{
+		ListFn: func(orm.DB, gorsk.Pagination) ([]gorsk.Role, error) {
+			return roles, nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := role.New(nil, rdb, tt.rbac)
+			resp, err := s.List(nil, gorsk.Pagination{Limit: 100})
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       gorsk.AccessRole
+		wantErr  error
+		wantData gorsk.Role
+	}{
+		{
+			name:    "Role does not exist",
+			id:      1001,
+			wantErr: role.ErrNotFound,
+		},
+		{
+			name:     "Success",
+			id:       1000,
+			wantData: gorsk.Role{ID: 1000, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := role.New(nil, &mockdb.Role{ViewFn: viewRole}, can(gorsk.PermissionRolesView, nil))
+			resp, err := s.View(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      role.Update
+		rbac     *mock.RBAC
+		wantErr  bool
+		wantData gorsk.Role
+	}{
+		{
+			name:    "Fail on RBAC",
+			req:     role.Update{ID: 1000, Name: "READER"},
+			rbac:    can(gorsk.PermissionRolesUpdate, echo.ErrForbidden),
+			wantErr: true,
+		},
+		{
+			name:    "Role does not exist",
+			req:     role.Update{ID: 1001, Name: "READER"},
+			rbac:    can(gorsk.PermissionRolesUpdate, nil),
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown permission",
+			req:     role.Update{ID: 1000, Permissions: []string{"users:destroy"}},
+			rbac:    can(gorsk.PermissionRolesUpdate, nil),
+			wantErr: true,
+		},
+		{
+			name:     "Success renaming",
+			req:      role.Update{ID: 1000, Name: "READER"},
+			rbac:     can(gorsk.PermissionRolesUpdate, nil),
+			wantData: gorsk.Role{ID: 1000, AccessLevel: 150, Name: "READER", Permissions: []string{gorsk.PermissionUsersView}},
+		},
+		{
+			name:     "Success revoking permissions of built-in role",
+			req:      role.Update{ID: gorsk.UserRole, Permissions: []string{}},
+			rbac:     can(gorsk.PermissionRolesUpdate, nil),
+			wantData: gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole, Name: "USER", Permissions: []string{}},
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: viewRole,
+		UpdateFn: func(orm.DB, gorsk.Role) error {
+			return nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := role.New(nil, rdb, tt.rbac)
+			resp, err := s.Update(nil, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantData, resp)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name    string
+		id      gorsk.AccessRole
+		rbac    *mock.RBAC
+		inUse   bool
+		wantErr error
+	}{
+		{
+			name:    "Fail on RBAC",
+			id:      1000,
+			rbac:    can(gorsk.PermissionRolesDelete, echo.ErrForbidden),
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Role does not exist",
+			id:      1001,
+			rbac:    can(gorsk.PermissionRolesDelete, nil),
+			wantErr: role.ErrNotFound,
+		},
+		{
+			name:    "Fail on built-in role",
+			id:      gorsk.UserRole,
+			rbac:    can(gorsk.PermissionRolesDelete, nil),
+			wantErr: role.ErrBuiltIn,
+		},
+		{
+			name:    "Fail on role assigned to users",
+			id:      1000,
+			rbac:    can(gorsk.PermissionRolesDelete, nil),
+			inUse:   true,
+			wantErr: role.ErrInUse,
+		},
+		{
+			name: "Success",
+			id:   1000,
+			rbac: can(gorsk.PermissionRolesDelete, nil),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb := &mockdb.Role{
+				ViewFn: viewRole,
+				InUseFn: func(orm.DB, gorsk.AccessRole) (bool, error) {
+					return tt.inUse, nil
+				},
+				DeleteFn: func(db orm.DB, r gorsk.Role) error {
+					if r.ID != 1000 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			}
+			s := role.New(nil, rdb, tt.rbac)
+			err := s.Delete(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	cases := []struct {
+		name    string
+		id      gorsk.AccessRole
+		userID  int
+		rbac    *mock.RBAC
+		wantErr error
+	}{
+		{
+			name:    "Fail on RBAC",
+			id:      1000,
+			userID:  2,
+			rbac:    can(gorsk.PermissionRolesAssign, echo.ErrForbidden),
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Fail on own role",
+			id:      1000,
+			userID:  1,
+			rbac:    can(gorsk.PermissionRolesAssign, nil),
+			wantErr: role.ErrAssignOwnRole,
+		},
+		{
+			name:    "Role does not exist",
+			id:      1001,
+			userID:  2,
+			rbac:    can(gorsk.PermissionRolesAssign, nil),
+			wantErr: role.ErrNotFound,
+		},
+		{
+			name:    "User does not exist",
+			id:      1000,
+			userID:  3,
+			rbac:    can(gorsk.PermissionRolesAssign, nil),
+			wantErr: role.ErrUserNotFound,
+		},
+		{
+			name:   "Success",
+			id:     1000,
+			userID: 2,
+			rbac:   can(gorsk.PermissionRolesAssign, nil),
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: viewRole,
+		AssignFn: func(db orm.DB, userID int, id gorsk.AccessRole) error {
+			if userID != 2 {
+				return pg.ErrNoRows
+			}
+			return nil
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := role.New(nil, rdb, tt.rbac)
+			err := s.Assign(nil, tt.id, tt.userID)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	r := role.Initialize(nil, nil)
+	if r == nil {
+		t.Error("Role service not initialized")
+	}
+}
@@ -0,0 +1,54 @@
+package role
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/role/platform/pgsql"
+)
+
+// Service represents role application interface
+type Service interface {
+	Create(echo.Context, gorsk.Role) (gorsk.Role, error)
+	List(echo.Context, gorsk.Pagination) ([]gorsk.Role, error)
+	View(echo.Context, gorsk.AccessRole) (gorsk.Role, error)
+	Update(echo.Context, Update) (gorsk.Role, error)
+	Delete(echo.Context, gorsk.AccessRole) error
+	Assign(echo.Context, gorsk.AccessRole, int) error
+}
+
+// New creates new role application service
+func New(db *pg.DB, rdb RDB, rbac RBAC) *Role {
+	return &Role{db: db, rdb: rdb, rbac: rbac}
+}
+
+// Initialize initalizes Role application service with defaults
+func Initialize(db *pg.DB, rbac RBAC) *Role {
+	return New(db, pgsql.Role{}, rbac)
+}
+
+// Role represents role application service
+type Role struct {
+	db   *pg.DB
+	rdb  RDB
+	rbac RBAC
+}
+
+// RDB represents role repository interface
+type RDB interface {
+	Create(orm.DB, gorsk.Role) (gorsk.Role, error)
+	View(orm.DB, gorsk.AccessRole) (gorsk.Role, error)
+	List(orm.DB, gorsk.Pagination) ([]gorsk.Role, error)
+	Update(orm.DB, gorsk.Role) error
+	Delete(orm.DB, gorsk.Role) error
+	InUse(orm.DB, gorsk.AccessRole) (bool, error)
+	Assign(orm.DB, int, gorsk.AccessRole) error
+}
+
+// RBAC represents role-based-access-control interface
+type RBAC interface {
+	User(echo.Context) gorsk.AuthUser
+	Can(echo.Context, string, gorsk.Resource) error
+}
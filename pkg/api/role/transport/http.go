@@ -0,0 +1,310 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/role"
+
+	"github.com/labstack/echo"
+)
+
+// HTTP represents role http service
+type HTTP struct {
+	svc role.Service
+}
+
+// NewHTTP creates new role http service
+func NewHTTP(svc role.Service, r *echo.Group) {
+	h := HTTP{svc}
+	rr := r.Group("/roles")
+	// swagger:operation POST /v1/roles roles roleCreate
+	// ---
+	// summary: Creates new custom role.
+	// description: Creates new role with an access level between SuperAdmin (100) and User (200) and a set of permissions. Available to SuperAdmin users only.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/roleCreate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/roleResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.POST("", h.create)
+
+	// swagger:operation GET /v1/roles roles listRoles
+	// ---
+	// summary: Returns list of roles.
+	// description: Returns list of built-in and custom roles, ordered by access level. Available to users who can create accounts.
+	// parameters:
+	// - name: limit
+	//   in: query
+	//   description: number of results
+	//   type: int
+	//   required: false
+	// - name: page
+	//   in: query
+	//   description: page number
+	//   type: int
+	//   required: false
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/roleListResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.GET("", h.list)
+
+	// swagger:operation GET /v1/roles/{id} roles getRole
+	// ---
+	// summary: Returns a single role.
+	// description: Returns a single role with its permissions.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of role
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/roleResp"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.GET("/:id", h.view)
+
+	// swagger:operation PATCH /v1/roles/{id} roles roleUpdate
+	// ---
+	// summary: Updates role's name and permissions
+	// description: Updates role's name and replaces its permissions, if given. Access level of a role cannot be changed. Available to SuperAdmin users only.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of role
+	//   type: int
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/roleUpdate"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/roleResp"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.PATCH("/:id", h.update)
+
+	// swagger:operation DELETE /v1/roles/{id} roles roleDelete
+	// ---
+	// summary: Deletes a custom role
+	// description: Deletes a custom role which is not assigned to any user. Built-in roles cannot be deleted. Available to SuperAdmin users only.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of role
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "409":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.DELETE("/:id", h.delete)
+
+	// swagger:operation PUT /v1/roles/{id}/users/{user_id} roles roleAssign
+	// ---
+	// summary: Assigns role to a user
+	// description: Assigns role to a user other than the requesting one, revoking user's tokens so the user has to log in again. Available to SuperAdmin users only.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of role
+	//   type: int
+	//   required: true
+	// - name: user_id
+	//   in: path
+	//   description: id of user
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "404":
+	//     "$ref": "#/responses/errMsg"
+	//   "409":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	rr.PUT("/:id/users/:user_id", h.assign)
+}
+
+// Role create request
+// swagger:model roleCreate
+type createReq struct {
+	Name        string           `json:"name" validate:"required,min=2"`
+	AccessLevel gorsk.AccessRole `json:"access_level" validate:"required,min=100,max=200"`
+	Permissions []string         `json:"permissions"`
+}
+
+func (h HTTP) create(c echo.Context) error {
+	r := new(createReq)
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	rl, err := h.svc.Create(c, gorsk.Role{
+		Name:        r.Name,
+		AccessLevel: r.AccessLevel,
+		Permissions: r.Permissions,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rl)
+}
+
+type listResponse struct {
+	Roles []gorsk.Role `json:"roles"`
+	Page  int          `json:"page"`
+}
+
+func (h HTTP) list(c echo.Context) error {
+	var req gorsk.PaginationReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	result, err := h.svc.List(c, req.Transform())
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, listResponse{result, req.Page})
+}
+
+func (h HTTP) view(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	result, err := h.svc.View(c, gorsk.AccessRole(id))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// Role update request
+// swagger:model roleUpdate
+type updateReq struct {
+	Name        string   `json:"name,omitempty" validate:"omitempty,min=2"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func (h HTTP) update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	req := new(updateReq)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	rl, err := h.svc.Update(c, role.Update{
+		ID:          gorsk.AccessRole(id),
+		Name:        req.Name,
+		Permissions: req.Permissions,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rl)
+}
+
+func (h HTTP) delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	if err := h.svc.Delete(c, gorsk.AccessRole(id)); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h HTTP) assign(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	if err := h.svc.Assign(c, gorsk.AccessRole(id), userID); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,453 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/role"
+	"github.com/ribice/gorsk/pkg/api/role/transport"
+
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/server"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func enforceRole(err error) *mock.RBAC {
+	return &mock.RBAC{
+		CanFn: func(echo.Context, string, gorsk.Resource) error {
+			return err
+		},
+		UserFn: func(echo.Context) gorsk.AuthUser {
+			return gorsk.AuthUser{ID: 1, Role: gorsk.SuperAdminRole}
+		},
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.Role
+		rdb        *mockdb.Role
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Fail on validation",
+			req:        `{"name":"AUDITOR"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on access level out of range",
+			req:        `{"name":"AUDITOR","access_level":50}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			req:        `{"name":"AUDITOR","access_level":150,"permissions":["users:view"]}`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Fail on unknown permission",
+			req:        `{"name":"AUDITOR","access_level":150,"permissions":["users:destroy"]}`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Success",
+			req:  `{"name":"AUDITOR","access_level":150,"permissions":["users:view","logins:view"]}`,
+			rbac: enforceRole(nil),
+			rdb: &mockdb.Role{
+				CreateFn: func(db orm.DB, r gorsk.Role) (gorsk.Role, error) {
+					r.ID = 1000
+					return r, nil
+				},
+			},
+			wantResp: &gorsk.Role{
+				ID:          1000,
+				AccessLevel: 150,
+				Name:        "AUDITOR",
+				Permissions: []string{gorsk.PermissionUsersView, gorsk.PermissionLoginsView},
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, tt.rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Post(ts.URL+"/roles", "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.Role)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	type listResponse struct {
+		Roles []gorsk.Role `json:"roles"`
+		Page  int          `json:"page"`
+	}
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *listResponse
+		rdb        *mockdb.Role
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `?limit=2222&page=-1`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			req:        `?limit=100&page=1`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			req:  `?limit=100&page=1`,
+			rbac: enforceRole(nil),
+			rdb: &mockdb.Role{
+				ListFn: func(db orm.DB, p gorsk.Pagination) ([]gorsk.Role, error) {
+					if p.Limit == 100 && p.Offset == 100 {
+						return []gorsk.Role{{ID: 1000, AccessLevel: 150, Name: "AUDITOR"}}, nil
+					}
+					return nil, gorsk.ErrGeneric
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: &listResponse{
+				Roles: []gorsk.Role{{ID: 1000, AccessLevel: 150, Name: "AUDITOR"}},
+				Page:  1,
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, tt.rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + "/roles" + tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(listResponse)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.Role
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			req:        `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			req:        `1000`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Role does not exist",
+			req:        `1001`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Success",
+			req:        `1000`,
+			rbac:       enforceRole(nil),
+			wantResp:   &gorsk.Role{ID: 1000, AccessLevel: 150, Name: "AUDITOR"},
+			wantStatus: http.StatusOK,
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			if id != 1000 {
+				return gorsk.Role{}, pg.ErrNoRows
+			}
+			return gorsk.Role{ID: id, AccessLevel: 150, Name: "AUDITOR"}, nil
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + "/roles/" + tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.Role)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		req        string
+		wantStatus int
+		wantResp   *gorsk.Role
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid id",
+			path:       `a`,
+			req:        `{"name":"READER"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on validation",
+			path:       `1000`,
+			req:        `{"name":"R"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			path:       `1000`,
+			req:        `{"name":"READER"}`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Success",
+			path:       `1000`,
+			req:        `{"name":"READER","permissions":["users:view","logins:view"]}`,
+			rbac:       enforceRole(nil),
+			wantResp:   &gorsk.Role{ID: 1000, AccessLevel: 150, Name: "READER", Permissions: []string{gorsk.PermissionUsersView, gorsk.PermissionLoginsView}},
+			wantStatus: http.StatusOK,
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			return gorsk.Role{ID: id, AccessLevel: 150, Name: "AUDITOR", Permissions: []string{gorsk.PermissionUsersView}}, nil
+		},
+		UpdateFn: func(orm.DB, gorsk.Role) error {
+			return nil
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, _ := http.NewRequest("PATCH", ts.URL+"/roles/"+tt.path, bytes.NewBufferString(tt.req))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if tt.wantResp != nil {
+				response := new(gorsk.Role)
+				if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantResp, response)
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		wantStatus int
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid id",
+			path:       `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			path:       `1000`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Fail on built-in role",
+			path:       `200`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Success",
+			path:       `1000`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusOK,
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			return gorsk.Role{ID: id}, nil
+		},
+		InUseFn: func(orm.DB, gorsk.AccessRole) (bool, error) {
+			return false, nil
+		},
+		DeleteFn: func(orm.DB, gorsk.Role) error {
+			return nil
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, _ := http.NewRequest("DELETE", ts.URL+"/roles/"+tt.path, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		wantStatus int
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid role id",
+			path:       `a/users/2`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid user id",
+			path:       `1000/users/a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Fail on RBAC",
+			path:       `1000/users/2`,
+			rbac:       enforceRole(echo.ErrForbidden),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Fail on own role",
+			path:       `1000/users/1`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "User does not exist",
+			path:       `1000/users/3`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Success",
+			path:       `1000/users/2`,
+			rbac:       enforceRole(nil),
+			wantStatus: http.StatusOK,
+		},
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			return gorsk.Role{ID: id, AccessLevel: 150}, nil
+		},
+		AssignFn: func(db orm.DB, userID int, id gorsk.AccessRole) error {
+			if userID != 2 || id != 1000 {
+				return pg.ErrNoRows
+			}
+			return nil
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(role.New(nil, rdb, tt.rbac), rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			req, _ := http.NewRequest("PUT", ts.URL+"/roles/"+tt.path, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
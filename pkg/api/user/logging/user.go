@@ -104,3 +104,47 @@ func (ls *LogService) Update(c echo.Context, req user.Update) (resp gorsk.User,
 	}(time.Now())
 	return ls.Service.Update(c, req)
 }
+
+// Unlock logging
+func (ls *LogService) Unlock(c echo.Context, req int) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Unlock user request", err,
+			map[string]interface{}{
+				"req":  req,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Unlock(c, req)
+}
+
+// Verify logging
+func (ls *LogService) Verify(c echo.Context, token string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Verify email request", err,
+			map[string]interface{}{
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.Verify(c, token)
+}
+
+// ResendVerification logging
+func (ls *LogService) ResendVerification(c echo.Context, email string) (err error) {
+	defer func(begin time.Time) {
+		ls.logger.Log(
+			c,
+			name, "Resend verification request", err,
+			map[string]interface{}{
+				"req":  email,
+				"took": time.Since(begin),
+			},
+		)
+	}(time.Now())
+	return ls.Service.ResendVerification(c, email)
+}
@@ -10,10 +10,13 @@ import (
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
+	onetime "github.com/ribice/gorsk/pkg/utl/onetime/platform/pgsql"
 )
 
-// User represents the client for user table
-type User struct{}
+// User represents the client for user table, and for one-time tokens given out to users
+type User struct {
+	onetime.Token
+}
 
 // Custom errors
 var (
@@ -49,6 +52,12 @@ func (u User) Update(db orm.DB, user gorsk.User) error {
 	return err
 }
 
+// UpdateLoginAttempts updates user's failed login attempts and lockout
+func (u User) UpdateLoginAttempts(db orm.DB, user gorsk.User) error {
+	_, err := db.Model(&user).Column("failed_logins", "locked_until").WherePK().Update()
+	return err
+}
+
 // List returns list of all users retrievable for the current user, depending on role
 func (u User) List(db orm.DB, qp *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.User, error) {
 	var users []gorsk.User
@@ -64,3 +73,16 @@ func (u User) List(db orm.DB, qp *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.
 func (u User) Delete(db orm.DB, user gorsk.User) error {
 	return db.Delete(&user)
 }
+
+// FindByEmail queries for single user by email
+func (u User) FindByEmail(db orm.DB, email string) (gorsk.User, error) {
+	var user gorsk.User
+	err := db.Model(&user).Where("lower(email) = ? and deleted_at is null", strings.ToLower(email)).Select()
+	return user, err
+}
+
+// UpdateVerification updates user's email verification and activation
+func (u User) UpdateVerification(db orm.DB, user gorsk.User) error {
+	_, err := db.Model(&user).Column("email_verified_at", "active").WherePK().Update()
+	return err
+}
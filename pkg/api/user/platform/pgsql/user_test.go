@@ -3,7 +3,9 @@ package pgsql_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ribice/gorsk"
@@ -262,6 +264,38 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateLoginAttempts(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	locked := gorsk.User{
+		Base:         gorsk.Base{ID: 1},
+		Username:     "johndoe",
+		FirstName:    "John",
+		RoleID:       1,
+		FailedLogins: 5,
+		LockedUntil:  mock.TestTime(2100),
+	}
+	if err := mock.InsertMultiple(db, &gorsk.Role{
+		ID:          1,
+		AccessLevel: 1,
+		Name:        "SUPER_ADMIN"}, &locked); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	assert.Nil(t, udb.UpdateLoginAttempts(db, gorsk.User{Base: gorsk.Base{ID: 1}}))
+
+	user, err := udb.View(db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, user.FailedLogins)
+	assert.True(t, user.LockedUntil.IsZero())
+	assert.Equal(t, "John", user.FirstName)
+}
+
 func TestList(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -323,7 +357,6 @@ func TestList(t *testing.T) {
 						AccessLevel: 1,
 						Name:        "SUPER_ADMIN",
 					},
-					Token: "loginrefresh",
 				},
 			},
 		},
@@ -413,3 +446,53 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestFindByEmail(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Email: "johndoe@mail.com", Username: "johndoe", RoleID: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 2, DeletedAt: time.Now()}, Email: "deleted@mail.com", Username: "deleted", RoleID: 1},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	user, err := udb.FindByEmail(db, "JohnDoe@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, user.ID)
+
+	_, err = udb.FindByEmail(db, "deleted@mail.com")
+	assert.Equal(t, pg.ErrNoRows, err)
+}
+
+func TestUpdateVerification(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{}, &gorsk.User{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.Role{ID: 1, AccessLevel: 1, Name: "SUPER_ADMIN"},
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", FirstName: "John", RoleID: 1},
+	); err != nil {
+		t.Error(err)
+	}
+
+	udb := pgsql.User{}
+
+	verified := gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "Changed"}
+	verified.VerifyEmail()
+	assert.Nil(t, udb.UpdateVerification(db, verified))
+
+	user, err := udb.View(db, 1)
+	assert.Nil(t, err)
+	assert.True(t, user.Active)
+	assert.True(t, user.EmailVerified())
+	assert.Equal(t, "John", user.FirstName)
+}
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"time"
+
 	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
@@ -16,16 +18,19 @@ type Service interface {
 	View(echo.Context, int) (gorsk.User, error)
 	Delete(echo.Context, int) error
 	Update(echo.Context, Update) (gorsk.User, error)
+	Unlock(echo.Context, int) error
+	Verify(echo.Context, string) error
+	ResendVerification(echo.Context, string) error
 }
 
 // New creates new user application service
-func New(db *pg.DB, udb UDB, rbac RBAC, sec Securer) *User {
-	return &User{db: db, udb: udb, rbac: rbac, sec: sec}
+func New(db *pg.DB, udb UDB, rbac RBAC, sec Securer, mail gorsk.Mailer, cfg Config) *User {
+	return &User{db: db, udb: udb, rbac: rbac, sec: sec, mail: mail, cfg: cfg}
 }
 
 // Initialize initalizes User application service with defaults
-func Initialize(db *pg.DB, rbac RBAC, sec Securer) *User {
-	return New(db, pgsql.User{}, rbac, sec)
+func Initialize(db *pg.DB, rbac RBAC, sec Securer, mail gorsk.Mailer, cfg Config) *User {
+	return New(db, pgsql.User{}, rbac, sec, mail, cfg)
 }
 
 // User represents user application service
@@ -34,11 +39,23 @@ type User struct {
 	udb  UDB
 	rbac RBAC
 	sec  Securer
+	mail gorsk.Mailer
+	cfg  Config
+}
+
+// Config represents user application configuration
+type Config struct {
+	// URL of the email verification endpoint, sent to user with verification token appended to its path
+	VerifyURL string
+	// Duration for which the verification token is valid, defaults to 24 hours
+	VerifyDuration time.Duration
 }
 
 // Securer represents security interface
 type Securer interface {
 	Hash(string) string
+	Token() (string, error)
+	HashToken(string) string
 }
 
 // UDB represents user repository interface
@@ -47,13 +64,20 @@ type UDB interface {
 	View(orm.DB, int) (gorsk.User, error)
 	List(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.User, error)
 	Update(orm.DB, gorsk.User) error
+	UpdateLoginAttempts(orm.DB, gorsk.User) error
 	Delete(orm.DB, gorsk.User) error
+	FindByEmail(orm.DB, string) (gorsk.User, error)
+	UpdateVerification(orm.DB, gorsk.User) error
+	CreateToken(orm.DB, gorsk.OneTimeToken) error
+	FindToken(orm.DB, string, string) (gorsk.OneTimeToken, error)
+	DeleteUserTokens(orm.DB, string, int) error
 }
 
 // RBAC represents role-based-access-control interface
 type RBAC interface {
 	User(echo.Context) gorsk.AuthUser
-	EnforceUser(echo.Context, int) error
+	Can(echo.Context, string, gorsk.Resource) error
 	AccountCreate(echo.Context, gorsk.AccessRole, int, int) error
 	IsLowerRole(echo.Context, gorsk.AccessRole) error
+	Scope(echo.Context, string) (*gorsk.Resource, error)
 }
@@ -16,8 +16,48 @@ type HTTP struct {
 }
 
 // NewHTTP creates new user http service
-func NewHTTP(svc user.Service, r *echo.Group) {
+func NewHTTP(svc user.Service, e *echo.Echo, r *echo.Group) {
 	h := HTTP{svc}
+
+	// swagger:operation GET /verify/{token} users userVerify
+	// ---
+	// summary: Verifies user's email
+	// description: Marks user's email as verified using the token sent to it, and activates the account.
+	// parameters:
+	// - name: token
+	//   in: path
+	//   description: verification token
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.GET("/verify/:token", h.verify)
+
+	// swagger:operation POST /verify/resend users userResendVerification
+	// ---
+	// summary: Resends verification email
+	// description: Sends new verification token to the email of unverified user. The response is the same whether the email belongs to such user or not.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/userResendVerification"
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/errMsg"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	e.POST("/verify/resend", h.resendVerification)
+
 	ur := r.Group("/users")
 	// swagger:route POST /v1/users users userCreate
 	// Creates new user account.
@@ -133,6 +173,29 @@ func NewHTTP(svc user.Service, r *echo.Group) {
 	//   "500":
 	//     "$ref": "#/responses/err"
 	ur.DELETE("/:id", h.delete)
+
+	// swagger:operation POST /v1/users/{id}/unlock users userUnlock
+	// ---
+	// summary: Unlocks a user
+	// description: Lifts the lockout of a user locked out after too many failed logins.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: id of user
+	//   type: int
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ok"
+	//   "400":
+	//     "$ref": "#/responses/err"
+	//   "401":
+	//     "$ref": "#/responses/err"
+	//   "403":
+	//     "$ref": "#/responses/err"
+	//   "500":
+	//     "$ref": "#/responses/err"
+	ur.POST("/:id/unlock", h.unlock)
 }
 
 // Custom errors
@@ -167,10 +230,6 @@ func (h HTTP) create(c echo.Context) error {
 		return ErrPasswordsNotMaching
 	}
 
-	if r.RoleID < gorsk.SuperAdminRole || r.RoleID > gorsk.UserRole {
-		return gorsk.ErrBadRequest
-	}
-
 	usr, err := h.svc.Create(c, gorsk.User{
 		Username:   r.Username,
 		Password:   r.Password,
@@ -273,3 +332,43 @@ func (h HTTP) delete(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+func (h HTTP) unlock(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return gorsk.ErrBadRequest
+	}
+
+	if err := h.svc.Unlock(c, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h HTTP) verify(c echo.Context) error {
+	if err := h.svc.Verify(c, c.Param("token")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// Verification resend request
+// swagger:model userResendVerification
+type resendVerificationReq struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (h HTTP) resendVerification(c echo.Context) error {
+	r := new(resendVerificationReq)
+	if err := c.Bind(r); err != nil {
+		return err
+	}
+
+	if err := h.svc.ResendVerification(c, r.Email); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -6,15 +6,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/user"
 	"github.com/ribice/gorsk/pkg/api/user/transport"
 
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
 	"github.com/ribice/gorsk/pkg/utl/server"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
@@ -41,11 +45,14 @@ func TestCreate(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 		},
 		{
-			name: "Fail on invalid role",
+			name: "Fail on unknown role",
 			req:  `{"first_name":"John","last_name":"Doe","username":"juzernejm","password":"hunter123","password_confirm":"hunter123","email":"johndoe@gmail.com","company_id":1,"location_id":2,"role_id":50}`,
 			rbac: &mock.RBAC{
 				AccountCreateFn: func(c echo.Context, roleID gorsk.AccessRole, companyID, locationID int) error {
-					return echo.ErrForbidden
+					if roleID != 50 {
+						return echo.ErrForbidden
+					}
+					return rbac.ErrUnknownRole
 				},
 			},
 			wantStatus: http.StatusBadRequest,
@@ -76,11 +83,20 @@ func TestCreate(t *testing.T) {
 					usr.UpdatedAt = mock.TestTime(2018)
 					return usr, nil
 				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
 			},
 			sec: &mock.Secure{
 				HashFn: func(string) string {
 					return "h4$h3d"
 				},
+				TokenFn: func() (string, error) {
+					return "verifytoken", nil
+				},
+				HashTokenFn: func(s string) string {
+					return "hashed" + s
+				},
 			},
 			wantResp: &gorsk.User{
 				Base: gorsk.Base{
@@ -103,7 +119,7 @@ func TestCreate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/users"
@@ -144,17 +160,11 @@ func TestList(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 		},
 		{
-			name: "Fail on query list",
+			name: "Fail on Scope",
 			req:  `?limit=100&page=1`,
 			rbac: &mock.RBAC{
-				UserFn: func(c echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{
-						ID:         1,
-						CompanyID:  2,
-						LocationID: 3,
-						Role:       gorsk.UserRole,
-						Email:      "john@mail.com",
-					}
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, echo.ErrForbidden
 				}},
 			wantStatus: http.StatusForbidden,
 		},
@@ -162,14 +172,8 @@ func TestList(t *testing.T) {
 			name: "Success",
 			req:  `?limit=100&page=1`,
 			rbac: &mock.RBAC{
-				UserFn: func(c echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{
-						ID:         1,
-						CompanyID:  2,
-						LocationID: 3,
-						Role:       gorsk.SuperAdminRole,
-						Email:      "john@mail.com",
-					}
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, nil
 				}},
 			udb: &mockdb.User{
 				ListFn: func(db orm.DB, q *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.User, error) {
@@ -259,7 +263,7 @@ func TestList(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/users" + tt.req
@@ -299,7 +303,7 @@ func TestView(t *testing.T) {
 			name: "Fail on RBAC",
 			req:  `1`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(echo.Context, int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return echo.ErrForbidden
 				},
 			},
@@ -309,7 +313,7 @@ func TestView(t *testing.T) {
 			name: "Success",
 			req:  `1`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(echo.Context, int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				},
 			},
@@ -345,7 +349,7 @@ func TestView(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/users/" + tt.req
@@ -393,7 +397,7 @@ func TestUpdate(t *testing.T) {
 			id:   `1`,
 			req:  `{"first_name":"jj","last_name":"okocha","mobile":"123456","phone":"321321","address":"home"}`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(echo.Context, int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return echo.ErrForbidden
 				},
 			},
@@ -404,9 +408,12 @@ func TestUpdate(t *testing.T) {
 			id:   `1`,
 			req:  `{"first_name":"jj","last_name":"okocha","phone":"321321","address":"home"}`,
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(echo.Context, int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				},
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1}
+				},
 			},
 			udb: &mockdb.User{
 				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
@@ -453,7 +460,7 @@ func TestUpdate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/users/" + tt.id
@@ -503,6 +510,9 @@ func TestDelete(t *testing.T) {
 				},
 			},
 			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
 				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
 					return echo.ErrForbidden
 				},
@@ -525,6 +535,9 @@ func TestDelete(t *testing.T) {
 				},
 			},
 			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
 				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
 					return nil
 				},
@@ -539,7 +552,7 @@ func TestDelete(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := server.New()
 			rg := r.Group("")
-			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec), rg)
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{}), r, rg)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 			path := ts.URL + "/users/" + tt.id
@@ -553,3 +566,206 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestUnlock(t *testing.T) {
+	cases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		udb        *mockdb.User
+		rbac       *mock.RBAC
+	}{
+		{
+			name:       "Invalid request",
+			id:         `a`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Fail on RBAC",
+			id:   `1`,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{
+						Role: &gorsk.Role{
+							AccessLevel: gorsk.CompanyAdminRole,
+						},
+					}, nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
+					return echo.ErrForbidden
+				},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Success",
+			id:   `1`,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{
+						Role: &gorsk.Role{
+							AccessLevel: gorsk.UserRole,
+						},
+					}, nil
+				},
+				UpdateLoginAttemptsFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
+					return nil
+				},
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	client := http.Client{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			rg := r.Group("")
+			transport.NewHTTP(user.New(nil, tt.udb, tt.rbac, nil, new(mail.Memory), user.Config{}), r, rg)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			path := ts.URL + "/users/" + tt.id + "/unlock"
+			req, _ := http.NewRequest("POST", path, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	cases := []struct {
+		name       string
+		token      string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Invalid token",
+			token:      "invalid",
+			wantStatus: http.StatusBadRequest,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Success",
+			token:      "verifytoken",
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+				},
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, nil
+				},
+				UpdateVerificationFn: func(orm.DB, gorsk.User) error {
+					return nil
+				},
+				DeleteUserTokensFn: func(orm.DB, string, int) error {
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(user.New(nil, tt.udb, nil, sec, nil, user.Config{}), r, r.Group("/v1"))
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Get(ts.URL + "/verify/" + tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        string
+		wantStatus int
+		udb        *mockdb.User
+	}{
+		{
+			name:       "Fail on Bind",
+			req:        `{"email":"notanemail"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown email",
+			req:        `{"email":"nobody@mail.com"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:       "Success",
+			req:        `{"email":"johndoe@mail.com"}`,
+			wantStatus: http.StatusOK,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email}, nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "verifytoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed" + s
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := server.New()
+			transport.NewHTTP(user.New(nil, tt.udb, nil, sec, new(mail.Memory), user.Config{}), r, r.Group("/v1"))
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			res, err := http.Post(ts.URL+"/verify/resend", "application/json", bytes.NewBufferString(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
@@ -8,28 +8,35 @@ import (
 	"github.com/ribice/gorsk/pkg/utl/query"
 )
 
-// Create creates a new user account
+// Create creates a new user account and sends verification token to its email.
+// Failing to send the token is only logged, as the account is created already and the token can be sent again.
 func (u User) Create(c echo.Context, req gorsk.User) (gorsk.User, error) {
 	if err := u.rbac.AccountCreate(c, req.RoleID, req.CompanyID, req.LocationID); err != nil {
 		return gorsk.User{}, err
 	}
 	req.Password = u.sec.Hash(req.Password)
-	return u.udb.Create(u.db, req)
+	usr, err := u.udb.Create(u.db, req)
+	if err != nil {
+		return gorsk.User{}, err
+	}
+	if err := u.sendVerification(usr); err != nil {
+		c.Logger().Errorf("sending verification to user %d: %v", usr.ID, err)
+	}
+	return usr, nil
 }
 
 // List returns list of users
 func (u User) List(c echo.Context, p gorsk.Pagination) ([]gorsk.User, error) {
-	au := u.rbac.User(c)
-	q, err := query.List(au)
+	sc, err := u.rbac.Scope(c, gorsk.PermissionUsersView)
 	if err != nil {
 		return nil, err
 	}
-	return u.udb.List(u.db, q, p)
+	return u.udb.List(u.db, query.List(sc), p)
 }
 
 // View returns single user
 func (u User) View(c echo.Context, id int) (gorsk.User, error) {
-	if err := u.rbac.EnforceUser(c, id); err != nil {
+	if err := u.rbac.Can(c, gorsk.PermissionUsersView, gorsk.Resource{UserID: id}); err != nil {
 		return gorsk.User{}, err
 	}
 	return u.udb.View(u.db, id)
@@ -41,7 +48,7 @@ func (u User) Delete(c echo.Context, id int) error {
 	if err != nil {
 		return err
 	}
-	if err := u.rbac.IsLowerRole(c, user.Role.AccessLevel); err != nil {
+	if err := u.authorize(c, gorsk.PermissionUsersDelete, user); err != nil {
 		return err
 	}
 	return u.udb.Delete(u.db, user)
@@ -57,12 +64,22 @@ type Update struct {
 	Address   string
 }
 
-// Update updates user's contact information
+// Update updates user's contact information. Users other than the requesting one must have lower role than the requesting user.
 func (u User) Update(c echo.Context, r Update) (gorsk.User, error) {
-	if err := u.rbac.EnforceUser(c, r.ID); err != nil {
+	if err := u.rbac.Can(c, gorsk.PermissionUsersUpdate, gorsk.Resource{UserID: r.ID}); err != nil {
 		return gorsk.User{}, err
 	}
 
+	if r.ID != u.rbac.User(c).ID {
+		user, err := u.udb.View(u.db, r.ID)
+		if err != nil {
+			return gorsk.User{}, err
+		}
+		if err := u.rbac.IsLowerRole(c, user.RoleID); err != nil {
+			return gorsk.User{}, err
+		}
+	}
+
 	if err := u.udb.Update(u.db, gorsk.User{
 		Base:      gorsk.Base{ID: r.ID},
 		FirstName: r.FirstName,
@@ -75,3 +92,24 @@ func (u User) Update(c echo.Context, r Update) (gorsk.User, error) {
 
 	return u.udb.View(u.db, r.ID)
 }
+
+// Unlock lifts the lockout of a user locked out after too many failed logins
+func (u User) Unlock(c echo.Context, id int) error {
+	user, err := u.udb.View(u.db, id)
+	if err != nil {
+		return err
+	}
+	if err := u.authorize(c, gorsk.PermissionUsersUnlock, user); err != nil {
+		return err
+	}
+	user.Unlock()
+	return u.udb.UpdateLoginAttempts(u.db, user)
+}
+
+// authorize checks the permission to manage another user, which must have lower role than the requesting user
+func (u User) authorize(c echo.Context, permission string, user gorsk.User) error {
+	if err := u.rbac.Can(c, permission, gorsk.Resource{CompanyID: user.CompanyID, LocationID: user.LocationID}); err != nil {
+		return err
+	}
+	return u.rbac.IsLowerRole(c, user.RoleID)
+}
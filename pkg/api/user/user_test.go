@@ -8,6 +8,7 @@ import (
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/api/user"
+	"github.com/ribice/gorsk/pkg/utl/mail"
 	"github.com/ribice/gorsk/pkg/utl/mock"
 	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
 
@@ -50,6 +51,7 @@ func TestCreate(t *testing.T) {
 				Username:  "JohnDoe",
 				RoleID:    1,
 				Password:  "Thranduil8822",
+				Email:     "johndoe@mail.com",
 			}},
 			udb: &mockdb.User{
 				CreateFn: func(db orm.DB, u gorsk.User) (gorsk.User, error) {
@@ -58,6 +60,12 @@ func TestCreate(t *testing.T) {
 					u.Base.ID = 1
 					return u, nil
 				},
+				CreateTokenFn: func(db orm.DB, v gorsk.OneTimeToken) error {
+					if v.UserID != 1 || v.Purpose != gorsk.TokenEmailVerification || v.Token != "hashed:verifytoken" {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
 			},
 			rbac: &mock.RBAC{
 				AccountCreateFn: func(echo.Context, gorsk.AccessRole, int, int) error {
@@ -67,6 +75,12 @@ func TestCreate(t *testing.T) {
 				HashFn: func(string) string {
 					return "h4$h3d"
 				},
+				TokenFn: func() (string, error) {
+					return "verifytoken", nil
+				},
+				HashTokenFn: func(s string) string {
+					return "hashed:" + s
+				},
 			},
 			wantData: gorsk.User{
 				Base: gorsk.Base{
@@ -79,10 +93,11 @@ func TestCreate(t *testing.T) {
 				Username:  "JohnDoe",
 				RoleID:    1,
 				Password:  "h4$h3d",
+				Email:     "johndoe@mail.com",
 			}}}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := user.New(nil, tt.udb, tt.rbac, tt.sec)
+			s := user.New(nil, tt.udb, tt.rbac, tt.sec, new(mail.Memory), user.Config{})
 			usr, err := s.Create(tt.args.c, tt.args.req)
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.wantData, usr)
@@ -90,6 +105,42 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateVerificationNotSent(t *testing.T) {
+	udb := &mockdb.User{
+		CreateFn: func(db orm.DB, u gorsk.User) (gorsk.User, error) {
+			u.Base.ID = 1
+			return u, nil
+		},
+		CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+			return nil
+		},
+	}
+	rbac := &mock.RBAC{
+		AccountCreateFn: func(echo.Context, gorsk.AccessRole, int, int) error {
+			return nil
+		}}
+	sec := &mock.Secure{
+		HashFn: func(string) string {
+			return "h4$h3d"
+		},
+		TokenFn: func() (string, error) {
+			return "verifytoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed:" + s
+		},
+	}
+	mailer := &mock.Mailer{
+		SendFn: func(string, string, string) error {
+			return gorsk.ErrGeneric
+		},
+	}
+	s := user.New(nil, udb, rbac, sec, mailer, user.Config{})
+	usr, err := s.Create(mock.EchoCtxWithKeys(nil), gorsk.User{Username: "JohnDoe", Email: "johndoe@mail.com", Password: "Thranduil8822"})
+	assert.Nil(t, err)
+	assert.Equal(t, gorsk.User{Base: gorsk.Base{ID: 1}, Username: "JohnDoe", Email: "johndoe@mail.com", Password: "h4$h3d"}, usr)
+}
+
 func TestView(t *testing.T) {
 	type args struct {
 		c  echo.Context
@@ -107,7 +158,7 @@ func TestView(t *testing.T) {
 			name: "Fail on RBAC",
 			args: args{id: 5},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return gorsk.ErrGeneric
 				}},
 			wantErr: gorsk.ErrGeneric,
@@ -126,7 +177,7 @@ func TestView(t *testing.T) {
 				Username:  "JohnDoe",
 			},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
 				}},
 			udb: &mockdb.User{
@@ -149,7 +200,7 @@ func TestView(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := user.New(nil, tt.udb, tt.rbac, nil)
+			s := user.New(nil, tt.udb, tt.rbac, nil, nil, user.Config{})
 			usr, err := s.View(tt.args.c, tt.args.id)
 			assert.Equal(t, tt.wantData, usr)
 			assert.Equal(t, tt.wantErr, err)
@@ -171,20 +222,15 @@ func TestList(t *testing.T) {
 		rbac     *mock.RBAC
 	}{
 		{
-			name: "Fail on query List",
+			name: "Fail on Scope",
 			args: args{c: nil, pgn: gorsk.Pagination{
 				Limit:  100,
 				Offset: 200,
 			}},
 			wantErr: true,
 			rbac: &mock.RBAC{
-				UserFn: func(c echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{
-						ID:         1,
-						CompanyID:  2,
-						LocationID: 3,
-						Role:       gorsk.UserRole,
-					}
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, gorsk.ErrGeneric
 				}}},
 		{
 			name: "Success",
@@ -193,13 +239,8 @@ func TestList(t *testing.T) {
 				Offset: 200,
 			}},
 			rbac: &mock.RBAC{
-				UserFn: func(c echo.Context) gorsk.AuthUser {
-					return gorsk.AuthUser{
-						ID:         1,
-						CompanyID:  2,
-						LocationID: 3,
-						Role:       gorsk.AdminRole,
-					}
+				ScopeFn: func(echo.Context, string) (*gorsk.Resource, error) {
+					return nil, nil
 				}},
 			udb: &mockdb.User{
 				ListFn: func(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.User, error) {
@@ -255,7 +296,7 @@ func TestList(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := user.New(nil, tt.udb, tt.rbac, nil)
+			s := user.New(nil, tt.udb, tt.rbac, nil, nil, user.Config{})
 			usrs, err := s.List(tt.args.c, tt.args.pgn)
 			assert.Equal(t, tt.wantData, usrs)
 			assert.Equal(t, tt.wantErr, err != nil)
@@ -289,6 +330,29 @@ func TestDelete(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Fail on permission",
+			args: args{id: 1},
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{
+						Base:       gorsk.Base{ID: id},
+						CompanyID:  2,
+						LocationID: 3,
+						Role:       &gorsk.Role{AccessLevel: gorsk.UserRole},
+					}, nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(c echo.Context, permission string, r gorsk.Resource) error {
+					if permission == gorsk.PermissionUsersDelete && r == (gorsk.Resource{CompanyID: 2, LocationID: 3}) {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			wantErr: gorsk.ErrGeneric,
+		},
 		{
 			name: "Fail on RBAC",
 			args: args{id: 1},
@@ -309,6 +373,9 @@ func TestDelete(t *testing.T) {
 				},
 			},
 			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
 				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
 					return gorsk.ErrGeneric
 				}},
@@ -339,6 +406,9 @@ func TestDelete(t *testing.T) {
 				},
 			},
 			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
 				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
 					return nil
 				}},
@@ -346,7 +416,7 @@ func TestDelete(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := user.New(nil, tt.udb, tt.rbac, nil)
+			s := user.New(nil, tt.udb, tt.rbac, nil, nil, user.Config{})
 			err := s.Delete(tt.args.c, tt.args.id)
 			if err != tt.wantErr {
 				t.Errorf("Expected error %v, received %v", tt.wantErr, err)
@@ -374,10 +444,55 @@ func TestUpdate(t *testing.T) {
 				ID: 1,
 			}},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return gorsk.ErrGeneric
+				}},
+			wantErr: gorsk.ErrGeneric,
+		},
+		{
+			name: "Fail on ViewUser",
+			args: args{upd: user.Update{
+				ID: 1,
+			}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 2}
+				}},
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Fail on updating user of higher role",
+			args: args{upd: user.Update{
+				ID:        1,
+				FirstName: "John",
+			}},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 2, CompanyID: 1, LocationID: 2, Role: gorsk.CompanyAdminRole}
+				},
+				IsLowerRoleFn: func(c echo.Context, role gorsk.AccessRole) error {
+					if role != gorsk.SuperAdminRole {
+						return nil
+					}
 					return gorsk.ErrGeneric
 				}},
 			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, CompanyID: 1, LocationID: 2, RoleID: gorsk.SuperAdminRole}, nil
+				},
+			},
 		},
 		{
 			name: "Fail on Update",
@@ -385,8 +500,11 @@ func TestUpdate(t *testing.T) {
 				ID: 1,
 			}},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
+				},
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1}
 				}},
 			wantErr: gorsk.ErrGeneric,
 			udb: &mockdb.User{
@@ -423,8 +541,11 @@ func TestUpdate(t *testing.T) {
 				Phone:     "234567",
 			}},
 			rbac: &mock.RBAC{
-				EnforceUserFn: func(c echo.Context, id int) error {
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
 					return nil
+				},
+				UserFn: func(echo.Context) gorsk.AuthUser {
+					return gorsk.AuthUser{ID: 1}
 				}},
 			wantData: gorsk.User{
 				Base: gorsk.Base{
@@ -470,7 +591,7 @@ func TestUpdate(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := user.New(nil, tt.udb, tt.rbac, nil)
+			s := user.New(nil, tt.udb, tt.rbac, nil, nil, user.Config{})
 			usr, err := s.Update(tt.args.c, tt.args.upd)
 			assert.Equal(t, tt.wantData, usr)
 			assert.Equal(t, tt.wantErr, err)
@@ -478,8 +599,83 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUnlock(t *testing.T) {
+	cases := []struct {
+		name    string
+		id      int
+		wantErr error
+		udb     *mockdb.User
+		rbac    *mock.RBAC
+	}{
+		{
+			name:    "Fail on ViewUser",
+			id:      1,
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:    "Fail on RBAC",
+			id:      1,
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{
+						Base: gorsk.Base{ID: id},
+						Role: &gorsk.Role{AccessLevel: gorsk.AdminRole},
+					}, nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
+					return gorsk.ErrGeneric
+				}},
+		},
+		{
+			name: "Success",
+			id:   1,
+			udb: &mockdb.User{
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{
+						Base:         gorsk.Base{ID: id},
+						Role:         &gorsk.Role{AccessLevel: gorsk.UserRole},
+						FailedLogins: 5,
+						LockedUntil:  mock.TestTime(2100),
+					}, nil
+				},
+				UpdateLoginAttemptsFn: func(db orm.DB, usr gorsk.User) error {
+					if usr.ID != 1 || usr.FailedLogins != 0 || !usr.LockedUntil.IsZero() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+			rbac: &mock.RBAC{
+				CanFn: func(echo.Context, string, gorsk.Resource) error {
+					return nil
+				},
+				IsLowerRoleFn: func(echo.Context, gorsk.AccessRole) error {
+					return nil
+				}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := user.New(nil, tt.udb, tt.rbac, nil, nil, user.Config{})
+			err := s.Unlock(nil, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
 func TestInitialize(t *testing.T) {
-	u := user.Initialize(nil, nil, nil)
+	u := user.Initialize(nil, nil, nil, nil, user.Config{})
 	if u == nil {
 		t.Error("User service not initialized")
 	}
@@ -0,0 +1,117 @@
+package user
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Duration for which the verification token is valid if not configured
+const defaultVerifyDuration = 24 * time.Hour
+
+// Custom errors
+var (
+	ErrInvalidVerificationToken = echo.NewHTTPError(http.StatusBadRequest, "Verification token is invalid or has expired")
+)
+
+// Verify marks the email of the user the verification token was sent to as verified, activating the account
+func (u User) Verify(c echo.Context, token string) error {
+	v, err := u.udb.FindToken(u.db, gorsk.TokenEmailVerification, u.sec.HashToken(token))
+	if err == pg.ErrNoRows {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if v.Expired() {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := u.udb.View(u.db, v.UserID)
+	if err == pg.ErrNoRows {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user.VerifyEmail()
+	if err := u.udb.UpdateVerification(u.db, user); err != nil {
+		return err
+	}
+
+	return u.udb.DeleteUserTokens(u.db, gorsk.TokenEmailVerification, user.ID)
+}
+
+// ResendVerification sends new verification token to the user with given email.
+// To avoid revealing which emails are registered, no error is returned if there is no such unverified user.
+func (u User) ResendVerification(c echo.Context, email string) error {
+	user, err := u.udb.FindByEmail(u.db, email)
+	if err == pg.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerified() {
+		return nil
+	}
+
+	return u.sendVerification(user)
+}
+
+// sendVerification stores new verification token of the user and sends it to user's email
+func (u User) sendVerification(user gorsk.User) error {
+	token, err := u.sec.Token()
+	if err != nil {
+		return err
+	}
+	duration := u.verifyDuration()
+	if err := u.udb.CreateToken(u.db, gorsk.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   gorsk.TokenEmailVerification,
+		Token:     u.sec.HashToken(token),
+		ExpiresAt: time.Now().Add(duration),
+	}); err != nil {
+		return err
+	}
+
+	return u.mail.Send(user.Email, "Verify your email", fmt.Sprintf(verificationMail, user.FirstName, u.verifyLink(token), validity(duration)))
+}
+
+const verificationMail = `Hi %s,
+
+an account was created for you. To verify your email and activate the account, follow the link below:
+
+%s
+
+The link is valid for %s. If it has expired, you can request a new one.
+`
+
+func (u User) verifyLink(token string) string {
+	return strings.TrimSuffix(u.cfg.VerifyURL, "/") + "/" + url.PathEscape(token)
+}
+
+func (u User) verifyDuration() time.Duration {
+	if u.cfg.VerifyDuration > 0 {
+		return u.cfg.VerifyDuration
+	}
+	return defaultVerifyDuration
+}
+
+// validity formats duration for which the link is valid in whole hours, or minutes if shorter than an hour
+func validity(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d hours", int(d.Hours()))
+}
@@ -0,0 +1,204 @@
+package user_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/api/user"
+	"github.com/ribice/gorsk/pkg/utl/mail"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	verification := func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+		return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+	cases := []struct {
+		name    string
+		wantErr error
+		udb     *mockdb.User
+	}{
+		{
+			name:    "Unknown token",
+			wantErr: user.ErrInvalidVerificationToken,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Expired token",
+			wantErr: user.ErrInvalidVerificationToken,
+			udb: &mockdb.User{
+				FindTokenFn: func(orm.DB, string, string) (gorsk.OneTimeToken, error) {
+					return gorsk.OneTimeToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(-time.Hour)}, nil
+				},
+			},
+		},
+		{
+			name:    "Deleted user",
+			wantErr: user.ErrInvalidVerificationToken,
+			udb: &mockdb.User{
+				FindTokenFn: verification,
+				ViewFn: func(orm.DB, int) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on UpdateVerification",
+			wantErr: gorsk.ErrGeneric,
+			udb: &mockdb.User{
+				FindTokenFn: verification,
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}}, nil
+				},
+				UpdateVerificationFn: func(orm.DB, gorsk.User) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Success",
+			udb: &mockdb.User{
+				FindTokenFn: func(db orm.DB, purpose, token string) (gorsk.OneTimeToken, error) {
+					if purpose != gorsk.TokenEmailVerification || token != "hashed:verifytoken" {
+						return gorsk.OneTimeToken{}, pg.ErrNoRows
+					}
+					return verification(db, purpose, token)
+				},
+				ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: id}}, nil
+				},
+				UpdateVerificationFn: func(db orm.DB, u gorsk.User) error {
+					if u.ID != 1 || !u.Active || !u.EmailVerified() {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+				DeleteUserTokensFn: func(db orm.DB, purpose string, userID int) error {
+					if purpose != gorsk.TokenEmailVerification || userID != 1 {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		HashTokenFn: func(s string) string {
+			return "hashed:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := user.New(nil, tt.udb, nil, sec, nil, user.Config{})
+			err := s.Verify(nil, "verifytoken")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	cases := []struct {
+		name     string
+		wantErr  bool
+		wantMail bool
+		udb      *mockdb.User
+	}{
+		{
+			name: "Unknown email",
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, pg.ErrNoRows
+				},
+			},
+		},
+		{
+			name:    "Fail on FindByEmail",
+			wantErr: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(orm.DB, string) (gorsk.User, error) {
+					return gorsk.User{}, gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name: "Already verified",
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email, EmailVerifiedAt: mock.TestTime(2019)}, nil
+				},
+			},
+		},
+		{
+			name:    "Fail on CreateVerification",
+			wantErr: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Email: email}, nil
+				},
+				CreateTokenFn: func(orm.DB, gorsk.OneTimeToken) error {
+					return gorsk.ErrGeneric
+				},
+			},
+		},
+		{
+			name:     "Success",
+			wantMail: true,
+			udb: &mockdb.User{
+				FindByEmailFn: func(db orm.DB, email string) (gorsk.User, error) {
+					return gorsk.User{Base: gorsk.Base{ID: 1}, FirstName: "John", Email: email}, nil
+				},
+				CreateTokenFn: func(db orm.DB, v gorsk.OneTimeToken) error {
+					if v.UserID != 1 || v.Purpose != gorsk.TokenEmailVerification || v.Token != "hashed:verifytoken" {
+						return gorsk.ErrGeneric
+					}
+					if d := time.Until(v.ExpiresAt); d < 47*time.Hour || d > 48*time.Hour {
+						return gorsk.ErrGeneric
+					}
+					return nil
+				},
+			},
+		},
+	}
+	sec := &mock.Secure{
+		TokenFn: func() (string, error) {
+			return "verifytoken", nil
+		},
+		HashTokenFn: func(s string) string {
+			return "hashed:" + s
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := new(mail.Memory)
+			s := user.New(nil, tt.udb, nil, sec, mailer, user.Config{
+				VerifyURL:      "https://api.gorsk.com/verify/",
+				VerifyDuration: 48 * time.Hour,
+			})
+			err := s.ResendVerification(nil, "johndoe@mail.com")
+			assert.Equal(t, tt.wantErr, err != nil)
+			msgs := mailer.Messages()
+			if !tt.wantMail {
+				assert.Empty(t, msgs)
+				return
+			}
+			if assert.Len(t, msgs, 1) {
+				assert.Equal(t, "johndoe@mail.com", msgs[0].To)
+				assert.True(t, strings.HasPrefix(msgs[0].Body, "Hi John,"))
+				assert.Contains(t, msgs[0].Body, "https://api.gorsk.com/verify/verifytoken\n")
+				assert.Contains(t, msgs[0].Body, "valid for 48 hours")
+			}
+		})
+	}
+}
@@ -26,6 +26,9 @@ type Configuration struct {
 	DB     *Database    `yaml:"database,omitempty"`
 	JWT    *JWT         `yaml:"jwt,omitempty"`
 	App    *Application `yaml:"application,omitempty"`
+	Mail   *Mail        `yaml:"mail,omitempty"`
+	Cookie *Cookie      `yaml:"cookie,omitempty"`
+	OIDC   *OIDC        `yaml:"oidc,omitempty"`
 }
 
 // Database holds data necessary for database configuration
@@ -44,15 +47,74 @@ type Server struct {
 
 // JWT holds data necessary for JWT configuration
 type JWT struct {
-	MinSecretLength  int    `yaml:"min_secret_length,omitempty"`
-	DurationMinutes  int    `yaml:"duration_minutes,omitempty"`
-	RefreshDuration  int    `yaml:"refresh_duration_minutes,omitempty"`
-	MaxRefresh       int    `yaml:"max_refresh_minutes,omitempty"`
+	MinSecretLength  int      `yaml:"min_secret_length,omitempty"`
+	DurationMinutes  int      `yaml:"duration_minutes,omitempty"`
+	RefreshDuration  int      `yaml:"refresh_duration_minutes,omitempty"`
+	Impersonation    int      `yaml:"impersonation_duration_minutes,omitempty"`
+	MaxRefresh       int      `yaml:"max_refresh_minutes,omitempty"`
+	SigningAlgorithm string   `yaml:"signing_algorithm,omitempty"`
+	PrivateKeyPath   string   `yaml:"private_key_path,omitempty"`
+	KeyID            string   `yaml:"key_id,omitempty"`
+	RetiredKeys      []JWTKey `yaml:"retired_keys,omitempty"`
+	Issuer           string   `yaml:"issuer,omitempty"`
+	Audience         string   `yaml:"audience,omitempty"`
+	LegacyRefresh    bool     `yaml:"legacy_refresh_route,omitempty"`
+}
+
+// JWTKey holds retired JWT key, still accepted for verifying tokens signed before keys were rotated.
+// HMAC secret is read from SecretEnv env var, asymmetric keys from PEM file at KeyPath.
+type JWTKey struct {
+	ID               string `yaml:"id,omitempty"`
 	SigningAlgorithm string `yaml:"signing_algorithm,omitempty"`
+	SecretEnv        string `yaml:"secret_env,omitempty"`
+	KeyPath          string `yaml:"key_path,omitempty"`
 }
 
 // Application holds application configuration details
 type Application struct {
-	MinPasswordStr int    `yaml:"min_password_strength,omitempty"`
-	SwaggerUIPath  string `yaml:"swagger_ui_path,omitempty"`
+	MinPasswordStr        int    `yaml:"min_password_strength,omitempty"`
+	SwaggerUIPath         string `yaml:"swagger_ui_path,omitempty"`
+	MaxLoginAttempts      int    `yaml:"max_login_attempts,omitempty"`
+	LockoutDuration       int    `yaml:"lockout_duration_minutes,omitempty"`
+	MaxLockoutDuration    int    `yaml:"max_lockout_duration_minutes,omitempty"`
+	MFAIssuer             string `yaml:"mfa_issuer,omitempty"`
+	ForceMFARole          int    `yaml:"force_mfa_access_level,omitempty"`
+	PasswordResetURL      string `yaml:"password_reset_url,omitempty"`
+	PasswordResetDuration int    `yaml:"password_reset_duration_minutes,omitempty"`
+	VerificationURL       string `yaml:"verification_url,omitempty"`
+	VerificationDuration  int    `yaml:"verification_duration_minutes,omitempty"`
+	RequireVerifiedEmail  bool   `yaml:"require_verified_email,omitempty"`
+	MagicLinkURL          string `yaml:"magic_link_url,omitempty"`
+	MagicLinkDuration     int    `yaml:"magic_link_duration_minutes,omitempty"`
+	NotifyNewLogin        bool   `yaml:"notify_new_login,omitempty"`
+	PolicyPath            string `yaml:"policy_path,omitempty"`
+}
+
+// Mail holds data necessary for sending emails. If SMTP host is not set, emails are written to files in Dir.
+type Mail struct {
+	From         string `yaml:"from,omitempty"`
+	SMTPHost     string `yaml:"smtp_host,omitempty"`
+	SMTPPort     int    `yaml:"smtp_port,omitempty"`
+	SMTPUsername string `yaml:"smtp_username,omitempty"`
+	Dir          string `yaml:"dir,omitempty"`
+}
+
+// Cookie holds configuration of cookie based authentication, used by browser clients instead of Authorization header.
+// SameSite is one of strict, lax or none.
+type Cookie struct {
+	Enabled  bool   `yaml:"enabled,omitempty"`
+	Domain   string `yaml:"domain,omitempty"`
+	SameSite string `yaml:"same_site,omitempty"`
+}
+
+// OIDC holds configuration of login through external OpenID Connect identity provider. Client secret is read from OIDC_CLIENT_SECRET env var.
+// Users logging in for the first time are created with DefaultRole, DefaultCompanyID and DefaultLocationID, unless DefaultRole is zero.
+type OIDC struct {
+	Issuer            string   `yaml:"issuer,omitempty"`
+	ClientID          string   `yaml:"client_id,omitempty"`
+	RedirectURL       string   `yaml:"redirect_url,omitempty"`
+	Scopes            []string `yaml:"scopes,omitempty"`
+	DefaultRole       int      `yaml:"default_access_level,omitempty"`
+	DefaultCompanyID  int      `yaml:"default_company_id,omitempty"`
+	DefaultLocationID int      `yaml:"default_location_id,omitempty"`
 }
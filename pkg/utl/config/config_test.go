@@ -42,12 +42,54 @@ func TestLoad(t *testing.T) {
 					MinSecretLength:  128,
 					DurationMinutes:  10,
 					RefreshDuration:  10,
+					Impersonation:    5,
 					MaxRefresh:       144,
 					SigningAlgorithm: "HS384",
+					KeyID:            "2",
+					Issuer:           "https://api.gorsk.com",
+					Audience:         "gorsk",
+					LegacyRefresh:    true,
+					RetiredKeys: []config.JWTKey{
+						{ID: "1", SigningAlgorithm: "HS384", SecretEnv: "JWT_SECRET_1"},
+						{SigningAlgorithm: "ES256", KeyPath: "jwt.pub.pem"},
+					},
 				},
 				App: &config.Application{
-					MinPasswordStr: 3,
-					SwaggerUIPath:  "assets/swagger",
+					MinPasswordStr:        3,
+					SwaggerUIPath:         "assets/swagger",
+					MaxLoginAttempts:      3,
+					LockoutDuration:       1,
+					MaxLockoutDuration:    60,
+					MFAIssuer:             "gorsk",
+					ForceMFARole:          110,
+					PasswordResetURL:      "https://gorsk.com/password/reset",
+					PasswordResetDuration: 15,
+					VerificationURL:       "https://api.gorsk.com/verify",
+					VerificationDuration:  60,
+					RequireVerifiedEmail:  true,
+					MagicLinkURL:          "https://api.gorsk.com/login/magic",
+					MagicLinkDuration:     10,
+					NotifyNewLogin:        true,
+					PolicyPath:            "cmd/api/policy.yaml",
+				},
+				Mail: &config.Mail{
+					From:         "gorsk <noreply@gorsk.com>",
+					SMTPHost:     "smtp.gorsk.com",
+					SMTPPort:     587,
+					SMTPUsername: "gorsk",
+				},
+				Cookie: &config.Cookie{
+					Enabled:  true,
+					Domain:   "gorsk.com",
+					SameSite: "lax",
+				},
+				OIDC: &config.OIDC{
+					Issuer:            "https://login.gorsk.com",
+					ClientID:          "gorsk",
+					RedirectURL:       "https://api.gorsk.com/auth/oidc/callback",
+					DefaultRole:       200,
+					DefaultCompanyID:  1,
+					DefaultLocationID: 1,
 				},
 			},
 		},
@@ -0,0 +1,61 @@
+package jwt
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/ribice/gorsk"
+)
+
+// requiredClaims must be present in every token. Missing role claim in particular
+// must not be decoded as zero value, which is the most privileged role.
+var requiredClaims = []string{"sub", "exp", "jti", "r", "c", "l"}
+
+// Claims represents claims of access tokens issued by gorsk.
+// User's ID is held in sub claim. Role holds access level of user's role, and RoleID the role itself,
+// which differ for custom roles. Tokens issued to admins impersonating the user also hold admin's ID in imp claim.
+type Claims struct {
+	jwt.StandardClaims
+	Version        int              `json:"v"`
+	Username       string           `json:"u"`
+	Email          string           `json:"e"`
+	Role           gorsk.AccessRole `json:"r"`
+	RoleID         gorsk.AccessRole `json:"rid,omitempty"`
+	CompanyID      int              `json:"c"`
+	LocationID     int              `json:"l"`
+	ImpersonatorID int              `json:"imp,omitempty"`
+}
+
+// UserID returns ID of the user the token was issued to
+func (c Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// Valid validates time based claims and user's ID
+func (c Claims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if _, err := c.UserID(); err != nil {
+		return ErrMissingClaims
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes claims, failing if any of required claims is missing or null
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	for _, k := range requiredClaims {
+		if v, ok := raw[k]; !ok || string(v) == "null" {
+			return ErrMissingClaims
+		}
+	}
+
+	type claims Claims
+	return json.Unmarshal(b, (*claims)(c))
+}
@@ -1,7 +1,11 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,64 +16,223 @@ import (
 
 var minSecretLen = 128
 
-// New generates new JWT service necessary for auth middleware
-func New(algo, secret string, ttlMinutes, minSecretLength int) (Service, error) {
-	if minSecretLength > 0 {
-		minSecretLen = minSecretLength
+// Custom errors
+var (
+	ErrKeyNotPEM       = errors.New("jwt private key must be PEM encoded")
+	ErrMissingClaims   = errors.New("jwt token is missing required claims")
+	ErrInvalidIssuer   = errors.New("jwt token is issued by unknown issuer")
+	ErrInvalidAudience = errors.New("jwt token is not intended for this audience")
+)
+
+// Key represents a key of the keyring, identified by kid header of tokens it signs.
+// For HMAC signing methods Key is the shared secret, otherwise it is PEM encoded private key.
+// Retired asymmetric keys may be given as PEM encoded public key instead.
+type Key struct {
+	ID        string
+	Algorithm string
+	Key       string
+}
+
+// New generates new JWT service necessary for auth middleware, signing tokens with a single key.
+// For HMAC signing methods key is the shared secret, otherwise it is PEM encoded private key.
+func New(algo, key string, ttlMinutes, minSecretLength int) (Service, error) {
+	return NewKeyring(Key{Algorithm: algo, Key: key}, nil, "", "", ttlMinutes, minSecretLength)
+}
+
+// NewKeyring generates new JWT service signing tokens with active key, and verifying them with active or any of retired keys.
+// Asymmetric keys without ID are identified by their RFC 7638 thumbprint.
+// A single HMAC key may be left without ID, to verify tokens issued without kid header before keys were rotated.
+// Issuer and audience are set as iss and aud claims, and if not empty, only tokens with matching claims are accepted.
+func NewKeyring(active Key, retired []Key, issuer, audience string, ttlMinutes, minSecretLength int) (Service, error) {
+	if minSecretLength <= 0 {
+		minSecretLength = minSecretLen
 	}
-	if len(secret) < minSecretLen {
-		return Service{}, fmt.Errorf("jwt secret length is %v, which is less than required %v", len(secret), minSecretLen)
+
+	s := Service{
+		ttl:      time.Duration(ttlMinutes) * time.Minute,
+		issuer:   issuer,
+		audience: audience,
+		keys:     make(map[string]verificationKey, len(retired)+1),
+		jwks:     []gorsk.JWK{},
 	}
-	signingMethod := jwt.GetSigningMethod(algo)
-	if signingMethod == nil {
-		return Service{}, fmt.Errorf("invalid jwt signing method: %s", algo)
+
+	for i, k := range append([]Key{active}, retired...) {
+		method := jwt.GetSigningMethod(k.Algorithm)
+		if method == nil {
+			return Service{}, fmt.Errorf("invalid jwt signing method: %s", k.Algorithm)
+		}
+
+		var signKey interface{}
+		vk := verificationKey{method: method}
+		if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+			if len(k.Key) < minSecretLength {
+				return Service{}, fmt.Errorf("jwt secret length is %v, which is less than required %v", len(k.Key), minSecretLength)
+			}
+			signKey, vk.key = []byte(k.Key), []byte(k.Key)
+		} else {
+			if i == 0 {
+				priv, err := parsePrivateKey(method, []byte(k.Key))
+				if err != nil {
+					return Service{}, err
+				}
+				signKey, vk.key = priv, priv.Public()
+			} else {
+				pub, err := parsePublicKey(method, []byte(k.Key))
+				if err != nil {
+					return Service{}, err
+				}
+				vk.key = pub
+			}
+
+			jwk, err := newJWK(method.Alg(), vk.key)
+			if err != nil {
+				return Service{}, err
+			}
+			if k.ID == "" {
+				k.ID = jwk.Kid
+			}
+			jwk.Kid = k.ID
+			s.jwks = append(s.jwks, jwk)
+		}
+
+		if _, ok := s.keys[k.ID]; ok {
+			return Service{}, fmt.Errorf("duplicate jwt key id: %q", k.ID)
+		}
+		s.keys[k.ID] = vk
+
+		if i == 0 {
+			s.key, s.kid, s.algo = signKey, k.ID, method
+		}
 	}
 
-	return Service{
-		key:  []byte(secret),
-		algo: signingMethod,
-		ttl:  time.Duration(ttlMinutes) * time.Minute,
-	}, nil
+	return s, nil
 }
 
 // Service provides a Json-Web-Token authentication implementation
 type Service struct {
-	// Secret key used for signing.
-	key []byte
+	// Secret or private key used for signing.
+	key interface{}
+
+	// Key ID set as kid header of signed tokens. Header is omitted if empty.
+	kid string
 
 	// Duration for which the jwt token is valid.
 	ttl time.Duration
 
 	// JWT signing algorithm
 	algo jwt.SigningMethod
+
+	// Values of iss and aud claims, required in parsed tokens if not empty
+	issuer   string
+	audience string
+
+	// Keys used for verifying signatures, by key ID
+	keys map[string]verificationKey
+
+	// Public keys published in JWKS
+	jwks []gorsk.JWK
 }
 
-// ParseToken parses token from Authorization header
+// verificationKey holds secret or public key used for verifying tokens, with signing method it is used with
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// ParseToken parses token from Authorization header, verifying it with the key identified by its kid header.
+// Claims of parsed token are of type *Claims.
 func (s Service) ParseToken(authHeader string) (*jwt.Token, error) {
 	parts := strings.SplitN(authHeader, " ", 2)
 	if !(len(parts) == 2 && parts[0] == "Bearer") {
 		return nil, gorsk.ErrGeneric
 	}
 
-	return jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-		if s.algo != token.Method {
+	token, err := jwt.ParseWithClaims(parts[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		var kid string
+		if h, ok := token.Header["kid"]; ok {
+			if kid, ok = h.(string); !ok {
+				return nil, gorsk.ErrGeneric
+			}
+		}
+		k, ok := s.keys[kid]
+		if !ok || k.method != token.Method {
 			return nil, gorsk.ErrGeneric
 		}
-		return s.key, nil
+		return k.key, nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*Claims)
+	if s.issuer != "" && !claims.VerifyIssuer(s.issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if s.audience != "" && !claims.VerifyAudience(s.audience, true) {
+		return nil, ErrInvalidAudience
+	}
 
+	return token, nil
 }
 
 // GenerateToken generates new JWT token and populates it with user data
 func (s Service) GenerateToken(u gorsk.User) (string, error) {
-	return jwt.NewWithClaims(s.algo, jwt.MapClaims{
-		"id":  u.Base.ID,
-		"u":   u.Username,
-		"e":   u.Email,
-		"r":   u.Role.AccessLevel,
-		"c":   u.CompanyID,
-		"l":   u.LocationID,
-		"exp": time.Now().Add(s.ttl).Unix(),
-	}).SignedString(s.key)
+	return s.generate(u, 0, s.ttl)
+}
+
+// GenerateImpersonationToken generates JWT token for the user, issued to the impersonator acting as that user.
+// The token carries impersonator's ID in imp claim and expires after ttl, or after the usual duration if ttl is not shorter.
+func (s Service) GenerateImpersonationToken(u gorsk.User, impersonatorID int, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > s.ttl {
+		ttl = s.ttl
+	}
+	return s.generate(u, impersonatorID, ttl)
+}
 
+func (s Service) generate(u gorsk.User, impersonatorID int, ttl time.Duration) (string, error) {
+	jti, err := tokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(s.algo, Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Subject:   strconv.Itoa(u.Base.ID),
+			Issuer:    s.issuer,
+			Audience:  s.audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		Version:        u.TokenVersion,
+		Username:       u.Username,
+		Email:          u.Email,
+		Role:           u.Role.AccessLevel,
+		RoleID:         u.RoleID,
+		CompanyID:      u.CompanyID,
+		LocationID:     u.LocationID,
+		ImpersonatorID: impersonatorID,
+	})
+	if s.kid != "" {
+		token.Header["kid"] = s.kid
+	}
+
+	return token.SignedString(s.key)
+}
+
+// JWKS returns JSON Web Key Set with public keys used for verifying tokens, the active key first.
+// Shared secrets are never published, so the set is empty if only HMAC signing methods are used.
+func (s Service) JWKS() gorsk.JWKS {
+	return gorsk.JWKS{Keys: append([]gorsk.JWK{}, s.jwks...)}
+}
+
+// tokenID generates random jti claim, used to revoke a single token
+func tokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -1,12 +1,24 @@
 package jwt_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/utl/jwt"
 
+	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,3 +81,447 @@ func TestGenerateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestParseToken(t *testing.T) {
+	secret := "g0r$kt3$t1ng"
+	jwtSvc, err := jwt.NewKeyring(jwt.Key{Algorithm: "HS256", Key: secret}, nil, "gorsk", "api", 60, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(override jwtgo.MapClaims) jwtgo.MapClaims {
+		c := jwtgo.MapClaims{
+			"iss": "gorsk",
+			"aud": "api",
+			"sub": "1",
+			"jti": "tokenid",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"u":   "johndoe",
+			"e":   "johndoe@mail.com",
+			"r":   200,
+			"c":   2,
+			"l":   3,
+			"v":   4,
+		}
+		for k, v := range override {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	cases := map[string]struct {
+		claims  jwtgo.MapClaims
+		wantErr bool
+	}{
+		"unknown issuer": {
+			claims:  claims(jwtgo.MapClaims{"iss": "other"}),
+			wantErr: true,
+		},
+		"missing issuer": {
+			claims:  claims(jwtgo.MapClaims{"iss": nil}),
+			wantErr: true,
+		},
+		"other audience": {
+			claims:  claims(jwtgo.MapClaims{"aud": "other"}),
+			wantErr: true,
+		},
+		"expired": {
+			claims:  claims(jwtgo.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+			wantErr: true,
+		},
+		"not valid yet": {
+			claims:  claims(jwtgo.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}),
+			wantErr: true,
+		},
+		"missing expiration": {
+			claims:  claims(jwtgo.MapClaims{"exp": nil}),
+			wantErr: true,
+		},
+		"missing role": {
+			claims:  claims(jwtgo.MapClaims{"r": nil}),
+			wantErr: true,
+		},
+		"missing token id": {
+			claims:  claims(jwtgo.MapClaims{"jti": nil}),
+			wantErr: true,
+		},
+		"non-numeric subject": {
+			claims:  claims(jwtgo.MapClaims{"sub": "johndoe"}),
+			wantErr: true,
+		},
+		"mistyped subject": {
+			claims:  claims(jwtgo.MapClaims{"sub": 1}),
+			wantErr: true,
+		},
+		"mistyped role": {
+			claims:  claims(jwtgo.MapClaims{"r": "admin"}),
+			wantErr: true,
+		},
+		"success": {
+			claims: claims(nil),
+		},
+		"success with custom role": {
+			claims: claims(jwtgo.MapClaims{"rid": 1000}),
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, tt.claims).SignedString([]byte(secret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := jwtSvc.ParseToken("Bearer " + token)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			c := parsed.Claims.(*jwt.Claims)
+			id, err := c.UserID()
+			assert.Nil(t, err)
+			assert.Equal(t, 1, id)
+			assert.Equal(t, "tokenid", c.Id)
+			assert.Equal(t, "johndoe", c.Username)
+			assert.Equal(t, "johndoe@mail.com", c.Email)
+			assert.Equal(t, gorsk.UserRole, c.Role)
+			if name == "success with custom role" {
+				assert.Equal(t, gorsk.AccessRole(1000), c.RoleID)
+			} else {
+				assert.Zero(t, c.RoleID)
+			}
+			assert.Equal(t, 2, c.CompanyID)
+			assert.Equal(t, 3, c.LocationID)
+			assert.Equal(t, 4, c.Version)
+		})
+	}
+
+	token, err := jwtSvc.GenerateToken(gorsk.User{Base: gorsk.Base{ID: 7}, RoleID: 1000, Role: &gorsk.Role{ID: 1000, AccessLevel: gorsk.AdminRole}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwtSvc.ParseToken("Bearer " + token)
+	if assert.Nil(t, err) {
+		c := parsed.Claims.(*jwt.Claims)
+		assert.Equal(t, "gorsk", c.Issuer)
+		assert.Equal(t, "api", c.Audience)
+		assert.Equal(t, "7", c.Subject)
+		assert.NotEmpty(t, c.Id)
+		assert.NotZero(t, c.IssuedAt)
+		assert.Equal(t, c.IssuedAt, c.NotBefore)
+		assert.Equal(t, c.IssuedAt+3600, c.ExpiresAt)
+		assert.Equal(t, gorsk.AdminRole, c.Role)
+		assert.Equal(t, gorsk.AccessRole(1000), c.RoleID)
+		assert.Zero(t, c.ImpersonatorID)
+	}
+
+	imp, err := jwtSvc.GenerateImpersonationToken(gorsk.User{Base: gorsk.Base{ID: 8}, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, 7, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = jwtSvc.ParseToken("Bearer " + imp)
+	if assert.Nil(t, err) {
+		c := parsed.Claims.(*jwt.Claims)
+		assert.Equal(t, "8", c.Subject)
+		assert.Equal(t, 7, c.ImpersonatorID)
+		assert.Equal(t, c.IssuedAt+600, c.ExpiresAt)
+	}
+
+	imp, err = jwtSvc.GenerateImpersonationToken(gorsk.User{Base: gorsk.Base{ID: 8}, Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}, 7, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = jwtSvc.ParseToken("Bearer " + imp)
+	if assert.Nil(t, err) {
+		c := parsed.Claims.(*jwt.Claims)
+		assert.Equal(t, c.IssuedAt+3600, c.ExpiresAt)
+	}
+
+	other, err := jwt.NewKeyring(jwt.Key{Algorithm: "HS256", Key: secret}, nil, "other", "api", 60, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.ParseToken("Bearer " + token)
+	assert.Equal(t, jwt.ErrInvalidIssuer, err)
+}
+
+func TestPrivateKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecSEC1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		algo    string
+		key     string
+		wantErr bool
+		wantKty string
+		wantCrv string
+	}{
+		"not PEM": {
+			algo:    "RS256",
+			key:     "g0r$kt3$t1ng",
+			wantErr: true,
+		},
+		"invalid key": {
+			algo:    "RS256",
+			key:     string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")})),
+			wantErr: true,
+		},
+		"key type mismatch": {
+			algo:    "ES256",
+			key:     pkcs8(t, rsaKey),
+			wantErr: true,
+		},
+		"curve mismatch": {
+			algo:    "ES384",
+			key:     pkcs8(t, ecKey),
+			wantErr: true,
+		},
+		"RSA PKCS1": {
+			algo:    "RS256",
+			key:     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})),
+			wantKty: "RSA",
+		},
+		"RSA-PSS": {
+			algo:    "PS256",
+			key:     pkcs8(t, rsaKey),
+			wantKty: "RSA",
+		},
+		"ECDSA SEC1": {
+			algo:    "ES256",
+			key:     string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecSEC1})),
+			wantKty: "EC",
+			wantCrv: "P-256",
+		},
+		"ECDSA PKCS8": {
+			algo:    "ES256",
+			key:     pkcs8(t, ecKey),
+			wantKty: "EC",
+			wantCrv: "P-256",
+		},
+		"Ed25519": {
+			algo:    "EdDSA",
+			key:     pkcs8(t, edKey),
+			wantKty: "OKP",
+			wantCrv: "Ed25519",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			jwtSvc, err := jwt.New(tt.algo, tt.key, 60, 0)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+
+			jwks := jwtSvc.JWKS()
+			if !assert.Len(t, jwks.Keys, 1) {
+				return
+			}
+			jwk := jwks.Keys[0]
+			assert.Equal(t, tt.wantKty, jwk.Kty)
+			assert.Equal(t, tt.wantCrv, jwk.Crv)
+			assert.Equal(t, tt.algo, jwk.Alg)
+			assert.Equal(t, "sig", jwk.Use)
+			assert.NotEmpty(t, jwk.Kid)
+
+			token, err := jwtSvc.GenerateToken(gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Role: &gorsk.Role{AccessLevel: gorsk.UserRole}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := jwtSvc.ParseToken("Bearer " + token)
+			if assert.Nil(t, err) {
+				assert.True(t, parsed.Valid)
+				assert.Equal(t, jwk.Kid, parsed.Header["kid"])
+				assert.Equal(t, "johndoe", parsed.Claims.(*jwt.Claims).Username)
+			}
+
+			parts := strings.Split(token, ".")
+			_, err = jwtSvc.ParseToken("Bearer " + parts[0] + "." + parts[1] + ".c2lnbmF0dXJl")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	jwtSvc, err := jwt.New("HS256", "g0r$kt3$t1ng", 60, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(jwtSvc.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"keys":[]}`, string(b))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSvc, err = jwt.New("EdDSA", pkcs8(t, edKey), 60, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey))
+	thumbprint := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	assert.Equal(t, gorsk.JWKS{Keys: []gorsk.JWK{{
+		Kty: "OKP",
+		Use: "sig",
+		Alg: "EdDSA",
+		Kid: base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		Crv: "Ed25519",
+		X:   x,
+	}}}, jwtSvc.JWKS())
+}
+
+func TestKeyring(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPub, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldSecret, newSecret := "0ld$kt3$t1ng", "n3w$kt3$t1ng"
+	u := gorsk.User{Base: gorsk.Base{ID: 1}, Username: "johndoe", Role: &gorsk.Role{AccessLevel: gorsk.UserRole}}
+
+	cases := map[string]struct {
+		active    jwt.Key
+		retired   []jwt.Key
+		issuer    jwt.Key
+		wantErr   bool
+		wantValid bool
+		wantKids  []string
+	}{
+		"duplicate key id": {
+			active:  jwt.Key{ID: "1", Algorithm: "HS256", Key: newSecret},
+			retired: []jwt.Key{{ID: "1", Algorithm: "HS256", Key: oldSecret}},
+			wantErr: true,
+		},
+		"several keys without id": {
+			active:  jwt.Key{Algorithm: "HS256", Key: newSecret},
+			retired: []jwt.Key{{Algorithm: "HS512", Key: oldSecret}},
+			wantErr: true,
+		},
+		"invalid retired key": {
+			active:  jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			retired: []jwt.Key{{ID: "1", Algorithm: "HS256", Key: "short"}},
+			wantErr: true,
+		},
+		"token without kid issued before rotation": {
+			active:    jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			retired:   []jwt.Key{{Algorithm: "HS256", Key: oldSecret}},
+			issuer:    jwt.Key{Algorithm: "HS256", Key: oldSecret},
+			wantValid: true,
+			wantKids:  []string{},
+		},
+		"token signed with retired key": {
+			active:    jwt.Key{ID: "2", Algorithm: "HS512", Key: newSecret},
+			retired:   []jwt.Key{{ID: "1", Algorithm: "HS256", Key: oldSecret}},
+			issuer:    jwt.Key{ID: "1", Algorithm: "HS256", Key: oldSecret},
+			wantValid: true,
+			wantKids:  []string{},
+		},
+		"token signed with removed key": {
+			active:   jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			issuer:   jwt.Key{ID: "1", Algorithm: "HS256", Key: oldSecret},
+			wantKids: []string{},
+		},
+		"token with kid of other key": {
+			active:   jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			retired:  []jwt.Key{{ID: "1", Algorithm: "HS256", Key: oldSecret}},
+			issuer:   jwt.Key{ID: "1", Algorithm: "HS256", Key: newSecret},
+			wantKids: []string{},
+		},
+		"token with algorithm of other key": {
+			active:   jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			retired:  []jwt.Key{{ID: "1", Algorithm: "HS256", Key: oldSecret}},
+			issuer:   jwt.Key{ID: "1", Algorithm: "HS512", Key: oldSecret},
+			wantKids: []string{},
+		},
+		"retired public key": {
+			active:    jwt.Key{ID: "ed", Algorithm: "EdDSA", Key: pkcs8(t, edKey)},
+			retired:   []jwt.Key{{ID: "ec", Algorithm: "ES256", Key: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPub}))}},
+			issuer:    jwt.Key{ID: "ec", Algorithm: "ES256", Key: pkcs8(t, ecKey)},
+			wantValid: true,
+			wantKids:  []string{"ed", "ec"},
+		},
+		"retired private key identified by thumbprint": {
+			active:    jwt.Key{ID: "2", Algorithm: "HS256", Key: newSecret},
+			retired:   []jwt.Key{{Algorithm: "ES256", Key: pkcs8(t, ecKey)}},
+			issuer:    jwt.Key{Algorithm: "ES256", Key: pkcs8(t, ecKey)},
+			wantValid: true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			jwtSvc, err := jwt.NewKeyring(tt.active, tt.retired, "", "", 60, 8)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+
+			issuer, err := jwt.NewKeyring(tt.issuer, nil, "", "", 60, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := issuer.GenerateToken(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = jwtSvc.ParseToken("Bearer " + token)
+			assert.Equal(t, tt.wantValid, err == nil)
+
+			token, err = jwtSvc.GenerateToken(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := jwtSvc.ParseToken("Bearer " + token)
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.active.ID, parsed.Header["kid"])
+			}
+
+			if tt.wantKids != nil {
+				kids := []string{}
+				for _, k := range jwtSvc.JWKS().Keys {
+					kids = append(kids, k.Kid)
+				}
+				assert.Equal(t, tt.wantKids, kids)
+			} else {
+				assert.Equal(t, issuer.JWKS().Keys, jwtSvc.JWKS().Keys)
+			}
+		})
+	}
+}
+
+func pkcs8(t *testing.T, key interface{}) string {
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+}
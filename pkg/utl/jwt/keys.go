@@ -0,0 +1,149 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/ribice/gorsk"
+)
+
+// parsePrivateKey parses PEM encoded PKCS#8, PKCS#1 or SEC 1 private key, checking it can be used with signing method
+func parsePrivateKey(method jwt.SigningMethod, data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrKeyNotPEM
+	}
+
+	var key interface{}
+	var err error
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("unable to parse jwt private key: %v", err)
+			}
+		}
+	}
+
+	priv, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("jwt private key of type %T can not be used for signing", key)
+	}
+
+	if err := checkKey(method, priv.Public()); err != nil {
+		return nil, err
+	}
+
+	return priv, nil
+}
+
+// parsePublicKey parses PEM encoded PKIX public key, or public part of PEM encoded private key, checking it can be used with signing method
+func parsePublicKey(method jwt.SigningMethod, data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrKeyNotPEM
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		priv, err := parsePrivateKey(method, data)
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public(), nil
+	}
+
+	if err := checkKey(method, pub); err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// checkKey checks public key pub can be used with signing method
+func checkKey(method jwt.SigningMethod, pub crypto.PublicKey) error {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := pub.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if k, ok := pub.(*ecdsa.PublicKey); ok && k.Curve.Params().BitSize == m.CurveBits {
+			return nil
+		}
+	case *signingMethodEdDSA:
+		if _, ok := pub.(ed25519.PublicKey); ok {
+			return nil
+		}
+	default:
+		return fmt.Errorf("jwt signing method %s does not use private keys", method.Alg())
+	}
+
+	return fmt.Errorf("jwt key of type %T can not be used with signing method %s", pub, method.Alg())
+}
+
+// newJWK returns public JSON Web Key of pub, identified by its RFC 7638 thumbprint
+func newJWK(alg string, pub crypto.PublicKey) (gorsk.JWK, error) {
+	var k gorsk.JWK
+	var members interface{}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k = gorsk.JWK{Kty: "RSA", N: encode(p.N.Bytes()), E: encode(big.NewInt(int64(p.E)).Bytes())}
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case *ecdsa.PublicKey:
+		size := (p.Curve.Params().BitSize + 7) / 8
+		k = gorsk.JWK{Kty: "EC", Crv: p.Curve.Params().Name, X: encode(pad(p.X.Bytes(), size)), Y: encode(pad(p.Y.Bytes(), size))}
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case ed25519.PublicKey:
+		k = gorsk.JWK{Kty: "OKP", Crv: "Ed25519", X: encode(p)}
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return gorsk.JWK{}, fmt.Errorf("unsupported jwt public key type %T", pub)
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return gorsk.JWK{}, err
+	}
+	sum := sha256.Sum256(b)
+
+	k.Use = "sig"
+	k.Alg = alg
+	k.Kid = encode(sum[:])
+	return k, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pad left pads b with zeros to size bytes, as required for elliptic curve coordinates
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,144 @@
+// Package mail implements sending of plain text emails over SMTP,
+// and mailers keeping sent emails in files or in memory for development and testing
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Custom errors
+var (
+	ErrInvalidHeader = errors.New("mail header must not contain line breaks")
+)
+
+// NewSMTP creates new mailer sending emails through SMTP server.
+// Authentication is skipped if username is empty.
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	s := &SMTP{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// SMTP sends emails through SMTP server
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// Send sends an email to address to
+func (s *SMTP) Send(to, subject, body string) error {
+	msg, err := message(s.from, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, address(s.from), []string{address(to)}, msg)
+}
+
+// NewFile creates new mailer writing emails to files in dir, which is created if missing
+func NewFile(dir, from string) *File {
+	return &File{dir: dir, from: from}
+}
+
+// File writes emails to .eml files instead of sending them
+type File struct {
+	dir  string
+	from string
+}
+
+// Send writes an email to address to into a new file
+func (f *File) Send(to, subject, body string) error {
+	msg, err := message(f.from, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(address(to)))
+	return ioutil.WriteFile(filepath.Join(f.dir, name), msg, 0600)
+}
+
+// Message represents a sent email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Memory keeps sent emails in memory
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send stores an email to address to
+func (m *Memory) Send(to, subject, body string) error {
+	if _, err := message("", to, subject, body); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, Message{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Messages returns emails sent so far
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// message formats an email, refusing headers that could be used to inject other headers
+func message(from, to, subject, body string) ([]byte, error) {
+	for _, h := range []string{from, to, subject} {
+		if strings.ContainsAny(h, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	if _, err := mail.ParseAddress(to); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes(), nil
+}
+
+// address returns the bare address of a possibly named address, such as "John <john@mail.com>"
+func address(addr string) string {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return addr
+	}
+	return a.Address
+}
@@ -0,0 +1,58 @@
+package mail_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ribice/gorsk/pkg/utl/mail"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTP(t *testing.T) {
+	s := mail.NewSMTP("localhost", 25, "", "", "gorsk <noreply@gorsk.com>")
+	assert.Equal(t, mail.ErrInvalidHeader, s.Send("john@mail.com", "Hi\r\nBcc: jane@mail.com", "Body"))
+	assert.NotNil(t, s.Send("not an address", "Subject", "Body"))
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := mail.NewFile(filepath.Join(dir, "outbox"), "gorsk <noreply@gorsk.com>")
+	assert.Equal(t, mail.ErrInvalidHeader, f.Send("john@mail.com\nBcc: jane@mail.com", "Subject", "Body"))
+
+	if err := f.Send("John Doe <john@mail.com>", "Reset your password", "Hi John,\nreset it."); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, files, 1) {
+		assert.True(t, strings.HasSuffix(files[0].Name(), "-john_at_mail.com.eml"))
+		b, err := ioutil.ReadFile(filepath.Join(dir, "outbox", files[0].Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := string(b)
+		assert.Contains(t, msg, "From: gorsk <noreply@gorsk.com>\r\n")
+		assert.Contains(t, msg, "To: John Doe <john@mail.com>\r\n")
+		assert.Contains(t, msg, "Subject: Reset your password\r\n")
+		assert.True(t, strings.HasSuffix(msg, "\r\n\r\nHi John,\r\nreset it."))
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := new(mail.Memory)
+	assert.Equal(t, mail.ErrInvalidHeader, m.Send("john@mail.com", "Hi\nBcc: jane@mail.com", "Body"))
+	assert.Nil(t, m.Send("john@mail.com", "Subject", "Body"))
+	assert.Equal(t, []mail.Message{{To: "john@mail.com", Subject: "Subject", Body: "Body"}}, m.Messages())
+}
@@ -2,44 +2,115 @@ package auth
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
+	jwtUtl "github.com/ribice/gorsk/pkg/utl/jwt"
 )
 
-// TokenParser represents JWT token parser
+// TokenParser represents JWT token parser. Claims of parsed tokens must be of type *jwtUtl.Claims.
 type TokenParser interface {
 	ParseToken(string) (*jwt.Token, error)
 }
 
+// Revoker represents JWT revocation checker interface
+type Revoker interface {
+	IsRevoked(c echo.Context, jti string, userID, version int) (bool, error)
+}
+
+// KeyAuthenticator represents API key authentication interface
+type KeyAuthenticator interface {
+	Authenticate(echo.Context, string) (gorsk.AuthUser, error)
+}
+
+// APIKeyHeader is the header holding API key of requests made using API keys instead of JWT
+const APIKeyHeader = "X-API-Key"
+
 // Middleware makes JWT implement the Middleware interface.
-func Middleware(tokenParser TokenParser) echo.MiddlewareFunc {
+// Tokens with missing or malformed claims, and tokens reported as revoked by revoker are rejected. Revocation is not checked if revoker is nil.
+// If cookies is not nil, requests without Authorization header are authenticated by access token cookie, and must pass CSRF check.
+// If keys is not nil, requests with X-API-Key header are authenticated by the API key instead of JWT.
+func Middleware(tokenParser TokenParser, revoker Revoker, cookies *Cookies, keys KeyAuthenticator) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			token, err := tokenParser.ParseToken(c.Request().Header.Get("Authorization"))
+			if key := c.Request().Header.Get(APIKeyHeader); key != "" && keys != nil {
+				u, err := keys.Authenticate(c, key)
+				if err != nil {
+					return err
+				}
+				setUser(c, u)
+				c.Set("api_key_id", u.APIKeyID)
+				return next(c)
+			}
+
+			header := c.Request().Header.Get("Authorization")
+			if header == "" && cookies != nil {
+				if t := cookies.AccessToken(c); t != "" {
+					if err := cookies.CheckCSRF(c); err != nil {
+						return err
+					}
+					header = "Bearer " + t
+				}
+			}
+
+			token, err := tokenParser.ParseToken(header)
 			if err != nil || !token.Valid {
 				return c.NoContent(http.StatusUnauthorized)
 			}
 
-			claims := token.Claims.(jwt.MapClaims)
+			claims, ok := token.Claims.(*jwtUtl.Claims)
+			if !ok {
+				return c.NoContent(http.StatusUnauthorized)
+			}
 
-			id := int(claims["id"].(float64))
-			companyID := int(claims["c"].(float64))
-			locationID := int(claims["l"].(float64))
-			username := claims["u"].(string)
-			email := claims["e"].(string)
-			role := gorsk.AccessRole(claims["r"].(float64))
+			id, err := claims.UserID()
+			if err != nil {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+
+			if revoker != nil {
+				revoked, err := revoker.IsRevoked(c, claims.Id, id, claims.Version)
+				if err != nil {
+					return err
+				}
+				if revoked {
+					return c.NoContent(http.StatusUnauthorized)
+				}
+			}
 
-			c.Set("id", id)
-			c.Set("company_id", companyID)
-			c.Set("location_id", locationID)
-			c.Set("username", username)
-			c.Set("email", email)
-			c.Set("role", role)
+			setUser(c, gorsk.AuthUser{
+				ID:             id,
+				CompanyID:      claims.CompanyID,
+				LocationID:     claims.LocationID,
+				Username:       claims.Username,
+				Email:          claims.Email,
+				Role:           claims.Role,
+				RoleID:         claims.RoleID,
+				ImpersonatorID: claims.ImpersonatorID,
+			})
+			c.Set("jti", claims.Id)
+			c.Set("exp", time.Unix(claims.ExpiresAt, 0))
 
 			return next(c)
 		}
 	}
 }
+
+// setUser stores authenticated user in context keys read by rbac.Service
+func setUser(c echo.Context, u gorsk.AuthUser) {
+	c.Set("id", u.ID)
+	c.Set("company_id", u.CompanyID)
+	c.Set("location_id", u.LocationID)
+	c.Set("username", u.Username)
+	c.Set("email", u.Email)
+	c.Set("role", u.Role)
+	if u.RoleID != 0 {
+		c.Set("role_id", u.RoleID)
+	}
+	if u.ImpersonatorID != 0 {
+		c.Set("impersonator_id", u.ImpersonatorID)
+	}
+}
@@ -1,6 +1,8 @@
 package auth_test
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,7 +12,9 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ribice/gorsk"
+	jwtUtl "github.com/ribice/gorsk/pkg/utl/jwt"
 	"github.com/ribice/gorsk/pkg/utl/middleware/auth"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
 )
 
 func echoHandler(mw ...echo.MiddlewareFunc) *echo.Echo {
@@ -19,6 +23,7 @@ func echoHandler(mw ...echo.MiddlewareFunc) *echo.Echo {
 		e.Use(v)
 	}
 	e.GET("/hello", hwHandler)
+	e.POST("/hello", hwHandler)
 	return e
 }
 
@@ -27,57 +32,305 @@ func hwHandler(c echo.Context) error {
 }
 
 type tokenParser struct {
-	ParseTokenFn func(string) (*jwt.Token, error)
+	claims jwt.Claims
 }
 
 func (t tokenParser) ParseToken(s string) (*jwt.Token, error) {
 	if s == "" {
 		return nil, gorsk.ErrGeneric
 	}
+	claims := t.claims
+	if claims == nil {
+		claims = validClaims("1")
+	}
 	return &jwt.Token{
 		Raw:    "abcd",
 		Method: jwt.SigningMethodHS256,
-		Claims: jwt.MapClaims{
-			"c":   1.0,
-			"e":   "johndoe@mail.com",
-			"exp": 1581773411,
-			"id":  1.0,
-			"l":   1.0,
-			"r":   100.0,
-			"u":   "johndoe",
-		},
-		Valid: true,
+		Claims: claims,
+		Valid:  true,
 	}, nil
 }
 
+func validClaims(sub string) *jwtUtl.Claims {
+	return &jwtUtl.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   sub,
+			Id:        "tokenid",
+			ExpiresAt: 1581773411,
+		},
+		Version:    2,
+		Username:   "johndoe",
+		Email:      "johndoe@mail.com",
+		Role:       gorsk.CompanyAdminRole,
+		CompanyID:  1,
+		LocationID: 1,
+	}
+}
+
+type revoker struct {
+	IsRevokedFn func(echo.Context, string, int, int) (bool, error)
+}
+
+func (r revoker) IsRevoked(c echo.Context, jti string, id, version int) (bool, error) {
+	return r.IsRevokedFn(c, jti, id, version)
+}
+
 func TestMWFunc(t *testing.T) {
 	cases := map[string]struct {
 		wantStatus int
 		header     string
-		signMethod string
+		claims     jwt.Claims
+		revoker    auth.Revoker
 	}{
 		"Empty header": {
 			wantStatus: http.StatusUnauthorized,
 		},
+		"Claims of other type": {
+			header:     "Bearer 123",
+			claims:     jwt.MapClaims{"id": 1.0, "r": "admin"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Invalid subject": {
+			header:     "Bearer 123",
+			claims:     validClaims("johndoe"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Revoked token": {
+			header:     "Bearer 123",
+			wantStatus: http.StatusUnauthorized,
+			revoker: revoker{
+				IsRevokedFn: func(c echo.Context, jti string, id, version int) (bool, error) {
+					return jti == "tokenid" && id == 1 && version == 2, nil
+				},
+			},
+		},
+		"Fail on revocation check": {
+			header:     "Bearer 123",
+			wantStatus: http.StatusInternalServerError,
+			revoker: revoker{
+				IsRevokedFn: func(echo.Context, string, int, int) (bool, error) {
+					return false, gorsk.ErrGeneric
+				},
+			},
+		},
 		"Success": {
 			header:     "Bearer 123",
 			wantStatus: http.StatusOK,
+			revoker: revoker{
+				IsRevokedFn: func(echo.Context, string, int, int) (bool, error) {
+					return false, nil
+				},
+			},
+		},
+		"Success without revoker": {
+			header:     "Bearer 123",
+			wantStatus: http.StatusOK,
+		},
+	}
+	client := &http.Client{}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(echoHandler(auth.Middleware(tokenParser{tt.claims}, tt.revoker, nil, nil)))
+			defer ts.Close()
+			req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
+			req.Header.Set("Authorization", tt.header)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal("Cannot create http request")
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestMWFuncCookies(t *testing.T) {
+	cases := map[string]struct {
+		wantStatus int
+		method     string
+		header     string
+		cookies    map[string]string
+		csrf       string
+	}{
+		"No token": {
+			method:     "GET",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Safe request": {
+			method:     "GET",
+			cookies:    map[string]string{auth.AccessCookie: "123"},
+			wantStatus: http.StatusOK,
+		},
+		"Missing CSRF header": {
+			method:     "POST",
+			cookies:    map[string]string{auth.AccessCookie: "123", auth.CSRFCookie: "csrftoken"},
+			wantStatus: http.StatusForbidden,
+		},
+		"Mismatched CSRF header": {
+			method:     "POST",
+			cookies:    map[string]string{auth.AccessCookie: "123", auth.CSRFCookie: "csrftoken"},
+			csrf:       "othertoken",
+			wantStatus: http.StatusForbidden,
+		},
+		"Authorization header": {
+			method:     "POST",
+			header:     "Bearer 123",
+			cookies:    map[string]string{auth.AccessCookie: "123"},
+			wantStatus: http.StatusOK,
+		},
+		"Success": {
+			method:     "POST",
+			cookies:    map[string]string{auth.AccessCookie: "123", auth.CSRFCookie: "csrftoken"},
+			csrf:       "csrftoken",
+			wantStatus: http.StatusOK,
+		},
+	}
+	cookies, err := auth.NewCookies("", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(echoHandler(auth.Middleware(tokenParser{}, nil, cookies, nil)))
+			defer ts.Close()
+			req, _ := http.NewRequest(tt.method, ts.URL+"/hello", nil)
+			req.Header.Set("Authorization", tt.header)
+			req.Header.Set(auth.CSRFHeader, tt.csrf)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal("Cannot create http request")
+			}
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+type keyAuthenticator struct {
+	AuthenticateFn func(echo.Context, string) (gorsk.AuthUser, error)
+}
+
+func (k keyAuthenticator) Authenticate(c echo.Context, key string) (gorsk.AuthUser, error) {
+	return k.AuthenticateFn(c, key)
+}
+
+func TestMWFuncAPIKey(t *testing.T) {
+	keys := keyAuthenticator{
+		AuthenticateFn: func(c echo.Context, key string) (gorsk.AuthUser, error) {
+			if key != "gsk_valid" {
+				return gorsk.AuthUser{}, echo.ErrUnauthorized
+			}
+			return gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Username: "johndoe", Role: gorsk.UserRole, APIKeyID: 4}, nil
+		},
+	}
+	cases := map[string]struct {
+		wantStatus int
+		key        string
+		header     string
+		keys       auth.KeyAuthenticator
+		wantUser   string
+	}{
+		"Invalid key": {
+			key:        "gsk_invalid",
+			keys:       keys,
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Keys disabled": {
+			key:        "gsk_valid",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Authorization header": {
+			header:     "Bearer 123",
+			keys:       keys,
+			wantStatus: http.StatusOK,
+			wantUser:   "1 1 1 johndoe 120 0\n",
+		},
+		"Success": {
+			key:        "gsk_valid",
+			keys:       keys,
+			wantStatus: http.StatusOK,
+			wantUser:   "1 2 3 johndoe 200 4\n",
 		},
 	}
-	ts := httptest.NewServer(echoHandler(auth.Middleware(tokenParser{})))
-	defer ts.Close()
-	path := ts.URL + "/hello"
 	client := &http.Client{}
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			req, _ := http.NewRequest("GET", path, nil)
+			e := echo.New()
+			e.Use(auth.Middleware(tokenParser{}, nil, nil, tt.keys))
+			e.GET("/hello", func(c echo.Context) error {
+				u := rbac.Service{}.User(c)
+				return c.String(http.StatusOK, fmt.Sprintln(u.ID, u.CompanyID, u.LocationID, u.Username, int(u.Role), u.APIKeyID))
+			})
+			ts := httptest.NewServer(e)
+			defer ts.Close()
+			req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
 			req.Header.Set("Authorization", tt.header)
+			req.Header.Set(auth.APIKeyHeader, tt.key)
 			res, err := client.Do(req)
 			if err != nil {
 				t.Fatal("Cannot create http request")
 			}
+			defer res.Body.Close()
 			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantUser != "" {
+				body, _ := ioutil.ReadAll(res.Body)
+				assert.Equal(t, tt.wantUser, string(body))
+			}
 		})
 	}
 }
+
+func TestMWFuncImpersonation(t *testing.T) {
+	claims := validClaims("2")
+	claims.ImpersonatorID = 1
+
+	e := echo.New()
+	e.Use(auth.Middleware(tokenParser{claims}, nil, nil, nil))
+	e.GET("/hello", func(c echo.Context) error {
+		u := rbac.Service{}.User(c)
+		return c.String(http.StatusOK, fmt.Sprintln(u.ID, u.Username, u.ImpersonatorID))
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
+	req.Header.Set("Authorization", "Bearer 123")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Cannot create http request")
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, "2 johndoe 1\n", string(body))
+}
+
+func TestMWFuncCustomRole(t *testing.T) {
+	claims := validClaims("2")
+	claims.RoleID = 1000
+
+	e := echo.New()
+	e.Use(auth.Middleware(tokenParser{claims}, nil, nil, nil))
+	e.GET("/hello", func(c echo.Context) error {
+		u := rbac.Service{}.User(c)
+		return c.String(http.StatusOK, fmt.Sprintln(u.ID, u.Role, u.RoleID))
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
+	req.Header.Set("Authorization", "Bearer 123")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Cannot create http request")
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, "2 120 1000\n", string(body))
+}
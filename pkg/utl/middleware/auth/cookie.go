@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+)
+
+// Names of cookies and header used in cookie based authentication
+const (
+	AccessCookie  = "access_token"
+	RefreshCookie = "refresh_token"
+	CSRFCookie    = "csrf_token"
+	CSRFHeader    = "X-CSRF-Token"
+)
+
+// ErrInvalidCSRF is returned for state-changing requests authenticated by cookie without matching CSRF token
+var ErrInvalidCSRF = echo.NewHTTPError(http.StatusForbidden, "CSRF token is missing or invalid")
+
+// NewCookies creates new cookie based authentication, for browser clients that must not keep tokens
+// where scripts can read them. SameSite is one of strict, lax or none, and defaults to strict.
+// Refresh token cookie expires after maxAge, or with browser session if maxAge is zero.
+func NewCookies(domain, sameSite string, maxAge time.Duration) (*Cookies, error) {
+	ck := &Cookies{domain: domain, maxAge: maxAge}
+	switch strings.ToLower(sameSite) {
+	case "", "strict":
+		ck.sameSite = http.SameSiteStrictMode
+	case "lax":
+		ck.sameSite = http.SameSiteLaxMode
+	case "none":
+		ck.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("invalid cookie same site mode: %s", sameSite)
+	}
+	return ck, nil
+}
+
+// Cookies keeps access and refresh tokens in HttpOnly cookies, protected from CSRF by double-submit token:
+// state-changing requests must send the value of CSRF cookie in CSRF header.
+type Cookies struct {
+	domain   string
+	sameSite http.SameSite
+	maxAge   time.Duration
+}
+
+// Set sets tokens of t as cookies, along with a new CSRF token. Nothing is set if t holds no access token.
+func (ck *Cookies) Set(c echo.Context, t gorsk.AuthToken) error {
+	if t.Token == "" {
+		return nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	c.SetCookie(ck.cookie(AccessCookie, t.Token, true, 0))
+	c.SetCookie(ck.cookie(RefreshCookie, t.RefreshToken, true, ck.maxAge))
+	c.SetCookie(ck.cookie(CSRFCookie, hex.EncodeToString(b), false, ck.maxAge))
+	return nil
+}
+
+// Clear removes authentication cookies
+func (ck *Cookies) Clear(c echo.Context) {
+	for _, name := range []string{AccessCookie, RefreshCookie, CSRFCookie} {
+		cookie := ck.cookie(name, "", name != CSRFCookie, 0)
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(0, 0)
+		c.SetCookie(cookie)
+	}
+}
+
+// AccessToken returns access token from cookie, or an empty string if it is not set
+func (ck *Cookies) AccessToken(c echo.Context) string {
+	return ck.value(c, AccessCookie)
+}
+
+// RefreshToken returns refresh token from cookie, or an empty string if it is not set
+func (ck *Cookies) RefreshToken(c echo.Context) string {
+	return ck.value(c, RefreshCookie)
+}
+
+// CheckCSRF checks that state-changing request carries CSRF header matching CSRF cookie
+func (ck *Cookies) CheckCSRF(c echo.Context) error {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	token := ck.value(c, CSRFCookie)
+	header := c.Request().Header.Get(CSRFHeader)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(header)) != 1 {
+		return ErrInvalidCSRF
+	}
+	return nil
+}
+
+func (ck *Cookies) cookie(name, value string, httpOnly bool, maxAge time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   ck.domain,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: ck.sameSite,
+	}
+	if maxAge > 0 {
+		cookie.MaxAge = int(maxAge / time.Second)
+		cookie.Expires = time.Now().Add(maxAge)
+	}
+	return cookie
+}
+
+func (ck *Cookies) value(c echo.Context, name string) string {
+	cookie, err := c.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
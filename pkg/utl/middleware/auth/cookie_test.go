@@ -0,0 +1,143 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/middleware/auth"
+)
+
+func TestNewCookies(t *testing.T) {
+	cases := map[string]struct {
+		sameSite string
+		want     http.SameSite
+		wantErr  bool
+	}{
+		"Default": {want: http.SameSiteStrictMode},
+		"Strict":  {sameSite: "Strict", want: http.SameSiteStrictMode},
+		"Lax":     {sameSite: "lax", want: http.SameSiteLaxMode},
+		"None":    {sameSite: "none", want: http.SameSiteNoneMode},
+		"Invalid": {sameSite: "relaxed", wantErr: true},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			ck, err := auth.NewCookies("gorsk.com", tt.sameSite, 0)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err != nil {
+				return
+			}
+			rec := httptest.NewRecorder()
+			if err := ck.Set(echo.New().NewContext(httptest.NewRequest("POST", "/login", nil), rec), gorsk.AuthToken{Token: "a", RefreshToken: "r"}); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range rec.Result().Cookies() {
+				assert.Equal(t, tt.want, c.SameSite)
+			}
+		})
+	}
+}
+
+func TestCookiesSet(t *testing.T) {
+	ck, err := auth.NewCookies("gorsk.com", "strict", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest("POST", "/login", nil), rec)
+	if err := ck.Set(c, gorsk.AuthToken{MFAToken: "mfatoken"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, rec.Result().Cookies())
+
+	rec = httptest.NewRecorder()
+	c = echo.New().NewContext(httptest.NewRequest("POST", "/login", nil), rec)
+	if err := ck.Set(c, gorsk.AuthToken{Token: "accesstoken", RefreshToken: "refreshtoken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+		assert.True(t, c.Secure)
+		assert.Equal(t, "/", c.Path)
+		assert.Equal(t, "gorsk.com", c.Domain)
+		assert.Equal(t, http.SameSiteStrictMode, c.SameSite)
+	}
+	if assert.Len(t, cookies, 3) {
+		assert.Equal(t, "accesstoken", cookies[auth.AccessCookie].Value)
+		assert.True(t, cookies[auth.AccessCookie].HttpOnly)
+		assert.Zero(t, cookies[auth.AccessCookie].MaxAge)
+		assert.Equal(t, "refreshtoken", cookies[auth.RefreshCookie].Value)
+		assert.True(t, cookies[auth.RefreshCookie].HttpOnly)
+		assert.Equal(t, 3600, cookies[auth.RefreshCookie].MaxAge)
+		assert.Len(t, cookies[auth.CSRFCookie].Value, 64)
+		assert.False(t, cookies[auth.CSRFCookie].HttpOnly)
+	}
+
+	rec = httptest.NewRecorder()
+	ck.Clear(echo.New().NewContext(httptest.NewRequest("POST", "/logout", nil), rec))
+	cleared := rec.Result().Cookies()
+	if assert.Len(t, cleared, 3) {
+		for _, c := range cleared {
+			assert.Empty(t, c.Value)
+			assert.Equal(t, -1, c.MaxAge)
+		}
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	cases := map[string]struct {
+		method string
+		cookie string
+		header string
+		want   error
+	}{
+		"Safe method": {
+			method: "GET",
+		},
+		"Missing cookie": {
+			method: "POST",
+			header: "csrftoken",
+			want:   auth.ErrInvalidCSRF,
+		},
+		"Missing header": {
+			method: "DELETE",
+			cookie: "csrftoken",
+			want:   auth.ErrInvalidCSRF,
+		},
+		"Mismatch": {
+			method: "PATCH",
+			cookie: "csrftoken",
+			header: "othertoken",
+			want:   auth.ErrInvalidCSRF,
+		},
+		"Success": {
+			method: "POST",
+			cookie: "csrftoken",
+			header: "csrftoken",
+		},
+	}
+	ck, err := auth.NewCookies("", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: auth.CSRFCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(auth.CSRFHeader, tt.header)
+			}
+			assert.Equal(t, tt.want, ck.CheckCSRF(echo.New().NewContext(req, httptest.NewRecorder())))
+		})
+	}
+}
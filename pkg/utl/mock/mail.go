@@ -0,0 +1,11 @@
+package mock
+
+// Mailer mock
+type Mailer struct {
+	SendFn func(string, string, string) error
+}
+
+// Send mock
+func (m *Mailer) Send(to, subject, body string) error {
+	return m.SendFn(to, subject, body)
+}
@@ -0,0 +1,59 @@
+package mockdb
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// APIKey database mock
+type APIKey struct {
+	CreateFn         func(orm.DB, gorsk.APIKey) (gorsk.APIKey, error)
+	ViewFn           func(orm.DB, int) (gorsk.APIKey, error)
+	ListFn           func(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.APIKey, error)
+	DeleteFn         func(orm.DB, gorsk.APIKey) error
+	FindByHashFn     func(orm.DB, string) (gorsk.APIKey, error)
+	UpdateLastUsedFn func(orm.DB, gorsk.APIKey) error
+	ViewUserFn       func(orm.DB, int) (gorsk.User, error)
+	ViewCompanyFn    func(orm.DB, int) (gorsk.Company, error)
+}
+
+// Create mock
+func (k *APIKey) Create(db orm.DB, key gorsk.APIKey) (gorsk.APIKey, error) {
+	return k.CreateFn(db, key)
+}
+
+// View mock
+func (k *APIKey) View(db orm.DB, id int) (gorsk.APIKey, error) {
+	return k.ViewFn(db, id)
+}
+
+// List mock
+func (k *APIKey) List(db orm.DB, lq *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.APIKey, error) {
+	return k.ListFn(db, lq, p)
+}
+
+// Delete mock
+func (k *APIKey) Delete(db orm.DB, key gorsk.APIKey) error {
+	return k.DeleteFn(db, key)
+}
+
+// FindByHash mock
+func (k *APIKey) FindByHash(db orm.DB, hash string) (gorsk.APIKey, error) {
+	return k.FindByHashFn(db, hash)
+}
+
+// UpdateLastUsed mock
+func (k *APIKey) UpdateLastUsed(db orm.DB, key gorsk.APIKey) error {
+	return k.UpdateLastUsedFn(db, key)
+}
+
+// ViewUser mock
+func (k *APIKey) ViewUser(db orm.DB, id int) (gorsk.User, error) {
+	return k.ViewUserFn(db, id)
+}
+
+// ViewCompany mock
+func (k *APIKey) ViewCompany(db orm.DB, id int) (gorsk.Company, error) {
+	return k.ViewCompanyFn(db, id)
+}
@@ -0,0 +1,47 @@
+package mockdb
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Company database mock
+type Company struct {
+	CreateFn            func(orm.DB, gorsk.Company) (gorsk.Company, error)
+	ViewFn              func(orm.DB, int) (gorsk.Company, error)
+	ListFn              func(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.Company, error)
+	UpdateFn            func(orm.DB, gorsk.Company) error
+	SetMagicLinkLoginFn func(orm.DB, int, bool) error
+	DeactivateFn        func(orm.DB, int) error
+}
+
+// Create mock
+func (cp *Company) Create(db orm.DB, cmp gorsk.Company) (gorsk.Company, error) {
+	return cp.CreateFn(db, cmp)
+}
+
+// View mock
+func (cp *Company) View(db orm.DB, id int) (gorsk.Company, error) {
+	return cp.ViewFn(db, id)
+}
+
+// List mock
+func (cp *Company) List(db orm.DB, lq *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.Company, error) {
+	return cp.ListFn(db, lq, p)
+}
+
+// Update mock
+func (cp *Company) Update(db orm.DB, cmp gorsk.Company) error {
+	return cp.UpdateFn(db, cmp)
+}
+
+// SetMagicLinkLogin mock
+func (cp *Company) SetMagicLinkLogin(db orm.DB, id int, enabled bool) error {
+	return cp.SetMagicLinkLoginFn(db, id, enabled)
+}
+
+// Deactivate mock
+func (cp *Company) Deactivate(db orm.DB, id int) error {
+	return cp.DeactivateFn(db, id)
+}
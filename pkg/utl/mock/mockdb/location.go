@@ -0,0 +1,41 @@
+package mockdb
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Location database mock
+type Location struct {
+	CreateFn func(orm.DB, gorsk.Location) (gorsk.Location, error)
+	ViewFn   func(orm.DB, int, int) (gorsk.Location, error)
+	ListFn   func(orm.DB, int, gorsk.Pagination) ([]gorsk.Location, error)
+	UpdateFn func(orm.DB, gorsk.Location) error
+	DeleteFn func(orm.DB, gorsk.Location) error
+}
+
+// Create mock
+func (l *Location) Create(db orm.DB, loc gorsk.Location) (gorsk.Location, error) {
+	return l.CreateFn(db, loc)
+}
+
+// View mock
+func (l *Location) View(db orm.DB, companyID, id int) (gorsk.Location, error) {
+	return l.ViewFn(db, companyID, id)
+}
+
+// List mock
+func (l *Location) List(db orm.DB, companyID int, p gorsk.Pagination) ([]gorsk.Location, error) {
+	return l.ListFn(db, companyID, p)
+}
+
+// Update mock
+func (l *Location) Update(db orm.DB, loc gorsk.Location) error {
+	return l.UpdateFn(db, loc)
+}
+
+// Delete mock
+func (l *Location) Delete(db orm.DB, loc gorsk.Location) error {
+	return l.DeleteFn(db, loc)
+}
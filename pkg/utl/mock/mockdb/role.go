@@ -0,0 +1,53 @@
+package mockdb
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Role database mock
+type Role struct {
+	CreateFn func(orm.DB, gorsk.Role) (gorsk.Role, error)
+	ViewFn   func(orm.DB, gorsk.AccessRole) (gorsk.Role, error)
+	ListFn   func(orm.DB, gorsk.Pagination) ([]gorsk.Role, error)
+	UpdateFn func(orm.DB, gorsk.Role) error
+	DeleteFn func(orm.DB, gorsk.Role) error
+	InUseFn  func(orm.DB, gorsk.AccessRole) (bool, error)
+	AssignFn func(orm.DB, int, gorsk.AccessRole) error
+}
+
+// Create mock
+func (r *Role) Create(db orm.DB, role gorsk.Role) (gorsk.Role, error) {
+	return r.CreateFn(db, role)
+}
+
+// View mock
+func (r *Role) View(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+	return r.ViewFn(db, id)
+}
+
+// List mock
+func (r *Role) List(db orm.DB, p gorsk.Pagination) ([]gorsk.Role, error) {
+	return r.ListFn(db, p)
+}
+
+// Update mock
+func (r *Role) Update(db orm.DB, role gorsk.Role) error {
+	return r.UpdateFn(db, role)
+}
+
+// Delete mock
+func (r *Role) Delete(db orm.DB, role gorsk.Role) error {
+	return r.DeleteFn(db, role)
+}
+
+// InUse mock
+func (r *Role) InUse(db orm.DB, id gorsk.AccessRole) (bool, error) {
+	return r.InUseFn(db, id)
+}
+
+// Assign mock
+func (r *Role) Assign(db orm.DB, userID int, id gorsk.AccessRole) error {
+	return r.AssignFn(db, userID, id)
+}
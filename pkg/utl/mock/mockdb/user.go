@@ -8,13 +8,35 @@ import (
 
 // User database mock
 type User struct {
-	CreateFn         func(orm.DB, gorsk.User) (gorsk.User, error)
-	ViewFn           func(orm.DB, int) (gorsk.User, error)
-	FindByUsernameFn func(orm.DB, string) (gorsk.User, error)
-	FindByTokenFn    func(orm.DB, string) (gorsk.User, error)
-	ListFn           func(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.User, error)
-	DeleteFn         func(orm.DB, gorsk.User) error
-	UpdateFn         func(orm.DB, gorsk.User) error
+	CreateFn              func(orm.DB, gorsk.User) (gorsk.User, error)
+	ViewFn                func(orm.DB, int) (gorsk.User, error)
+	FindByUsernameFn      func(orm.DB, string) (gorsk.User, error)
+	ListFn                func(orm.DB, *gorsk.ListQuery, gorsk.Pagination) ([]gorsk.User, error)
+	DeleteFn              func(orm.DB, gorsk.User) error
+	UpdateFn              func(orm.DB, gorsk.User) error
+	UpdateLoginAttemptsFn func(orm.DB, gorsk.User) error
+	UpdateMagicLinksFn    func(orm.DB, gorsk.User) error
+	UpdateMFAFn           func(orm.DB, gorsk.User) error
+	CreateTokenFn         func(orm.DB, gorsk.OneTimeToken) error
+	FindTokenFn           func(orm.DB, string, string) (gorsk.OneTimeToken, error)
+	UpdateTokenFn         func(orm.DB, gorsk.OneTimeToken) error
+	DeleteTokenFn         func(orm.DB, int) error
+	DeleteUserTokensFn    func(orm.DB, string, int) error
+	CreateSessionFn       func(orm.DB, gorsk.Session) error
+	FindSessionFn         func(orm.DB, string) (gorsk.Session, error)
+	ListSessionsFn        func(orm.DB, int) ([]gorsk.Session, error)
+	RotateSessionFn       func(orm.DB, string, gorsk.Session) error
+	FindUsedTokenFn       func(orm.DB, string) (gorsk.RefreshToken, error)
+	DeleteSessionFn       func(orm.DB, int, int) error
+	DeleteUserSessionsFn  func(orm.DB, int) error
+	RevokeTokenFn         func(orm.DB, gorsk.RevokedToken) error
+	IsRevokedFn           func(orm.DB, string, int, int) (bool, error)
+	FindByEmailFn         func(orm.DB, string) (gorsk.User, error)
+	UpdateVerificationFn  func(orm.DB, gorsk.User) error
+	ViewCompanyFn         func(orm.DB, int) (gorsk.Company, error)
+	CreateLoginEventFn    func(orm.DB, gorsk.LoginEvent) error
+	KnownLoginFn          func(orm.DB, gorsk.LoginEvent) (bool, error)
+	ListLoginEventsFn     func(orm.DB, int, gorsk.Pagination) ([]gorsk.LoginEvent, error)
 }
 
 // Create mock
@@ -32,11 +54,6 @@ func (u *User) FindByUsername(db orm.DB, uname string) (gorsk.User, error) {
 	return u.FindByUsernameFn(db, uname)
 }
 
-// FindByToken mock
-func (u *User) FindByToken(db orm.DB, token string) (gorsk.User, error) {
-	return u.FindByTokenFn(db, token)
-}
-
 // List mock
 func (u *User) List(db orm.DB, lq *gorsk.ListQuery, p gorsk.Pagination) ([]gorsk.User, error) {
 	return u.ListFn(db, lq, p)
@@ -51,3 +68,118 @@ func (u *User) Delete(db orm.DB, usr gorsk.User) error {
 func (u *User) Update(db orm.DB, usr gorsk.User) error {
 	return u.UpdateFn(db, usr)
 }
+
+// UpdateLoginAttempts mock
+func (u *User) UpdateLoginAttempts(db orm.DB, usr gorsk.User) error {
+	return u.UpdateLoginAttemptsFn(db, usr)
+}
+
+// UpdateMagicLinks mock
+func (u *User) UpdateMagicLinks(db orm.DB, usr gorsk.User) error {
+	return u.UpdateMagicLinksFn(db, usr)
+}
+
+// UpdateMFA mock
+func (u *User) UpdateMFA(db orm.DB, usr gorsk.User) error {
+	return u.UpdateMFAFn(db, usr)
+}
+
+// CreateToken mock
+func (u *User) CreateToken(db orm.DB, token gorsk.OneTimeToken) error {
+	return u.CreateTokenFn(db, token)
+}
+
+// FindToken mock
+func (u *User) FindToken(db orm.DB, purpose, hash string) (gorsk.OneTimeToken, error) {
+	return u.FindTokenFn(db, purpose, hash)
+}
+
+// UpdateToken mock
+func (u *User) UpdateToken(db orm.DB, token gorsk.OneTimeToken) error {
+	return u.UpdateTokenFn(db, token)
+}
+
+// DeleteToken mock
+func (u *User) DeleteToken(db orm.DB, id int) error {
+	return u.DeleteTokenFn(db, id)
+}
+
+// DeleteUserTokens mock
+func (u *User) DeleteUserTokens(db orm.DB, purpose string, userID int) error {
+	return u.DeleteUserTokensFn(db, purpose, userID)
+}
+
+// CreateSession mock
+func (u *User) CreateSession(db orm.DB, session gorsk.Session) error {
+	return u.CreateSessionFn(db, session)
+}
+
+// FindSession mock
+func (u *User) FindSession(db orm.DB, token string) (gorsk.Session, error) {
+	return u.FindSessionFn(db, token)
+}
+
+// ListSessions mock
+func (u *User) ListSessions(db orm.DB, userID int) ([]gorsk.Session, error) {
+	return u.ListSessionsFn(db, userID)
+}
+
+// RotateSession mock
+func (u *User) RotateSession(db orm.DB, token string, session gorsk.Session) error {
+	return u.RotateSessionFn(db, token, session)
+}
+
+// FindUsedToken mock
+func (u *User) FindUsedToken(db orm.DB, token string) (gorsk.RefreshToken, error) {
+	return u.FindUsedTokenFn(db, token)
+}
+
+// DeleteSession mock
+func (u *User) DeleteSession(db orm.DB, userID, id int) error {
+	return u.DeleteSessionFn(db, userID, id)
+}
+
+// DeleteUserSessions mock
+func (u *User) DeleteUserSessions(db orm.DB, userID int) error {
+	return u.DeleteUserSessionsFn(db, userID)
+}
+
+// RevokeToken mock
+func (u *User) RevokeToken(db orm.DB, token gorsk.RevokedToken) error {
+	return u.RevokeTokenFn(db, token)
+}
+
+// IsRevoked mock
+func (u *User) IsRevoked(db orm.DB, jti string, userID, version int) (bool, error) {
+	return u.IsRevokedFn(db, jti, userID, version)
+}
+
+// FindByEmail mock
+func (u *User) FindByEmail(db orm.DB, email string) (gorsk.User, error) {
+	return u.FindByEmailFn(db, email)
+}
+
+// UpdateVerification mock
+func (u *User) UpdateVerification(db orm.DB, usr gorsk.User) error {
+	return u.UpdateVerificationFn(db, usr)
+}
+
+// ViewCompany mock
+func (u *User) ViewCompany(db orm.DB, id int) (gorsk.Company, error) {
+	return u.ViewCompanyFn(db, id)
+}
+
+// CreateLoginEvent mock
+func (u *User) CreateLoginEvent(db orm.DB, event gorsk.LoginEvent) error {
+	return u.CreateLoginEventFn(db, event)
+}
+
+// KnownLogin mock
+func (u *User) KnownLogin(db orm.DB, event gorsk.LoginEvent) (bool, error) {
+	return u.KnownLoginFn(db, event)
+}
+
+// ListLoginEvents mock
+func (u *User) ListLoginEvents(db orm.DB, userID int, p gorsk.Pagination) ([]gorsk.LoginEvent, error) {
+	return u.ListLoginEventsFn(db, userID, p)
+}
@@ -1,15 +1,29 @@
 package mock
 
 import (
+	"time"
+
 	"github.com/ribice/gorsk"
 )
 
 // JWT mock
 type JWT struct {
-	GenerateTokenFn func(gorsk.User) (string, error)
+	GenerateTokenFn              func(gorsk.User) (string, error)
+	GenerateImpersonationTokenFn func(gorsk.User, int, time.Duration) (string, error)
+	JWKSFn                       func() gorsk.JWKS
 }
 
 // GenerateToken mock
 func (j JWT) GenerateToken(u gorsk.User) (string, error) {
 	return j.GenerateTokenFn(u)
 }
+
+// GenerateImpersonationToken mock
+func (j JWT) GenerateImpersonationToken(u gorsk.User, impersonatorID int, ttl time.Duration) (string, error) {
+	return j.GenerateImpersonationTokenFn(u, impersonatorID, ttl)
+}
+
+// JWKS mock
+func (j JWT) JWKS() gorsk.JWKS {
+	return j.JWKSFn()
+}
@@ -0,0 +1,21 @@
+package mock
+
+import (
+	"github.com/ribice/gorsk/pkg/utl/oidc"
+)
+
+// OIDC mock
+type OIDC struct {
+	AuthURLFn  func(string, string, string) (string, error)
+	ExchangeFn func(string, string, string) (oidc.Claims, error)
+}
+
+// AuthURL mock
+func (o *OIDC) AuthURL(state, nonce, verifier string) (string, error) {
+	return o.AuthURLFn(state, nonce, verifier)
+}
+
+// Exchange mock
+func (o *OIDC) Exchange(code, verifier, nonce string) (oidc.Claims, error) {
+	return o.ExchangeFn(code, verifier, nonce)
+}
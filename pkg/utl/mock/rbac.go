@@ -15,6 +15,8 @@ type RBAC struct {
 	EnforceLocationFn func(echo.Context, int) error
 	AccountCreateFn   func(echo.Context, gorsk.AccessRole, int, int) error
 	IsLowerRoleFn     func(echo.Context, gorsk.AccessRole) error
+	CanFn             func(echo.Context, string, gorsk.Resource) error
+	ScopeFn           func(echo.Context, string) (*gorsk.Resource, error)
 }
 
 // User mock
@@ -51,3 +53,13 @@ func (a RBAC) AccountCreate(c echo.Context, roleID gorsk.AccessRole, companyID,
 func (a RBAC) IsLowerRole(c echo.Context, role gorsk.AccessRole) error {
 	return a.IsLowerRoleFn(c, role)
 }
+
+// Can mock
+func (a RBAC) Can(c echo.Context, permission string, r gorsk.Resource) error {
+	return a.CanFn(c, permission, r)
+}
+
+// Scope mock
+func (a RBAC) Scope(c echo.Context, permission string) (*gorsk.Resource, error) {
+	return a.ScopeFn(c, permission)
+}
@@ -5,7 +5,8 @@ type Secure struct {
 	PasswordFn            func(string, ...string) bool
 	HashFn                func(string) string
 	HashMatchesPasswordFn func(string, string) bool
-	TokenFn               func(string) string
+	TokenFn               func() (string, error)
+	HashTokenFn           func(string) string
 }
 
 // Password mock
@@ -24,6 +25,11 @@ func (s *Secure) HashMatchesPassword(hash, pw string) bool {
 }
 
 // Token mock
-func (s *Secure) Token(token string) string {
-	return s.TokenFn(token)
+func (s *Secure) Token() (string, error) {
+	return s.TokenFn()
+}
+
+// HashToken mock
+func (s *Secure) HashToken(token string) string {
+	return s.HashTokenFn(token)
 }
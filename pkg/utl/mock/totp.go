@@ -0,0 +1,29 @@
+package mock
+
+// TOTP mock
+type TOTP struct {
+	GenerateSecretFn func() (string, error)
+	URIFn            func(string, string) string
+	ValidateFn       func(string, string, int64) (int64, bool)
+	RecoveryCodesFn  func(int) ([]string, error)
+}
+
+// GenerateSecret mock
+func (t *TOTP) GenerateSecret() (string, error) {
+	return t.GenerateSecretFn()
+}
+
+// URI mock
+func (t *TOTP) URI(secret, account string) string {
+	return t.URIFn(secret, account)
+}
+
+// Validate mock
+func (t *TOTP) Validate(secret, code string, last int64) (int64, bool) {
+	return t.ValidateFn(secret, code, last)
+}
+
+// RecoveryCodes mock
+func (t *TOTP) RecoveryCodes(n int) ([]string, error) {
+	return t.RecoveryCodesFn(n)
+}
@@ -0,0 +1,326 @@
+// Package oidc implements login through external OpenID Connect identity provider,
+// using authorization code flow with PKCE.
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Custom errors
+var (
+	ErrInvalidIssuer    = errors.New("oidc: issuer of provider configuration does not match")
+	ErrMissingIDToken   = errors.New("oidc: token response does not contain id_token")
+	ErrUnknownKey       = errors.New("oidc: id token is signed by unknown key")
+	ErrInvalidAlgorithm = errors.New("oidc: id token is signed by unsupported algorithm")
+	ErrInvalidClaims    = errors.New("oidc: id token has invalid iss, aud, azp or nonce claim")
+)
+
+// leeway tolerates clock skew between the provider and this service
+const leeway = time.Minute
+
+// keysRefreshInterval limits how often provider's keys are fetched again when a token is signed by unknown key
+const keysRefreshInterval = time.Minute
+
+// Claims represents verified claims of an ID token
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+}
+
+// Valid checks time based claims, allowing for clock skew
+func (c *Claims) Valid() error {
+	now := time.Now()
+	if c.Subject == "" {
+		return errors.New("oidc: id token has no subject")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return errors.New("oidc: id token is expired")
+	}
+	if time.Unix(c.IssuedAt, 0).After(now.Add(leeway)) {
+		return errors.New("oidc: id token is issued in the future")
+	}
+	return nil
+}
+
+// audience is aud claim, which is either a single string or an array of strings
+type audience []string
+
+// UnmarshalJSON decodes both forms of aud claim
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates new OpenID Connect provider client. Provider's configuration is discovered from
+// issuer's /.well-known/openid-configuration on first use. Scopes default to openid, email and profile.
+func New(issuer, clientID, clientSecret, redirectURL string, scopes []string, client *http.Client) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		client:       client,
+	}
+}
+
+// Provider represents OpenID Connect identity provider client
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	client       *http.Client
+
+	mu          sync.Mutex
+	config      *configuration
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// configuration represents provider metadata, as defined by OpenID Connect Discovery
+type configuration struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// AuthURL returns URL of provider's login page the user is redirected to.
+// The verifier is sent only as its S256 challenge, and must be presented again to Exchange.
+func (p *Provider) AuthURL(state, nonce, verifier string) (string, error) {
+	cfg, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(cfg.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return cfg.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// tokenResponse represents response of provider's token endpoint
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange exchanges authorization code for tokens, and returns claims of the verified ID token.
+// The ID token must carry the nonce sent with the authorization request.
+func (p *Provider) Exchange(code, verifier, nonce string) (Claims, error) {
+	cfg, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, cfg.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var tr tokenResponse
+	status, err := p.do(req, &tr)
+	if err != nil {
+		return Claims{}, err
+	}
+	if status != http.StatusOK || tr.Error != "" {
+		return Claims{}, fmt.Errorf("oidc: token request failed with status %d: %s %s", status, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return Claims{}, ErrMissingIDToken
+	}
+
+	return p.verify(tr.IDToken, nonce)
+}
+
+// verify checks ID token's signature and claims
+func (p *Provider) verify(raw, nonce string) (Claims, error) {
+	claims := new(Claims)
+	if _, err := jwt.ParseWithClaims(raw, claims, p.key); err != nil {
+		return Claims{}, err
+	}
+	if claims.Issuer != p.issuer || !claims.Audience.contains(p.clientID) ||
+		(len(claims.Audience) > 1 && claims.AuthorizedParty != p.clientID) ||
+		(claims.AuthorizedParty != "" && claims.AuthorizedParty != p.clientID) ||
+		subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Claims{}, ErrInvalidClaims
+	}
+	return *claims, nil
+}
+
+// key returns provider's public key the token is signed with. Keys are fetched again if the key is unknown,
+// as the provider may have rotated them.
+func (p *Provider) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+	kid, _ := t.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.findKey(kid); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+	if err := p.fetchKeys(); err != nil {
+		return nil, err
+	}
+	if k, ok := p.findKey(kid); ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// findKey looks up key by its id. Token without kid can only be verified if the provider has a single key.
+func (p *Provider) findKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// fetchKeys fetches provider's public keys from its jwks_uri. Must be called with mu held.
+func (p *Provider) fetchKeys() error {
+	req, err := http.NewRequest(http.MethodGet, p.config.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+	var set jwks
+	status, err := p.do(req, &set)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("oidc: fetching keys failed with status %d", status)
+	}
+	keys, err := set.publicKeys()
+	if err != nil {
+		return err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+	return nil
+}
+
+// discover fetches provider's configuration, unless it was already fetched
+func (p *Provider) discover() (*configuration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config != nil {
+		return p.config, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(configuration)
+	status, err := p.do(req, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery failed with status %d", status)
+	}
+	if cfg.Issuer != p.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	p.config = cfg
+	return cfg, nil
+}
+
+// do sends the request and decodes JSON response into v, returning response's status code
+func (p *Provider) do(req *http.Request, v interface{}) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v); err != nil && res.StatusCode == http.StatusOK {
+		return 0, err
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,101 @@
+package oidc_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/oidc"
+)
+
+func TestAuthURL(t *testing.T) {
+	idp := mock.NewIdP("gorsk")
+	defer idp.Close()
+
+	p := oidc.New(idp.URL, "gorsk", "", "https://gorsk.test/callback", nil, nil)
+	authURL, err := p.AuthURL("state", "nonce", "verifier")
+	assert.Nil(t, err)
+
+	u, err := url.Parse(authURL)
+	assert.Nil(t, err)
+	q := u.Query()
+	assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "openid email profile", q.Get("scope"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, "iMnq5o6zALKXGivsnlom_0F5_WYda32GHkxlV7mq7hQ", q.Get("code_challenge"))
+	assert.Empty(t, q.Get("code_verifier"))
+
+	_, err = oidc.New(idp.URL+"/", "gorsk", "", "", nil, nil).AuthURL("state", "nonce", "verifier")
+	assert.Equal(t, oidc.ErrInvalidIssuer, err)
+}
+
+func TestExchange(t *testing.T) {
+	cases := map[string]struct {
+		claims   map[string]interface{}
+		verifier string
+		nonce    string
+		wantErr  bool
+	}{
+		"Invalid code verifier": {
+			verifier: "otherverifier",
+			wantErr:  true,
+		},
+		"Invalid nonce": {
+			nonce:   "othernonce",
+			wantErr: true,
+		},
+		"Invalid audience": {
+			claims:  map[string]interface{}{"aud": "other"},
+			wantErr: true,
+		},
+		"Invalid authorized party": {
+			claims:  map[string]interface{}{"aud": []string{"gorsk", "other"}, "azp": "other"},
+			wantErr: true,
+		},
+		"Invalid issuer": {
+			claims:  map[string]interface{}{"iss": "https://other.test"},
+			wantErr: true,
+		},
+		"Expired token": {
+			claims:  map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: true,
+		},
+		"Success with multiple audiences": {
+			claims: map[string]interface{}{"aud": []string{"gorsk", "other"}, "azp": "gorsk"},
+		},
+		"Success": {},
+	}
+	idp := mock.NewIdP("gorsk")
+	defer idp.Close()
+	p := oidc.New(idp.URL, "gorsk", "secret", "https://gorsk.test/callback", nil, nil)
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			idp.Claims = tt.claims
+			authURL, err := p.AuthURL("state", "nonce", "verifier")
+			assert.Nil(t, err)
+			code, state, err := idp.Authorize(authURL)
+			assert.Nil(t, err)
+			assert.Equal(t, "state", state)
+
+			verifier, nonce := "verifier", "nonce"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			claims, err := p.Exchange(code, verifier, nonce)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, "idp-user-1", claims.Subject)
+				assert.Equal(t, "johndoe@mail.com", claims.Email)
+				assert.True(t, claims.EmailVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package pgsql
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Token represents the client for one_time_tokens table, shared by the services giving out single-use tokens
+type Token struct{}
+
+// CreateToken stores newly given out token. Expired tokens are cleaned up.
+func (t Token) CreateToken(db orm.DB, token gorsk.OneTimeToken) error {
+	if _, err := db.Model((*gorsk.OneTimeToken)(nil)).Where("expires_at < ?", time.Now()).Delete(); err != nil {
+		return err
+	}
+	return db.Insert(&token)
+}
+
+// FindToken queries for single token with given purpose by its hash
+func (t Token) FindToken(db orm.DB, purpose, hash string) (gorsk.OneTimeToken, error) {
+	var token gorsk.OneTimeToken
+	err := db.Model(&token).Where("purpose = ? and token = ?", purpose, hash).Select()
+	return token, err
+}
+
+// UpdateToken updates number of wrong codes entered for the token
+func (t Token) UpdateToken(db orm.DB, token gorsk.OneTimeToken) error {
+	_, err := db.Model(&token).Column("attempts").WherePK().Update()
+	return err
+}
+
+// DeleteToken deletes token once it is used.
+// It returns pg.ErrNoRows if the token was already used.
+func (t Token) DeleteToken(db orm.DB, id int) error {
+	res, err := db.Model((*gorsk.OneTimeToken)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUserTokens deletes all tokens with given purpose given out to a user
+func (t Token) DeleteUserTokens(db orm.DB, purpose string, userID int) error {
+	_, err := db.Model((*gorsk.OneTimeToken)(nil)).Where("purpose = ? and user_id = ?", purpose, userID).Delete()
+	return err
+}
@@ -0,0 +1,66 @@
+package pgsql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/onetime/platform/pgsql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToken(t *testing.T) {
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.OneTimeToken{})
+
+	if err := mock.InsertMultiple(db,
+		&gorsk.OneTimeToken{ID: 1, UserID: 1, Purpose: gorsk.TokenPasswordReset, Token: "expired", ExpiresAt: mock.TestTime(2000)},
+		&gorsk.OneTimeToken{ID: 2, UserID: 1, Purpose: gorsk.TokenPasswordReset, Token: "previous", ExpiresAt: time.Now().Add(time.Hour)},
+		&gorsk.OneTimeToken{ID: 3, UserID: 1, Purpose: gorsk.TokenMagicLink, Token: "link", ExpiresAt: time.Now().Add(time.Hour)},
+		&gorsk.OneTimeToken{ID: 4, UserID: 2, Purpose: gorsk.TokenPasswordReset, Token: "other", ExpiresAt: time.Now().Add(time.Hour)},
+	); err != nil {
+		t.Error(err)
+	}
+
+	tdb := pgsql.Token{}
+
+	assert.Nil(t, tdb.CreateToken(db, gorsk.OneTimeToken{Purpose: gorsk.TokenOIDCLogin, Token: "state",
+		Data: map[string]string{"nonce": "nonce"}, ExpiresAt: time.Now().Add(time.Minute)}))
+
+	_, err := tdb.FindToken(db, gorsk.TokenPasswordReset, "expired")
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	_, err = tdb.FindToken(db, gorsk.TokenPasswordReset, "link")
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	token, err := tdb.FindToken(db, gorsk.TokenOIDCLogin, "state")
+	assert.Nil(t, err)
+	assert.Equal(t, "nonce", token.Data["nonce"])
+
+	token.Attempts = 2
+	assert.Nil(t, tdb.UpdateToken(db, token))
+
+	token, err = tdb.FindToken(db, gorsk.TokenOIDCLogin, "state")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, token.Attempts)
+
+	assert.Nil(t, tdb.DeleteToken(db, token.ID))
+	assert.Equal(t, pg.ErrNoRows, tdb.DeleteToken(db, token.ID))
+
+	assert.Nil(t, tdb.DeleteUserTokens(db, gorsk.TokenPasswordReset, 1))
+
+	_, err = tdb.FindToken(db, gorsk.TokenPasswordReset, "previous")
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	_, err = tdb.FindToken(db, gorsk.TokenMagicLink, "link")
+	assert.Nil(t, err)
+
+	_, err = tdb.FindToken(db, gorsk.TokenPasswordReset, "other")
+	assert.Nil(t, err)
+}
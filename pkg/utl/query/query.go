@@ -1,21 +1,44 @@
 package query
 
 import (
-	"github.com/labstack/echo"
-
 	"github.com/ribice/gorsk"
 )
 
-// List prepares data for list queries
-func List(u gorsk.AuthUser) (*gorsk.ListQuery, error) {
+// List prepares data for user list queries, limited to the scope returned by RBAC service
+func List(sc *gorsk.Resource) *gorsk.ListQuery {
+	switch true {
+	case sc == nil: // user is not limited, e.g. SuperAdmin or Admin
+		return nil
+	case sc.CompanyID != 0:
+		return &gorsk.ListQuery{Query: "company_id = ?", ID: sc.CompanyID}
+	case sc.LocationID != 0:
+		return &gorsk.ListQuery{Query: "location_id = ?", ID: sc.LocationID}
+	default:
+		return &gorsk.ListQuery{Query: `"user"."id" = ?`, ID: sc.UserID}
+	}
+}
+
+// Companies prepares data for company list queries, limited to the scope returned by RBAC service.
+// Users limited to their location or their own data get no companies, as they cannot view a single company either.
+func Companies(sc *gorsk.Resource) *gorsk.ListQuery {
+	switch true {
+	case sc == nil: // user is not limited, e.g. SuperAdmin or Admin
+		return nil
+	default:
+		return &gorsk.ListQuery{Query: "id = ?", ID: sc.CompanyID}
+	}
+}
+
+// APIKeys prepares data for API key list queries, limited to the scope returned by RBAC service
+func APIKeys(sc *gorsk.Resource) *gorsk.ListQuery {
 	switch true {
-	case u.Role <= gorsk.AdminRole: // user is SuperAdmin or Admin
-		return nil, nil
-	case u.Role == gorsk.CompanyAdminRole:
-		return &gorsk.ListQuery{Query: "company_id = ?", ID: u.CompanyID}, nil
-	case u.Role == gorsk.LocationAdminRole:
-		return &gorsk.ListQuery{Query: "location_id = ?", ID: u.LocationID}, nil
+	case sc == nil: // user is not limited, e.g. SuperAdmin or Admin
+		return nil
+	case sc.CompanyID != 0:
+		return &gorsk.ListQuery{Query: "company_id = ?", ID: sc.CompanyID}
+	case sc.LocationID != 0:
+		return &gorsk.ListQuery{Query: "location_id = ?", ID: sc.LocationID}
 	default:
-		return nil, echo.ErrForbidden
+		return &gorsk.ListQuery{Query: "user_id = ?", ID: sc.UserID}
 	}
 }
@@ -3,8 +3,6 @@ package query_test
 import (
 	"testing"
 
-	"github.com/labstack/echo"
-
 	"github.com/ribice/gorsk"
 
 	"github.com/stretchr/testify/assert"
@@ -14,54 +12,123 @@ import (
 
 func TestList(t *testing.T) {
 	type args struct {
-		user gorsk.AuthUser
+		scope *gorsk.Resource
 	}
 	cases := []struct {
 		name     string
 		args     args
 		wantData *gorsk.ListQuery
-		wantErr  error
 	}{
 		{
-			name: "Super admin user",
-			args: args{user: gorsk.AuthUser{
-				Role: gorsk.SuperAdminRole,
-			}},
+			name: "Unlimited user",
 		},
 		{
-			name: "Company admin user",
-			args: args{user: gorsk.AuthUser{
-				Role:      gorsk.CompanyAdminRole,
-				CompanyID: 1,
-			}},
+			name: "Company scope",
+			args: args{scope: &gorsk.Resource{CompanyID: 1}},
 			wantData: &gorsk.ListQuery{
 				Query: "company_id = ?",
 				ID:    1},
 		},
 		{
-			name: "Location admin user",
-			args: args{user: gorsk.AuthUser{
-				Role:       gorsk.LocationAdminRole,
-				CompanyID:  1,
-				LocationID: 2,
-			}},
+			name: "Location scope",
+			args: args{scope: &gorsk.Resource{LocationID: 2}},
 			wantData: &gorsk.ListQuery{
 				Query: "location_id = ?",
 				ID:    2},
 		},
 		{
-			name: "Normal user",
-			args: args{user: gorsk.AuthUser{
-				Role: gorsk.UserRole,
-			}},
-			wantErr: echo.ErrForbidden,
+			name: "Own scope",
+			args: args{scope: &gorsk.Resource{UserID: 5}},
+			wantData: &gorsk.ListQuery{
+				Query: `"user"."id" = ?`,
+				ID:    5},
+		},
+		{
+			name: "Unknown scope",
+			args: args{scope: &gorsk.Resource{}},
+			wantData: &gorsk.ListQuery{
+				Query: `"user"."id" = ?`,
+				ID:    0},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantData, query.List(tt.args.scope))
+		})
+	}
+}
+
+func TestCompanies(t *testing.T) {
+	type args struct {
+		scope *gorsk.Resource
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantData *gorsk.ListQuery
+	}{
+		{
+			name: "Unlimited user",
+		},
+		{
+			name: "Company scope",
+			args: args{scope: &gorsk.Resource{CompanyID: 1}},
+			wantData: &gorsk.ListQuery{
+				Query: "id = ?",
+				ID:    1},
+		},
+		{
+			name: "Location scope",
+			args: args{scope: &gorsk.Resource{LocationID: 2}},
+			wantData: &gorsk.ListQuery{
+				Query: "id = ?",
+				ID:    0},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantData, query.Companies(tt.args.scope))
+		})
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	type args struct {
+		scope *gorsk.Resource
+	}
+	cases := []struct {
+		name     string
+		args     args
+		wantData *gorsk.ListQuery
+	}{
+		{
+			name: "Unlimited user",
+		},
+		{
+			name: "Company scope",
+			args: args{scope: &gorsk.Resource{CompanyID: 1}},
+			wantData: &gorsk.ListQuery{
+				Query: "company_id = ?",
+				ID:    1},
+		},
+		{
+			name: "Location scope",
+			args: args{scope: &gorsk.Resource{LocationID: 2}},
+			wantData: &gorsk.ListQuery{
+				Query: "location_id = ?",
+				ID:    2},
+		},
+		{
+			name: "Own scope",
+			args: args{scope: &gorsk.Resource{UserID: 5}},
+			wantData: &gorsk.ListQuery{
+				Query: "user_id = ?",
+				ID:    5},
 		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			q, err := query.List(tt.args.user)
-			assert.Equal(t, tt.wantData, q)
-			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantData, query.APIKeys(tt.args.scope))
 		})
 	}
 }
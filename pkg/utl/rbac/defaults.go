@@ -0,0 +1,116 @@
+package rbac
+
+// defaultRules authorize permissions granted by access level of user's role when the service has no policy.
+// Access levels are compared by conditions rather than listed as roles, so a custom role between two built-in levels
+// is treated as the less privileged of them, e.g. level 125 as LOCATION_ADMIN.
+// cmd/api/policy.yaml repeats these rules, and adds rules equivalent to the default permissions of the built-in roles,
+// which without a policy are authorized by permissions stored with roles instead.
+const defaultRules = `
+rules:
+  - name: admins manage all companies
+    roles: ["*"]
+    resources: [companies]
+    actions: ["*"]
+    conditions:
+      - user.role <= ADMIN
+
+  - name: company admins update their company
+    roles: ["*"]
+    resources: [companies]
+    actions: [update]
+    conditions:
+      - user.role <= COMPANY_ADMIN
+      - resource.company_id == user.company_id
+
+  - name: admins manage all locations
+    roles: ["*"]
+    resources: [locations]
+    actions: ["*"]
+    conditions:
+      - user.role <= ADMIN
+
+  - name: company admins manage locations of their company
+    roles: ["*"]
+    resources: [locations]
+    actions: [create, view, update, delete]
+    conditions:
+      - user.role <= COMPANY_ADMIN
+      - resource.company_id == user.company_id
+
+  - name: location admins manage their location
+    roles: ["*"]
+    resources: [locations]
+    actions: [view, update]
+    conditions:
+      - user.role <= LOCATION_ADMIN
+      - resource.company_id == user.company_id
+      - resource.location_id == user.location_id
+
+  - name: super admins manage roles
+    roles: ["*"]
+    resources: [roles]
+    actions: [create, view, update, delete, assign]
+    conditions:
+      - user.role <= SUPER_ADMIN
+
+  - name: account managers view roles
+    roles: ["*"]
+    resources: [roles]
+    actions: [view]
+    conditions:
+      - user.role <= LOCATION_ADMIN
+
+  - name: users outrank lower roles
+    roles: ["*"]
+    resources: [roles]
+    actions: [outrank]
+    conditions:
+      - user.role < resource.role
+
+  - name: admins manage all API keys
+    roles: ["*"]
+    resources: [apikeys]
+    actions: ["*"]
+    conditions:
+      - user.role <= ADMIN
+
+  - name: company admins manage API keys of their company
+    roles: ["*"]
+    resources: [apikeys]
+    actions: [create, revoke]
+    conditions:
+      - user.role <= COMPANY_ADMIN
+      - resource.company_id == user.company_id
+
+  - name: location admins revoke API keys of their location
+    roles: ["*"]
+    resources: [apikeys]
+    actions: [revoke]
+    conditions:
+      - user.role <= LOCATION_ADMIN
+      - resource.location_id == user.location_id
+
+  - name: users manage their own API keys
+    roles: ["*"]
+    resources: [apikeys]
+    actions: [create, revoke]
+    conditions:
+      - resource.user_id == user.id
+
+  - name: API keys do not have higher role than their creator
+    effect: deny
+    roles: ["*"]
+    resources: [apikeys]
+    actions: [create]
+    conditions:
+      - user.role > resource.role
+`
+
+// defaultPolicy is the policy of default rules
+var defaultPolicy = func() *Policy {
+	p, err := ParsePolicy([]byte(defaultRules))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
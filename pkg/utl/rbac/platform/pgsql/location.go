@@ -0,0 +1,17 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Location represents the client for location table, used to find out which company a location belongs to
+type Location struct{}
+
+// View returns location of a company with only ID and company ID set
+func (l Location) View(db orm.DB, companyID, id int) (gorsk.Location, error) {
+	var loc gorsk.Location
+	err := db.Model(&loc).Column("id", "company_id").Where("id = ? and company_id = ?", id, companyID).Select()
+	return loc, err
+}
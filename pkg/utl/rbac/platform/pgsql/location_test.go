@@ -0,0 +1,60 @@
+package pgsql_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/rbac/platform/pgsql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationView(t *testing.T) {
+	cases := []struct {
+		name      string
+		companyID int
+		id        int
+		wantData  gorsk.Location
+		wantErr   error
+	}{
+		{
+			name:      "Location does not exist",
+			companyID: 1,
+			id:        10,
+			wantErr:   pg.ErrNoRows,
+		},
+		{
+			name:      "Location of other company",
+			companyID: 1,
+			id:        3,
+			wantErr:   pg.ErrNoRows,
+		},
+		{
+			name:      "Success",
+			companyID: 1,
+			id:        2,
+			wantData:  gorsk.Location{Base: gorsk.Base{ID: 2}, CompanyID: 1},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{}, &gorsk.Role{}, &gorsk.User{})
+	insertScope(t, db)
+
+	ldb := pgsql.Location{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := ldb.View(db, tt.companyID, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.wantData, loc)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// Role represents the client for role table
+type Role struct{}
+
+// View returns single role by ID
+func (r Role) View(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+	role := gorsk.Role{ID: id}
+	err := db.Select(&role)
+	return role, err
+}
@@ -0,0 +1,64 @@
+package pgsql_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/rbac/platform/pgsql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestView(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       gorsk.AccessRole
+		wantData gorsk.Role
+		wantErr  error
+	}{
+		{
+			name:    "Role does not exist",
+			id:      gorsk.AdminRole,
+			wantErr: pg.ErrNoRows,
+		},
+		{
+			name: "Success",
+			id:   gorsk.UserRole,
+			wantData: gorsk.Role{
+				ID:          gorsk.UserRole,
+				AccessLevel: gorsk.UserRole,
+				Name:        "USER",
+				Permissions: []string{gorsk.PermissionUsersView, gorsk.PermissionUsersUpdate},
+			},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Role{})
+
+	if err := mock.InsertMultiple(db, &gorsk.Role{
+		ID:          gorsk.UserRole,
+		AccessLevel: gorsk.UserRole,
+		Name:        "USER",
+		Permissions: []string{gorsk.PermissionUsersView, gorsk.PermissionUsersUpdate},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	rdb := pgsql.Role{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := rdb.View(db, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.wantData, role)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package pgsql
+
+import (
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/ribice/gorsk"
+)
+
+// User represents the client for user table, used to find out which company and location a user belongs to
+type User struct{}
+
+// View returns user with only ID, company ID and location ID set
+func (u User) View(db orm.DB, id int) (gorsk.User, error) {
+	var user gorsk.User
+	err := db.Model(&user).Column("id", "company_id", "location_id").Where("id = ?", id).Select()
+	return user, err
+}
@@ -0,0 +1,140 @@
+package pgsql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
+	"github.com/ribice/gorsk/pkg/utl/rbac/platform/pgsql"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// insertScope inserts two companies, three locations and users of each location, along with built-in roles
+func insertScope(t *testing.T, db *pg.DB) {
+	models := []interface{}{
+		&gorsk.Company{Base: gorsk.Base{ID: 1}, Name: "Acme"},
+		&gorsk.Company{Base: gorsk.Base{ID: 2}, Name: "Globex"},
+		&gorsk.Location{Base: gorsk.Base{ID: 1}, Name: "HQ", CompanyID: 1},
+		&gorsk.Location{Base: gorsk.Base{ID: 2}, Name: "Branch", CompanyID: 1},
+		&gorsk.Location{Base: gorsk.Base{ID: 3}, Name: "HQ", CompanyID: 2},
+	}
+	for id, perms := range gorsk.DefaultPermissions {
+		models = append(models, &gorsk.Role{ID: id, AccessLevel: id, Permissions: perms})
+	}
+	models = append(models,
+		&gorsk.User{Base: gorsk.Base{ID: 1}, Username: "requester", Email: "requester@mail.com", RoleID: gorsk.UserRole, CompanyID: 1, LocationID: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 2}, Username: "samelocation", Email: "samelocation@mail.com", RoleID: gorsk.UserRole, CompanyID: 1, LocationID: 1},
+		&gorsk.User{Base: gorsk.Base{ID: 3}, Username: "samecompany", Email: "samecompany@mail.com", RoleID: gorsk.UserRole, CompanyID: 1, LocationID: 2},
+		&gorsk.User{Base: gorsk.Base{ID: 4}, Username: "othercompany", Email: "othercompany@mail.com", RoleID: gorsk.UserRole, CompanyID: 2, LocationID: 3},
+		&gorsk.User{Base: gorsk.Base{ID: 5, DeletedAt: time.Now()}, Username: "deleted", Email: "deleted@mail.com", RoleID: gorsk.UserRole, CompanyID: 1, LocationID: 1},
+	)
+	if err := mock.InsertMultiple(db, models...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserView(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       int
+		wantData gorsk.User
+		wantErr  error
+	}{
+		{
+			name:    "User does not exist",
+			id:      10,
+			wantErr: pg.ErrNoRows,
+		},
+		{
+			name:    "User is deleted",
+			id:      5,
+			wantErr: pg.ErrNoRows,
+		},
+		{
+			name:     "Success",
+			id:       3,
+			wantData: gorsk.User{Base: gorsk.Base{ID: 3}, CompanyID: 1, LocationID: 2},
+		},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{}, &gorsk.Role{}, &gorsk.User{})
+	insertScope(t, db)
+
+	udb := pgsql.User{}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := udb.View(db, tt.id)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.wantData, user)
+			}
+		})
+	}
+}
+
+// TestCanViewUser checks access of a user of company 1 and location 1, with each of the built-in roles, to every kind of user,
+// by the default permissions and by the example policy
+func TestCanViewUser(t *testing.T) {
+	targets := []struct {
+		name string
+		id   int
+	}{
+		{"self", 1},
+		{"user of the location", 2},
+		{"user of other location of the company", 3},
+		{"user of other company", 4},
+		{"deleted user of the location", 5},
+		{"missing user", 10},
+	}
+	names := map[gorsk.AccessRole]string{
+		gorsk.SuperAdminRole:    "Super admin",
+		gorsk.AdminRole:         "Admin",
+		gorsk.CompanyAdminRole:  "Company admin",
+		gorsk.LocationAdminRole: "Location admin",
+		gorsk.UserRole:          "User",
+	}
+	allowed := map[gorsk.AccessRole][]bool{
+		gorsk.SuperAdminRole:    {true, true, true, true, true, true},
+		gorsk.AdminRole:         {true, true, true, true, true, true},
+		gorsk.CompanyAdminRole:  {true, true, true, false, false, false},
+		gorsk.LocationAdminRole: {true, true, false, false, false, false},
+		gorsk.UserRole:          {true, false, false, false, false, false},
+	}
+
+	dbCon := mock.NewPGContainer(t)
+	defer dbCon.Shutdown()
+
+	db := mock.NewDB(t, dbCon, &gorsk.Company{}, &gorsk.Location{}, &gorsk.Role{}, &gorsk.User{})
+	insertScope(t, db)
+
+	policy, err := rbac.LoadPolicy("../../../../../cmd/api/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rbacSvc := rbac.New(db, pgsql.Role{}, pgsql.User{}, pgsql.Location{}, nil)
+	policySvc := rbac.New(db, pgsql.Role{}, pgsql.User{}, pgsql.Location{}, policy)
+
+	for role, want := range allowed {
+		for i, target := range targets {
+			t.Run(names[role]+" accessing "+target.name, func(t *testing.T) {
+				c := mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 1, 1, role)
+				var wantErr error
+				if !want[i] {
+					wantErr = echo.ErrForbidden
+				}
+				assert.Equal(t, wantErr, rbacSvc.Can(c, gorsk.PermissionUsersView, gorsk.Resource{UserID: target.id}))
+				assert.Equal(t, wantErr, policySvc.Can(c, gorsk.PermissionUsersView, gorsk.Resource{UserID: target.id}))
+			})
+		}
+	}
+}
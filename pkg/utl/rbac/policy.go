@@ -0,0 +1,369 @@
+package rbac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/ribice/gorsk"
+)
+
+// Effects of policy rules
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// roleNames maps names of built-in roles, usable in policy rules, to their access levels
+var roleNames = map[string]gorsk.AccessRole{
+	"SUPER_ADMIN":    gorsk.SuperAdminRole,
+	"ADMIN":          gorsk.AdminRole,
+	"COMPANY_ADMIN":  gorsk.CompanyAdminRole,
+	"LOCATION_ADMIN": gorsk.LocationAdminRole,
+	"USER":           gorsk.UserRole,
+}
+
+// attributes holds attributes of the user and of the resource which can be compared by policy conditions.
+// Resource attributes with zero value are not known for the request.
+var attributes = map[string]func(gorsk.AuthUser, gorsk.Resource) int{
+	"user.id":              func(u gorsk.AuthUser, r gorsk.Resource) int { return u.ID },
+	"user.company_id":      func(u gorsk.AuthUser, r gorsk.Resource) int { return u.CompanyID },
+	"user.location_id":     func(u gorsk.AuthUser, r gorsk.Resource) int { return u.LocationID },
+	"user.role":            func(u gorsk.AuthUser, r gorsk.Resource) int { return int(u.Role) },
+	"resource.user_id":     func(u gorsk.AuthUser, r gorsk.Resource) int { return r.UserID },
+	"resource.company_id":  func(u gorsk.AuthUser, r gorsk.Resource) int { return r.CompanyID },
+	"resource.location_id": func(u gorsk.AuthUser, r gorsk.Resource) int { return r.LocationID },
+	"resource.role":        func(u gorsk.AuthUser, r gorsk.Resource) int { return int(r.Role) },
+}
+
+var operators = map[string]func(a, b int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+// Policy is a declarative set of authorization rules, loaded from a YAML or JSON file so that the rules
+// can be reviewed without reading code. Policy is safe for concurrent use and can be reloaded without restart.
+//
+// A request is denied if any deny rule applies to it. Otherwise it is allowed if any allow rule applies to it,
+// and denied if no rule does.
+type Policy struct {
+	path  string
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// Rule applies to requests by users with given roles, performing given actions on given resource types, for which all of its conditions hold.
+//
+// Roles are names of built-in roles, such as COMPANY_ADMIN, or access levels, and are matched against access level of user's role.
+// Actions and resource types are the two parts of a permission, e.g. users:view is action view on resource type users.
+// "*" matches any role, action or resource type.
+//
+// Conditions compare attributes of the user (user.id, user.company_id, user.location_id, user.role) and of the resource
+// (resource.user_id, resource.company_id, resource.location_id, resource.role) to each other, to role names or to numbers,
+// using ==, !=, <, <=, > or >=, e.g. resource.company_id == user.company_id. Conditions on resource attributes
+// not known for the request do not hold for allow rules, and hold for deny rules, so that neither grants more than the policy says.
+type Rule struct {
+	Name       string   `yaml:"name,omitempty"`
+	Effect     string   `yaml:"effect,omitempty"`
+	Roles      []string `yaml:"roles"`
+	Actions    []string `yaml:"actions"`
+	Resources  []string `yaml:"resources"`
+	Conditions []string `yaml:"conditions,omitempty"`
+
+	levels     []gorsk.AccessRole
+	conditions []condition
+}
+
+// Decision is the outcome of evaluating policy for a request. Rule is the name of the rule that decided it,
+// empty if no rule applied, and Trace explains for every rule whether it applied to the request and why.
+type Decision struct {
+	Allowed bool
+	Rule    string
+	Trace   []string
+}
+
+func (d Decision) String() string {
+	switch {
+	case d.Rule == "":
+		return "denied, as no rule applies"
+	case d.Allowed:
+		return fmt.Sprintf("allowed by rule %q", d.Rule)
+	default:
+		return fmt.Sprintf("denied by rule %q", d.Rule)
+	}
+}
+
+// LoadPolicy loads policy from YAML or JSON file at path
+func LoadPolicy(path string) (*Policy, error) {
+	p := &Policy{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePolicy parses policy from YAML or JSON data
+func ParsePolicy(data []byte) (*Policy, error) {
+	rules, err := parseRules(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{rules: rules}, nil
+}
+
+// Reload reloads policy from its file. If the file cannot be loaded, the previous rules are kept.
+func (p *Policy) Reload() error {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("error reading policy file, %s", err)
+	}
+	rules, err := parseRules(data)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// ReloadOnSignal reloads policy every time the process receives one of the signals, e.g. SIGHUP,
+// and passes the outcome of each reload to done
+func (p *Policy) ReloadOnSignal(done func(error), sig ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		for range ch {
+			done(p.Reload())
+		}
+	}()
+}
+
+// Evaluate decides whether the user may perform the action given by permission on the resource
+func (p *Policy) Evaluate(u gorsk.AuthUser, permission string, r gorsk.Resource) Decision {
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+
+	resource, action := splitPermission(permission)
+	var d Decision
+	var allow, deny string
+	for _, rule := range rules {
+		applies, reason := rule.applies(u, resource, action, r)
+		d.Trace = append(d.Trace, fmt.Sprintf("%s: %s", rule.Name, reason))
+		switch {
+		case !applies:
+		case rule.Effect == EffectDeny && deny == "":
+			deny = rule.Name
+		case rule.Effect == EffectAllow && allow == "":
+			allow = rule.Name
+		}
+	}
+	switch {
+	case deny != "":
+		d.Rule = deny
+	case allow != "":
+		d.Allowed, d.Rule = true, allow
+	}
+	return d
+}
+
+func (rule Rule) applies(u gorsk.AuthUser, resource, action string, r gorsk.Resource) (bool, string) {
+	if !rule.hasRole(u.Role) {
+		return false, fmt.Sprintf("role %d is not one of %s", u.Role, strings.Join(rule.Roles, ", "))
+	}
+	if !contains(rule.Resources, resource) {
+		return false, fmt.Sprintf("resource type %s is not one of %s", resource, strings.Join(rule.Resources, ", "))
+	}
+	if !contains(rule.Actions, action) {
+		return false, fmt.Sprintf("action %s is not one of %s", action, strings.Join(rule.Actions, ", "))
+	}
+	for _, c := range rule.conditions {
+		if holds, reason := c.holds(u, r, rule.Effect == EffectDeny); !holds {
+			return false, reason
+		}
+	}
+	return true, "applies, " + rule.Effect
+}
+
+func (rule Rule) hasRole(level gorsk.AccessRole) bool {
+	if contains(rule.Roles, "*") {
+		return true
+	}
+	for _, l := range rule.levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == "*" || v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPermission(permission string) (resource, action string) {
+	parts := strings.SplitN(permission, ":", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func parseRules(data []byte) ([]Rule, error) {
+	var doc struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+		return nil, fmt.Errorf("unable to decode policy, %v", err)
+	}
+	if len(doc.Rules) == 0 {
+		return nil, fmt.Errorf("policy has no rules")
+	}
+	resources, actions := map[string]bool{"*": true}, map[string]bool{"*": true}
+	for _, permissions := range [][]string{gorsk.Permissions, gorsk.LevelPermissions} {
+		for _, p := range permissions {
+			resource, action := splitPermission(p)
+			resources[resource], actions[action] = true, true
+		}
+	}
+	for i := range doc.Rules {
+		if err := doc.Rules[i].compile(i, resources, actions); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Rules, nil
+}
+
+func (rule *Rule) compile(i int, resources, actions map[string]bool) error {
+	if rule.Name == "" {
+		rule.Name = fmt.Sprintf("rule %d", i+1)
+	}
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid policy rule %q, %s", rule.Name, fmt.Sprintf(format, args...))
+	}
+	switch rule.Effect {
+	case "":
+		rule.Effect = EffectAllow
+	case EffectAllow, EffectDeny:
+	default:
+		return fail("unknown effect %s", rule.Effect)
+	}
+	if len(rule.Roles) == 0 || len(rule.Resources) == 0 || len(rule.Actions) == 0 {
+		return fail("roles, resources and actions are required")
+	}
+	for _, r := range rule.Roles {
+		if r == "*" {
+			continue
+		}
+		level, ok := ParseRole(r)
+		if !ok || level < gorsk.SuperAdminRole || level > gorsk.UserRole {
+			return fail("unknown role %s", r)
+		}
+		rule.levels = append(rule.levels, level)
+	}
+	for _, r := range rule.Resources {
+		if !resources[r] {
+			return fail("unknown resource type %s", r)
+		}
+	}
+	for _, a := range rule.Actions {
+		if !actions[a] {
+			return fail("unknown action %s", a)
+		}
+	}
+	for _, expr := range rule.Conditions {
+		c, err := parseCondition(expr)
+		if err != nil {
+			return fail("%v", err)
+		}
+		rule.conditions = append(rule.conditions, c)
+	}
+	return nil
+}
+
+// ParseRole returns access level given by name of a built-in role, such as COMPANY_ADMIN, or by number
+func ParseRole(s string) (gorsk.AccessRole, bool) {
+	if level, ok := roleNames[s]; ok {
+		return level, true
+	}
+	level, err := strconv.Atoi(s)
+	return gorsk.AccessRole(level), err == nil
+}
+
+// condition compares two operands, each being either an attribute or a constant
+type condition struct {
+	expr        string
+	left, right operand
+	op          string
+}
+
+type operand struct {
+	attr  string
+	value int
+}
+
+func parseCondition(expr string) (condition, error) {
+	f := strings.Fields(expr)
+	if len(f) != 3 {
+		return condition{}, fmt.Errorf("condition %q is not in form <operand> <operator> <operand>", expr)
+	}
+	if _, ok := operators[f[1]]; !ok {
+		return condition{}, fmt.Errorf("unknown operator %s in condition %q", f[1], expr)
+	}
+	c := condition{expr: strings.Join(f, " "), op: f[1]}
+	for i, s := range []string{f[0], f[2]} {
+		var o operand
+		if _, ok := attributes[s]; ok {
+			o.attr = s
+		} else if level, ok := ParseRole(s); ok {
+			o.value = int(level)
+		} else {
+			return condition{}, fmt.Errorf("unknown operand %s in condition %q", s, expr)
+		}
+		if i == 0 {
+			c.left = o
+		} else {
+			c.right = o
+		}
+	}
+	return c, nil
+}
+
+// holds evaluates the condition. If it compares a resource attribute not known for the request,
+// it holds only if unknown is true, as it is for conditions of deny rules.
+func (c condition) holds(u gorsk.AuthUser, r gorsk.Resource, unknown bool) (bool, string) {
+	var values [2]int
+	for i, o := range []operand{c.left, c.right} {
+		values[i] = o.value
+		if o.attr == "" {
+			continue
+		}
+		values[i] = attributes[o.attr](u, r)
+		if values[i] == 0 && strings.HasPrefix(o.attr, "resource.") {
+			if unknown {
+				return true, ""
+			}
+			return false, fmt.Sprintf("condition %s does not hold, as %s is not known", c.expr, o.attr)
+		}
+	}
+	if !operators[c.op](values[0], values[1]) {
+		return false, fmt.Sprintf("condition %s does not hold (%d %s %d)", c.expr, values[0], c.op, values[1])
+	}
+	return true, ""
+}
@@ -0,0 +1,431 @@
+package rbac_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/labstack/echo"
+
+	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
+	"github.com/ribice/gorsk/pkg/utl/rbac"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name:    "Fail on invalid YAML",
+			data:    "rules: [",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown field",
+			data:    "rules: [{roles: [USER], resources: [users], actions: [view], subjects: [USER]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on no rules",
+			data:    "rules: []",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown effect",
+			data:    "rules: [{effect: permit, roles: [USER], resources: [users], actions: [view]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on missing actions",
+			data:    "rules: [{roles: [USER], resources: [users]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown role",
+			data:    "rules: [{roles: [AUDITOR], resources: [users], actions: [view]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on access level out of range",
+			data:    "rules: [{roles: [300], resources: [users], actions: [view]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown resource type",
+			data:    "rules: [{roles: [USER], resources: [invoices], actions: [view]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown action",
+			data:    "rules: [{roles: [USER], resources: [users], actions: [destroy]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on malformed condition",
+			data:    "rules: [{roles: [USER], resources: [users], actions: [view], conditions: [resource.user_id==user.id]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown operator",
+			data:    "rules: [{roles: [USER], resources: [users], actions: [view], conditions: [resource.user_id = user.id]}]",
+			wantErr: true,
+		},
+		{
+			name:    "Fail on unknown operand",
+			data:    "rules: [{roles: [USER], resources: [users], actions: [view], conditions: [resource.owner_id == user.id]}]",
+			wantErr: true,
+		},
+		{
+			name: "Success with YAML",
+			data: "rules:\n  - roles: [USER, 150]\n    resources: [users]\n    actions: [view]\n    conditions: [resource.user_id == user.id, user.role >= COMPANY_ADMIN]",
+		},
+		{
+			name: "Success with JSON",
+			data: `{"rules": [{"name": "auditors", "effect": "allow", "roles": [150], "resources": ["*"], "actions": ["view"]}]}`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := rbac.ParsePolicy([]byte(tt.data))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantErr, p == nil)
+		})
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := rbac.LoadPolicy("../../../cmd/api/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := func(role gorsk.AccessRole) gorsk.AuthUser {
+		return gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Role: role}
+	}
+	cases := []struct {
+		name       string
+		user       gorsk.AuthUser
+		permission string
+		resource   gorsk.Resource
+		wantData   rbac.Decision
+	}{
+		{
+			name:       "Admin impersonating user of other company",
+			user:       user(gorsk.AdminRole),
+			permission: gorsk.PermissionUsersImpersonate,
+			resource:   gorsk.Resource{CompanyID: 7, LocationID: 8},
+			wantData:   rbac.Decision{Allowed: true, Rule: "admins manage all users"},
+		},
+		{
+			name:       "Company admin viewing user of the company",
+			user:       user(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 4},
+			wantData:   rbac.Decision{Allowed: true, Rule: "company admins manage users of their company"},
+		},
+		{
+			name:       "Company admin viewing user of other company",
+			user:       user(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 7, LocationID: 8},
+		},
+		{
+			name:       "Company admin impersonating user of the company",
+			user:       user(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersImpersonate,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 3},
+			wantData:   rbac.Decision{Rule: "only admins impersonate"},
+		},
+		{
+			name:       "Location admin unlocking user of other location",
+			user:       user(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUnlock,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 4},
+		},
+		{
+			name:       "User viewing own login history",
+			user:       user(gorsk.UserRole),
+			permission: gorsk.PermissionLoginsView,
+			resource:   gorsk.Resource{UserID: 1},
+			wantData:   rbac.Decision{Allowed: true, Rule: "users manage their own account"},
+		},
+		{
+			name:       "User creating user in own location",
+			user:       user(gorsk.UserRole),
+			permission: gorsk.PermissionUsersCreate,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "Custom role viewing own account",
+			user:       user(150),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 1},
+			wantData:   rbac.Decision{Allowed: true, Rule: "users manage their own account"},
+		},
+		{
+			name:       "Company admin updating the company",
+			user:       user(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionCompaniesUpdate,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantData:   rbac.Decision{Allowed: true, Rule: "company admins view and update their company"},
+		},
+		{
+			name:       "Location admin viewing location of other company",
+			user:       user(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsView,
+			resource:   gorsk.Resource{CompanyID: 7, LocationID: 3},
+		},
+		{
+			name:       "Admin creating role",
+			user:       user(gorsk.AdminRole),
+			permission: gorsk.PermissionRolesCreate,
+		},
+		{
+			name:       "Location admin outranking user",
+			user:       user(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionRolesOutrank,
+			resource:   gorsk.Resource{Role: gorsk.UserRole},
+			wantData:   rbac.Decision{Allowed: true, Rule: "users outrank lower roles"},
+		},
+		{
+			name:       "Company admin creating company API key of admin role",
+			user:       user(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{CompanyID: 2, Role: gorsk.AdminRole},
+			wantData:   rbac.Decision{Rule: "API keys do not have higher role than their creator"},
+		},
+		{
+			name:       "Admin creating own API key of unknown role",
+			user:       user(gorsk.AdminRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{UserID: 1},
+			wantData:   rbac.Decision{Rule: "API keys do not have higher role than their creator"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := policy.Evaluate(tt.user, tt.permission, tt.resource)
+			assert.Equal(t, tt.wantData.Allowed, d.Allowed)
+			assert.Equal(t, tt.wantData.Rule, d.Rule)
+			assert.Len(t, d.Trace, 19)
+		})
+	}
+}
+
+func TestPolicyTrace(t *testing.T) {
+	policy, err := rbac.ParsePolicy([]byte(`
+rules:
+  - name: company admins view users of their company
+    roles: [COMPANY_ADMIN]
+    resources: [users]
+    actions: [view]
+    conditions:
+      - resource.company_id == user.company_id
+  - roles: [USER]
+    resources: [users]
+    actions: [view]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := gorsk.AuthUser{ID: 1, CompanyID: 2, LocationID: 3, Role: gorsk.CompanyAdminRole}
+
+	d := policy.Evaluate(u, gorsk.PermissionUsersView, gorsk.Resource{UserID: 5})
+	assert.Equal(t, rbac.Decision{Trace: []string{
+		"company admins view users of their company: condition resource.company_id == user.company_id does not hold, as resource.company_id is not known",
+		"rule 2: role 120 is not one of USER",
+	}}, d)
+	assert.Equal(t, "denied, as no rule applies", d.String())
+
+	d = policy.Evaluate(u, gorsk.PermissionUsersView, gorsk.Resource{CompanyID: 7})
+	assert.Equal(t, "company admins view users of their company: condition resource.company_id == user.company_id does not hold (7 == 2)", d.Trace[0])
+
+	d = policy.Evaluate(u, gorsk.PermissionUsersView, gorsk.Resource{CompanyID: 2})
+	assert.Equal(t, "company admins view users of their company: applies, allow", d.Trace[0])
+	assert.Equal(t, `allowed by rule "company admins view users of their company"`, d.String())
+}
+
+func TestPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := gorsk.AuthUser{ID: 1, Role: gorsk.UserRole}
+	r := gorsk.Resource{UserID: 1}
+
+	_, err := rbac.LoadPolicy(path)
+	assert.NotNil(t, err)
+
+	write("rules: [{roles: [USER], resources: [users], actions: [view]}]")
+	policy, err := rbac.LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, policy.Evaluate(u, gorsk.PermissionUsersView, r).Allowed)
+
+	write("rules: [{effect: deny, roles: [USER], resources: [users], actions: [view]}]")
+	assert.Nil(t, policy.Reload())
+	assert.False(t, policy.Evaluate(u, gorsk.PermissionUsersView, r).Allowed)
+
+	// invalid policy keeps the previous rules
+	write("rules: [{roles: [USER], resources: [users], actions: [destroy]}]")
+	assert.NotNil(t, policy.Reload())
+	assert.Equal(t, "rule 1", policy.Evaluate(u, gorsk.PermissionUsersView, r).Rule)
+}
+
+func TestCanPolicy(t *testing.T) {
+	policy, err := rbac.ParsePolicy([]byte(`
+rules:
+  - roles: [LOCATION_ADMIN]
+    resources: [users]
+    actions: [view, update]
+    conditions: [resource.location_id == user.location_id]
+  - roles: ["*"]
+    resources: [users]
+    actions: [view]
+    conditions: [resource.user_id == user.id]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	udb := &mockdb.User{
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			switch id {
+			case 5:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 2, LocationID: 3}, nil
+			case 6:
+				return gorsk.User{}, gorsk.ErrGeneric
+			}
+			return gorsk.User{}, pg.ErrNoRows
+		},
+	}
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, role)
+	}
+	cases := []struct {
+		name       string
+		ctx        echo.Context
+		permission string
+		resource   gorsk.Resource
+		wantErr    error
+	}{
+		{
+			name:       "User viewing own account",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 1},
+		},
+		{
+			name:       "User updating own account",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersUpdate,
+			resource:   gorsk.Resource{UserID: 1},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin updating user of the location by ID",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUpdate,
+			resource:   gorsk.Resource{UserID: 5},
+		},
+		{
+			name:       "Location admin updating missing user by ID",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUpdate,
+			resource:   gorsk.Resource{UserID: 7},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Fail on user repository",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUpdate,
+			resource:   gorsk.Resource{UserID: 6},
+			wantErr:    gorsk.ErrGeneric,
+		},
+		{
+			name:       "Admin viewing company not covered by policy",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionCompaniesView,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantErr:    echo.ErrForbidden,
+		},
+	}
+	rbacSvc := rbac.New(nil, nil, udb, nil, policy)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, rbacSvc.Can(tt.ctx, tt.permission, tt.resource))
+		})
+	}
+}
+
+func TestShippedPolicy(t *testing.T) {
+	policy, err := rbac.LoadPolicy("../../../cmd/api/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// custom roles have the permissions of the less privileged of the built-in roles around their access level
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			level := id
+			for ; gorsk.DefaultPermissions[level] == nil; level++ {
+			}
+			return gorsk.Role{ID: id, AccessLevel: id, Permissions: gorsk.DefaultPermissions[level]}, nil
+		},
+	}
+	udb := &mockdb.User{
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			switch id {
+			case 2:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 1, LocationID: 1}, nil
+			case 4:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 2, LocationID: 3}, nil
+			}
+			return gorsk.User{}, pg.ErrNoRows
+		},
+	}
+	// location 1 belongs to company 1, and location 3 to company 2
+	var resources []gorsk.Resource
+	for _, userID := range []int{0, 1, 2, 4} {
+		for _, place := range [][2]int{{0, 0}, {1, 0}, {1, 1}, {2, 0}, {2, 3}} {
+			for _, role := range []gorsk.AccessRole{0, gorsk.CompanyAdminRole, gorsk.LocationAdminRole, gorsk.UserRole} {
+				resources = append(resources, gorsk.Resource{UserID: userID, CompanyID: place[0], LocationID: place[1], Role: role})
+			}
+		}
+	}
+	byRoles := rbac.New(nil, rdb, udb, nil, nil)
+	byPolicy := rbac.New(nil, rdb, udb, nil, policy)
+	for _, level := range []gorsk.AccessRole{100, 110, 115, 120, 125, 130, 150, 200} {
+		ctx := mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 1, 1, level)
+		for _, permission := range append(append([]string{}, gorsk.Permissions...), gorsk.LevelPermissions...) {
+			wantScope, wantErr := byRoles.Scope(ctx, permission)
+			sc, err := byPolicy.Scope(ctx, permission)
+			assert.Equal(t, wantScope, sc, "scope of %s at level %d", permission, level)
+			assert.Equal(t, wantErr, err, "scope of %s at level %d", permission, level)
+			for _, r := range resources {
+				if !isUserResource(permission) && r.UserID != 0 {
+					continue
+				}
+				assert.Equal(t, byRoles.Can(ctx, permission, r), byPolicy.Can(ctx, permission, r), "%s at level %d on %+v", permission, level, r)
+			}
+		}
+	}
+}
+
+// isUserResource reports whether resources of the permission can belong to a user
+func isUserResource(permission string) bool {
+	for _, prefix := range []string{"users:", "passwords:", "logins:", "apikeys:", "roles:"} {
+		if len(permission) > len(prefix) && permission[:len(prefix)] == prefix {
+			return true
+		}
+	}
+	return false
+}
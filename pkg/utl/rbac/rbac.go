@@ -1,13 +1,66 @@
 package rbac
 
 import (
+	"errors"
+	"net/http"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 
 	"github.com/ribice/gorsk"
+	"github.com/ribice/gorsk/pkg/utl/rbac/platform/pgsql"
 )
 
-// Service is RBAC application service
-type Service struct{}
+// ErrUnknownRole is returned when role of user being created or changed does not exist
+var ErrUnknownRole = echo.NewHTTPError(http.StatusBadRequest, "Role does not exist")
+
+// ErrLocationNotInCompany is returned when location of user being created does not belong to user's company
+var ErrLocationNotInCompany = echo.NewHTTPError(http.StatusBadRequest, "Location does not belong to the company")
+
+// ErrNoPolicy is returned when explaining a decision of the service which authorizes requests without policy
+var ErrNoPolicy = errors.New("RBAC service has no policy")
+
+// New creates new RBAC application service, authorizing requests by permissions assigned to roles in the database.
+// Company and location of users accessed by company and location admins are loaded by udb,
+// and locations are checked to belong to companies by ldb. If policy is not nil, it replaces permissions of roles.
+func New(db *pg.DB, rdb RoleDB, udb UserDB, ldb LocationDB, policy *Policy) Service {
+	return Service{db: db, rdb: rdb, udb: udb, ldb: ldb, policy: policy}
+}
+
+// Initialize initalizes RBAC application service with defaults, authorizing by policy if it is not nil
+func Initialize(db *pg.DB, policy *Policy) Service {
+	return New(db, pgsql.Role{}, pgsql.User{}, pgsql.Location{}, policy)
+}
+
+// Service is RBAC application service.
+// Zero value of Service authorizes requests by default permission sets of the built-in roles,
+// limits company and location admins to the users whose company and location are given by the request,
+// and treats no location as belonging to a company.
+// Permissions granted by access level are authorized by the default rules unless the service has a policy.
+type Service struct {
+	db  *pg.DB
+	rdb RoleDB
+	udb UserDB
+	ldb LocationDB
+
+	policy *Policy
+}
+
+// RoleDB represents role repository interface
+type RoleDB interface {
+	View(orm.DB, gorsk.AccessRole) (gorsk.Role, error)
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	View(orm.DB, int) (gorsk.User, error)
+}
+
+// LocationDB represents location repository interface
+type LocationDB interface {
+	View(orm.DB, int, int) (gorsk.Location, error)
+}
 
 func checkBool(b bool) error {
 	if b {
@@ -24,13 +77,19 @@ func (s Service) User(c echo.Context) gorsk.AuthUser {
 	user := c.Get("username").(string)
 	email := c.Get("email").(string)
 	role := c.Get("role").(gorsk.AccessRole)
+	roleID, _ := c.Get("role_id").(gorsk.AccessRole)
+	apiKeyID, _ := c.Get("api_key_id").(int)
+	impersonatorID, _ := c.Get("impersonator_id").(int)
 	return gorsk.AuthUser{
-		ID:         id,
-		Username:   user,
-		CompanyID:  companyID,
-		LocationID: locationID,
-		Email:      email,
-		Role:       role,
+		ID:             id,
+		Username:       user,
+		CompanyID:      companyID,
+		LocationID:     locationID,
+		Email:          email,
+		Role:           role,
+		RoleID:         roleID,
+		APIKeyID:       apiKeyID,
+		ImpersonatorID: impersonatorID,
 	}
 }
 
@@ -39,61 +98,261 @@ func (s Service) EnforceRole(c echo.Context, r gorsk.AccessRole) error {
 	return checkBool(!(c.Get("role").(gorsk.AccessRole) > r))
 }
 
-// EnforceUser checks whether the request to change user data is done by the same user
+// EnforceUser checks whether the request to access user data is permitted, as Can does for users:view
 func (s Service) EnforceUser(c echo.Context, ID int) error {
-	// TODO: Implement querying db and checking the requested user's company_id/location_id
-	// to allow company/location admins to view the user
-	if s.isAdmin(c) {
-		return nil
-	}
-	return checkBool(c.Get("id").(int) == ID)
+	return s.Can(c, gorsk.PermissionUsersView, gorsk.Resource{UserID: ID})
 }
 
-// EnforceCompany checks whether the request to apply change to company data
-// is done by the user belonging to the that company and that the user has role CompanyAdmin.
-// If user has admin role, the check for company doesnt need to pass.
+// EnforceCompany checks whether the request to apply change to company data is permitted, as Can does for companies:update
 func (s Service) EnforceCompany(c echo.Context, ID int) error {
-	if s.isAdmin(c) {
-		return nil
+	return s.Can(c, gorsk.PermissionCompaniesUpdate, gorsk.Resource{CompanyID: ID})
+}
+
+// EnforceLocation checks whether the request to change location data is permitted, as Can does for locations:update.
+// The location is treated as belonging to user's company only if it is user's location or is found in user's company.
+func (s Service) EnforceLocation(c echo.Context, ID int) error {
+	r := gorsk.Resource{LocationID: ID}
+	companyID := c.Get("company_id").(int)
+	ok := ID == c.Get("location_id").(int)
+	if !ok {
+		var err error
+		if ok, err = s.inCompany(companyID, ID); err != nil {
+			return err
+		}
+	}
+	if ok {
+		r.CompanyID = companyID
+	}
+	return s.Can(c, gorsk.PermissionLocationsUpdate, r)
+}
+
+// Can authorizes request by permission of user's role.
+// Users other than admins are limited to the resources of their own, and company and location admins
+// to the resources of their company or location, respectively, as given by the access level of the role.
+// If the resource is identified by user only, company and location of the user are loaded.
+// Permissions granted by access level, such as companies:update, are authorized by the default rules.
+// If the service has a policy, every request is authorized by the policy instead.
+func (s Service) Can(c echo.Context, permission string, r gorsk.Resource) error {
+	if s.policy != nil {
+		return s.canByPolicy(c, s.policy, permission, r)
+	}
+	if !gorsk.ValidPermission(permission) {
+		return s.canByPolicy(c, defaultPolicy, permission, r)
 	}
-	if err := s.EnforceRole(c, gorsk.CompanyAdminRole); err != nil {
+	role, err := s.permitted(c, permission)
+	if err != nil {
 		return err
 	}
-	return checkBool(c.Get("company_id").(int) == ID)
+	sc := scope(c, role.AccessLevel)
+	if sc != nil && sc.UserID == 0 && r.UserID != 0 && r.CompanyID == 0 && r.LocationID == 0 && r.UserID != c.Get("id").(int) {
+		if r, err = s.userResource(r.UserID); err != nil {
+			return err
+		}
+	}
+	return checkBool(inScope(c, sc, r))
 }
 
-// EnforceLocation checks whether the request to change location data
-// is done by the user belonging to the requested location
-func (s Service) EnforceLocation(c echo.Context, ID int) error {
-	if s.isCompanyAdmin(c) {
-		return nil
+// Scope authorizes listing resources by permission of user's role, returning the resources the user can list
+// by the same rules as Can. Nil scope means the user is not limited to any resources.
+// Permissions granted by access level, and every permission if the service has a policy, are scoped by the rules.
+func (s Service) Scope(c echo.Context, permission string) (*gorsk.Resource, error) {
+	if s.policy != nil {
+		return scopeByPolicy(c, s.policy, permission)
 	}
-	if err := s.EnforceRole(c, gorsk.LocationAdminRole); err != nil {
+	if !gorsk.ValidPermission(permission) {
+		return scopeByPolicy(c, defaultPolicy, permission)
+	}
+	role, err := s.permitted(c, permission)
+	if err != nil {
+		return nil, err
+	}
+	return scope(c, role.AccessLevel), nil
+}
+
+// permitted returns user's role if it has the permission
+func (s Service) permitted(c echo.Context, permission string) (gorsk.Role, error) {
+	role, err := s.role(roleID(c))
+	if err == pg.ErrNoRows {
+		return gorsk.Role{}, echo.ErrForbidden
+	}
+	if err != nil {
+		return gorsk.Role{}, err
+	}
+	if !role.Can(permission) {
+		return gorsk.Role{}, echo.ErrForbidden
+	}
+	return role, nil
+}
+
+// canByPolicy authorizes request by policy
+func (s Service) canByPolicy(c echo.Context, p *Policy, permission string, r gorsk.Resource) error {
+	d, err := s.decide(p, policyUser(c), permission, r)
+	if err != nil {
 		return err
 	}
-	return checkBool(c.Get("location_id").(int) == ID)
+	return checkBool(d.Allowed)
+}
+
+// scopeByPolicy returns the widest scope in which policy allows the permission: any resource, resources of user's company,
+// of user's location, or user's own resources. Each scope is evaluated as a resource with only the attributes it is limited by,
+// so rules conditioned on other attributes of the resource do not widen it.
+func scopeByPolicy(c echo.Context, p *Policy, permission string) (*gorsk.Resource, error) {
+	u := policyUser(c)
+	if p.Evaluate(u, permission, gorsk.Resource{}).Allowed {
+		return nil, nil
+	}
+	if u.CompanyID != 0 && p.Evaluate(u, permission, gorsk.Resource{CompanyID: u.CompanyID}).Allowed {
+		return &gorsk.Resource{CompanyID: u.CompanyID}, nil
+	}
+	if u.LocationID != 0 && p.Evaluate(u, permission, gorsk.Resource{CompanyID: u.CompanyID, LocationID: u.LocationID}).Allowed {
+		return &gorsk.Resource{LocationID: u.LocationID}, nil
+	}
+	if u.ID != 0 && p.Evaluate(u, permission, gorsk.Resource{UserID: u.ID}).Allowed {
+		return &gorsk.Resource{UserID: u.ID}, nil
+	}
+	return nil, echo.ErrForbidden
 }
 
-func (s Service) isAdmin(c echo.Context) bool {
-	return !(c.Get("role").(gorsk.AccessRole) > gorsk.AdminRole)
+// policyUser returns the requesting user with the attributes policy rules can be conditioned on
+func policyUser(c echo.Context) gorsk.AuthUser {
+	return gorsk.AuthUser{
+		ID:         c.Get("id").(int),
+		CompanyID:  c.Get("company_id").(int),
+		LocationID: c.Get("location_id").(int),
+		Role:       c.Get("role").(gorsk.AccessRole),
+		RoleID:     roleID(c),
+	}
 }
 
-func (s Service) isCompanyAdmin(c echo.Context) bool {
-	// Must query company ID in database for the given user
-	return !(c.Get("role").(gorsk.AccessRole) > gorsk.CompanyAdminRole)
+// Explain decides whether the user may perform the action given by permission on the resource by the policy
+// of the service, the same way Can does, and returns the decision explaining why
+func (s Service) Explain(u gorsk.AuthUser, permission string, r gorsk.Resource) (Decision, error) {
+	if s.policy == nil {
+		return Decision{}, ErrNoPolicy
+	}
+	return s.decide(s.policy, u, permission, r)
+}
+
+// decide evaluates policy for the request, loading company and location of the user the resource is identified by
+func (s Service) decide(p *Policy, u gorsk.AuthUser, permission string, r gorsk.Resource) (Decision, error) {
+	if r.UserID != 0 && r.CompanyID == 0 && r.LocationID == 0 && r.UserID != u.ID {
+		var err error
+		if r, err = s.userResource(r.UserID); err != nil {
+			return Decision{}, err
+		}
+	}
+	return p.Evaluate(u, permission, r), nil
+}
+
+// roleID returns ID of user's role. Requests authenticated by API key, or by tokens issued before custom roles
+// were introduced, carry only the access level, which is also the ID of the built-in role with that level.
+func roleID(c echo.Context) gorsk.AccessRole {
+	if id, ok := c.Get("role_id").(gorsk.AccessRole); ok {
+		return id
+	}
+	return c.Get("role").(gorsk.AccessRole)
+}
+
+// role returns role with given ID along with its permissions
+func (s Service) role(id gorsk.AccessRole) (gorsk.Role, error) {
+	if s.rdb == nil {
+		return gorsk.Role{ID: id, AccessLevel: id, Permissions: gorsk.DefaultPermissions[id]}, nil
+	}
+	return s.rdb.View(s.db, id)
+}
+
+// inCompany checks whether location with given ID belongs to the company
+func (s Service) inCompany(companyID, locationID int) (bool, error) {
+	if s.ldb == nil {
+		return false, nil
+	}
+	_, err := s.ldb.View(s.db, companyID, locationID)
+	if err == pg.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
 }
 
-// AccountCreate performs auth check when creating a new account
-// Location admin cannot create accounts, needs to be fixed on EnforceLocation function
+// userResource returns user with given ID as a resource of user's company and location.
+// Without user repository, or if the user does not exist, the resource is out of scope of company and location admins.
+func (s Service) userResource(id int) (gorsk.Resource, error) {
+	r := gorsk.Resource{UserID: id}
+	if s.udb == nil {
+		return r, nil
+	}
+	u, err := s.udb.View(s.db, id)
+	if err == pg.ErrNoRows {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	r.CompanyID, r.LocationID = u.CompanyID, u.LocationID
+	return r, nil
+}
+
+// scope returns the resources user with role of given access level is limited to: none for admins,
+// user's company or location for company and location admins, and otherwise user's own resources
+func scope(c echo.Context, level gorsk.AccessRole) *gorsk.Resource {
+	switch {
+	case level <= gorsk.AdminRole:
+		return nil
+	case level <= gorsk.CompanyAdminRole:
+		return &gorsk.Resource{CompanyID: c.Get("company_id").(int)}
+	case level <= gorsk.LocationAdminRole:
+		return &gorsk.Resource{LocationID: c.Get("location_id").(int)}
+	default:
+		return &gorsk.Resource{UserID: c.Get("id").(int)}
+	}
+}
+
+// inScope checks whether the resource belongs to the user, or is within the scope the user is limited to
+func inScope(c echo.Context, sc *gorsk.Resource, r gorsk.Resource) bool {
+	switch {
+	case sc == nil:
+		return true
+	case r.UserID != 0 && r.UserID == c.Get("id").(int):
+		return true
+	case sc.CompanyID != 0:
+		return r.CompanyID == sc.CompanyID
+	case sc.LocationID != 0:
+		return r.LocationID == sc.LocationID
+	default:
+		return false
+	}
+}
+
+// AccountCreate performs auth check when creating a new account with role of given ID in given company and location.
+// Besides having the permission within the company or location, the requesting user must have higher role than the created account,
+// so location admins can create accounts of lower roles, such as User, in their own location only.
+// The location must belong to the company.
 func (s Service) AccountCreate(c echo.Context, roleID gorsk.AccessRole, companyID, locationID int) error {
-	if err := s.EnforceLocation(c, locationID); err != nil {
+	if err := s.Can(c, gorsk.PermissionUsersCreate, gorsk.Resource{CompanyID: companyID, LocationID: locationID}); err != nil {
+		return err
+	}
+	if err := s.IsLowerRole(c, roleID); err != nil {
+		return err
+	}
+	ok, err := s.inCompany(companyID, locationID)
+	if err != nil {
 		return err
 	}
-	return s.IsLowerRole(c, roleID)
+	if !ok {
+		return ErrLocationNotInCompany
+	}
+	return nil
 }
 
-// IsLowerRole checks whether the requesting user has higher role than the user it wants to change
-// Used for account creation/deletion
-func (s Service) IsLowerRole(c echo.Context, r gorsk.AccessRole) error {
-	return checkBool(c.Get("role").(gorsk.AccessRole) < r)
+// IsLowerRole checks whether the requesting user outranks the role with given ID, by permission roles:outrank
+// on the access level of the role. By default rules, user's role must have higher access level.
+// Used for account creation/deletion and assigning roles.
+func (s Service) IsLowerRole(c echo.Context, id gorsk.AccessRole) error {
+	role, err := s.role(id)
+	if err == pg.ErrNoRows {
+		return ErrUnknownRole
+	}
+	if err != nil {
+		return err
+	}
+	return s.Can(c, gorsk.PermissionRolesOutrank, gorsk.Resource{Role: role.AccessLevel})
 }
@@ -5,8 +5,11 @@ import (
 
 	"github.com/ribice/gorsk"
 	"github.com/ribice/gorsk/pkg/utl/mock"
+	"github.com/ribice/gorsk/pkg/utl/mock/mockdb"
 	"github.com/ribice/gorsk/pkg/utl/rbac"
 
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
 	"github.com/labstack/echo"
 
 	"github.com/stretchr/testify/assert"
@@ -26,6 +29,14 @@ func TestUser(t *testing.T) {
 	}
 	rbacSvc := rbac.Service{}
 	assert.Equal(t, wantUser, rbacSvc.User(ctx))
+
+	ctx.Set("api_key_id", 3)
+	wantUser.APIKeyID = 3
+	assert.Equal(t, wantUser, rbacSvc.User(ctx))
+
+	ctx.Set("impersonator_id", 4)
+	wantUser.ImpersonatorID = 4
+	assert.Equal(t, wantUser, rbacSvc.User(ctx))
 }
 
 func TestEnforceRole(t *testing.T) {
@@ -70,7 +81,7 @@ func TestEnforceUser(t *testing.T) {
 	}{
 		{
 			name:    "Not same user, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "role"}, 15, gorsk.LocationAdminRole), id: 122},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 15, 1, 1, gorsk.LocationAdminRole), id: 122},
 			wantErr: true,
 		},
 		{
@@ -93,6 +104,80 @@ func TestEnforceUser(t *testing.T) {
 	}
 }
 
+func TestEnforceUserScope(t *testing.T) {
+	udb := &mockdb.User{
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			switch id {
+			case 2:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 1, LocationID: 1}, nil
+			case 3:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 1, LocationID: 2}, nil
+			case 4:
+				return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 2, LocationID: 3}, nil
+			case 5:
+				return gorsk.User{}, gorsk.ErrGeneric
+			}
+			return gorsk.User{}, pg.ErrNoRows
+		},
+	}
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 1, 1, role)
+	}
+	cases := []struct {
+		name    string
+		ctx     echo.Context
+		id      int
+		wantErr error
+	}{
+		{
+			name: "Company admin, user of the company",
+			ctx:  ctx(gorsk.CompanyAdminRole),
+			id:   3,
+		},
+		{
+			name:    "Company admin, user of other company",
+			ctx:     ctx(gorsk.CompanyAdminRole),
+			id:      4,
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name: "Location admin, user of the location",
+			ctx:  ctx(gorsk.LocationAdminRole),
+			id:   2,
+		},
+		{
+			name:    "Location admin, user of other location",
+			ctx:     ctx(gorsk.LocationAdminRole),
+			id:      3,
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "User, user of the location",
+			ctx:     ctx(gorsk.UserRole),
+			id:      2,
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Company admin, user does not exist",
+			ctx:     ctx(gorsk.CompanyAdminRole),
+			id:      6,
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Fail on View",
+			ctx:     ctx(gorsk.CompanyAdminRole),
+			id:      5,
+			wantErr: gorsk.ErrGeneric,
+		},
+	}
+	rbacSvc := rbac.New(nil, nil, udb, nil, nil)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, rbacSvc.EnforceUser(tt.ctx, tt.id))
+		})
+	}
+}
+
 func TestEnforceCompany(t *testing.T) {
 	type args struct {
 		ctx echo.Context
@@ -105,22 +190,22 @@ func TestEnforceCompany(t *testing.T) {
 	}{
 		{
 			name:    "Not same company, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "role"}, 7, gorsk.UserRole), id: 9},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 7, 1, gorsk.UserRole), id: 9},
 			wantErr: true,
 		},
 		{
 			name:    "Same company, not company admin or admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "role"}, 22, gorsk.UserRole), id: 22},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 22, 1, gorsk.UserRole), id: 22},
 			wantErr: true,
 		},
 		{
 			name:    "Same company, company admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "role"}, 5, gorsk.CompanyAdminRole), id: 5},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 5, 1, gorsk.CompanyAdminRole), id: 5},
 			wantErr: false,
 		},
 		{
 			name:    "Not same company but admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "role"}, 8, gorsk.AdminRole), id: 9},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 8, 1, gorsk.AdminRole), id: 9},
 			wantErr: false,
 		},
 	}
@@ -133,6 +218,20 @@ func TestEnforceCompany(t *testing.T) {
 	}
 }
 
+func locationDB() *mockdb.Location {
+	return &mockdb.Location{
+		ViewFn: func(db orm.DB, companyID, id int) (gorsk.Location, error) {
+			switch {
+			case id == 9:
+				return gorsk.Location{}, gorsk.ErrGeneric
+			case companyID == 2 && (id == 3 || id == 4), companyID == 7 && id == 8:
+				return gorsk.Location{Base: gorsk.Base{ID: id}, CompanyID: companyID}, nil
+			}
+			return gorsk.Location{}, pg.ErrNoRows
+		},
+	}
+}
+
 func TestEnforceLocation(t *testing.T) {
 	type args struct {
 		ctx echo.Context
@@ -145,35 +244,557 @@ func TestEnforceLocation(t *testing.T) {
 	}{
 		{
 			name:    "Not same location, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"location_id", "role"}, 7, gorsk.UserRole), id: 9},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 7, gorsk.UserRole), id: 10},
 			wantErr: true,
 		},
 		{
 			name:    "Same location, not company admin or admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"location_id", "role"}, 22, gorsk.UserRole), id: 22},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 22, gorsk.UserRole), id: 22},
 			wantErr: true,
 		},
 		{
 			name:    "Same location, company admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"location_id", "role"}, 5, gorsk.CompanyAdminRole), id: 5},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 5, gorsk.CompanyAdminRole), id: 5},
 			wantErr: false,
 		},
 		{
 			name:    "Location admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"location_id", "role"}, 5, gorsk.LocationAdminRole), id: 5},
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 5, gorsk.LocationAdminRole), id: 5},
+			wantErr: false,
+		},
+		{
+			name:    "Other location of the company, location admin",
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.LocationAdminRole), id: 4},
+			wantErr: true,
+		},
+		{
+			name:    "Other location of the company, company admin",
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.CompanyAdminRole), id: 4},
+			wantErr: false,
+		},
+		{
+			name:    "Location of other company, company admin",
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.CompanyAdminRole), id: 8},
+			wantErr: true,
+		},
+		{
+			name:    "Location of other company, admin",
+			args:    args{ctx: mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.AdminRole), id: 8},
 			wantErr: false,
 		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			rbacSvc := rbac.Service{}
+			rbacSvc := rbac.New(nil, nil, nil, locationDB(), nil)
 			res := rbacSvc.EnforceLocation(tt.args.ctx, tt.args.id)
 			assert.Equal(t, tt.wantErr, res == echo.ErrForbidden)
 		})
 	}
 }
 
+func TestCanDefaultRules(t *testing.T) {
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, role)
+	}
+	cases := []struct {
+		name       string
+		ctx        echo.Context
+		permission string
+		resource   gorsk.Resource
+		wantErr    error
+	}{
+		{
+			name:       "User creates company",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionCompaniesCreate,
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Admin creates company",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionCompaniesCreate,
+		},
+		{
+			name:       "Company admin deactivates own company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionCompaniesDeactivate,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin updates own company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionCompaniesUpdate,
+			resource:   gorsk.Resource{CompanyID: 2},
+		},
+		{
+			name:       "Company admin views other company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionCompaniesView,
+			resource:   gorsk.Resource{CompanyID: 9},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Custom role above company admin updates own company",
+			ctx:        ctx(115),
+			permission: gorsk.PermissionCompaniesUpdate,
+			resource:   gorsk.Resource{CompanyID: 2},
+		},
+		{
+			name:       "User views own company",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionCompaniesView,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Admin views other company",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionCompaniesView,
+			resource:   gorsk.Resource{CompanyID: 9},
+		},
+		{
+			name:       "Company admin creates location of own company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionLocationsCreate,
+			resource:   gorsk.Resource{CompanyID: 2},
+		},
+		{
+			name:       "Location admin creates location of own company",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsCreate,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin lists locations of own company",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsView,
+			resource:   gorsk.Resource{CompanyID: 2},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin updates own location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsUpdate,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "Location admin views other location of own company",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsView,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 4},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin views location of other company",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsView,
+			resource:   gorsk.Resource{CompanyID: 7, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin deletes own location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionLocationsDelete,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin updates location of other company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionLocationsUpdate,
+			resource:   gorsk.Resource{CompanyID: 7, LocationID: 8},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Admin deletes location of other company",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionLocationsDelete,
+			resource:   gorsk.Resource{CompanyID: 7, LocationID: 8},
+		},
+		{
+			name:       "User views own location",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionLocationsView,
+			resource:   gorsk.Resource{CompanyID: 2, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Admin creates role",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionRolesCreate,
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Super admin assigns role",
+			ctx:        ctx(gorsk.SuperAdminRole),
+			permission: gorsk.PermissionRolesAssign,
+			resource:   gorsk.Resource{UserID: 5},
+		},
+		{
+			name:       "Location admin views roles",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionRolesView,
+		},
+		{
+			name:       "User views roles",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionRolesView,
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin outranks user",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionRolesOutrank,
+			resource:   gorsk.Resource{Role: gorsk.UserRole},
+		},
+		{
+			name:       "Company admin outranks company admin",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionRolesOutrank,
+			resource:   gorsk.Resource{Role: gorsk.CompanyAdminRole},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "User creates own API key",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{UserID: 1, Role: gorsk.UserRole},
+		},
+		{
+			name:       "User creates own API key of higher role",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{UserID: 1, Role: gorsk.AdminRole},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin creates API key of own company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{CompanyID: 2, Role: gorsk.CompanyAdminRole},
+		},
+		{
+			name:       "Company admin creates API key of other company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{CompanyID: 7, Role: gorsk.UserRole},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin creates API key of own company",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionAPIKeysCreate,
+			resource:   gorsk.Resource{CompanyID: 2, Role: gorsk.UserRole},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin revokes API key of user of own location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionAPIKeysRevoke,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "User revokes API key of other user",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionAPIKeysRevoke,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Admin revokes API key of other company",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionAPIKeysRevoke,
+			resource:   gorsk.Resource{CompanyID: 7},
+		},
+	}
+	rbacSvc := rbac.Service{}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, rbacSvc.Can(tt.ctx, tt.permission, tt.resource))
+		})
+	}
+}
+
+func TestCan(t *testing.T) {
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, role)
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			switch id {
+			case 150:
+				return gorsk.Role{ID: 150, AccessLevel: gorsk.LocationAdminRole, Permissions: []string{gorsk.PermissionUsersView}}, nil
+			case gorsk.UserRole:
+				return gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole}, nil
+			case 300:
+				return gorsk.Role{}, gorsk.ErrGeneric
+			}
+			return gorsk.Role{}, pg.ErrNoRows
+		},
+	}
+	udb := &mockdb.User{
+		ViewFn: func(db orm.DB, id int) (gorsk.User, error) {
+			if id != 5 {
+				return gorsk.User{}, pg.ErrNoRows
+			}
+			return gorsk.User{Base: gorsk.Base{ID: id}, CompanyID: 2, LocationID: 4}, nil
+		},
+	}
+	cases := []struct {
+		name       string
+		rbac       rbac.Service
+		ctx        echo.Context
+		permission string
+		resource   gorsk.Resource
+		wantErr    error
+	}{
+		{
+			name:       "Admin accessing any user",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionUsersDelete,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 7, LocationID: 8},
+		},
+		{
+			name:       "Unknown permission",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: "invoices:delete",
+			resource:   gorsk.Resource{UserID: 5},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "User accessing own data",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersUpdate,
+			resource:   gorsk.Resource{UserID: 1},
+		},
+		{
+			name:       "User accessing other user",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "User lacking permission on own data",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersDelete,
+			resource:   gorsk.Resource{UserID: 1},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin accessing user of the company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersDelete,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 8},
+		},
+		{
+			name:       "Company admin accessing user of other company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersDelete,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 7, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin without known company",
+			ctx:        mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 0, 0, gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Location admin accessing user of the location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUnlock,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "Location admin accessing user of other location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersUnlock,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 4},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin accessing user of the company by ID",
+			rbac:       rbac.New(nil, nil, udb, nil, nil),
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5},
+		},
+		{
+			name:       "Location admin accessing user of other location by ID",
+			rbac:       rbac.New(nil, nil, udb, nil, nil),
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Company admin accessing missing user by ID",
+			rbac:       rbac.New(nil, nil, udb, nil, nil),
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 6},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Permission of role stored in database",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(150),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "Custom role given by role_id",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role", "role_id"}, 1, 2, 3, gorsk.LocationAdminRole, gorsk.AccessRole(150)),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+		},
+		{
+			name:       "Custom role lacking permission of its access level",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role", "role_id"}, 1, 2, 3, gorsk.LocationAdminRole, gorsk.AccessRole(150)),
+			permission: gorsk.PermissionUsersDelete,
+			resource:   gorsk.Resource{UserID: 5, CompanyID: 2, LocationID: 3},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Permission removed from role in database",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 1},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Role missing from database",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 1},
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Fail on View",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(300),
+			permission: gorsk.PermissionUsersView,
+			resource:   gorsk.Resource{UserID: 1},
+			wantErr:    gorsk.ErrGeneric,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rbac.Can(tt.ctx, tt.permission, tt.resource)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	policy, err := rbac.LoadPolicy("../../../cmd/api/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, role)
+	}
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			if id == 300 {
+				return gorsk.Role{}, gorsk.ErrGeneric
+			}
+			return gorsk.Role{ID: id, AccessLevel: gorsk.LocationAdminRole, Permissions: []string{gorsk.PermissionUsersView}}, nil
+		},
+	}
+	cases := []struct {
+		name       string
+		rbac       rbac.Service
+		ctx        echo.Context
+		permission string
+		wantData   *gorsk.Resource
+		wantErr    error
+	}{
+		{
+			name:       "Admin listing all users",
+			ctx:        ctx(gorsk.AdminRole),
+			permission: gorsk.PermissionUsersView,
+		},
+		{
+			name:       "Company admin listing users of the company",
+			ctx:        ctx(gorsk.CompanyAdminRole),
+			permission: gorsk.PermissionUsersView,
+			wantData:   &gorsk.Resource{CompanyID: 2},
+		},
+		{
+			name:       "Location admin listing users of the location",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionUsersView,
+			wantData:   &gorsk.Resource{LocationID: 3},
+		},
+		{
+			name:       "User listing own API keys",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionAPIKeysView,
+			wantData:   &gorsk.Resource{UserID: 1},
+		},
+		{
+			name:       "User lacking permission",
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionCompaniesView,
+			wantErr:    echo.ErrForbidden,
+		},
+		{
+			name:       "Custom role scoped by its access level",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(155),
+			permission: gorsk.PermissionUsersView,
+			wantData:   &gorsk.Resource{LocationID: 3},
+		},
+		{
+			name:       "Fail on View",
+			rbac:       rbac.New(nil, rdb, nil, nil, nil),
+			ctx:        ctx(300),
+			permission: gorsk.PermissionUsersView,
+			wantErr:    gorsk.ErrGeneric,
+		},
+		{
+			name:       "Location admin listing API keys to revoke by default rules",
+			ctx:        ctx(gorsk.LocationAdminRole),
+			permission: gorsk.PermissionAPIKeysRevoke,
+			wantData:   &gorsk.Resource{LocationID: 3},
+		},
+		{
+			name:       "Custom role scoped by policy",
+			rbac:       rbac.New(nil, nil, nil, nil, policy),
+			ctx:        ctx(125),
+			permission: gorsk.PermissionUsersView,
+			wantData:   &gorsk.Resource{LocationID: 3},
+		},
+		{
+			name:       "User lacking permission by policy",
+			rbac:       rbac.New(nil, nil, nil, nil, policy),
+			ctx:        ctx(gorsk.UserRole),
+			permission: gorsk.PermissionUsersCreate,
+			wantErr:    echo.ErrForbidden,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := tt.rbac.Scope(tt.ctx, tt.permission)
+			assert.Equal(t, tt.wantData, sc)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
 func TestAccountCreate(t *testing.T) {
+	ctx := func(role gorsk.AccessRole) echo.Context {
+		return mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, role)
+	}
 	type args struct {
 		ctx        echo.Context
 		roleID     gorsk.AccessRole
@@ -183,50 +804,77 @@ func TestAccountCreate(t *testing.T) {
 	cases := []struct {
 		name    string
 		args    args
-		wantErr bool
+		wantErr error
 	}{
 		{
-			name:    "Different location, company, creating user role, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.UserRole), roleID: 500, companyID: 7, locationID: 8},
-			wantErr: true,
+			name:    "User creating user in own location",
+			args:    args{ctx: ctx(gorsk.UserRole), roleID: gorsk.UserRole, companyID: 2, locationID: 3},
+			wantErr: echo.ErrForbidden,
 		},
 		{
-			name:    "Same location, not company, creating user role, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.UserRole), roleID: 500, companyID: 2, locationID: 8},
-			wantErr: true,
+			name: "Location admin creating user in own location",
+			args: args{ctx: ctx(gorsk.LocationAdminRole), roleID: gorsk.UserRole, companyID: 2, locationID: 3},
 		},
 		{
-			name:    "Different location, company, creating user role, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.CompanyAdminRole), roleID: 400, companyID: 2, locationID: 4},
-			wantErr: false,
+			name:    "Location admin creating location admin in own location",
+			args:    args{ctx: ctx(gorsk.LocationAdminRole), roleID: gorsk.LocationAdminRole, companyID: 2, locationID: 3},
+			wantErr: echo.ErrForbidden,
 		},
 		{
-			name:    "Same location, company, creating user role, not an admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.CompanyAdminRole), roleID: 500, companyID: 2, locationID: 3},
-			wantErr: false,
+			name:    "Location admin creating user in other location of the company",
+			args:    args{ctx: ctx(gorsk.LocationAdminRole), roleID: gorsk.UserRole, companyID: 2, locationID: 4},
+			wantErr: echo.ErrForbidden,
 		},
 		{
-			name:    "Same location, company, creating user role, admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.CompanyAdminRole), roleID: 500, companyID: 2, locationID: 3},
-			wantErr: false,
+			name:    "Location admin creating user in own location under other company",
+			args:    args{ctx: ctx(gorsk.LocationAdminRole), roleID: gorsk.UserRole, companyID: 7, locationID: 3},
+			wantErr: rbac.ErrLocationNotInCompany,
 		},
 		{
-			name:    "Different everything, admin",
-			args:    args{ctx: mock.EchoCtxWithKeys([]string{"company_id", "location_id", "role"}, 2, 3, gorsk.AdminRole), roleID: 200, companyID: 7, locationID: 4},
-			wantErr: false,
+			name: "Company admin creating location admin in other location of the company",
+			args: args{ctx: ctx(gorsk.CompanyAdminRole), roleID: gorsk.LocationAdminRole, companyID: 2, locationID: 4},
+		},
+		{
+			name:    "Company admin creating company admin",
+			args:    args{ctx: ctx(gorsk.CompanyAdminRole), roleID: gorsk.CompanyAdminRole, companyID: 2, locationID: 4},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Company admin creating user in other company",
+			args:    args{ctx: ctx(gorsk.CompanyAdminRole), roleID: gorsk.UserRole, companyID: 7, locationID: 8},
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Company admin creating user in location of other company",
+			args:    args{ctx: ctx(gorsk.CompanyAdminRole), roleID: gorsk.UserRole, companyID: 2, locationID: 8},
+			wantErr: rbac.ErrLocationNotInCompany,
+		},
+		{
+			name: "Admin creating company admin in other company",
+			args: args{ctx: ctx(gorsk.AdminRole), roleID: gorsk.CompanyAdminRole, companyID: 7, locationID: 8},
+		},
+		{
+			name:    "Admin creating user in location of other company",
+			args:    args{ctx: ctx(gorsk.AdminRole), roleID: gorsk.UserRole, companyID: 7, locationID: 4},
+			wantErr: rbac.ErrLocationNotInCompany,
+		},
+		{
+			name:    "Fail on location repository",
+			args:    args{ctx: ctx(gorsk.AdminRole), roleID: gorsk.UserRole, companyID: 7, locationID: 9},
+			wantErr: gorsk.ErrGeneric,
 		},
 	}
+	rbacSvc := rbac.New(nil, nil, nil, locationDB(), nil)
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			rbacSvc := rbac.Service{}
 			res := rbacSvc.AccountCreate(tt.args.ctx, tt.args.roleID, tt.args.companyID, tt.args.locationID)
-			assert.Equal(t, tt.wantErr, res == echo.ErrForbidden)
+			assert.Equal(t, tt.wantErr, res)
 		})
 	}
 }
 
 func TestIsLowerRole(t *testing.T) {
-	ctx := mock.EchoCtxWithKeys([]string{"role"}, gorsk.CompanyAdminRole)
+	ctx := mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.CompanyAdminRole)
 	rbacSvc := rbac.Service{}
 	if rbacSvc.IsLowerRole(ctx, gorsk.LocationAdminRole) != nil {
 		t.Error("The requested user is higher role than the user requesting it")
@@ -235,3 +883,51 @@ func TestIsLowerRole(t *testing.T) {
 		t.Error("The requested user is lower role than the user requesting it")
 	}
 }
+
+func TestIsLowerRoleCustom(t *testing.T) {
+	rdb := &mockdb.Role{
+		ViewFn: func(db orm.DB, id gorsk.AccessRole) (gorsk.Role, error) {
+			switch id {
+			case 1000:
+				return gorsk.Role{ID: id, AccessLevel: 150}, nil
+			case 1001:
+				return gorsk.Role{ID: id, AccessLevel: 115}, nil
+			case 1002:
+				return gorsk.Role{}, gorsk.ErrGeneric
+			}
+			return gorsk.Role{}, pg.ErrNoRows
+		},
+	}
+	cases := []struct {
+		name    string
+		id      gorsk.AccessRole
+		wantErr error
+	}{
+		{
+			name: "Custom role with lower access level",
+			id:   1000,
+		},
+		{
+			name:    "Custom role with higher access level",
+			id:      1001,
+			wantErr: echo.ErrForbidden,
+		},
+		{
+			name:    "Unknown role",
+			id:      300,
+			wantErr: rbac.ErrUnknownRole,
+		},
+		{
+			name:    "Fail on View",
+			id:      1002,
+			wantErr: gorsk.ErrGeneric,
+		},
+	}
+	ctx := mock.EchoCtxWithKeys([]string{"id", "company_id", "location_id", "role"}, 1, 2, 3, gorsk.CompanyAdminRole)
+	rbacSvc := rbac.New(nil, rdb, nil, nil, nil)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, rbacSvc.IsLowerRole(ctx, tt.id))
+		})
+	}
+}
@@ -1,24 +1,22 @@
 package secure
 
 import (
-	"fmt"
-	"hash"
-	"strconv"
-	"time"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 
 	"github.com/nbutton23/zxcvbn-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // New initializes security service
-func New(minPWStr int, h hash.Hash) *Service {
-	return &Service{minPWStr: minPWStr, h: h}
+func New(minPWStr int) *Service {
+	return &Service{minPWStr: minPWStr}
 }
 
 // Service holds security related methods
 type Service struct {
 	minPWStr int
-	h        hash.Hash
 }
 
 // Password checks whether password is secure enough using zxcvbn library
@@ -38,9 +36,18 @@ func (*Service) HashMatchesPassword(hash, password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
-// Token generates new unique token
-func (s *Service) Token(str string) string {
-	s.h.Reset()
-	fmt.Fprintf(s.h, "%s%s", str, strconv.Itoa(time.Now().Nanosecond()))
-	return fmt.Sprintf("%x", s.h.Sum(nil))
+// Token generates new random token of 32 bytes, hex encoded
+func (*Service) Token() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken hashes the token using sha256, so it can be stored and looked up
+// without keeping the token itself
+func (*Service) HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -1,7 +1,7 @@
 package secure_test
 
 import (
-	"crypto/sha1"
+	"sync"
 	"testing"
 
 	"github.com/ribice/gorsk/pkg/utl/secure"
@@ -35,7 +35,7 @@ func TestPassword(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := secure.New(1, nil)
+			s := secure.New(1)
 			got := s.Password(tt.pass, tt.inputs...)
 			assert.Equal(t, tt.want, got)
 		})
@@ -56,7 +56,7 @@ func TestHashAndMatch(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			s := secure.New(1, nil)
+			s := secure.New(1)
 			hash := s.Hash(tt.pass)
 			assert.Equal(t, tt.want, s.HashMatchesPassword(hash, tt.pass))
 		})
@@ -64,8 +64,32 @@ func TestHashAndMatch(t *testing.T) {
 }
 
 func TestToken(t *testing.T) {
-	s := secure.New(1, sha1.New())
+	s := secure.New(1)
+	tokens := make(chan string, 100)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := s.Token()
+			assert.Nil(t, err)
+			tokens <- token
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+	seen := make(map[string]bool)
+	for token := range tokens {
+		assert.Len(t, token, 64)
+		assert.False(t, seen[token])
+		seen[token] = true
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	s := secure.New(1)
 	token := "token"
-	tokenized := s.Token(token)
-	assert.NotEqual(t, tokenized, token)
+	hashed := s.HashToken(token)
+	assert.NotEqual(t, token, hashed)
+	assert.Equal(t, hashed, s.HashToken(token))
 }
@@ -0,0 +1,133 @@
+// Package totp implements RFC 6238 time-based one-time passwords used for two-factor authentication
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Length of generated secrets in bytes, as recommended by RFC 4226
+	secretLength = 20
+
+	// Number of digits in a code
+	digits = 6
+
+	// Duration for which a single code is valid
+	period = 30 * time.Second
+
+	// Number of periods before and after the current one in which codes are still accepted,
+	// to account for clock drift and slow typing
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// New creates new TOTP service. Codes are generated and validated against the time returned by clock.
+func New(issuer string, clock func() time.Time) *Service {
+	return &Service{issuer: issuer, clock: clock}
+}
+
+// Service generates and validates time-based one-time passwords
+type Service struct {
+	issuer string
+	clock  func() time.Time
+}
+
+// GenerateSecret generates new random base32 encoded secret
+func (s *Service) GenerateSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns otpauth URI of the secret, which authenticator apps read from a QR code
+func (s *Service) URI(secret, account string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", s.issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(digits))
+	v.Set("period", fmt.Sprint(int(period.Seconds())))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + s.issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the code for the current time
+func (s *Service) Code(secret string) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, counter(s.clock())), nil
+}
+
+// Validate checks whether code matches the secret at the current time and returns the time step it was generated for.
+// Codes of time steps up to last are rejected, so a code that was already accepted cannot be replayed.
+func (s *Service) Validate(secret, c string, last int64) (int64, bool) {
+	key, err := decode(secret)
+	if err != nil || len(c) != digits {
+		return 0, false
+	}
+	now := counter(s.clock())
+	for step := now - skew; step <= now+skew; step++ {
+		if step <= last {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(c)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// RecoveryCodes generates n random single-use recovery codes
+func (s *Service) RecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		codes[i] = encoding.EncodeToString(b)
+	}
+	return codes, nil
+}
+
+func decode(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+func counter(t time.Time) int64 {
+	return t.Unix() / int64(period.Seconds())
+}
+
+// code computes HOTP value as defined in RFC 4226
+func code(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
@@ -0,0 +1,145 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ribice/gorsk/pkg/utl/totp"
+)
+
+// Base32 encoded secret used by RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func clock(unix int64) func() time.Time {
+	return func() time.Time {
+		return time.Unix(unix, 0)
+	}
+}
+
+func TestCode(t *testing.T) {
+	cases := map[string]struct {
+		unix    int64
+		want    string
+		wantErr bool
+	}{
+		"Invalid secret": {
+			unix:    59,
+			wantErr: true,
+		},
+		"RFC 6238 vector at 59": {
+			unix: 59,
+			want: "287082",
+		},
+		"RFC 6238 vector at 1111111109": {
+			unix: 1111111109,
+			want: "081804",
+		},
+		"RFC 6238 vector at 2000000000": {
+			unix: 2000000000,
+			want: "279037",
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret := rfcSecret
+			if tt.wantErr {
+				secret = "not base32!"
+			}
+			code, err := totp.New("gorsk", clock(tt.unix)).Code(secret)
+			assert.Equal(t, tt.want, code)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		secret   string
+		code     string
+		last     int64
+		want     bool
+		wantStep int64
+	}{
+		"Invalid secret": {
+			secret: "not base32!",
+			code:   "081804",
+		},
+		"Invalid code length": {
+			secret: rfcSecret,
+			code:   "81804",
+		},
+		"Wrong code": {
+			secret: rfcSecret,
+			code:   "123456",
+		},
+		"Current code": {
+			secret:   rfcSecret,
+			code:     "081804",
+			want:     true,
+			wantStep: 37037036,
+		},
+		"Next code is accepted": {
+			secret:   rfcSecret,
+			code:     "050471",
+			want:     true,
+			wantStep: 37037037,
+		},
+		"Code outside allowed skew": {
+			secret: rfcSecret,
+			code:   "287082",
+		},
+		"Replayed code": {
+			secret: rfcSecret,
+			code:   "081804",
+			last:   37037036,
+		},
+		"Code after last accepted one": {
+			secret:   rfcSecret,
+			code:     "050471",
+			last:     37037036,
+			want:     true,
+			wantStep: 37037037,
+		},
+	}
+	s := totp.New("gorsk", clock(1111111109))
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			step, ok := s.Validate(tt.secret, tt.code, tt.last)
+			assert.Equal(t, tt.want, ok)
+			assert.Equal(t, tt.wantStep, step)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s := totp.New("gorsk", time.Now)
+	secret, err := s.GenerateSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := s.Code(secret)
+	assert.Nil(t, err)
+	_, ok := s.Validate(secret, code, 0)
+	assert.True(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	s := totp.New("gorsk", time.Now)
+	assert.Equal(t,
+		"otpauth://totp/gorsk:johndoe?algorithm=SHA1&digits=6&issuer=gorsk&period=30&secret="+rfcSecret,
+		s.URI(rfcSecret, "johndoe"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	s := totp.New("gorsk", time.Now)
+	codes, err := s.RecoveryCodes(10)
+	assert.Nil(t, err)
+	assert.Len(t, codes, 10)
+	for _, c := range codes {
+		assert.Len(t, c, 8)
+		assert.Equal(t, strings.ToUpper(c), c)
+	}
+}
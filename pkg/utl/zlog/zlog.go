@@ -35,6 +35,10 @@ func (z *Log) Log(ctx echo.Context, source, msg string, err error, params map[st
 		params["user"] = ctx.Get("username").(string)
 	}
 
+	if id, ok := ctx.Get("impersonator_id").(int); ok {
+		params["impersonator_id"] = id
+	}
+
 	if err != nil {
 		params["error"] = err
 		z.logger.Error().Fields(params).Msg(msg)
@@ -20,9 +20,119 @@ const (
 	UserRole AccessRole = 200
 )
 
-// Role model
+// Permissions that can be assigned to roles, named as resource:action
+const (
+	PermissionUsersCreate      = "users:create"
+	PermissionUsersView        = "users:view"
+	PermissionUsersUpdate      = "users:update"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersUnlock      = "users:unlock"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionPasswordsChange  = "passwords:change"
+	PermissionLoginsView       = "logins:view"
+	PermissionCompaniesView    = "companies:view"
+	PermissionAPIKeysView      = "apikeys:view"
+)
+
+// Permissions holds all permissions that can be assigned to roles
+var Permissions = []string{
+	PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersUnlock,
+	PermissionUsersImpersonate, PermissionPasswordsChange, PermissionLoginsView, PermissionCompaniesView, PermissionAPIKeysView,
+}
+
+// Permissions granted by access level of user's role instead of being assigned to roles, named as resource:action.
+// Without a policy, they are authorized by the default rules of the RBAC service.
+const (
+	PermissionCompaniesCreate     = "companies:create"
+	PermissionCompaniesUpdate     = "companies:update"
+	PermissionCompaniesDeactivate = "companies:deactivate"
+	PermissionLocationsCreate     = "locations:create"
+	PermissionLocationsView       = "locations:view"
+	PermissionLocationsUpdate     = "locations:update"
+	PermissionLocationsDelete     = "locations:delete"
+	PermissionRolesCreate         = "roles:create"
+	PermissionRolesView           = "roles:view"
+	PermissionRolesUpdate         = "roles:update"
+	PermissionRolesDelete         = "roles:delete"
+	PermissionRolesAssign         = "roles:assign"
+	// PermissionRolesOutrank is required to create, change, delete or impersonate accounts with the role
+	PermissionRolesOutrank  = "roles:outrank"
+	PermissionAPIKeysCreate = "apikeys:create"
+	PermissionAPIKeysRevoke = "apikeys:revoke"
+)
+
+// LevelPermissions holds all permissions granted by access level of user's role
+var LevelPermissions = []string{
+	PermissionCompaniesCreate, PermissionCompaniesUpdate, PermissionCompaniesDeactivate,
+	PermissionLocationsCreate, PermissionLocationsView, PermissionLocationsUpdate, PermissionLocationsDelete,
+	PermissionRolesCreate, PermissionRolesView, PermissionRolesUpdate, PermissionRolesDelete, PermissionRolesAssign,
+	PermissionRolesOutrank, PermissionAPIKeysCreate, PermissionAPIKeysRevoke,
+}
+
+// ValidPermission reports whether the permission can be assigned to roles
+func ValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPermissions holds permission sets of the built-in roles
+var DefaultPermissions = map[AccessRole][]string{
+	SuperAdminRole: {
+		PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersUnlock,
+		PermissionUsersImpersonate, PermissionPasswordsChange, PermissionLoginsView, PermissionCompaniesView, PermissionAPIKeysView,
+	},
+	AdminRole: {
+		PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersUnlock,
+		PermissionUsersImpersonate, PermissionPasswordsChange, PermissionLoginsView, PermissionCompaniesView, PermissionAPIKeysView,
+	},
+	CompanyAdminRole: {
+		PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersUnlock,
+		PermissionPasswordsChange, PermissionLoginsView, PermissionCompaniesView, PermissionAPIKeysView,
+	},
+	LocationAdminRole: {
+		PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersUnlock,
+		PermissionPasswordsChange, PermissionLoginsView, PermissionAPIKeysView,
+	},
+	UserRole: {
+		PermissionUsersView, PermissionUsersUpdate, PermissionPasswordsChange, PermissionLoginsView, PermissionAPIKeysView,
+	},
+}
+
+// Role model. Besides the five built-in roles, SuperAdmins can create custom roles,
+// whose access level is one of the built-in levels or any level between them.
 type Role struct {
 	ID          AccessRole `json:"id"`
 	AccessLevel AccessRole `json:"access_level"`
 	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions,omitempty" pg:",array"`
+}
+
+// Can reports whether the role has the permission
+func (r Role) Can(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltIn reports whether the role is one of the roles every installation starts with
+func (r Role) BuiltIn() bool {
+	_, ok := DefaultPermissions[r.ID]
+	return ok
+}
+
+// Resource identifies whose data a request accesses, limiting permissions of non-admin users to their own data,
+// or data of their company or location. It also describes the data list queries of non-admin users are limited to.
+type Resource struct {
+	UserID     int
+	CompanyID  int
+	LocationID int
+	// Role is access level of the role the resource grants, e.g. of the account being created or of the API key
+	Role AccessRole
 }
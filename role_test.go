@@ -0,0 +1,45 @@
+package gorsk_test
+
+import (
+	"testing"
+
+	"github.com/ribice/gorsk"
+)
+
+func TestRoleCan(t *testing.T) {
+	role := gorsk.Role{ID: gorsk.UserRole, AccessLevel: gorsk.UserRole, Permissions: gorsk.DefaultPermissions[gorsk.UserRole]}
+	if !role.Can(gorsk.PermissionUsersView) {
+		t.Error("Expected role to have permission")
+	}
+	if role.Can(gorsk.PermissionUsersDelete) {
+		t.Error("Expected role not to have permission")
+	}
+	if (gorsk.Role{}).Can(gorsk.PermissionUsersView) {
+		t.Error("Expected role without permissions not to have permission")
+	}
+}
+
+func TestValidPermission(t *testing.T) {
+	if !gorsk.ValidPermission(gorsk.PermissionUsersImpersonate) {
+		t.Error("Expected permission to be valid")
+	}
+	if gorsk.ValidPermission("users:destroy") {
+		t.Error("Expected permission to be invalid")
+	}
+	for role, permissions := range gorsk.DefaultPermissions {
+		for _, p := range permissions {
+			if !gorsk.ValidPermission(p) {
+				t.Errorf("Expected default permission %s of role %d to be valid", p, role)
+			}
+		}
+	}
+}
+
+func TestRoleBuiltIn(t *testing.T) {
+	if !(gorsk.Role{ID: gorsk.LocationAdminRole}).BuiltIn() {
+		t.Error("Expected role to be built-in")
+	}
+	if (gorsk.Role{ID: 1000, AccessLevel: gorsk.LocationAdminRole}).BuiltIn() {
+		t.Error("Expected custom role not to be built-in")
+	}
+}
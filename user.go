@@ -17,18 +17,35 @@ type User struct {
 	Phone   string `json:"phone,omitempty"`
 	Address string `json:"address,omitempty"`
 
-	Active bool `json:"active"`
+	Active          bool      `json:"active"`
+	EmailVerifiedAt time.Time `json:"email_verified_at,omitempty"`
 
 	LastLogin          time.Time `json:"last_login,omitempty"`
 	LastPasswordChange time.Time `json:"last_password_change,omitempty"`
 
-	Token string `json:"-"`
+	MFAEnabled    bool     `json:"mfa_enabled"`
+	MFASecret     string   `json:"-"`
+	MFACounter    int64    `json:"-" pg:",use_zero"`
+	RecoveryCodes []string `json:"-" pg:",array"`
+
+	FailedLogins int       `json:"-" pg:",notnull,default:0,use_zero"`
+	LockedUntil  time.Time `json:"locked_until,omitempty"`
+
+	// MagicLinks is the number of login links sent by email since MagicLinksSince
+	MagicLinks      int       `json:"-" pg:",notnull,default:0,use_zero"`
+	MagicLinksSince time.Time `json:"-"`
+
+	// TokenVersion is embedded into issued JWTs. Incrementing it revokes all of them.
+	TokenVersion int `json:"-" pg:",notnull,default:0,use_zero"`
 
 	Role *Role `json:"role,omitempty"`
 
 	RoleID     AccessRole `json:"-"`
 	CompanyID  int        `json:"company_id"`
 	LocationID int        `json:"location_id"`
+
+	// ImpersonatedBy is ID of the admin acting as the user, set only in response to the impersonating admin
+	ImpersonatedBy int `json:"impersonated_by,omitempty" pg:"-"`
 }
 
 // AuthUser represents data stored in JWT token for user
@@ -39,6 +56,13 @@ type AuthUser struct {
 	Username   string
 	Email      string
 	Role       AccessRole
+	// RoleID is ID of user's role, which differs from Role, its access level, for custom roles.
+	// For requests authenticated by API key, it is the role of key's owning user, or the built-in role of key's access level if it is lower.
+	RoleID AccessRole
+	// APIKeyID is set when the request is authenticated by API key instead of JWT
+	APIKeyID int
+	// ImpersonatorID is set when an admin is acting as the user
+	ImpersonatorID int
 }
 
 // ChangePassword updates user's password related fields
@@ -47,8 +71,93 @@ func (u *User) ChangePassword(hash string) {
 	u.LastPasswordChange = time.Now()
 }
 
-// UpdateLastLogin updates last login field
-func (u *User) UpdateLastLogin(token string) {
-	u.Token = token
+// VerifyEmail marks user's email as verified and activates the account
+func (u *User) VerifyEmail() {
+	u.EmailVerifiedAt = time.Now()
+	u.Active = true
+}
+
+// EmailVerified reports whether user's email was verified
+func (u *User) EmailVerified() bool {
+	return !u.EmailVerifiedAt.IsZero()
+}
+
+// RevokeTokens invalidates all JWTs issued to the user so far
+func (u *User) RevokeTokens() {
+	u.TokenVersion++
+}
+
+// UpdateLastLogin updates last login field and clears failed login attempts
+func (u *User) UpdateLastLogin() {
 	u.LastLogin = time.Now()
+	u.Unlock()
+}
+
+// Locked reports whether the user is temporarily locked out after too many failed logins
+func (u *User) Locked() bool {
+	return time.Now().Before(u.LockedUntil)
+}
+
+// LoginFailed records a failed login attempt. Once maxAttempts is reached, the user gets locked out for lockout duration,
+// doubled with every further failed attempt up to maxLockout.
+func (u *User) LoginFailed(maxAttempts int, lockout, maxLockout time.Duration) {
+	u.FailedLogins++
+	if u.FailedLogins < maxAttempts {
+		return
+	}
+
+	d := lockout
+	for i := maxAttempts; i < u.FailedLogins && d < maxLockout; i++ {
+		d *= 2
+	}
+	if maxLockout > lockout && d > maxLockout {
+		d = maxLockout
+	}
+
+	u.LockedUntil = time.Now().Add(d)
+}
+
+// MagicLinkSent records a login link being sent by email. It reports false if max links were already sent
+// within window, in which case no link should be sent.
+func (u *User) MagicLinkSent(max int, window time.Duration) bool {
+	now := time.Now()
+	if now.Sub(u.MagicLinksSince) >= window {
+		u.MagicLinks = 0
+		u.MagicLinksSince = now
+	}
+	if u.MagicLinks >= max {
+		return false
+	}
+	u.MagicLinks++
+	return true
+}
+
+// EnableMFA turns on two-factor authentication, replacing recovery codes with the given hashes
+func (u *User) EnableMFA(recoveryCodes []string) {
+	u.MFAEnabled = true
+	u.RecoveryCodes = recoveryCodes
+}
+
+// DisableMFA turns off two-factor authentication and discards its secret and recovery codes
+func (u *User) DisableMFA() {
+	u.MFAEnabled = false
+	u.MFASecret = ""
+	u.RecoveryCodes = nil
+}
+
+// UseRecoveryCode consumes recovery code with the given hash. It reports whether the code was valid.
+func (u *User) UseRecoveryCode(hash string) bool {
+	for i, c := range u.RecoveryCodes {
+		if c == hash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Unlock clears failed login attempts and lifts the lockout
+func (u *User) Unlock() {
+	u.FailedLogins = 0
+	u.LockedUntil = time.Time{}
 }
@@ -2,6 +2,7 @@ package gorsk_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ribice/gorsk"
 )
@@ -29,15 +30,174 @@ func TestUpdateLastLogin(t *testing.T) {
 		FirstName: "TestGuy",
 	}
 
-	token := "helloWorld"
+	user.FailedLogins = 2
 
-	user.UpdateLastLogin(token)
+	user.UpdateLastLogin()
 	if user.LastLogin.IsZero() {
 		t.Errorf("Last login time was not changed")
 	}
 
-	if user.Token != token {
-		t.Errorf("Token was not changed")
+	if user.FailedLogins != 0 {
+		t.Errorf("Failed logins were not cleared")
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	user := &gorsk.User{
+		TokenVersion: 2,
+	}
+
+	user.RevokeTokens()
+	if user.TokenVersion != 3 {
+		t.Errorf("Token version was not incremented")
+	}
+}
+
+func TestLoginFailed(t *testing.T) {
+	cases := map[string]struct {
+		failedLogins int
+		wantLocked   bool
+		wantLockout  time.Duration
+	}{
+		"Below threshold": {
+			failedLogins: 1,
+		},
+		"Reached threshold": {
+			failedLogins: 2,
+			wantLocked:   true,
+			wantLockout:  time.Minute,
+		},
+		"Exponential backoff": {
+			failedLogins: 4,
+			wantLocked:   true,
+			wantLockout:  4 * time.Minute,
+		},
+		"Capped backoff": {
+			failedLogins: 100,
+			wantLocked:   true,
+			wantLockout:  time.Hour,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			user := &gorsk.User{FailedLogins: tt.failedLogins}
+			user.LoginFailed(3, time.Minute, time.Hour)
+
+			if user.FailedLogins != tt.failedLogins+1 {
+				t.Errorf("Failed logins were not incremented")
+			}
+
+			if user.Locked() != tt.wantLocked {
+				t.Errorf("Expected locked to be %v", tt.wantLocked)
+			}
+
+			if tt.wantLocked {
+				lockout := time.Until(user.LockedUntil)
+				if lockout > tt.wantLockout || lockout < tt.wantLockout-time.Second {
+					t.Errorf("Expected lockout of %v, got %v", tt.wantLockout, lockout)
+				}
+			}
+		})
+	}
+}
+
+func TestMagicLinkSent(t *testing.T) {
+	cases := map[string]struct {
+		sent       int
+		since      time.Duration
+		wantAllow  bool
+		wantSent   int
+		wantWindow bool
+	}{
+		"Below limit": {
+			sent:      1,
+			since:     10 * time.Minute,
+			wantAllow: true,
+			wantSent:  2,
+		},
+		"Reached limit": {
+			sent:     3,
+			since:    10 * time.Minute,
+			wantSent: 3,
+		},
+		"Window passed": {
+			sent:       3,
+			since:      2 * time.Hour,
+			wantAllow:  true,
+			wantSent:   1,
+			wantWindow: true,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			since := time.Now().Add(-tt.since)
+			user := &gorsk.User{MagicLinks: tt.sent, MagicLinksSince: since}
+
+			if user.MagicLinkSent(3, time.Hour) != tt.wantAllow {
+				t.Errorf("Expected link to be allowed: %v", tt.wantAllow)
+			}
+
+			if user.MagicLinks != tt.wantSent {
+				t.Errorf("Expected %d links sent, got %d", tt.wantSent, user.MagicLinks)
+			}
+
+			if user.MagicLinksSince.After(since) != tt.wantWindow {
+				t.Errorf("Expected new window to be started: %v", tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	user := &gorsk.User{
+		FailedLogins: 5,
+		LockedUntil:  time.Now().Add(time.Hour),
+	}
+
+	user.Unlock()
+	if user.Locked() || user.FailedLogins != 0 {
+		t.Errorf("User was not unlocked")
+	}
+}
+
+func TestMFA(t *testing.T) {
+	user := &gorsk.User{MFASecret: "secret"}
+
+	user.EnableMFA([]string{"first", "second"})
+	if !user.MFAEnabled {
+		t.Errorf("MFA was not enabled")
+	}
+
+	if user.UseRecoveryCode("unknown") {
+		t.Errorf("Unknown recovery code was accepted")
+	}
+
+	if !user.UseRecoveryCode("first") {
+		t.Errorf("Recovery code was not accepted")
+	}
+
+	if user.UseRecoveryCode("first") {
+		t.Errorf("Recovery code was accepted twice")
+	}
+
+	if len(user.RecoveryCodes) != 1 || user.RecoveryCodes[0] != "second" {
+		t.Errorf("Recovery code was not consumed")
+	}
+
+	user.DisableMFA()
+	if user.MFAEnabled || user.MFASecret != "" || user.RecoveryCodes != nil {
+		t.Errorf("MFA was not disabled")
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	user := &gorsk.User{}
+	if user.EmailVerified() {
+		t.Errorf("New user's email reported as verified")
+	}
 
+	user.VerifyEmail()
+	if !user.EmailVerified() || !user.Active {
+		t.Errorf("User's email was not verified")
 	}
 }